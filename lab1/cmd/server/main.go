@@ -4,6 +4,7 @@ import (
 	"NSSaDS/internal/infrastructure/network"
 	"NSSaDS/internal/infrastructure/repository"
 	"NSSaDS/internal/usecase"
+	"NSSaDS/pkg/admin"
 	"NSSaDS/pkg/config"
 	"context"
 	"flag"
@@ -11,19 +12,24 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 )
 
 func main() {
 	var (
-		host = flag.String("host", "localhost", "Server host")
-		port = flag.String("port", "8080", "Server port")
+		host        = flag.String("host", "localhost", "Server host")
+		port        = flag.String("port", "8080", "Server port")
+		maxClients  = flag.Int("max-clients", 0, "Maximum simultaneous clients (0 = unlimited)")
+		adminSocket = flag.String("admin-socket", "", "Unix socket for lab1-admin (disabled when empty)")
+		adminMode   = flag.String("admin-mode", "0600", "Permissions of the admin socket; they decide who may use it")
 	)
 	flag.Parse()
 
 	cfg := config.NewConfig()
 	cfg.Server.Host = *host
 	cfg.Server.Port = *port
+	cfg.Server.MaxClients = *maxClients
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -41,11 +47,27 @@ func main() {
 
 	server := network.NewTCPServer(&cfg.Server, commandHandler, connMgr)
 
+	if *adminSocket != "" {
+		mode, err := strconv.ParseUint(*adminMode, 8, 32)
+		if err != nil {
+			log.Fatalf("Invalid admin socket mode %q: %v", *adminMode, err)
+		}
+		adminServer := admin.NewServer(*adminSocket, os.FileMode(mode))
+		admin.RegisterControl(adminServer, server)
+		if err := adminServer.Start(); err != nil {
+			log.Fatalf("Failed to start admin socket: %v", err)
+		}
+		defer adminServer.Stop()
+		fmt.Printf("Admin socket listening on %s (mode %s)\n", *adminSocket, *adminMode)
+	}
+
+	done := make(chan struct{})
 	go func() {
 		addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
 		if err := server.Start(ctx, addr); err != nil {
 			log.Fatalf("Server error: %v", err)
 		}
+		close(done)
 	}()
 
 	fmt.Printf("TCP Server started on %s:%s\n", cfg.Server.Host, cfg.Server.Port)
@@ -59,12 +81,17 @@ func main() {
 	fmt.Printf("  telnet %s %s\n", cfg.Server.Host, cfg.Server.Port)
 	fmt.Printf("  nc %s %s\n", cfg.Server.Host, cfg.Server.Port)
 
-	<-sigChan
-	fmt.Println("\nShutting down server...")
+	select {
+	case <-sigChan:
+		fmt.Println("\nShutting down server...")
 
-	if err := server.Stop(); err != nil {
-		log.Printf("Error stopping server: %v", err)
-	}
+		if err := server.Stop(); err != nil {
+			log.Printf("Error stopping server: %v", err)
+		}
 
-	fmt.Println("Server stopped")
+		fmt.Println("Server stopped")
+
+	case <-done:
+		fmt.Println("Server drained and stopped")
+	}
 }
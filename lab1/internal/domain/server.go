@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"NSSaDS/pkg/admin"
 	"context"
 	"net"
 )
@@ -35,3 +36,7 @@ type FileManager interface {
 	DeleteTransferSession(sessionID string) error
 	CleanupExpiredSessions() error
 }
+
+type ClientInfo = admin.ClientInfo
+
+type ServerControl = admin.ServerControl
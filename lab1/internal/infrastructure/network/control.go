@@ -0,0 +1,161 @@
+package network
+
+import (
+	"NSSaDS/internal/domain"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrNotRunning    = errors.New("server is not running")
+	ErrUnknownClient = errors.New("no such client")
+)
+
+type trackedConn struct {
+	net.Conn
+	id           string
+	fd           int
+	bytesIn      atomic.Int64
+	bytesOut     atomic.Int64
+	lastActivity atomic.Int64
+	writeMutex   sync.Mutex
+}
+
+func newTrackedConn(conn net.Conn, id string) *trackedConn {
+	tc := &trackedConn{Conn: conn, id: id, fd: -1}
+	tc.lastActivity.Store(time.Now().UnixNano())
+
+	if sc, ok := conn.(interface {
+		SyscallConn() (syscall.RawConn, error)
+	}); ok {
+		if raw, err := sc.SyscallConn(); err == nil {
+			raw.Control(func(fd uintptr) {
+				tc.fd = int(fd)
+			})
+		}
+	}
+	return tc
+}
+
+func (tc *trackedConn) Read(p []byte) (int, error) {
+	n, err := tc.Conn.Read(p)
+	if n > 0 {
+		tc.bytesIn.Add(int64(n))
+		tc.lastActivity.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
+
+func (tc *trackedConn) Write(p []byte) (int, error) {
+	tc.writeMutex.Lock()
+	defer tc.writeMutex.Unlock()
+
+	n, err := tc.Conn.Write(p)
+	if n > 0 {
+		tc.bytesOut.Add(int64(n))
+		tc.lastActivity.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
+
+func (tc *trackedConn) NetConn() net.Conn {
+	return tc.Conn
+}
+
+func (s *TCPServer) acceptClient(conn net.Conn) (*trackedConn, error) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if s.draining {
+		return nil, fmt.Errorf("server is shutting down")
+	}
+	if s.maxClients > 0 && len(s.clients) >= s.maxClients {
+		return nil, fmt.Errorf("server busy")
+	}
+
+	s.nextClientID++
+	tc := newTrackedConn(conn, fmt.Sprintf("client_%d", s.nextClientID))
+	s.clients[tc.id] = tc
+	s.clientsWG.Add(1)
+	return tc, nil
+}
+
+func (s *TCPServer) releaseClient(tc *trackedConn) {
+	s.clientsMutex.Lock()
+	delete(s.clients, tc.id)
+	s.clientsMutex.Unlock()
+	s.clientsWG.Done()
+}
+
+func (s *TCPServer) ListClients() ([]domain.ClientInfo, error) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if s.listener == nil {
+		return nil, ErrNotRunning
+	}
+
+	now := time.Now()
+	clients := make([]domain.ClientInfo, 0, len(s.clients))
+	for _, tc := range s.clients {
+		clients = append(clients, domain.ClientInfo{
+			ID:        tc.id,
+			Addr:      tc.RemoteAddr().String(),
+			FD:        tc.fd,
+			Idle:      now.Sub(time.Unix(0, tc.lastActivity.Load())),
+			ChunkSize: s.config.BufferSize,
+			BytesIn:   tc.bytesIn.Load(),
+			BytesOut:  tc.bytesOut.Load(),
+		})
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+	return clients, nil
+}
+
+func (s *TCPServer) KickClient(id string) error {
+	s.clientsMutex.Lock()
+	tc, ok := s.clients[id]
+	s.clientsMutex.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownClient, id)
+	}
+
+	tc.SetWriteDeadline(time.Now().Add(time.Second))
+	tc.Write([]byte("ERROR: disconnected by administrator\r\n"))
+	fmt.Printf("Client %s (%s) kicked by administrator\n", id, tc.RemoteAddr())
+
+	if tcpConn, ok := tc.Conn.(*net.TCPConn); ok {
+		return tcpConn.CloseRead()
+	}
+	return tc.Close()
+}
+
+func (s *TCPServer) SetMaxClients(n int) (int, error) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	old := s.maxClients
+	s.maxClients = n
+	fmt.Printf("Max clients changed from %d to %d\n", old, n)
+	return old, nil
+}
+
+func (s *TCPServer) Drain() (int, error) {
+	s.clientsMutex.Lock()
+	if s.listener == nil {
+		s.clientsMutex.Unlock()
+		return 0, ErrNotRunning
+	}
+	s.draining = true
+	remaining := len(s.clients)
+	s.clientsMutex.Unlock()
+
+	fmt.Printf("Draining: no longer accepting clients, %d still connected\n", remaining)
+	return remaining, s.listener.Close()
+}
@@ -15,6 +15,10 @@ const (
 )
 
 func setKeepAlive(conn net.Conn, keepAlive bool, keepAliveIdle time.Duration, keepAliveCount int, keepAliveIntvl time.Duration) error {
+	if wrapped, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = wrapped.NetConn()
+	}
+
 	tcpConn, ok := conn.(*net.TCPConn)
 	if !ok {
 		return nil
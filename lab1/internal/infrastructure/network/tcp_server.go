@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,23 +16,35 @@ type TCPServer struct {
 	listener net.Listener
 	handler  domain.CommandHandler
 	connMgr  domain.ConnectionManager
+
+	clients      map[string]*trackedConn
+	clientsMutex sync.Mutex
+	clientsWG    sync.WaitGroup
+	nextClientID int
+	maxClients   int
+	draining     bool
 }
 
 func NewTCPServer(cfg *config.ServerConfig, handler domain.CommandHandler, connMgr domain.ConnectionManager) *TCPServer {
 	return &TCPServer{
-		config:  cfg,
-		handler: handler,
-		connMgr: connMgr,
+		config:     cfg,
+		handler:    handler,
+		connMgr:    connMgr,
+		clients:    make(map[string]*trackedConn),
+		maxClients: cfg.MaxClients,
 	}
 }
 
 func (s *TCPServer) Start(ctx context.Context, addr string) error {
-	var err error
-	s.listener, err = net.Listen("tcp", addr)
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("failed to start server: %w", err)
 	}
 
+	s.clientsMutex.Lock()
+	s.listener = listener
+	s.clientsMutex.Unlock()
+
 	fmt.Printf("Server started on %s\n", addr)
 
 	for {
@@ -39,8 +52,13 @@ func (s *TCPServer) Start(ctx context.Context, addr string) error {
 		case <-ctx.Done():
 			return nil
 		default:
-			conn, err := s.listener.Accept()
+			conn, err := listener.Accept()
 			if err != nil {
+				if s.isDraining() {
+					s.clientsWG.Wait()
+					fmt.Println("Server drained")
+					return nil
+				}
 				select {
 				case <-ctx.Done():
 					return nil
@@ -49,7 +67,19 @@ func (s *TCPServer) Start(ctx context.Context, addr string) error {
 				}
 			}
 
-			go s.connMgr.HandleConnection(ctx, conn)
+			tc, err := s.acceptClient(conn)
+			if err != nil {
+				fmt.Printf("Rejected client %s: %v\n", conn.RemoteAddr(), err)
+				conn.SetWriteDeadline(time.Now().Add(time.Second))
+				conn.Write([]byte(fmt.Sprintf("ERROR: %v\r\n", err)))
+				conn.Close()
+				continue
+			}
+
+			go func() {
+				defer s.releaseClient(tc)
+				s.connMgr.HandleConnection(ctx, tc)
+			}()
 		}
 	}
 }
@@ -61,6 +91,12 @@ func (s *TCPServer) Stop() error {
 	return nil
 }
 
+func (s *TCPServer) isDraining() bool {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	return s.draining
+}
+
 func (s *TCPServer) SetHandler(handler domain.CommandHandler) {
 	s.handler = handler
 }
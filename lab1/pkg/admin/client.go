@@ -0,0 +1,64 @@
+package admin
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+func Call(socket, command string, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", socket, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", socket, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := fmt.Fprintf(conn, "%s\n", command); err != nil {
+		return nil, fmt.Errorf("failed to send command: %w", err)
+	}
+
+	response, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return response, nil
+}
+
+// RunClient is the main function of the labN-admin tools. commands lists the
+// usage lines shown by -h, the server's own help may list more.
+func RunClient(program string, commands []string) {
+	socket := flag.String("socket", program+".sock", "Admin socket of the server (-admin-socket)")
+	timeout := flag.Duration("timeout", 10*time.Second, "How long to wait for the server")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-socket path] <command> [args]\n", program)
+		fmt.Fprintln(os.Stderr, "Commands:")
+		for _, line := range commands {
+			fmt.Fprintln(os.Stderr, "  "+line)
+		}
+		fmt.Fprintln(os.Stderr, "  help                  - Commands supported by the server")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	response, err := Call(*socket, strings.Join(flag.Args(), " "), *timeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Stdout.Write(response)
+
+	if bytes.HasPrefix(response, []byte("ERROR:")) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type ClientInfo struct {
+	ID        string
+	Addr      string
+	FD        int
+	Idle      time.Duration
+	ChunkSize int
+	BytesIn   int64
+	BytesOut  int64
+}
+
+type ServerControl interface {
+	ListClients() ([]ClientInfo, error)
+	KickClient(id string) error
+	SetMaxClients(n int) (int, error)
+	Drain() (int, error)
+}
+
+func RegisterControl(s *Server, control ServerControl) {
+	s.Handle("list-clients", "list-clients          - ID, address, fd, idle time, chunk size and bytes in/out of every client",
+		func(args []string) (string, error) {
+			clients, err := control.ListClients()
+			if err != nil {
+				return "", err
+			}
+			return FormatClients(clients), nil
+		})
+
+	s.Handle("kick-client", "kick-client <id>      - Disconnect a client",
+		func(args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("usage: kick-client <id>")
+			}
+			if err := control.KickClient(args[0]); err != nil {
+				return "", err
+			}
+			return "kicked " + args[0], nil
+		})
+
+	s.Handle("set-max-clients", "set-max-clients <n>   - Change the client limit, existing clients stay connected",
+		func(args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("usage: set-max-clients <n>")
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 0 {
+				return "", fmt.Errorf("invalid client limit %q", args[0])
+			}
+			old, err := control.SetMaxClients(n)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("max clients %d -> %d", old, n), nil
+		})
+
+	s.Handle("drain", "drain                 - Stop accepting clients and shut down when the last one leaves",
+		func(args []string) (string, error) {
+			remaining, err := control.Drain()
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("draining, %d clients still connected", remaining), nil
+		})
+}
+
+func FormatClients(clients []ClientInfo) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tADDRESS\tFD\tIDLE\tCHUNK\tBYTES IN\tBYTES OUT")
+	for _, c := range clients {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%v\t%d\t%d\t%d\n", c.ID, c.Addr, c.FD, c.Idle.Round(time.Second), c.ChunkSize, c.BytesIn, c.BytesOut)
+	}
+	w.Flush()
+	fmt.Fprintf(&b, "%d clients", len(clients))
+	return b.String()
+}
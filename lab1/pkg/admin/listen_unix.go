@@ -0,0 +1,38 @@
+//go:build !windows
+
+package admin
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// listenUnix binds the socket inside a private 0700 directory and renames it
+// into place once it has its final mode, so it is never reachable with looser
+// permissions. The umask is left alone: it is process-wide and would also
+// apply to files other goroutines create meanwhile.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".admin-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	listener.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmp, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
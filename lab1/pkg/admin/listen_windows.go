@@ -0,0 +1,10 @@
+package admin
+
+import (
+	"net"
+	"os"
+)
+
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
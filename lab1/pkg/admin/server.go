@@ -0,0 +1,135 @@
+package admin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown admin command")
+	ErrNotSocket      = errors.New("path exists and is not a socket")
+)
+
+const requestTimeout = 10 * time.Second
+
+type Handler func(args []string) (string, error)
+
+type Server struct {
+	path     string
+	mode     os.FileMode
+	listener net.Listener
+	handlers map[string]Handler
+	usage    map[string]string
+	wg       sync.WaitGroup
+}
+
+func NewServer(path string, mode os.FileMode) *Server {
+	s := &Server{
+		path:     path,
+		mode:     mode,
+		handlers: make(map[string]Handler),
+		usage:    make(map[string]string),
+	}
+	s.Handle("help", "help                  - List admin commands", s.help)
+	return s
+}
+
+func (s *Server) Handle(name, usage string, handler Handler) {
+	s.handlers[name] = handler
+	s.usage[name] = usage
+}
+
+func (s *Server) Start() error {
+	if info, err := os.Lstat(s.path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s: %w", s.path, ErrNotSocket)
+		}
+		os.Remove(s.path)
+	}
+
+	listener, err := listenUnix(s.path, s.mode)
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin socket %s: %w", s.path, err)
+	}
+	s.listener = listener
+
+	s.wg.Add(1)
+	go s.serve()
+	return nil
+}
+
+func (s *Server) Stop() error {
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	s.wg.Wait()
+	os.Remove(s.path)
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+
+	response, err := s.Execute(line)
+	if err != nil {
+		response = fmt.Sprintf("ERROR: %v", err)
+	}
+	fmt.Fprintf(conn, "%s\n", strings.TrimRight(response, "\n"))
+}
+
+func (s *Server) Execute(line string) (string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%w: empty request", ErrUnknownCommand)
+	}
+
+	handler, exists := s.handlers[strings.ToLower(fields[0])]
+	if !exists {
+		return "", fmt.Errorf("%w %q, try help", ErrUnknownCommand, fields[0])
+	}
+	return handler(fields[1:])
+}
+
+func (s *Server) help(args []string) (string, error) {
+	names := make([]string, 0, len(s.usage))
+	for name := range s.usage {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = s.usage[name]
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,66 @@
+//go:build !windows
+
+package admin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerSocketModeAndRequests(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "admin.sock")
+
+	s := NewServer(path, 0o600)
+	s.Handle("ping", "ping - Answer pong", func(args []string) (string, error) {
+		return "pong " + strings.Join(args, " "), nil
+	})
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer s.Stop()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o600 {
+		t.Fatalf("socket mode %v, want socket with 0600", info.Mode())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("directory holds %d entries, want only the socket", len(entries))
+	}
+
+	response, err := Call(path, "ping a b", time.Second)
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if string(response) != "pong a b\n" {
+		t.Fatalf("response %q", response)
+	}
+
+	response, err = Call(path, "nope", time.Second)
+	if err != nil || !strings.HasPrefix(string(response), "ERROR: unknown admin command") {
+		t.Fatalf("unknown command answered %q, %v", response, err)
+	}
+
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket left behind after Stop: %v", err)
+	}
+}
+
+func TestStartRefusesToReplaceRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewServer(path, 0o600).Start(); err == nil {
+		t.Fatalf("Start replaced a regular file")
+	}
+}
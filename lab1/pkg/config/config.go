@@ -19,6 +19,7 @@ type ServerConfig struct {
 	BufferSize     int           `json:"buffer_size"`
 	UploadDir      string        `json:"upload_dir"`
 	SessionTimeout time.Duration `json:"session_timeout"`
+	MaxClients     int           `json:"max_clients"`
 }
 
 type ClientConfig struct {
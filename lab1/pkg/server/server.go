@@ -0,0 +1,37 @@
+// Package server assembles the lab1 TCP file server for programs outside this
+// module, such as the lab2 benchmark, that cannot import its internal packages.
+package server
+
+import (
+	"NSSaDS/internal/infrastructure/network"
+	"NSSaDS/internal/infrastructure/repository"
+	"NSSaDS/internal/usecase"
+	"NSSaDS/pkg/config"
+)
+
+// Server is a TCP server with the standard command set that stores files in
+// cfg.UploadDir. Start and Stop come from the embedded TCPServer.
+type Server struct {
+	*network.TCPServer
+	fileMgr *repository.FileManager
+}
+
+func New(cfg *config.ServerConfig) *Server {
+	fileMgr := repository.NewFileManager(cfg.UploadDir)
+	handler := usecase.NewCommandHandler()
+
+	connMgr := network.NewTCPConnectionManager(cfg, fileMgr)
+	connMgr.SetCommandHandler(handler)
+
+	return &Server{
+		TCPServer: network.NewTCPServer(cfg, handler, connMgr),
+		fileMgr:   fileMgr,
+	}
+}
+
+// Close stops the server and its file manager.
+func (s *Server) Close() error {
+	err := s.Stop()
+	s.fileMgr.Close()
+	return err
+}
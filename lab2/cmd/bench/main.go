@@ -0,0 +1,390 @@
+package main
+
+import (
+	"NSSaDS/lab2/pkg/config"
+	"NSSaDS/lab2/pkg/netem"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type benchResult struct {
+	Protocol    string  `json:"protocol"`
+	Direction   string  `json:"direction"`
+	FileSize    int64   `json:"file_size"`
+	Runs        int     `json:"runs"`
+	Failures    int     `json:"failures"`
+	Goodput     float64 `json:"goodput_mbps"`
+	MinGoodput  float64 `json:"min_goodput_mbps"`
+	MaxGoodput  float64 `json:"max_goodput_mbps"`
+	CPUTime     float64 `json:"cpu_ms"`
+	Retransmits float64 `json:"retransmits"`
+	LastError   string  `json:"last_error,omitempty"`
+}
+
+type benchReport struct {
+	Proxy   *netem.Config  `json:"proxy,omitempty"`
+	Latency map[string]any `json:"latency_ms"`
+	Results []benchResult  `json:"results"`
+}
+
+type transferFunc func(name, source, localPath string) transferStats
+
+type transferStats struct {
+	elapsed     time.Duration
+	cpu         time.Duration
+	retransmits uint64
+	received    string
+	err         error
+}
+
+func main() {
+	var (
+		sizes     = flag.String("sizes", "1048576,16777216", "Comma separated test file sizes in bytes")
+		runs      = flag.Int("runs", 3, "Runs per protocol, direction and size")
+		pings     = flag.Int("pings", 20, "ECHO round trips used to measure latency")
+		payload   = flag.Int("payload", 0, "UDP payload size (0 uses the client default)")
+		batch     = flag.Int("batch", 0, "UDP sendmmsg/recvmmsg batch size (0 uses the config default, 1 disables batching)")
+		pps       = flag.Int("pps", 0, "Only measure raw datagrams per second with and without batching, sending this many datagrams")
+		useProxy  = flag.Bool("proxy", false, "Route both protocols through the impairment proxy")
+		loss      = flag.Float64("loss", 0, "Proxy loss probability (0..1)")
+		delay     = flag.Duration("delay", 0, "Proxy one-way delay")
+		jitter    = flag.Duration("jitter", 0, "Proxy delay jitter (+/-)")
+		bandwidth = flag.Int64("bandwidth", 0, "Proxy bandwidth limit in bytes per second (0 = unlimited)")
+		seed      = flag.Uint64("seed", 1, "Proxy random seed")
+		jsonOut   = flag.Bool("json", false, "Print results as JSON")
+		verbose   = flag.Bool("v", false, "Show server and client logs")
+	)
+	flag.Parse()
+
+	fileSizes, err := parseSizes(*sizes)
+	if err != nil {
+		log.Fatalf("Invalid -sizes: %v", err)
+	}
+
+	workDir, err := os.MkdirTemp("", "nssads_bench_*")
+	if err != nil {
+		log.Fatalf("Failed to create work directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	out := os.Stdout
+	if !*verbose {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", os.DevNull, err)
+		}
+		defer devNull.Close()
+
+		os.Stdout = devNull
+		defer func() { os.Stdout = out }()
+	}
+
+	if *pps > 0 {
+		runPPS(out, *pps, *payload, *batch, *jsonOut)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var proxyCfg *netem.Config
+	if *useProxy || *loss > 0 || *delay > 0 || *jitter > 0 || *bandwidth > 0 {
+		proxyCfg = &netem.Config{
+			Loss:      *loss,
+			Delay:     *delay,
+			Jitter:    *jitter,
+			Bandwidth: *bandwidth,
+			Seed:      *seed,
+		}
+	}
+
+	tcp, err := startTCPBench(ctx, filepath.Join(workDir, "tcp"), proxyCfg)
+	if err != nil {
+		log.Fatalf("Failed to start TCP server: %v", err)
+	}
+	defer tcp.stop()
+
+	udp, err := startUDPBench(ctx, filepath.Join(workDir, "udp"), proxyCfg, *payload, *batch)
+	if err != nil {
+		log.Fatalf("Failed to start UDP server: %v", err)
+	}
+	defer udp.stop()
+
+	report := benchReport{
+		Proxy:   proxyCfg,
+		Latency: make(map[string]any),
+	}
+
+	fmt.Fprintf(out, "Measuring latency with %d ECHO round trips...\n", *pings)
+	for _, b := range []struct {
+		name string
+		ping func(int) ([]time.Duration, error)
+	}{{"TCP", tcp.ping}, {"UDP", udp.ping}} {
+		samples, err := b.ping(*pings)
+		if err != nil {
+			fmt.Fprintf(out, "  %s latency failed: %v\n", b.name, err)
+			continue
+		}
+		median, p95 := percentile(samples, 0.5), percentile(samples, 0.95)
+		report.Latency[b.name] = map[string]float64{"median": ms(median), "p95": ms(p95)}
+		fmt.Fprintf(out, "  %s: median %.3f ms, p95 %.3f ms\n", b.name, ms(median), ms(p95))
+	}
+
+	for _, size := range fileSizes {
+		name := fmt.Sprintf("bench_%d.bin", size)
+		source := filepath.Join(workDir, name)
+		if err := writeTestFile(source, size); err != nil {
+			log.Fatalf("Failed to create test file: %v", err)
+		}
+
+		if err := copyFile(source, filepath.Join(tcp.dir, name)); err != nil {
+			log.Fatalf("Failed to stage TCP download: %v", err)
+		}
+		if err := copyFile(source, filepath.Join(udp.dir, name)); err != nil {
+			log.Fatalf("Failed to stage UDP download: %v", err)
+		}
+
+		cases := []struct {
+			protocol  string
+			direction string
+			run       transferFunc
+		}{
+			{"TCP", "upload", tcp.upload},
+			{"UDP", "upload", udp.upload},
+			{"TCP", "download", tcp.download},
+			{"UDP", "download", udp.download},
+		}
+
+		for _, c := range cases {
+			fmt.Fprintf(out, "Running %s %s of %d bytes (%d runs)...\n", c.protocol, c.direction, size, *runs)
+			result := runCase(c.run, name, source, workDir, size, *runs)
+			result.Protocol = c.protocol
+			result.Direction = c.direction
+			report.Results = append(report.Results, result)
+		}
+	}
+
+	if *jsonOut {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode results: %v", err)
+		}
+		fmt.Fprintln(out, string(data))
+		return
+	}
+
+	printReport(out, report)
+}
+
+func runPPS(out *os.File, count, payload, batch int, jsonOut bool) {
+	defaults := config.NewConfig().UDP
+	if payload <= 0 {
+		payload = defaults.BufferSizes[len(defaults.BufferSizes)/2]
+	}
+	if batch <= 0 {
+		batch = defaults.BatchSize
+	}
+
+	var results []ppsResult
+	for _, size := range []int{1, batch} {
+		fmt.Fprintf(out, "Sending %d datagrams of %d bytes with batch size %d...\n", count, payload, size)
+		results = append(results, measurePPS(size, payload, count))
+	}
+
+	if jsonOut {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode results: %v", err)
+		}
+		fmt.Fprintln(out, string(data))
+		return
+	}
+
+	fmt.Fprintln(out)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Batch\tPayload\tSent\tReceived\tSend pps\tRecv pps\t")
+	for _, r := range results {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%.0f\t%.0f\t\n", r.BatchSize, r.Payload, r.Sent, r.Received, r.SendRate, r.RecvRate)
+	}
+	w.Flush()
+
+	if results[0].SendRate > 0 && results[0].RecvRate > 0 {
+		fmt.Fprintf(out, "\nBatching: send %.2fx, receive %.2fx\n",
+			results[1].SendRate/results[0].SendRate, results[1].RecvRate/results[0].RecvRate)
+	}
+
+	for _, r := range results {
+		if r.LastError != "" {
+			fmt.Fprintf(out, "\nLast error with batch size %d: %s\n", r.BatchSize, r.LastError)
+		}
+	}
+}
+
+func runCase(run transferFunc, name, source, workDir string, size int64, runs int) benchResult {
+	result := benchResult{FileSize: size, Runs: runs}
+	var goodputs []float64
+	var cpu time.Duration
+	var retransmits uint64
+
+	for i := 0; i < runs; i++ {
+		localPath := filepath.Join(workDir, "received_"+name)
+		os.Remove(localPath)
+
+		stats := run(name, source, localPath)
+		if stats.err == nil {
+			stats.err = verifyTransfer(source, stats.received)
+		}
+
+		cpu += stats.cpu
+		retransmits += stats.retransmits
+
+		if stats.err != nil {
+			result.Failures++
+			result.LastError = stats.err.Error()
+			continue
+		}
+
+		goodputs = append(goodputs, float64(size)/stats.elapsed.Seconds()/1024/1024)
+	}
+
+	result.CPUTime = ms(cpu) / float64(runs)
+	result.Retransmits = float64(retransmits) / float64(runs)
+
+	if len(goodputs) > 0 {
+		result.MinGoodput, result.MaxGoodput = goodputs[0], goodputs[0]
+		var sum float64
+		for _, goodput := range goodputs {
+			sum += goodput
+			result.MinGoodput = min(result.MinGoodput, goodput)
+			result.MaxGoodput = max(result.MaxGoodput, goodput)
+		}
+		result.Goodput = sum / float64(len(goodputs))
+	}
+
+	return result
+}
+
+func printReport(out *os.File, report benchReport) {
+	if report.Proxy != nil {
+		fmt.Fprintf(out, "\nThrough impairment proxy: loss=%.3f delay=%v jitter=%v bandwidth=%d B/s seed=%d\n",
+			report.Proxy.Loss, report.Proxy.Delay, report.Proxy.Jitter, report.Proxy.Bandwidth, report.Proxy.Seed)
+	}
+
+	fmt.Fprintln(out)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Size\tDirection\tProto\tGoodput MB/s\tMin\tMax\tCPU ms\tRetransmits\tFailed\t")
+	for _, r := range report.Results {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%.2f\t%.2f\t%.2f\t%.1f\t%.1f\t%d/%d\t\n",
+			r.FileSize, r.Direction, r.Protocol, r.Goodput, r.MinGoodput, r.MaxGoodput,
+			r.CPUTime, r.Retransmits, r.Failures, r.Runs)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out, "\nUDP vs TCP goodput:")
+	for i := 0; i+1 < len(report.Results); i += 2 {
+		tcp, udp := report.Results[i], report.Results[i+1]
+		if tcp.Goodput == 0 || udp.Goodput == 0 {
+			fmt.Fprintf(out, "  %d bytes %s: no successful runs to compare\n", tcp.FileSize, tcp.Direction)
+			continue
+		}
+
+		ratio := udp.Goodput / tcp.Goodput
+		verdict := "does not meet"
+		if ratio >= 1.5 {
+			verdict = "meets"
+		}
+		fmt.Fprintf(out, "  %d bytes %s: UDP is %.2fx TCP (%s 1.5x requirement)\n", tcp.FileSize, tcp.Direction, ratio, verdict)
+	}
+
+	for _, r := range report.Results {
+		if r.LastError != "" {
+			fmt.Fprintf(out, "\nLast error for %s %s of %d bytes: %s\n", r.Protocol, r.Direction, r.FileSize, r.LastError)
+		}
+	}
+}
+
+func parseSizes(value string) ([]int64, error) {
+	var sizes []int64
+	for _, field := range strings.Split(value, ",") {
+		size, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid size %q", field)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+func writeTestFile(path string, size int64) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rng := rand.NewChaCha8([32]byte{byte(size), byte(size >> 8), byte(size >> 16)})
+	buffer := make([]byte, 64*1024)
+
+	for written := int64(0); written < size; {
+		n := int(min(int64(len(buffer)), size-written))
+		rng.Read(buffer[:n])
+		if _, err := file.Write(buffer[:n]); err != nil {
+			return err
+		}
+		written += int64(n)
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+func verifyTransfer(source, received string) error {
+	data, err := os.ReadFile(received)
+	if err != nil {
+		return err
+	}
+
+	expected, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	if string(data) != string(expected) {
+		return fmt.Errorf("received file differs from source (%d of %d bytes)", len(data), len(expected))
+	}
+	return nil
+}
+
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted[min(int(float64(len(sorted))*p), len(sorted)-1)]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
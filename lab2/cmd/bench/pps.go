@@ -0,0 +1,117 @@
+package main
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"NSSaDS/lab2/internal/infrastructure/network"
+	"errors"
+	"net"
+	"time"
+)
+
+type ppsResult struct {
+	BatchSize int     `json:"batch_size"`
+	Payload   int     `json:"payload_size"`
+	Sent      int     `json:"sent"`
+	Received  int     `json:"received"`
+	SendRate  float64 `json:"send_pps"`
+	RecvRate  float64 `json:"recv_pps"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+func measurePPS(batchSize, payloadSize, count int) ppsResult {
+	result := ppsResult{BatchSize: batchSize, Payload: payloadSize}
+
+	local := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	receiver, err := net.ListenUDP("udp", local)
+	if err != nil {
+		result.LastError = err.Error()
+		return result
+	}
+	defer receiver.Close()
+	receiver.SetReadBuffer(8 * 1024 * 1024)
+
+	sender, err := net.ListenUDP("udp", local)
+	if err != nil {
+		result.LastError = err.Error()
+		return result
+	}
+	defer sender.Close()
+	sender.SetWriteBuffer(8 * 1024 * 1024)
+
+	packet := domain.NewPacket(domain.PacketTypeData, 0, make([]byte, payloadSize))
+	datagram := packet.Serialize()
+	target := receiver.LocalAddr().(*net.UDPAddr)
+
+	received := make(chan ppsResult, 1)
+	senderDone := make(chan struct{})
+	go func() {
+		received <- receivePPS(receiver, network.NewTransport(receiver, batchSize), count, senderDone)
+	}()
+
+	transport := network.NewTransport(sender, batchSize)
+	batch := make([]network.Datagram, transport.BatchSize())
+	for i := range batch {
+		batch[i] = network.Datagram{Buf: datagram, N: len(datagram), Addr: target}
+	}
+
+	start := time.Now()
+	for result.Sent < count {
+		n, err := transport.WriteBatch(batch[:min(len(batch), count-result.Sent)])
+		result.Sent += n
+		if err != nil {
+			result.LastError = err.Error()
+			break
+		}
+	}
+	result.SendRate = float64(result.Sent) / time.Since(start).Seconds()
+	close(senderDone)
+
+	recv := <-received
+	result.Received = recv.Received
+	result.RecvRate = recv.RecvRate
+	if result.LastError == "" {
+		result.LastError = recv.LastError
+	}
+
+	return result
+}
+
+func receivePPS(conn *net.UDPConn, transport network.Transport, count int, senderDone <-chan struct{}) ppsResult {
+	var result ppsResult
+
+	batch := make([]network.Datagram, transport.BatchSize())
+	for i := range batch {
+		batch[i].Buf = make([]byte, domain.MaxPayloadSize+domain.PacketHeaderSize)
+	}
+
+	var first, last time.Time
+
+	for result.Received < count {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := transport.ReadBatch(batch)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				select {
+				case <-senderDone:
+					count = result.Received
+				default:
+				}
+				continue
+			}
+			result.LastError = err.Error()
+			break
+		}
+
+		if first.IsZero() {
+			first = time.Now()
+		}
+		last = time.Now()
+		result.Received += n
+	}
+
+	if elapsed := last.Sub(first).Seconds(); elapsed > 0 {
+		result.RecvRate = float64(result.Received) / elapsed
+	}
+	return result
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"NSSaDS/lab2/pkg/netem"
+	tcpconfig "NSSaDS/pkg/config"
+	tcpserver "NSSaDS/pkg/server"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type tcpBench struct {
+	dir     string
+	addr    string
+	timeout time.Duration
+	server  *tcpserver.Server
+	proxy   *netem.TCPProxy
+	cancel  context.CancelFunc
+}
+
+func startTCPBench(ctx context.Context, dir string, proxyCfg *netem.Config) (*tcpBench, error) {
+	cfg := tcpconfig.NewConfig()
+	cfg.Server.UploadDir = dir
+
+	addr, err := freeAddr("tcp")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	b := &tcpBench{
+		dir:     dir,
+		addr:    addr,
+		timeout: cfg.Client.Timeout,
+		server:  tcpserver.New(&cfg.Server),
+		cancel:  cancel,
+	}
+
+	go b.server.Start(ctx, addr)
+
+	if err := waitForTCP(addr, 5*time.Second); err != nil {
+		b.stop()
+		return nil, err
+	}
+
+	if proxyCfg != nil {
+		b.proxy = netem.NewTCPProxy(*proxyCfg)
+		if err := b.proxy.Start("127.0.0.1:0", addr); err != nil {
+			b.stop()
+			return nil, fmt.Errorf("failed to start TCP proxy: %w", err)
+		}
+		b.addr = b.proxy.Addr().String()
+	}
+
+	return b, nil
+}
+
+func (b *tcpBench) stop() {
+	b.cancel()
+	if b.proxy != nil {
+		b.proxy.Stop()
+	}
+	b.server.Close()
+}
+
+func (b *tcpBench) dial() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", b.addr, b.timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Minute))
+	return conn, bufio.NewReader(conn), nil
+}
+
+func (b *tcpBench) ping(count int) ([]time.Duration, error) {
+	conn, reader, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	samples := make([]time.Duration, 0, count)
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		if _, err := fmt.Fprintf(conn, "ECHO ping %d\r\n", i); err != nil {
+			return nil, err
+		}
+		if _, err := readLine(reader); err != nil {
+			return nil, err
+		}
+		samples = append(samples, time.Since(start))
+	}
+
+	return samples, nil
+}
+
+func (b *tcpBench) upload(name, source, _ string) transferStats {
+	remote := "up_" + name
+	os.Remove(filepath.Join(b.dir, remote))
+
+	stats := b.measure(func() error {
+		file, err := os.Open(source)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		conn, reader, err := b.dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		if _, err := fmt.Fprintf(conn, "UPLOAD %s\r\n", remote); err != nil {
+			return err
+		}
+
+		line, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "READY_TO_RECEIVE") {
+			return fmt.Errorf("server not ready: %s", line)
+		}
+
+		if _, err := io.Copy(conn, file); err != nil {
+			return err
+		}
+
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			tcpConn.CloseWrite()
+		}
+
+		line, err = readLine(reader)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "File uploaded") {
+			return fmt.Errorf("upload not confirmed: %s", line)
+		}
+		return nil
+	})
+
+	stats.received = filepath.Join(b.dir, remote)
+	return stats
+}
+
+func (b *tcpBench) download(name, _, localPath string) transferStats {
+	stats := b.measure(func() error {
+		conn, reader, err := b.dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		if _, err := fmt.Fprintf(conn, "DOWNLOAD %s\r\n", name); err != nil {
+			return err
+		}
+
+		line, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) < 3 || parts[0] != "FILE_INFO" {
+			return fmt.Errorf("unexpected response: %s", line)
+		}
+
+		size, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid file size: %s", parts[2])
+		}
+
+		file, err := os.Create(localPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if _, err := io.CopyN(file, reader, size); err != nil {
+			return err
+		}
+
+		_, err = readLine(reader)
+		return err
+	})
+
+	stats.received = localPath
+	return stats
+}
+
+func (b *tcpBench) measure(transfer func() error) transferStats {
+	var droppedBefore uint64
+	if b.proxy != nil {
+		droppedBefore = b.proxy.Stats().Dropped
+	}
+	retransBefore := tcpRetransmits()
+	cpuBefore := cpuTime()
+	start := time.Now()
+
+	err := transfer()
+
+	stats := transferStats{
+		elapsed:     time.Since(start),
+		cpu:         cpuTime() - cpuBefore,
+		retransmits: tcpRetransmits() - retransBefore,
+		err:         err,
+	}
+
+	if b.proxy != nil {
+		stats.retransmits += b.proxy.Stats().Dropped - droppedBefore
+	}
+
+	return stats
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func tcpRetransmits() uint64 {
+	data, err := os.ReadFile("/proc/net/snmp")
+	if err != nil {
+		return 0
+	}
+
+	var header []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Tcp:") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if header == nil {
+			header = fields
+			continue
+		}
+
+		for i, name := range header {
+			if name == "RetransSegs" && i < len(fields) {
+				value, _ := strconv.ParseUint(fields[i], 10, 64)
+				return value
+			}
+		}
+	}
+
+	return 0
+}
+
+func waitForTCP(addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("server on %s not ready: %w", addr, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func freeAddr(network string) (string, error) {
+	switch network {
+	case "udp":
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		return conn.LocalAddr().String(), nil
+	default:
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		defer listener.Close()
+		return listener.Addr().String(), nil
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"NSSaDS/lab2/internal/infrastructure/network"
+	"NSSaDS/lab2/internal/infrastructure/repository"
+	"NSSaDS/lab2/internal/usecase"
+	"NSSaDS/lab2/pkg/config"
+	"NSSaDS/lab2/pkg/netem"
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+type udpBench struct {
+	dir     string
+	addr    string
+	cfg     *config.Config
+	server  *network.UDPServer
+	fileMgr *repository.FileManager
+	proxy   *netem.UDPProxy
+	cancel  context.CancelFunc
+}
+
+func startUDPBench(ctx context.Context, dir string, proxyCfg *netem.Config, payloadSize, batchSize int) (*udpBench, error) {
+	cfg := config.NewConfig()
+	cfg.Server.UploadDir = dir
+	if payloadSize > 0 {
+		cfg.UDP.BufferSizes = []int{payloadSize}
+	}
+	if batchSize > 0 {
+		cfg.UDP.BatchSize = batchSize
+	}
+
+	addr, err := freeAddr("udp")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	b := &udpBench{
+		dir:     dir,
+		addr:    addr,
+		cfg:     cfg,
+		fileMgr: repository.NewFileManager(dir),
+		cancel:  cancel,
+	}
+
+	b.server = network.NewUDPServer(&cfg.Server, &cfg.UDP, usecase.NewCommandHandler(), b.fileMgr)
+	go b.server.Start(ctx, addr)
+
+	if proxyCfg != nil {
+		b.proxy = netem.NewUDPProxy(*proxyCfg)
+		if err := b.proxy.Start("127.0.0.1:0", addr); err != nil {
+			b.stop()
+			return nil, fmt.Errorf("failed to start UDP proxy: %w", err)
+		}
+		b.addr = b.proxy.Addr().String()
+	}
+
+	if err := b.waitReady(5 * time.Second); err != nil {
+		b.stop()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *udpBench) stop() {
+	b.cancel()
+	if b.proxy != nil {
+		b.proxy.Stop()
+	}
+	b.server.Stop()
+	b.fileMgr.Close()
+}
+
+func (b *udpBench) connect() (*network.UDPClient, error) {
+	return b.connectWith(&b.cfg.Client)
+}
+
+func (b *udpBench) connectWith(clientCfg *config.ClientConfig) (*network.UDPClient, error) {
+	client := network.NewUDPClient(clientCfg, &b.cfg.UDP, nil)
+	if err := client.Connect(context.Background(), b.addr); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (b *udpBench) waitReady(timeout time.Duration) error {
+	clientCfg := b.cfg.Client
+	clientCfg.Timeout = 200 * time.Millisecond
+
+	client, err := b.connectWith(&clientCfg)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := client.SendCommand("ECHO", []string{"ready"})
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("server on %s not ready: %w", b.addr, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (b *udpBench) ping(count int) ([]time.Duration, error) {
+	client, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Disconnect()
+
+	samples := make([]time.Duration, 0, count)
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		if _, err := client.SendCommand("ECHO", []string{"ping", fmt.Sprint(i)}); err != nil {
+			return nil, err
+		}
+		samples = append(samples, time.Since(start))
+	}
+
+	return samples, nil
+}
+
+func (b *udpBench) upload(name, source, _ string) transferStats {
+	remote := "up_" + name
+
+	stats := b.measure(func(client *network.UDPClient) (uint32, error) {
+		progress, err := client.UploadFile(source, remote)
+		if err != nil {
+			return 0, err
+		}
+		return progress.Retransmits, nil
+	})
+
+	stats.received = filepath.Join(b.dir, remote)
+	return stats
+}
+
+func (b *udpBench) download(name, _, localPath string) transferStats {
+	stats := b.measure(func(client *network.UDPClient) (uint32, error) {
+		progress, err := client.DownloadFile(name, localPath)
+		if err != nil {
+			return 0, err
+		}
+		return progress.Retransmits, nil
+	})
+
+	stats.received = localPath
+	return stats
+}
+
+func (b *udpBench) measure(transfer func(*network.UDPClient) (uint32, error)) transferStats {
+	client, err := b.connect()
+	if err != nil {
+		return transferStats{err: err}
+	}
+	defer client.Disconnect()
+
+	cpuBefore := cpuTime()
+	start := time.Now()
+
+	retransmits, err := transfer(client)
+
+	return transferStats{
+		elapsed:     time.Since(start),
+		cpu:         cpuTime() - cpuBefore,
+		retransmits: uint64(retransmits),
+		err:         err,
+	}
+}
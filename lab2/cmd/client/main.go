@@ -6,6 +6,7 @@ import (
 	"NSSaDS/lab2/pkg/config"
 	"bufio"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -13,18 +14,51 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 )
 
 func main() {
 	var (
-		host = flag.String("host", "localhost", "Server host")
-		port = flag.String("port", "8080", "Server port")
-		test = flag.Bool("test", false, "Run performance comparison tests")
+		host      = flag.String("host", "localhost", "Server host")
+		port      = flag.String("port", "8080", "Server port")
+		test      = flag.Bool("test", false, "Run performance comparison tests")
+		fecBlock  = flag.Int("fec-block", 0, "Data packets per FEC block (0 disables FEC)")
+		fecParity = flag.Int("fec-parity", 1, "Parity packets per FEC block")
+		tuneSize  = flag.Int64("tune-size", 4*1024*1024, "Test file size in bytes for buffer tuning")
+		repeats   = flag.Int("tune-repeats", 3, "Runs per buffer size during tuning")
+		tuneRange = flag.Bool("tune-range", false, "Tune across MinBufferSize..MaxBufferSize by BufferStep instead of BufferSizes")
+		jsonOut   = flag.Bool("json", false, "Print tuning results as JSON")
+		psk       = flag.String("psk", os.Getenv("LAB2_PSK"), "Pre-shared key for secure mode (default $LAB2_PSK, empty disables)")
+		cipher    = flag.String("cipher", network.CipherAESGCM, "Secure mode cipher: aes-gcm or chacha20-poly1305")
+		heartbeat = flag.Duration("heartbeat", 500*time.Millisecond, "Heartbeat interval while waiting on the server (0 disables)")
+		misses    = flag.Int("heartbeat-misses", 4, "Unanswered heartbeats before the server is considered dead")
+		reconnect = flag.Bool("reconnect", false, "Reconnect and resume transfers when the server stops responding")
+		attempts  = flag.Int("reconnect-attempts", 3, "Reconnect attempts per transfer")
+		trace     = flag.String("trace", "", "Record every sent and received packet to this trace file (see cmd/trace)")
+		snapLen   = flag.Int("trace-snaplen", 0, "Bytes captured per traced packet (0 captures whole packets)")
+		pacing    = flag.Bool("pacing", true, "Pace DATA packets at window/RTT instead of sending back to back")
+		paceRate  = flag.Int("pace-rate", 0, "Pacing rate cap in bytes per second (0 = window/RTT only)")
+		paceBurst = flag.Int("pace-burst", 4, "DATA packets sent back to back per pacing interval")
 	)
 	flag.Parse()
 
 	cfg := config.NewConfig()
+	cfg.UDP.PSK = *psk
+	cfg.UDP.Cipher = *cipher
+	cfg.UDP.HeartbeatInterval = *heartbeat
+	cfg.UDP.HeartbeatMisses = *misses
+	cfg.UDP.TracePath = *trace
+	cfg.UDP.TraceSnapLen = *snapLen
+	cfg.UDP.Pacing = *pacing
+	cfg.UDP.PacingRate = *paceRate
+	cfg.UDP.PacingBurst = *paceBurst
+	cfg.UDP.AutoReconnect = *reconnect
+	cfg.UDP.ReconnectAttempts = *attempts
+	cfg.UDP.FECBlockSize = *fecBlock
+	if *fecBlock > 0 {
+		cfg.UDP.FECParity = *fecParity
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -54,8 +88,15 @@ func main() {
 	fmt.Println("  TEST                  - Run performance tests")
 	fmt.Println("  HELP                  - Show this help")
 
+	tuning := tuneOptions{
+		fileSize: *tuneSize,
+		repeats:  *repeats,
+		useRange: *tuneRange,
+		json:     *jsonOut,
+	}
+
 	if *test {
-		runPerformanceTests(client, &cfg.UDP)
+		runPerformanceTests(client, &cfg.UDP, tuning)
 		return
 	}
 
@@ -93,7 +134,7 @@ func main() {
 		case "PERF":
 			client.GetPerformanceReport()
 		case "TEST":
-			runPerformanceTests(client, &cfg.UDP)
+			runPerformanceTests(client, &cfg.UDP, tuning)
 		case "UPLOAD":
 			if len(args) < 2 {
 				fmt.Println("Usage: UPLOAD <local_path> <remote_name>")
@@ -158,77 +199,61 @@ func handleDownload(client *network.UDPClient, remoteName, localPath string) {
 		progress.Bitrate)
 }
 
-func runPerformanceTests(client *network.UDPClient, udpConfig *config.UDPConfig) {
-	fmt.Println("Running UDP performance tests...")
-	fmt.Printf("Testing buffer sizes: %v\n", udpConfig.BufferSizes)
-
-	var results []TestResult
-
-	for _, bufferSize := range udpConfig.BufferSizes {
-		fmt.Printf("Testing buffer size: %d bytes\n", bufferSize)
-
-		testFile := fmt.Sprintf("test_%d.dat", bufferSize)
-		testData := make([]byte, bufferSize)
-		for i := range testData {
-			testData[i] = byte(i % 256)
-		}
+type tuneOptions struct {
+	fileSize int64
+	repeats  int
+	useRange bool
+	json     bool
+}
 
-		if err := os.WriteFile(testFile, testData, 0644); err != nil {
-			fmt.Printf("Failed to create test file: %v\n", err)
-			continue
-		}
+func runPerformanceTests(client *network.UDPClient, udpConfig *config.UDPConfig, opts tuneOptions) {
+	sizes := udpConfig.BufferSizes
+	if opts.useRange {
+		sizes = network.BufferSizeRange(udpConfig.MinBufferSize, udpConfig.MaxBufferSize, udpConfig.BufferStep)
+	}
 
-		start := time.Now()
-		progress, err := client.UploadFile(testFile, fmt.Sprintf("upload_%d.dat", bufferSize))
-		elapsed := time.Since(start).Seconds()
+	fmt.Println("Running UDP buffer size tuning...")
+	fmt.Printf("Testing %d buffer sizes with a %d byte file, %d runs each\n", len(sizes), opts.fileSize, opts.repeats)
 
-		os.Remove(testFile)
+	report, err := client.TuneBufferSizes(sizes, opts.fileSize, opts.repeats)
+	if report == nil {
+		fmt.Printf("Buffer tuning failed: %v\n", err)
+		return
+	}
 
+	if opts.json {
+		data, err := json.MarshalIndent(report, "", "  ")
 		if err != nil {
-			fmt.Printf("Test failed for buffer size %d: %v\n", bufferSize, err)
-			continue
+			fmt.Printf("Failed to encode results: %v\n", err)
+			return
 		}
-
-		bitrate := float64(progress.Transferred) / elapsed / 1024 / 1024
-		results = append(results, TestResult{
-			BufferSize: bufferSize,
-			Bitrate:    bitrate,
-			Elapsed:    elapsed,
-		})
-
-		fmt.Printf("  Buffer size %d: %.2f MB/s (%.2fs)\n", bufferSize, bitrate, elapsed)
-		time.Sleep(1 * time.Second)
+		fmt.Println(string(data))
+	} else {
+		printTuneReport(report)
 	}
 
-	var optimal TestResult
-	for _, result := range results {
-		if result.Bitrate > optimal.Bitrate {
-			optimal = result
-		}
+	if err != nil {
+		fmt.Printf("Buffer tuning failed: %v\n", err)
 	}
+}
 
-	fmt.Printf("\nOptimal buffer size: %d bytes (%.2f MB/s)\n", optimal.BufferSize, optimal.Bitrate)
-
-	tcpBaseline := 10.0
-	ratio := optimal.Bitrate / tcpBaseline
-
-	fmt.Printf("UDP vs TCP Performance Ratio: %.2f\n", ratio)
-	if ratio >= 1.5 {
-		fmt.Printf("✓ UDP is %.2fx faster than TCP (meets requirement)\n", ratio)
-	} else {
-		fmt.Printf("✗ UDP is %.2fx faster than TCP (does not meet 1.5x requirement)\n", ratio)
+func printTuneReport(report *network.BufferTuneReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Payload\tMean MB/s\t±95%\tMin\tMax\tRetx %\tLost %\tFailed\t")
+	for _, result := range report.Results {
+		fmt.Fprintf(w, "%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%d/%d\t\n",
+			result.PayloadSize, result.MeanGoodput, result.Confidence95, result.MinGoodput, result.MaxGoodput,
+			result.RetransmitRate*100, result.LossRate*100, result.Failures, len(result.Runs))
 	}
+	w.Flush()
 
-	fmt.Printf("\nBuffer Size Analysis:\n")
-	fmt.Printf("The optimal buffer size of %d bytes balances:\n", optimal.BufferSize)
-	fmt.Printf("- Network MTU considerations (reduces packet fragmentation)\n")
-	fmt.Printf("- System overhead (fewer system calls)\n")
-	fmt.Printf("- Memory usage efficiency\n")
-	fmt.Printf("- UDP protocol characteristics (connectionless, no congestion control)\n")
-}
+	if report.Recommended == 0 {
+		return
+	}
 
-type TestResult struct {
-	BufferSize int
-	Bitrate    float64
-	Elapsed    float64
+	fmt.Printf("\nHighest mean goodput: %d bytes\n", report.Best)
+	fmt.Printf("Recommended payload size: %d bytes\n", report.Recommended)
+	if report.Recommended != report.Best {
+		fmt.Printf("(smallest size whose goodput is within the 95%% confidence interval of the best)\n")
+	}
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"NSSaDS/lab2/internal/infrastructure/network"
+	"NSSaDS/lab2/pkg/config"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	var (
+		send      = flag.String("send", "", "File to multicast (sender mode)")
+		recv      = flag.String("recv", "", "Directory to store the received file (receiver mode)")
+		receivers = flag.Int("receivers", 1, "Receivers to wait for before sending")
+		group     = flag.String("group", "239.255.42.99:9999", "Multicast group address")
+		iface     = flag.String("iface", "", "Multicast interface, e.g. lo for single-host tests (default: system choice)")
+		ttl       = flag.Int("ttl", 1, "Multicast TTL")
+		payload   = flag.Int("payload", 1400, "Data bytes per packet")
+		rate      = flag.Int("rate", 20*1024*1024, "Send rate cap in bytes per second (0 = unlimited)")
+		threshold = flag.Int("repair-threshold", 2, "Receivers that must miss a packet before it is repaired by multicast instead of unicast")
+		join      = flag.Duration("join-timeout", 10*time.Second, "How long to wait for receivers to join")
+		drop      = flag.Float64("drop", 0, "Receiver only: discard this fraction of data packets to test NACK repair on one host")
+	)
+	flag.Parse()
+
+	if (*send == "") == (*recv == "") {
+		fmt.Fprintln(os.Stderr, "exactly one of -send or -recv is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.NewConfig()
+	cfg.UDP.MulticastGroup = *group
+	cfg.UDP.MulticastInterface = *iface
+	cfg.UDP.MulticastTTL = *ttl
+	cfg.UDP.MulticastPayload = *payload
+	cfg.UDP.MulticastRate = *rate
+	cfg.UDP.MulticastRepairThreshold = *threshold
+	cfg.UDP.MulticastJoinTimeout = *join
+	cfg.UDP.MulticastDropRate = *drop
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if *send != "" {
+		runSender(ctx, &cfg.UDP, *send, *receivers)
+		return
+	}
+	runReceiver(ctx, &cfg.UDP, *recv)
+}
+
+func runSender(ctx context.Context, udpConfig *config.UDPConfig, path string, receivers int) {
+	sender, err := network.NewMulticastSender(udpConfig)
+	if err != nil {
+		log.Fatalf("Failed to start multicast sender: %v", err)
+	}
+	defer sender.Close()
+
+	stats, err := sender.Send(ctx, path, receivers)
+	if stats != nil {
+		fmt.Printf("\n=== Multicast Sender Report ===\n")
+		fmt.Printf("File: %s (%d bytes)\n", stats.FileName, stats.FileSize)
+		fmt.Printf("Receivers: %d joined, %d completed, %d dropped\n", stats.Receivers, stats.Completed, stats.Dropped)
+		fmt.Printf("Data Packets: %d\n", stats.DataPackets)
+		fmt.Printf("NACKs Received: %d\n", stats.Nacks)
+		fmt.Printf("Repairs: %d multicast, %d unicast\n", stats.MulticastRepairs, stats.UnicastRepairs)
+		fmt.Printf("Duration: %v (%.2f MB/s)\n", stats.Duration.Round(time.Millisecond), stats.Bitrate())
+		fmt.Printf("===============================\n")
+	}
+	if err != nil {
+		log.Fatalf("Multicast failed: %v", err)
+	}
+}
+
+func runReceiver(ctx context.Context, udpConfig *config.UDPConfig, dir string) {
+	receiver, err := network.NewMulticastReceiver(udpConfig)
+	if err != nil {
+		log.Fatalf("Failed to start multicast receiver: %v", err)
+	}
+	defer receiver.Close()
+
+	stats, err := receiver.Receive(ctx, dir)
+	if err != nil {
+		log.Fatalf("Multicast receive failed: %v", err)
+	}
+
+	fmt.Printf("Received %s (%d bytes) in %v, %.2f MB/s: %d packets, %d NACKs, %d unicast repairs, %d duplicates\n",
+		stats.FileName, stats.FileSize, stats.Duration.Round(time.Millisecond), stats.Bitrate(),
+		stats.DataPackets, stats.Nacks, stats.UnicastRepairs, stats.Duplicates)
+}
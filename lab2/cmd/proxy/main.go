@@ -0,0 +1,97 @@
+package main
+
+import (
+	"NSSaDS/lab2/pkg/netem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+type proxy interface {
+	Start(listenAddr, targetAddr string) error
+	Stop() error
+	Stats() netem.Stats
+}
+
+func main() {
+	var (
+		proto        = flag.String("proto", "udp", "Protocol to proxy (udp or tcp)")
+		listen       = flag.String("listen", "localhost:9080", "Address the proxy listens on")
+		target       = flag.String("target", "localhost:8080", "Address of the real server")
+		loss         = flag.Float64("loss", 0, "Packet loss probability (0..1)")
+		duplicate    = flag.Float64("dup", 0, "Packet duplication probability (0..1)")
+		reorder      = flag.Float64("reorder", 0, "Probability that a packet is held back and reordered (0..1)")
+		reorderDelay = flag.Duration("reorder-delay", 10*time.Millisecond, "Extra delay applied to reordered packets")
+		corrupt      = flag.Float64("corrupt", 0, "Probability of flipping one bit in a packet (0..1)")
+		delay        = flag.Duration("delay", 0, "One-way delay")
+		jitter       = flag.Duration("jitter", 0, "Delay jitter (+/-)")
+		bandwidth    = flag.Int64("bandwidth", 0, "Bandwidth cap in bytes per second (0 = unlimited)")
+		queue        = flag.Int("queue", 0, "Bottleneck queue size in bytes, tail-dropped when full (0 = unlimited)")
+		seed         = flag.Uint64("seed", uint64(time.Now().UnixNano()), "Random seed for reproducible runs")
+		interval     = flag.Duration("stats-interval", 0, "Print statistics periodically (0 = only on exit)")
+	)
+	flag.Parse()
+
+	cfg := netem.Config{
+		Loss:         *loss,
+		Duplicate:    *duplicate,
+		Reorder:      *reorder,
+		ReorderDelay: *reorderDelay,
+		Corrupt:      *corrupt,
+		Delay:        *delay,
+		Jitter:       *jitter,
+		Bandwidth:    *bandwidth,
+		QueueSize:    *queue,
+		Seed:         *seed,
+	}
+
+	var p proxy
+	switch *proto {
+	case "udp":
+		p = netem.NewUDPProxy(cfg)
+	case "tcp":
+		p = netem.NewTCPProxy(cfg)
+	default:
+		log.Fatalf("Unknown protocol: %s", *proto)
+	}
+
+	if err := p.Start(*listen, *target); err != nil {
+		log.Fatalf("Failed to start proxy: %v", err)
+	}
+
+	fmt.Printf("Impairment proxy (%s) %s -> %s\n", *proto, *listen, *target)
+	fmt.Printf("  loss=%.3f dup=%.3f reorder=%.3f corrupt=%.3f\n", cfg.Loss, cfg.Duplicate, cfg.Reorder, cfg.Corrupt)
+	fmt.Printf("  delay=%v jitter=%v bandwidth=%d B/s queue=%d B\n", cfg.Delay, cfg.Jitter, cfg.Bandwidth, cfg.QueueSize)
+	fmt.Printf("  seed=%d\n", cfg.Seed)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	var tick <-chan time.Time
+	if *interval > 0 {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			printStats(p.Stats())
+		case <-sigChan:
+			p.Stop()
+			printStats(p.Stats())
+			return
+		}
+	}
+}
+
+func printStats(stats netem.Stats) {
+	fmt.Printf("received=%d forwarded=%d dropped=%d queue_drops=%d duplicated=%d reordered=%d corrupted=%d\n",
+		stats.Received, stats.Forwarded, stats.Dropped, stats.QueueDrops,
+		stats.Duplicated, stats.Reordered, stats.Corrupted)
+}
@@ -17,15 +17,33 @@ import (
 
 func main() {
 	var (
-		host = flag.String("host", "localhost", "Server host")
-		port = flag.String("port", "8080", "Server port")
-		test = flag.Bool("test", false, "Run performance tests")
+		host      = flag.String("host", "localhost", "Server host")
+		port      = flag.String("port", "8080", "Server port")
+		test      = flag.Bool("test", false, "Run performance tests")
+		psk       = flag.String("psk", os.Getenv("LAB2_PSK"), "Pre-shared key for secure mode (default $LAB2_PSK, empty disables)")
+		heartbeat = flag.Duration("heartbeat", 500*time.Millisecond, "Heartbeat interval for idle transfer sessions (0 disables)")
+		misses    = flag.Int("heartbeat-misses", 4, "Unanswered heartbeats before a session is torn down")
+		trace     = flag.String("trace", "", "Record every sent and received packet to this trace file (see cmd/trace)")
+		snapLen   = flag.Int("trace-snaplen", 0, "Bytes captured per traced packet (0 captures whole packets)")
+		pacing    = flag.Bool("pacing", true, "Pace DATA packets at window/RTT instead of sending back to back")
+		paceRate  = flag.Int("pace-rate", 0, "Pacing rate cap in bytes per second (0 = window/RTT only)")
+		paceBurst = flag.Int("pace-burst", 4, "DATA packets sent back to back per pacing interval")
+		sessions  = flag.Int("max-sessions", 64, "Concurrent transfers before new UPLOAD/DOWNLOAD requests are refused (0 = unlimited)")
 	)
 	flag.Parse()
 
 	cfg := config.NewConfig()
 	cfg.Server.Host = *host
 	cfg.Server.Port = *port
+	cfg.Server.MaxSessions = *sessions
+	cfg.UDP.PSK = *psk
+	cfg.UDP.HeartbeatInterval = *heartbeat
+	cfg.UDP.HeartbeatMisses = *misses
+	cfg.UDP.TracePath = *trace
+	cfg.UDP.TraceSnapLen = *snapLen
+	cfg.UDP.Pacing = *pacing
+	cfg.UDP.PacingRate = *paceRate
+	cfg.UDP.PacingBurst = *paceBurst
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"NSSaDS/lab2/internal/infrastructure/network"
+	"NSSaDS/lab2/internal/infrastructure/repository"
+	"NSSaDS/lab2/internal/usecase"
+	"NSSaDS/lab2/pkg/config"
+	"NSSaDS/lab2/pkg/netem"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type clientResult struct {
+	id        int
+	upload    time.Duration
+	download  time.Duration
+	rejected  int
+	err       error
+	verifyErr error
+}
+
+func main() {
+	var (
+		clients     = flag.Int("clients", 16, "Number of concurrent clients")
+		size        = flag.Int64("size", 2*1024*1024, "File size per client in bytes")
+		server      = flag.String("server", "", "Existing server to stress (default starts one in-process)")
+		maxSessions = flag.Int("max-sessions", 64, "Concurrent transfer limit of the in-process server")
+		download    = flag.Bool("download", true, "Download every file back concurrently and verify it too")
+		loss        = flag.Float64("loss", 0, "Loss probability of an in-process impairment proxy (0 = no proxy)")
+		timeout     = flag.Duration("timeout", 2*time.Minute, "Give up on the whole run after this long")
+		verbose     = flag.Bool("v", false, "Show server and client logs")
+	)
+	flag.Parse()
+
+	workDir, err := os.MkdirTemp("", "nssads_stress_*")
+	if err != nil {
+		log.Fatalf("Failed to create work directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	out := os.Stdout
+	if !*verbose {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", os.DevNull, err)
+		}
+		defer devNull.Close()
+
+		os.Stdout = devNull
+		defer func() { os.Stdout = out }()
+	}
+
+	cfg := config.NewConfig()
+	cfg.Server.UploadDir = filepath.Join(workDir, "server")
+	cfg.Server.MaxSessions = *maxSessions
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	addr := *server
+	serverDir := ""
+	if addr == "" {
+		addr, err = startServer(ctx, cfg)
+		if err != nil {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+		serverDir = cfg.Server.UploadDir
+	}
+
+	var proxy *netem.UDPProxy
+	if *loss > 0 {
+		proxy = netem.NewUDPProxy(netem.Config{Loss: *loss, Seed: rand.Uint64()})
+		if err := proxy.Start("127.0.0.1:0", addr); err != nil {
+			log.Fatalf("Failed to start proxy: %v", err)
+		}
+		defer proxy.Stop()
+		addr = proxy.Addr().String()
+	}
+
+	sources := make([]string, *clients)
+	for i := range sources {
+		sources[i] = filepath.Join(workDir, fmt.Sprintf("source_%03d.bin", i))
+		if err := writeRandomFile(sources[i], *size); err != nil {
+			log.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	fmt.Fprintf(out, "Stressing %s with %d clients, %d bytes each (max sessions %d)\n",
+		addr, *clients, *size, *maxSessions)
+
+	results := make([]clientResult, *clients)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			results[id] = runClient(ctx, cfg, addr, id, sources[id], workDir, serverDir, *download)
+		}(i)
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	failed, rejected := 0, 0
+	for _, result := range results {
+		rejected += result.rejected
+		switch {
+		case result.err != nil:
+			failed++
+			fmt.Fprintf(out, "client %03d: FAILED: %v\n", result.id, result.err)
+		case result.verifyErr != nil:
+			failed++
+			fmt.Fprintf(out, "client %03d: CORRUPT: %v\n", result.id, result.verifyErr)
+		}
+	}
+
+	total := float64(*size) * float64(*clients)
+	if *download {
+		total *= 2
+	}
+
+	fmt.Fprintf(out, "Finished in %v: %d of %d clients verified, %d busy rejections retried, %.2f MB/s aggregate\n",
+		elapsed.Round(time.Millisecond), *clients-failed, *clients, rejected, total/elapsed.Seconds()/1024/1024)
+	if proxy != nil {
+		stats := proxy.Stats()
+		fmt.Fprintf(out, "Proxy: %d received, %d dropped\n", stats.Received, stats.Dropped)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func startServer(ctx context.Context, cfg *config.Config) (string, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+
+	fileMgr := repository.NewFileManager(cfg.Server.UploadDir)
+	server := network.NewUDPServer(&cfg.Server, &cfg.UDP, usecase.NewCommandHandler(), fileMgr)
+
+	go func() {
+		server.Start(ctx, addr)
+		fileMgr.Close()
+	}()
+
+	clientCfg := cfg.Client
+	clientCfg.Timeout = 200 * time.Millisecond
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		client := network.NewUDPClient(&clientCfg, &cfg.UDP, nil)
+		if err := client.Connect(ctx, addr); err != nil {
+			return "", err
+		}
+		_, err := client.SendCommand("ECHO", []string{"ready"})
+		client.Disconnect()
+
+		if err == nil {
+			return addr, nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("server on %s not ready: %w", addr, err)
+		}
+	}
+}
+
+func runClient(ctx context.Context, cfg *config.Config, addr string, id int, source, workDir, serverDir string,
+	download bool) clientResult {
+
+	result := clientResult{id: id}
+	remote := fmt.Sprintf("stress_%03d.bin", id)
+
+	client := network.NewUDPClient(&cfg.Client, &cfg.UDP, nil)
+	if err := client.Connect(ctx, addr); err != nil {
+		result.err = err
+		return result
+	}
+	defer client.Disconnect()
+
+	start := time.Now()
+	result.err = retryBusy(ctx, &result.rejected, func() error {
+		_, err := client.UploadFile(source, remote)
+		return err
+	})
+	result.upload = time.Since(start)
+	if result.err != nil {
+		result.err = fmt.Errorf("upload: %w", result.err)
+		return result
+	}
+
+	if serverDir != "" {
+		if result.verifyErr = sameContent(source, filepath.Join(serverDir, remote)); result.verifyErr != nil {
+			result.verifyErr = fmt.Errorf("uploaded copy: %w", result.verifyErr)
+			return result
+		}
+	}
+
+	if !download {
+		return result
+	}
+
+	localPath := filepath.Join(workDir, fmt.Sprintf("download_%03d.bin", id))
+	start = time.Now()
+	result.err = retryBusy(ctx, &result.rejected, func() error {
+		_, err := client.DownloadFile(remote, localPath)
+		return err
+	})
+	result.download = time.Since(start)
+	if result.err != nil {
+		result.err = fmt.Errorf("download: %w", result.err)
+		return result
+	}
+
+	if result.verifyErr = sameContent(source, localPath); result.verifyErr != nil {
+		result.verifyErr = fmt.Errorf("downloaded copy: %w", result.verifyErr)
+	}
+	return result
+}
+
+func retryBusy(ctx context.Context, rejected *int, transfer func() error) error {
+	for {
+		err := transfer()
+		if err == nil || !strings.Contains(err.Error(), network.ErrServerBusy.Error()) {
+			return err
+		}
+
+		*rejected++
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(time.Duration(50+rand.IntN(200)) * time.Millisecond):
+		}
+	}
+}
+
+func writeRandomFile(path string, size int64) error {
+	data := make([]byte, size)
+	rand.NewChaCha8([32]byte{byte(rand.Uint32())}).Read(data)
+	return os.WriteFile(path, data, 0644)
+}
+
+func sameContent(source, copy string) error {
+	expected, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	actual, err := os.ReadFile(copy)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(expected, actual) {
+		return fmt.Errorf("sha256 %x, want %x (%d of %d bytes)",
+			sha256.Sum256(actual), sha256.Sum256(expected), len(actual), len(expected))
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"NSSaDS/lab2/internal/infrastructure/network"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var packetTypeNames = map[uint8]string{
+	domain.PacketTypeData:     "DATA",
+	domain.PacketTypeAck:      "ACK",
+	domain.PacketTypeNack:     "NACK",
+	domain.PacketTypeSyn:      "SYN",
+	domain.PacketTypeFin:      "FIN",
+	domain.PacketTypeFileInfo: "FILE_INFO",
+	domain.PacketTypeCommand:  "COMMAND",
+	domain.PacketTypeResponse: "RESPONSE",
+	domain.PacketTypeParity:   "PARITY",
+	domain.PacketTypeProbe:    "PROBE",
+	domain.PacketTypePing:     "PING",
+	domain.PacketTypePong:     "PONG",
+}
+
+func typeName(t uint8) string {
+	if name, ok := packetTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", t)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  trace print [-type DATA] [-limit N] <file>   - Print one line per packet")
+	fmt.Fprintln(os.Stderr, "  trace summary <file>                         - Summarize packets, retransmissions and stalls")
+	fmt.Fprintln(os.Stderr, "  trace replay -server host:port <file>        - Resend a client trace and compare responses")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "print":
+		fs := flag.NewFlagSet("print", flag.ExitOnError)
+		typeFilter := fs.String("type", "", "Only print packets of this type (e.g. DATA, ACK)")
+		limit := fs.Int("limit", 0, "Stop after this many packets (0 = all)")
+		fs.Parse(os.Args[2:])
+		if fs.NArg() != 1 {
+			usage()
+		}
+		printTrace(fs.Arg(0), strings.ToUpper(*typeFilter), *limit)
+	case "summary":
+		fs := flag.NewFlagSet("summary", flag.ExitOnError)
+		fs.Parse(os.Args[2:])
+		if fs.NArg() != 1 {
+			usage()
+		}
+		summarizeTrace(fs.Arg(0))
+	case "replay":
+		fs := flag.NewFlagSet("replay", flag.ExitOnError)
+		server := fs.String("server", "localhost:8080", "Server to replay the client packets against")
+		speed := fs.Float64("speed", 1, "Timing scale: 2 replays twice as fast, 0 sends back to back")
+		wait := fs.Duration("wait", 2*time.Second, "How long to wait for responses after the last packet")
+		ignore := fs.String("ignore", "TIME", "Comma-separated commands whose responses are not compared")
+		fs.Parse(os.Args[2:])
+		if fs.NArg() != 1 {
+			usage()
+		}
+		ignored := make(map[string]bool)
+		for _, command := range strings.Split(*ignore, ",") {
+			if command = strings.TrimSpace(command); command != "" {
+				ignored[strings.ToUpper(command)] = true
+			}
+		}
+		if !replayTrace(fs.Arg(0), *server, *speed, *wait, ignored) {
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
+
+func readTrace(path string, fn func(*network.TraceRecord)) {
+	reader, err := network.OpenTrace(path)
+	if err != nil {
+		log.Fatalf("Failed to open trace: %v", err)
+	}
+	defer reader.Close()
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			log.Fatalf("Failed to read trace: %v", err)
+		}
+		fn(record)
+	}
+}
+
+func printTrace(path, typeFilter string, limit int) {
+	var start time.Time
+	printed := 0
+
+	readTrace(path, func(record *network.TraceRecord) {
+		if start.IsZero() {
+			start = record.Time
+		}
+		if limit > 0 && printed >= limit {
+			return
+		}
+
+		packet, err := record.Header()
+		if err != nil {
+			if typeFilter == "" {
+				fmt.Printf("%11.6f %s %-21s undecodable (%v) len=%d\n",
+					record.Time.Sub(start).Seconds(), record.Direction, record.Addr, err, record.Length)
+				printed++
+			}
+			return
+		}
+
+		name := typeName(packet.Type)
+		if typeFilter != "" && name != typeFilter {
+			return
+		}
+
+		fmt.Printf("%11.6f %s %-21s %-9s seq=%-7d ack=%-7d win=%-4d flags=%d conn=%08x len=%-5d %s\n",
+			record.Time.Sub(start).Seconds(), record.Direction, record.Addr, name,
+			packet.SeqNum, packet.AckNum, packet.Window, packet.Flags, packet.ConnID, record.Length, record.Status)
+		printed++
+	})
+}
+
+type typeCounts struct {
+	sent, received           int
+	sentBytes, receivedBytes int64
+}
+
+func summarizeTrace(path string) {
+	var first, last, lastReceived time.Time
+	var longestGap time.Duration
+	var gapAt time.Time
+	records, malformed, dropped := 0, 0, 0
+	byType := make(map[string]*typeCounts)
+	connIDs := make(map[uint32]bool)
+	sentData := make(map[[2]uint32]bool)
+	receivedData := make(map[[2]uint32]bool)
+	retransmits, duplicates := 0, 0
+
+	readTrace(path, func(record *network.TraceRecord) {
+		records++
+		if first.IsZero() {
+			first = record.Time
+		}
+		last = record.Time
+
+		if record.Direction == network.TraceReceived {
+			if !lastReceived.IsZero() && record.Time.Sub(lastReceived) > longestGap {
+				longestGap = record.Time.Sub(lastReceived)
+				gapAt = lastReceived
+			}
+			lastReceived = record.Time
+		}
+
+		switch record.Status {
+		case network.TraceMalformed:
+			malformed++
+			return
+		case network.TraceDropped:
+			dropped++
+		}
+
+		packet, err := record.Header()
+		if err != nil {
+			malformed++
+			return
+		}
+		connIDs[packet.ConnID] = true
+
+		name := typeName(packet.Type)
+		counts := byType[name]
+		if counts == nil {
+			counts = &typeCounts{}
+			byType[name] = counts
+		}
+
+		key := [2]uint32{packet.ConnID, packet.SeqNum}
+		if record.Direction == network.TraceSent {
+			counts.sent++
+			counts.sentBytes += int64(record.Length)
+			if packet.Type == domain.PacketTypeData {
+				if sentData[key] {
+					retransmits++
+				}
+				sentData[key] = true
+			}
+		} else {
+			counts.received++
+			counts.receivedBytes += int64(record.Length)
+			if packet.Type == domain.PacketTypeData && record.Status == network.TraceOK {
+				if receivedData[key] {
+					duplicates++
+				}
+				receivedData[key] = true
+			}
+		}
+	})
+
+	if records == 0 {
+		fmt.Println("Trace is empty")
+		return
+	}
+
+	fmt.Printf("Trace: %s\n", path)
+	fmt.Printf("Span: %v (%s to %s)\n", last.Sub(first).Round(time.Millisecond),
+		first.Format("15:04:05.000"), last.Format("15:04:05.000"))
+	fmt.Printf("Packets: %d in %d connections\n", records, len(connIDs))
+
+	names := make([]string, 0, len(byType))
+	for name := range byType {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "\nType\tSent\tReceived\tBytes Sent\tBytes Received\t")
+	for _, name := range names {
+		c := byType[name]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t\n", name, c.sent, c.received, c.sentBytes, c.receivedBytes)
+	}
+	w.Flush()
+
+	fmt.Printf("\nDATA retransmissions sent: %d\n", retransmits)
+	fmt.Printf("Duplicate DATA received: %d\n", duplicates)
+	fmt.Printf("Bad checksum or malformed: %d\n", malformed)
+	fmt.Printf("Dropped by secure mode: %d\n", dropped)
+	if longestGap > 0 {
+		fmt.Printf("Longest receive silence: %v starting at +%.3fs\n",
+			longestGap.Round(time.Millisecond), gapAt.Sub(first).Seconds())
+	}
+}
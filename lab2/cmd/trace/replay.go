@@ -0,0 +1,158 @@
+package main
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"NSSaDS/lab2/internal/infrastructure/network"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type replayPacket struct {
+	offset time.Duration
+	packet *domain.Packet
+}
+
+func replayTrace(path, server string, speed float64, wait time.Duration, ignore map[string]bool) bool {
+	var start time.Time
+	var outgoing []replayPacket
+	expected := make(map[uint32]string)
+	commands := make(map[uint32]string)
+	skipped := 0
+
+	readTrace(path, func(record *network.TraceRecord) {
+		if start.IsZero() {
+			start = record.Time
+		}
+		if record.Status != network.TraceOK || !record.Complete() {
+			skipped++
+			return
+		}
+
+		packet, err := domain.DeserializePacket(record.Data)
+		if err != nil {
+			skipped++
+			return
+		}
+
+		if packet.Type == domain.PacketTypeSyn && packet.Flags != 0 {
+			log.Fatalf("Trace uses secure mode; sealed packets cannot be replayed against a new session")
+		}
+
+		switch record.Direction {
+		case network.TraceSent:
+			outgoing = append(outgoing, replayPacket{offset: record.Time.Sub(start), packet: packet})
+			if packet.Type == domain.PacketTypeCommand {
+				if fields := strings.Fields(string(packet.Data)); len(fields) > 0 {
+					commands[packet.SeqNum+1] = strings.ToUpper(fields[0])
+				}
+			}
+		case network.TraceReceived:
+			if packet.Type == domain.PacketTypeResponse {
+				expected[packet.SeqNum] = string(packet.Data)
+			}
+		}
+	})
+
+	if len(outgoing) == 0 {
+		log.Fatalf("Trace has no complete sent packets to replay (%d records skipped, was it captured with -trace-snaplen?)", skipped)
+	}
+
+	serverAddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		log.Fatalf("Failed to resolve server address: %v", err)
+	}
+
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		log.Fatalf("Failed to create UDP socket: %v", err)
+	}
+	defer conn.Close()
+
+	var mu sync.Mutex
+	got := make(map[uint32]string)
+	received := 0
+
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, _, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			packet, err := domain.DeserializePacket(buf[:n])
+			if err != nil {
+				continue
+			}
+
+			mu.Lock()
+			received++
+			if packet.Type == domain.PacketTypeResponse {
+				if _, seen := got[packet.SeqNum]; !seen {
+					got[packet.SeqNum] = string(packet.Data)
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	connIDs := make(map[uint32]uint32)
+	fmt.Printf("Replaying %d packets from %s to %s (speed %.1fx, %d records skipped)\n",
+		len(outgoing), path, serverAddr, speed, skipped)
+
+	replayStart := time.Now()
+	for _, rp := range outgoing {
+		if speed > 0 {
+			time.Sleep(time.Until(replayStart.Add(time.Duration(float64(rp.offset) / speed))))
+		}
+
+		connID, ok := connIDs[rp.packet.ConnID]
+		if !ok {
+			connID = rand.Uint32() | 1
+			connIDs[rp.packet.ConnID] = connID
+		}
+		rp.packet.ConnID = connID
+
+		if _, err := conn.WriteToUDP(rp.packet.Serialize(), serverAddr); err != nil {
+			log.Fatalf("Failed to send packet: %v", err)
+		}
+	}
+
+	time.Sleep(wait)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	seqs := make([]uint32, 0, len(expected))
+	for seq := range expected {
+		if ignore[commands[seq]] {
+			delete(expected, seq)
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	matched := 0
+	for _, seq := range seqs {
+		response, ok := got[seq]
+		switch {
+		case !ok:
+			fmt.Printf("MISSING  #%d: expected %q\n", seq, expected[seq])
+		case response != expected[seq]:
+			fmt.Printf("DIFFERS  #%d: expected %q, got %q\n", seq, expected[seq], response)
+		default:
+			matched++
+		}
+	}
+
+	fmt.Printf("Replay finished in %v: %d packets received, %d of %d responses match\n",
+		time.Since(replayStart).Round(time.Millisecond), received, matched, len(expected))
+
+	return matched == len(expected)
+}
@@ -1,3 +1,13 @@
 module NSSaDS/lab2
 
 go 1.26
+
+require NSSaDS v0.0.0
+
+require (
+	golang.org/x/crypto v0.48.0
+	golang.org/x/net v0.50.0
+	golang.org/x/sys v0.41.0 // indirect
+)
+
+replace NSSaDS => ../lab1
@@ -2,7 +2,9 @@ package domain
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"time"
 )
 
@@ -15,14 +17,19 @@ const (
 	PacketTypeFileInfo = 6
 	PacketTypeCommand  = 7
 	PacketTypeResponse = 8
+	PacketTypeParity   = 9
+	PacketTypeProbe    = 10
+	PacketTypePing     = 11
+	PacketTypePong     = 12
 )
 
 type Packet struct {
 	Type      uint8
 	SeqNum    uint32
 	AckNum    uint32
+	ConnID    uint32
 	Data      []byte
-	Checksum  uint16
+	Checksum  uint32
 	Window    uint16
 	Flags     uint8
 	Timestamp int64
@@ -36,30 +43,37 @@ type FileInfo struct {
 }
 
 type TransferProgress struct {
-	FileName    string
-	TotalBytes  int64
-	Transferred int64
-	StartTime   time.Time
-	Bitrate     float64
-	Percentage  float64
-	PacketsSent uint32
-	PacketsLost uint32
-	Retransmits uint32
+	FileName      string
+	TotalBytes    int64
+	Transferred   int64
+	StartTime     time.Time
+	Bitrate       float64
+	Percentage    float64
+	PacketsSent   uint32
+	PacketsLost   uint32
+	Retransmits   uint32
+	ParityPackets uint32
+	FECRecovered  uint32
+	Duplicates    uint32
 }
 
 type TransferSession struct {
-	ID          string
-	ClientAddr  string
-	FileName    string
-	FileSize    int64
-	Transferred int64
-	IsUpload    bool
-	LastUpdate  time.Time
-	FilePath    string
-	WindowBase  uint32
-	WindowSize  uint16
-	LastAck     uint32
-	BufferSize  int
+	ID           string
+	ClientAddr   string
+	FileName     string
+	FileSize     int64
+	Offset       int64
+	Transferred  int64
+	IsUpload     bool
+	LastUpdate   time.Time
+	FilePath     string
+	WindowBase   uint32
+	WindowSize   uint16
+	LastAck      uint32
+	BufferSize   int
+	FECBlockSize int
+	FECParity    int
+	ResumeToken  string
 }
 
 type SlidingWindow struct {
@@ -96,77 +110,112 @@ func NewNackPacket(seqNum uint32) *Packet {
 	}
 }
 
-func (p *Packet) Serialize() []byte {
-	buf := make([]byte, 23+len(p.Data))
-	buf[0] = p.Type
-	binary.BigEndian.PutUint32(buf[1:5], p.SeqNum)
-	binary.BigEndian.PutUint32(buf[5:9], p.AckNum)
-	binary.BigEndian.PutUint16(buf[9:11], p.Window)
-	buf[11] = p.Flags
-	binary.BigEndian.PutUint64(buf[12:20], uint64(p.Timestamp))
-	binary.BigEndian.PutUint16(buf[20:22], uint16(len(p.Data)))
-	copy(buf[22:], p.Data)
-
-	p.Checksum = p.calculateChecksum(buf)
-	binary.BigEndian.PutUint16(buf[21:23], p.Checksum)
+const (
+	PacketMagic      = 0x4E53
+	PacketVersion    = 1
+	PacketHeaderSize = 36
+	MaxPayloadSize   = 65507 - PacketHeaderSize
+)
+
+var (
+	ErrPacketTooShort      = errors.New("packet too short")
+	ErrBadMagic            = errors.New("bad packet magic")
+	ErrUnsupportedVersion  = errors.New("unsupported packet version")
+	ErrInvalidHeaderLength = errors.New("invalid header length")
+	ErrInvalidDataLength   = errors.New("invalid data length")
+	ErrChecksumMismatch    = errors.New("checksum mismatch")
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
 
+func (p *Packet) Serialize() []byte {
+	buf := make([]byte, PacketHeaderSize+len(p.Data))
+	p.SerializeTo(buf)
 	return buf
 }
 
-func DeserializePacket(data []byte) (*Packet, error) {
-	if len(data) < 22 {
-		return nil, fmt.Errorf("packet too short")
-	}
+func (p *Packet) SerializeTo(buf []byte) int {
+	size := PacketHeaderSize + len(p.Data)
+	buf = buf[:size]
+	binary.BigEndian.PutUint16(buf[0:2], PacketMagic)
+	buf[2] = PacketVersion
+	buf[3] = PacketHeaderSize
+	buf[4] = p.Type
+	buf[5] = p.Flags
+	binary.BigEndian.PutUint16(buf[6:8], p.Window)
+	binary.BigEndian.PutUint32(buf[8:12], p.ConnID)
+	binary.BigEndian.PutUint32(buf[12:16], p.SeqNum)
+	binary.BigEndian.PutUint32(buf[16:20], p.AckNum)
+	binary.BigEndian.PutUint64(buf[20:28], uint64(p.Timestamp))
+	binary.BigEndian.PutUint16(buf[28:30], uint16(len(p.Data)))
+	clear(buf[30:PacketHeaderSize])
+	copy(buf[PacketHeaderSize:], p.Data)
+
+	p.Checksum = crc32.Checksum(buf, crc32cTable)
+	binary.BigEndian.PutUint32(buf[32:36], p.Checksum)
+
+	return size
+}
 
-	p := &Packet{
-		Type:      data[0],
-		SeqNum:    binary.BigEndian.Uint32(data[1:5]),
-		AckNum:    binary.BigEndian.Uint32(data[5:9]),
-		Window:    binary.BigEndian.Uint16(data[9:11]),
-		Flags:     data[11],
-		Timestamp: int64(binary.BigEndian.Uint64(data[12:20])),
-		Checksum:  binary.BigEndian.Uint16(data[21:23]),
+func DecodeHeader(data []byte) (*Packet, error) {
+	if len(data) < PacketHeaderSize {
+		return nil, ErrPacketTooShort
 	}
 
-	dataLen := binary.BigEndian.Uint16(data[20:22])
-	if int(dataLen) > len(data)-22 {
-		return nil, fmt.Errorf("invalid data length")
+	if binary.BigEndian.Uint16(data[0:2]) != PacketMagic {
+		return nil, ErrBadMagic
 	}
 
-	p.Data = make([]byte, dataLen)
-	copy(p.Data, data[22:22+dataLen])
+	return &Packet{
+		Type:      data[4],
+		Flags:     data[5],
+		Window:    binary.BigEndian.Uint16(data[6:8]),
+		ConnID:    binary.BigEndian.Uint32(data[8:12]),
+		SeqNum:    binary.BigEndian.Uint32(data[12:16]),
+		AckNum:    binary.BigEndian.Uint32(data[16:20]),
+		Timestamp: int64(binary.BigEndian.Uint64(data[20:28])),
+		Checksum:  binary.BigEndian.Uint32(data[32:36]),
+	}, nil
+}
 
-	if !p.verifyChecksum(data) {
-		return nil, fmt.Errorf("checksum mismatch")
+func DeserializePacket(data []byte) (*Packet, error) {
+	// The version is checked before the length so that a frame from another
+	// version with a shorter header is reported as such, not as truncated.
+	if len(data) >= 3 && binary.BigEndian.Uint16(data[0:2]) == PacketMagic && data[2] != PacketVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, data[2])
 	}
 
-	return p, nil
-}
+	p, err := DecodeHeader(data)
+	if err != nil {
+		return nil, err
+	}
 
-func (p *Packet) calculateChecksum(data []byte) uint16 {
-	var sum uint32
-	for i := 0; i < len(data); i += 2 {
-		if i+1 < len(data) {
-			sum += uint32(data[i])<<8 | uint32(data[i+1])
-		} else {
-			sum += uint32(data[i]) << 8
-		}
+	headerLen := int(data[3])
+	if headerLen < PacketHeaderSize || headerLen > len(data) {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidHeaderLength, headerLen)
 	}
 
-	for sum > 0xFFFF {
-		sum = (sum & 0xFFFF) + (sum >> 16)
+	dataLen := int(binary.BigEndian.Uint16(data[28:30]))
+	if dataLen != len(data)-headerLen {
+		return nil, fmt.Errorf("%w: header says %d, got %d", ErrInvalidDataLength, dataLen, len(data)-headerLen)
 	}
 
-	return ^uint16(sum)
+	if checksum(data) != p.Checksum {
+		return nil, ErrChecksumMismatch
+	}
+
+	p.Data = make([]byte, dataLen)
+	copy(p.Data, data[headerLen:])
+
+	return p, nil
 }
 
-func (p *Packet) verifyChecksum(data []byte) bool {
-	originalChecksum := p.Checksum
-	p.Checksum = 0
-	calculated := p.calculateChecksum(data)
-	p.Checksum = originalChecksum
+func checksum(data []byte) uint32 {
+	var zero [4]byte
 
-	return calculated == originalChecksum
+	crc := crc32.Update(0, crc32cTable, data[:32])
+	crc = crc32.Update(crc, crc32cTable, zero[:])
+	return crc32.Update(crc, crc32cTable, data[36:])
 }
 
 func NewSlidingWindow(windowSize uint16) *SlidingWindow {
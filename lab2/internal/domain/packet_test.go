@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func mustHex(t testing.TB, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("bad hex %q: %v", s, err)
+	}
+	return b
+}
+
+var goldenPackets = []struct {
+	name   string
+	packet Packet
+	wire   string
+	crc    uint32
+}{
+	{
+		name: "data",
+		packet: Packet{
+			Type:      PacketTypeData,
+			Flags:     0x81,
+			Window:    0x0102,
+			ConnID:    0xDEADBEEF,
+			SeqNum:    0x01020304,
+			AckNum:    0x0A0B0C0D,
+			Timestamp: 0x1122334455667788,
+			Data:      []byte("hello"),
+		},
+		wire: "4e53" + "01" + "24" + // magic, version, header length
+			"01" + "81" + "0102" + // type, flags, window
+			"deadbeef" + // connection ID
+			"01020304" + "0a0b0c0d" + // seq, ack
+			"1122334455667788" + // timestamp
+			"0005" + "0000" + // data length, reserved
+			"4ce26091" + // CRC32C
+			"68656c6c6f",
+		crc: 0x4ce26091,
+	},
+	{
+		name: "empty ack",
+		packet: Packet{
+			Type:   PacketTypeAck,
+			Window: 64,
+			ConnID: 7,
+			SeqNum: 1,
+			AckNum: 2,
+		},
+		wire: "4e53" + "01" + "24" +
+			"02" + "00" + "0040" +
+			"00000007" +
+			"00000001" + "00000002" +
+			"0000000000000000" +
+			"0000" + "0000" +
+			"77cff1c4",
+		crc: 0x77cff1c4,
+	},
+}
+
+func TestSerializeGolden(t *testing.T) {
+	for _, tc := range goldenPackets {
+		t.Run(tc.name, func(t *testing.T) {
+			want := mustHex(t, tc.wire)
+			p := tc.packet
+			got := p.Serialize()
+			if !bytes.Equal(got, want) {
+				t.Fatalf("Serialize:\n got %x\nwant %x", got, want)
+			}
+			if p.Checksum != tc.crc {
+				t.Fatalf("Checksum = %#x, want %#x", p.Checksum, tc.crc)
+			}
+			if crc := binary.BigEndian.Uint32(got[32:36]); crc != tc.crc {
+				t.Fatalf("CRC at [32:36] = %#x, want %#x", crc, tc.crc)
+			}
+
+			buf := make([]byte, 2*len(want))
+			for i := range buf {
+				buf[i] = 0xFF
+			}
+			n := p.SerializeTo(buf)
+			if n != len(want) || !bytes.Equal(buf[:n], want) {
+				t.Fatalf("SerializeTo into a dirty buffer:\n got %x\nwant %x", buf[:n], want)
+			}
+		})
+	}
+}
+
+func TestDeserializeGolden(t *testing.T) {
+	for _, tc := range goldenPackets {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := DeserializePacket(mustHex(t, tc.wire))
+			if err != nil {
+				t.Fatalf("DeserializePacket: %v", err)
+			}
+
+			want := tc.packet
+			if p.Type != want.Type || p.Flags != want.Flags || p.Window != want.Window ||
+				p.ConnID != want.ConnID || p.SeqNum != want.SeqNum || p.AckNum != want.AckNum ||
+				p.Timestamp != want.Timestamp || p.Checksum != tc.crc {
+				t.Fatalf("decoded %+v, want %+v with checksum %#x", *p, want, tc.crc)
+			}
+			if !bytes.Equal(p.Data, want.Data) {
+				t.Fatalf("Data = %q, want %q", p.Data, want.Data)
+			}
+		})
+	}
+}
+
+func TestDeserializeRejects(t *testing.T) {
+	valid := func(t *testing.T) []byte { return mustHex(t, goldenPackets[0].wire) }
+
+	tests := []struct {
+		name   string
+		mutate func([]byte) []byte
+		want   error
+	}{
+		{"empty", func(b []byte) []byte { return nil }, ErrPacketTooShort},
+		{"truncated header", func(b []byte) []byte { return b[:PacketHeaderSize-1] }, ErrPacketTooShort},
+		{"bad magic", func(b []byte) []byte { b[0] ^= 0xFF; return b }, ErrBadMagic},
+		{"version 2", func(b []byte) []byte { b[2] = 2; return b }, ErrUnsupportedVersion},
+		{"version 2 short frame", func(b []byte) []byte { b[2] = 2; return b[:8] }, ErrUnsupportedVersion},
+		{"version 0", func(b []byte) []byte { b[2] = 0; return b }, ErrUnsupportedVersion},
+		{"header length below 36", func(b []byte) []byte { b[3] = PacketHeaderSize - 1; return b }, ErrInvalidHeaderLength},
+		{"header length beyond buffer", func(b []byte) []byte { b[3] = byte(len(b) + 1); return b }, ErrInvalidHeaderLength},
+		{"data length too large", func(b []byte) []byte { b[29]++; return b }, ErrInvalidDataLength},
+		{"data length too small", func(b []byte) []byte { b[29]--; return b }, ErrInvalidDataLength},
+		{"trailing byte", func(b []byte) []byte { return append(b, 0) }, ErrInvalidDataLength},
+		{"crc bit flipped", func(b []byte) []byte { b[35] ^= 0x01; return b }, ErrChecksumMismatch},
+		{"header bit flipped", func(b []byte) []byte { b[12] ^= 0x80; return b }, ErrChecksumMismatch},
+		{"payload bit flipped", func(b []byte) []byte { b[len(b)-1] ^= 0x01; return b }, ErrChecksumMismatch},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := DeserializePacket(tc.mutate(valid(t)))
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+			if p != nil {
+				t.Fatalf("got packet %+v alongside error", *p)
+			}
+		})
+	}
+}
+
+func FuzzDeserializePacket(f *testing.F) {
+	for _, tc := range goldenPackets {
+		f.Add(mustHex(f, tc.wire))
+	}
+	f.Add([]byte{})
+	f.Add([]byte{0x4e, 0x53, 0x02})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := DeserializePacket(data)
+		if err != nil {
+			return
+		}
+
+		out := p.Serialize()
+		q, err := DeserializePacket(out)
+		if err != nil {
+			t.Fatalf("re-decoding serialized packet: %v", err)
+		}
+		if q.Type != p.Type || q.Flags != p.Flags || q.Window != p.Window ||
+			q.ConnID != p.ConnID || q.SeqNum != p.SeqNum || q.AckNum != p.AckNum ||
+			q.Timestamp != p.Timestamp || !bytes.Equal(q.Data, p.Data) {
+			t.Fatalf("round trip changed packet: %+v -> %+v", *p, *q)
+		}
+		if int(data[3]) == PacketHeaderSize && data[30] == 0 && data[31] == 0 && !bytes.Equal(out, data) {
+			t.Fatalf("re-encoding a canonical frame changed it:\n got %x\nwant %x", out, data)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package domain
+
+import "sort"
+
+type SeqRange struct {
+	Start uint32
+	End   uint32
+}
+
+type ReceivedRanges struct {
+	ranges     []SeqRange
+	count      uint32
+	Duplicates uint32
+}
+
+func NewReceivedRanges() *ReceivedRanges {
+	return &ReceivedRanges{}
+}
+
+func (r *ReceivedRanges) Contains(seq uint32) bool {
+	i := sort.Search(len(r.ranges), func(i int) bool { return r.ranges[i].End > seq })
+	return i < len(r.ranges) && r.ranges[i].Start <= seq
+}
+
+func (r *ReceivedRanges) Add(seq uint32) bool {
+	i := sort.Search(len(r.ranges), func(i int) bool { return r.ranges[i].End >= seq })
+	if i < len(r.ranges) && r.ranges[i].Start <= seq && seq < r.ranges[i].End {
+		r.Duplicates++
+		return false
+	}
+
+	r.count++
+
+	joinsPrev := i < len(r.ranges) && r.ranges[i].End == seq
+	joinsNext := false
+	next := i
+	if joinsPrev {
+		next = i + 1
+	}
+	if next < len(r.ranges) && r.ranges[next].Start == seq+1 {
+		joinsNext = true
+	}
+
+	switch {
+	case joinsPrev && joinsNext:
+		r.ranges[i].End = r.ranges[next].End
+		r.ranges = append(r.ranges[:next], r.ranges[next+1:]...)
+	case joinsPrev:
+		r.ranges[i].End = seq + 1
+	case joinsNext:
+		r.ranges[next].Start = seq
+	default:
+		r.ranges = append(r.ranges, SeqRange{})
+		copy(r.ranges[i+1:], r.ranges[i:])
+		r.ranges[i] = SeqRange{Start: seq, End: seq + 1}
+	}
+
+	return true
+}
+
+func (r *ReceivedRanges) Count() uint32 {
+	return r.count
+}
+
+func (r *ReceivedRanges) Contiguous() uint32 {
+	if len(r.ranges) == 0 || r.ranges[0].Start != 0 {
+		return 0
+	}
+	return r.ranges[0].End
+}
+
+func (r *ReceivedRanges) Ranges() []SeqRange {
+	return append([]SeqRange(nil), r.ranges...)
+}
+
+func (r *ReceivedRanges) Missing(limit uint32) []SeqRange {
+	var missing []SeqRange
+	next := uint32(0)
+	for _, rng := range r.ranges {
+		if rng.Start >= limit {
+			break
+		}
+		if rng.Start > next {
+			missing = append(missing, SeqRange{Start: next, End: rng.Start})
+		}
+		next = rng.End
+	}
+	if next < limit {
+		missing = append(missing, SeqRange{Start: next, End: limit})
+	}
+	return missing
+}
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReceivedRanges(t *testing.T) {
+	tests := []struct {
+		name       string
+		add        []uint32
+		ranges     []SeqRange
+		count      uint32
+		duplicates uint32
+		contiguous uint32
+		missing    []SeqRange // up to seq 10
+	}{
+		{
+			name:    "empty",
+			missing: []SeqRange{{0, 10}},
+		},
+		{
+			name:       "in order",
+			add:        []uint32{0, 1, 2},
+			ranges:     []SeqRange{{0, 3}},
+			count:      3,
+			contiguous: 3,
+			missing:    []SeqRange{{3, 10}},
+		},
+		{
+			name:    "gaps",
+			add:     []uint32{2, 5, 7},
+			ranges:  []SeqRange{{2, 3}, {5, 6}, {7, 8}},
+			count:   3,
+			missing: []SeqRange{{0, 2}, {3, 5}, {6, 7}, {8, 10}},
+		},
+		{
+			name:    "adjacent to the previous range",
+			add:     []uint32{3, 4},
+			ranges:  []SeqRange{{3, 5}},
+			count:   2,
+			missing: []SeqRange{{0, 3}, {5, 10}},
+		},
+		{
+			name:    "adjacent to the next range",
+			add:     []uint32{4, 3},
+			ranges:  []SeqRange{{3, 5}},
+			count:   2,
+			missing: []SeqRange{{0, 3}, {5, 10}},
+		},
+		{
+			name:       "fills the gap between two ranges",
+			add:        []uint32{0, 1, 3, 4, 2},
+			ranges:     []SeqRange{{0, 5}},
+			count:      5,
+			contiguous: 5,
+			missing:    []SeqRange{{5, 10}},
+		},
+		{
+			name:       "duplicates",
+			add:        []uint32{1, 1, 0, 1, 0},
+			ranges:     []SeqRange{{0, 2}},
+			count:      2,
+			duplicates: 3,
+			contiguous: 2,
+			missing:    []SeqRange{{2, 10}},
+		},
+		{
+			name:       "duplicates inside a merged range",
+			add:        []uint32{6, 8, 7, 6, 7, 8},
+			ranges:     []SeqRange{{6, 9}},
+			count:      3,
+			duplicates: 3,
+			missing:    []SeqRange{{0, 6}, {9, 10}},
+		},
+		{
+			name:    "reverse order",
+			add:     []uint32{9, 8, 7, 6},
+			ranges:  []SeqRange{{6, 10}},
+			count:   4,
+			missing: []SeqRange{{0, 6}},
+		},
+		{
+			name:       "beyond the missing limit",
+			add:        []uint32{0, 12, 13},
+			ranges:     []SeqRange{{0, 1}, {12, 14}},
+			count:      3,
+			contiguous: 1,
+			missing:    []SeqRange{{1, 10}},
+		},
+		{
+			name:       "complete",
+			add:        []uint32{5, 0, 9, 1, 8, 2, 7, 3, 6, 4},
+			ranges:     []SeqRange{{0, 10}},
+			count:      10,
+			contiguous: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReceivedRanges()
+			for _, seq := range tt.add {
+				r.Add(seq)
+				if !r.Contains(seq) {
+					t.Fatalf("Contains(%d) = false right after Add", seq)
+				}
+			}
+
+			if got := r.Ranges(); !reflect.DeepEqual(got, tt.ranges) {
+				t.Errorf("Ranges() = %v, want %v", got, tt.ranges)
+			}
+			if r.Count() != tt.count || r.Duplicates != tt.duplicates {
+				t.Errorf("Count() = %d, Duplicates = %d, want %d and %d", r.Count(), r.Duplicates, tt.count, tt.duplicates)
+			}
+			if got := r.Contiguous(); got != tt.contiguous {
+				t.Errorf("Contiguous() = %d, want %d", got, tt.contiguous)
+			}
+			if got := r.Missing(10); !reflect.DeepEqual(got, tt.missing) {
+				t.Errorf("Missing(10) = %v, want %v", got, tt.missing)
+			}
+		})
+	}
+}
+
+func TestReceivedRangesAddReportsNewSeqs(t *testing.T) {
+	r := NewReceivedRanges()
+	if !r.Add(4) || r.Add(4) || !r.Add(5) || r.Add(4) {
+		t.Fatalf("Add must be true only for sequence numbers not seen before")
+	}
+	if r.Contains(3) || r.Contains(6) {
+		t.Fatalf("Contains reports sequence numbers next to a range")
+	}
+}
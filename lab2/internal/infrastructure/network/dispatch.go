@@ -0,0 +1,97 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	peerQueueSize = 512
+	maxPeers      = 4096
+)
+
+type serverPeer struct {
+	connID  uint32
+	packets chan receivedPacket
+}
+
+func (s *UDPServer) dispatch(ctx context.Context, packet *domain.Packet, addr *net.UDPAddr) {
+	s.peersMu.Lock()
+	defer s.peersMu.Unlock()
+
+	peer, exists := s.peers[packet.ConnID]
+	if !exists {
+		if len(s.peers) >= maxPeers {
+			s.dropped++
+			return
+		}
+
+		peer = &serverPeer{
+			connID:  packet.ConnID,
+			packets: make(chan receivedPacket, peerQueueSize),
+		}
+		s.peers[packet.ConnID] = peer
+		go s.servePeer(ctx, peer)
+	}
+
+	select {
+	case peer.packets <- receivedPacket{packet: packet, addr: addr}:
+	default:
+		s.dropped++
+	}
+}
+
+func (s *UDPServer) servePeer(ctx context.Context, peer *serverPeer) {
+	idle := time.NewTimer(s.config.SessionTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-peer.packets:
+			s.handlePacket(ctx, p.packet, p.addr)
+			idle.Reset(s.config.SessionTimeout)
+		case <-idle.C:
+			if s.retirePeer(peer) {
+				return
+			}
+			idle.Reset(s.config.SessionTimeout)
+		}
+	}
+}
+
+func (s *UDPServer) retirePeer(peer *serverPeer) bool {
+	s.peersMu.Lock()
+	if len(peer.packets) > 0 {
+		s.peersMu.Unlock()
+		return false
+	}
+	delete(s.peers, peer.connID)
+	s.peersMu.Unlock()
+
+	s.sessionsMu.RLock()
+	session := s.sessions[peer.connID]
+	s.sessionsMu.RUnlock()
+	if session != nil {
+		s.removeSession(session)
+	}
+
+	s.relMgr.ForgetPeer(peer.connID)
+	s.connMgr.RemoveClient(peer.connID)
+	return true
+}
+
+func (s *UDPServer) reportDropped() {
+	s.peersMu.Lock()
+	dropped := s.dropped
+	s.dropped = 0
+	s.peersMu.Unlock()
+
+	if dropped > 0 {
+		fmt.Printf("Dispatch: dropped %d packets (peer queue full or more than %d peers)\n", dropped, maxPeers)
+	}
+}
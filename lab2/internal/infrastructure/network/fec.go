@@ -0,0 +1,390 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	maxFECSymbols = 256
+
+	// fecLengthPrefix is the length each symbol carries in front of the packet
+	// data, so parity packets are that much larger than the data they protect.
+	fecLengthPrefix = 2
+)
+
+var (
+	gfExp [512]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+func gfMulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+	if c == 1 {
+		for i, b := range src {
+			dst[i] ^= b
+		}
+		return
+	}
+
+	logC := int(gfLog[c])
+	for i, b := range src {
+		if b != 0 {
+			dst[i] ^= gfExp[int(gfLog[b])+logC]
+		}
+	}
+}
+
+func fecCoefficient(row, index int) byte {
+	return gfDiv(byte(255^index), byte(255-row)^byte(index))
+}
+
+func validateFEC(blockSize, parity int) error {
+	if blockSize < 0 || parity < 0 {
+		return fmt.Errorf("invalid FEC parameters: block %d, parity %d", blockSize, parity)
+	}
+	if blockSize > 0 && (parity == 0 || blockSize+parity > maxFECSymbols) {
+		return fmt.Errorf("invalid FEC parameters: block %d, parity %d (block+parity must be at most %d)",
+			blockSize, parity, maxFECSymbols)
+	}
+	return nil
+}
+
+func fecSymbol(data []byte) []byte {
+	symbol := make([]byte, fecLengthPrefix+len(data))
+	binary.BigEndian.PutUint16(symbol, uint16(len(data)))
+	copy(symbol[fecLengthPrefix:], data)
+	return symbol
+}
+
+type fecEncoder struct {
+	blockSize  int
+	parity     int
+	connID     uint32
+	start      uint32
+	symbols    [][]byte
+	paritySent uint32
+}
+
+func newFECEncoder(blockSize, parity int, connID uint32) *fecEncoder {
+	if blockSize <= 0 || parity <= 0 {
+		return nil
+	}
+
+	return &fecEncoder{
+		blockSize: blockSize,
+		parity:    parity,
+		connID:    connID,
+		symbols:   make([][]byte, 0, blockSize),
+	}
+}
+
+func (e *fecEncoder) Add(packet *domain.Packet) []*domain.Packet {
+	if e == nil {
+		return nil
+	}
+
+	if len(e.symbols) == 0 {
+		e.start = packet.SeqNum
+	}
+	e.symbols = append(e.symbols, fecSymbol(packet.Data))
+
+	if len(e.symbols) < e.blockSize {
+		return nil
+	}
+	return e.Flush()
+}
+
+func (e *fecEncoder) Flush() []*domain.Packet {
+	if e == nil || len(e.symbols) == 0 {
+		return nil
+	}
+
+	size := 0
+	for _, symbol := range e.symbols {
+		size = max(size, len(symbol))
+	}
+
+	packets := make([]*domain.Packet, 0, e.parity)
+	for row := 0; row < e.parity; row++ {
+		data := make([]byte, size)
+		for index, symbol := range e.symbols {
+			gfMulAdd(data, symbol, fecCoefficient(row, index))
+		}
+
+		packet := domain.NewPacket(domain.PacketTypeParity, e.start, data)
+		packet.AckNum = uint32(len(e.symbols))
+		packet.Flags = uint8(row)
+		packet.ConnID = e.connID
+		packets = append(packets, packet)
+	}
+
+	e.paritySent += uint32(len(packets))
+	e.symbols = e.symbols[:0]
+
+	return packets
+}
+
+func (e *fecEncoder) ParitySent() uint32 {
+	if e == nil {
+		return 0
+	}
+	return e.paritySent
+}
+
+type fecBlock struct {
+	count  int
+	data   map[int][]byte
+	parity map[int][]byte
+}
+
+type fecDecoder struct {
+	blockSize int
+	parity    int
+	total     uint32
+	blocks    map[uint32]*fecBlock
+	complete  map[uint32]bool
+	recovered uint32
+}
+
+func newFECDecoder(blockSize, parity int, totalPackets uint32) *fecDecoder {
+	if blockSize <= 0 || parity <= 0 {
+		return nil
+	}
+
+	return &fecDecoder{
+		blockSize: blockSize,
+		parity:    parity,
+		total:     totalPackets,
+		blocks:    make(map[uint32]*fecBlock),
+		complete:  make(map[uint32]bool),
+	}
+}
+
+func (d *fecDecoder) block(start uint32) *fecBlock {
+	if d.complete[start] || start >= d.total {
+		return nil
+	}
+
+	block, exists := d.blocks[start]
+	if !exists {
+		block = &fecBlock{
+			count:  int(min(d.total-start, uint32(d.blockSize))),
+			data:   make(map[int][]byte),
+			parity: make(map[int][]byte),
+		}
+		d.blocks[start] = block
+	}
+	return block
+}
+
+func (d *fecDecoder) AddData(packet *domain.Packet) []*domain.Packet {
+	if d == nil {
+		return nil
+	}
+
+	start := packet.SeqNum - packet.SeqNum%uint32(d.blockSize)
+	block := d.block(start)
+	if block == nil {
+		return nil
+	}
+
+	index := int(packet.SeqNum - start)
+	if _, exists := block.data[index]; !exists {
+		block.data[index] = fecSymbol(packet.Data)
+	}
+
+	return d.recover(start, block)
+}
+
+func (d *fecDecoder) AddParity(packet *domain.Packet) []*domain.Packet {
+	if d == nil || packet.SeqNum%uint32(d.blockSize) != 0 || int(packet.Flags) >= d.parity {
+		return nil
+	}
+
+	block := d.block(packet.SeqNum)
+	if block == nil || int(packet.AckNum) != block.count {
+		return nil
+	}
+
+	if _, exists := block.parity[int(packet.Flags)]; !exists {
+		block.parity[int(packet.Flags)] = packet.Data
+	}
+
+	return d.recover(packet.SeqNum, block)
+}
+
+func (d *fecDecoder) recover(start uint32, block *fecBlock) []*domain.Packet {
+	if len(block.data) == block.count {
+		d.finish(start)
+		return nil
+	}
+
+	missing := make([]int, 0, block.count-len(block.data))
+	for index := 0; index < block.count; index++ {
+		if _, exists := block.data[index]; !exists {
+			missing = append(missing, index)
+		}
+	}
+
+	if len(block.parity) < len(missing) {
+		return nil
+	}
+
+	rows := make([]int, 0, len(missing))
+	size := -1
+	for row := 0; row < d.parity && len(rows) < len(missing); row++ {
+		data, exists := block.parity[row]
+		if !exists {
+			continue
+		}
+		if size >= 0 && len(data) != size {
+			return nil
+		}
+		size = len(data)
+		rows = append(rows, row)
+	}
+
+	syndromes := make([][]byte, len(rows))
+	for r, row := range rows {
+		syndrome := make([]byte, size)
+		copy(syndrome, block.parity[row])
+		for index, symbol := range block.data {
+			if len(symbol) > size {
+				return nil
+			}
+			gfMulAdd(syndrome, symbol, fecCoefficient(row, index))
+		}
+		syndromes[r] = syndrome
+	}
+
+	matrix := make([][]byte, len(rows))
+	for r, row := range rows {
+		matrix[r] = make([]byte, len(missing))
+		for c, index := range missing {
+			matrix[r][c] = fecCoefficient(row, index)
+		}
+	}
+
+	inverse, ok := gfInvert(matrix)
+	if !ok {
+		return nil
+	}
+
+	packets := make([]*domain.Packet, 0, len(missing))
+	for c, index := range missing {
+		symbol := make([]byte, size)
+		for r := range rows {
+			gfMulAdd(symbol, syndromes[r], inverse[c][r])
+		}
+
+		length := int(binary.BigEndian.Uint16(symbol))
+		if length > size-2 {
+			return nil
+		}
+
+		packet := domain.NewPacket(domain.PacketTypeData, start+uint32(index), symbol[2:2+length])
+		packets = append(packets, packet)
+	}
+
+	d.recovered += uint32(len(packets))
+	d.finish(start)
+
+	return packets
+}
+
+func (d *fecDecoder) finish(start uint32) {
+	delete(d.blocks, start)
+	d.complete[start] = true
+}
+
+func (d *fecDecoder) Recovered() uint32 {
+	if d == nil {
+		return 0
+	}
+	return d.recovered
+}
+
+func gfInvert(matrix [][]byte) ([][]byte, bool) {
+	n := len(matrix)
+	work := make([][]byte, n)
+	for i := range matrix {
+		work[i] = make([]byte, 2*n)
+		copy(work[i], matrix[i])
+		work[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for row := col; row < n; row++ {
+			if work[row][col] != 0 {
+				pivot = row
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, false
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+
+		scale := gfInv(work[col][col])
+		for i := range work[col] {
+			work[col][i] = gfMul(work[col][i], scale)
+		}
+
+		for row := 0; row < n; row++ {
+			if row != col && work[row][col] != 0 {
+				gfMulAdd(work[row], work[col], work[row][col])
+			}
+		}
+	}
+
+	inverse := make([][]byte, n)
+	for i := range work {
+		inverse[i] = work[i][n:]
+	}
+	return inverse, true
+}
+
+func packetCount(fileSize int64, payloadSize int) uint32 {
+	if payloadSize <= 0 {
+		return 0
+	}
+	return uint32((fileSize + int64(payloadSize) - 1) / int64(payloadSize))
+}
@@ -0,0 +1,129 @@
+package network_test
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFECPayloadLeavesRoomForLengthPrefix(t *testing.T) {
+	cfg := newLoopbackConfig(t)
+	addr := startLoopbackServer(t, cfg)
+	client := dialLoopback(t, cfg, addr)
+
+	for _, tt := range []struct {
+		payload int
+		fec     string
+		ok      bool
+	}{
+		{domain.MaxPayloadSize, "0 0", true},
+		{domain.MaxPayloadSize, "4 2", false},
+		{domain.MaxPayloadSize - 2, "4 2", true},
+	} {
+		args := strings.Fields("fec_probe.bin 1 " + strconv.Itoa(tt.payload) + " " + tt.fec)
+		response, err := client.SendCommand("UPLOAD", args)
+		if err != nil {
+			t.Fatalf("UPLOAD %v: %v", args, err)
+		}
+		if ok := strings.HasPrefix(response, "READY"); ok != tt.ok {
+			t.Fatalf("UPLOAD %v answered %q", args, response)
+		}
+	}
+
+	// The client's default payload must shrink too, or parity packets for a
+	// full-size payload exceed the largest UDP datagram.
+	fecCfg := *cfg
+	fecCfg.UDP.BufferSizes = []int{domain.MaxPayloadSize}
+	fecCfg.UDP.FECBlockSize = 4
+	fecCfg.UDP.FECParity = 2
+	fecClient := dialLoopback(t, &fecCfg, addr)
+
+	source := filepath.Join(t.TempDir(), "source.bin")
+	writeRandomFile(t, source, 16*domain.MaxPayloadSize/3, 29)
+	if _, err := fecClient.UploadFile(source, "fec.bin"); err != nil {
+		t.Fatalf("upload with FEC: %v", err)
+	}
+	if err := sameContent(source, filepath.Join(cfg.Server.UploadDir, "fec.bin")); err != nil {
+		t.Fatalf("uploaded copy: %v", err)
+	}
+}
+
+// blockDropper loses the first transmission of one DATA packet in every FEC
+// block and counts any later copies of the lost packets.
+type blockDropper struct {
+	mu      sync.Mutex
+	block   uint32
+	dropped map[uint32]bool
+	resent  map[uint32]int
+}
+
+func newBlockDropper(block int) *blockDropper {
+	return &blockDropper{block: uint32(block), dropped: make(map[uint32]bool), resent: make(map[uint32]int)}
+}
+
+func (d *blockDropper) rewrite(datagram []byte) [][]byte {
+	packet, err := domain.DeserializePacket(datagram)
+	if err != nil || packet.Type != domain.PacketTypeData || packet.SeqNum%d.block != d.block/2 {
+		return [][]byte{datagram}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.dropped[packet.SeqNum] {
+		d.dropped[packet.SeqNum] = true
+		return nil
+	}
+	d.resent[packet.SeqNum]++
+	return [][]byte{datagram}
+}
+
+func (d *blockDropper) check(t *testing.T, direction string, recovered uint32) {
+	t.Helper()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.dropped) == 0 {
+		t.Fatalf("%s: relay dropped no DATA packets", direction)
+	}
+	if int(recovered) != len(d.dropped) {
+		t.Fatalf("%s: FEC recovered %d packets, relay dropped %d", direction, recovered, len(d.dropped))
+	}
+	if len(d.resent) > 0 {
+		t.Fatalf("%s: packets rebuilt by FEC were retransmitted: %v", direction, d.resent)
+	}
+}
+
+func TestFECRecoversLostPackets(t *testing.T) {
+	cfg := newLoopbackConfig(t)
+	cfg.UDP.FECBlockSize = 8
+	cfg.UDP.FECParity = 2
+	addr := startLoopbackServer(t, cfg)
+
+	upload, download := newBlockDropper(cfg.UDP.FECBlockSize), newBlockDropper(cfg.UDP.FECBlockSize)
+	client := dialLoopback(t, cfg, startRelay(t, addr, upload.rewrite, download.rewrite))
+
+	source := filepath.Join(t.TempDir(), "source.bin")
+	writeRandomFile(t, source, 100*cfg.UDP.BufferSizes[len(cfg.UDP.BufferSizes)/2]+123, 29)
+
+	progress, err := client.UploadFile(source, "fec.bin")
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if err := sameContent(source, filepath.Join(cfg.Server.UploadDir, "fec.bin")); err != nil {
+		t.Fatalf("uploaded copy: %v", err)
+	}
+	upload.check(t, "upload", progress.FECRecovered)
+
+	local := filepath.Join(t.TempDir(), "fec.bin")
+	progress, err = client.DownloadFile("fec.bin", local)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if err := sameContent(source, local); err != nil {
+		t.Fatalf("downloaded copy: %v", err)
+	}
+	download.check(t, "download", progress.FECRecovered)
+}
@@ -0,0 +1,291 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+var ErrPeerDead = errors.New("server stopped responding")
+
+type cachedResponse struct {
+	seq      uint32
+	response []byte
+	lastUsed time.Time
+}
+
+// partialUpload is an interrupted upload, kept under the resume token that
+// the server handed to its client in READY.
+type partialUpload struct {
+	filename string
+	size     int64
+	offset   int64
+	savedAt  time.Time
+}
+
+func newResumeToken() string {
+	token := make([]byte, 16)
+	rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+func newPong(ping *domain.Packet) *domain.Packet {
+	pong := domain.NewPacket(domain.PacketTypePong, ping.SeqNum, nil)
+	pong.ConnID = ping.ConnID
+	pong.Timestamp = ping.Timestamp
+	return pong
+}
+
+func (c *UDPClient) resetPeer() {
+	c.lastHeard = time.Now()
+	c.lastPing = time.Time{}
+	c.pingsMissed = 0
+}
+
+func (c *UDPClient) heard(packet *domain.Packet) bool {
+	c.lastHeard = time.Now()
+	c.pingsMissed = 0
+
+	switch packet.Type {
+	case domain.PacketTypePing:
+		c.relMgr.SendPacket(newPong(packet), c.serverAddr)
+		return true
+	case domain.PacketTypePong:
+		return true
+	}
+	return false
+}
+
+func (c *UDPClient) checkPeer() error {
+	interval := c.udpConfig.HeartbeatInterval
+	if interval <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Sub(c.lastHeard) < interval || now.Sub(c.lastPing) < interval {
+		return nil
+	}
+
+	if c.pingsMissed >= max(c.udpConfig.HeartbeatMisses, 1) {
+		return fmt.Errorf("%w: %d heartbeats unanswered, silent for %v",
+			ErrPeerDead, c.pingsMissed, now.Sub(c.lastHeard).Round(time.Millisecond))
+	}
+
+	c.pingsMissed++
+	c.lastPing = now
+
+	ping := domain.NewPacket(domain.PacketTypePing, uint32(c.pingsMissed), nil)
+	if err := c.relMgr.SendPacket(ping, c.serverAddr); err != nil {
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	return nil
+}
+
+func (c *UDPClient) reconnect() error {
+	addr := c.serverAddr.String()
+	c.Disconnect()
+	return c.Connect(context.Background(), addr)
+}
+
+func (c *UDPClient) resumeAfterPeerDeath(progress *domain.TransferProgress, err error,
+	resume func() (*domain.TransferProgress, error)) (*domain.TransferProgress, error) {
+
+	if !c.udpConfig.AutoReconnect {
+		return progress, err
+	}
+
+	for attempt := 1; errors.Is(err, ErrPeerDead) && attempt <= c.udpConfig.ReconnectAttempts; attempt++ {
+		fmt.Printf("%v, reconnecting (attempt %d of %d)\n", err, attempt, c.udpConfig.ReconnectAttempts)
+		time.Sleep(c.udpConfig.HeartbeatInterval)
+
+		if rerr := c.reconnect(); rerr != nil {
+			err = fmt.Errorf("%w: reconnect failed: %v", ErrPeerDead, rerr)
+			continue
+		}
+
+		progress, err = resume()
+	}
+
+	return progress, err
+}
+
+func (ss *serverSession) heard() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.lastHeard = time.Now()
+	ss.missed = 0
+}
+
+func (s *UDPServer) heartbeatRoutine(ctx context.Context) {
+	if s.udpConfig.HeartbeatInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.udpConfig.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkHeartbeats()
+		}
+	}
+}
+
+func (s *UDPServer) checkHeartbeats() {
+	s.sessionsMu.RLock()
+	sessions := make([]*serverSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.sessionsMu.RUnlock()
+
+	now := time.Now()
+	for _, session := range sessions {
+		session.mu.Lock()
+		idle := now.Sub(session.lastHeard) >= s.udpConfig.HeartbeatInterval
+		dead := idle && session.missed >= max(s.udpConfig.HeartbeatMisses, 1)
+		if idle && !dead {
+			session.missed++
+		}
+		missed := session.missed
+		session.mu.Unlock()
+
+		switch {
+		case dead:
+			fmt.Printf("Client %s (%08x) missed %d heartbeats, closing %s\n",
+				session.addr, session.connID, missed, session.info.FileName)
+			s.abortSession(session)
+		case idle:
+			ping := domain.NewPacket(domain.PacketTypePing, uint32(missed), nil)
+			ping.ConnID = session.connID
+			s.relMgr.SendPacket(ping, session.addr)
+		}
+	}
+}
+
+func (s *UDPServer) abortSession(session *serverSession) {
+	s.removeSession(session)
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if !session.info.IsUpload {
+		return
+	}
+
+	offset := session.info.Offset + int64(session.received.Contiguous())*int64(session.info.BufferSize)
+
+	s.partialsMu.Lock()
+	s.partials[session.info.ResumeToken] = partialUpload{
+		filename: session.info.FileName,
+		size:     session.info.FileSize,
+		offset:   min(offset, session.info.FileSize),
+		savedAt:  time.Now(),
+	}
+	s.partialsMu.Unlock()
+}
+
+// resumeOffset returns where an upload resumed with token continues, or 0 if
+// the token does not belong to an interrupted upload of this file and size.
+func (s *UDPServer) resumeOffset(token, filename string, fileSize int64) int64 {
+	s.sessionsMu.RLock()
+	var stale []*serverSession
+	for _, session := range s.sessions {
+		if session.info.IsUpload && session.info.ResumeToken == token {
+			stale = append(stale, session)
+		}
+	}
+	s.sessionsMu.RUnlock()
+
+	for _, session := range stale {
+		fmt.Printf("Upload of %s resumed elsewhere, closing session from %s\n", session.info.FileName, session.addr)
+		s.abortSession(session)
+	}
+
+	s.partialsMu.Lock()
+	defer s.partialsMu.Unlock()
+
+	partial, ok := s.partials[token]
+	if !ok || partial.filename != filename || partial.size != fileSize {
+		return 0
+	}
+	delete(s.partials, token)
+	return partial.offset
+}
+
+// forgetPartial drops interrupted uploads of filename once the file is
+// rewritten from the start.
+func (s *UDPServer) forgetPartial(filename string) {
+	s.partialsMu.Lock()
+	defer s.partialsMu.Unlock()
+
+	for token, partial := range s.partials {
+		if partial.filename == filename {
+			delete(s.partials, token)
+		}
+	}
+}
+
+func (s *UDPServer) replayResponse(packet *domain.Packet, clientAddr *net.UDPAddr) bool {
+	s.responsesMu.Lock()
+	entry, ok := s.responses[packet.ConnID]
+	if !ok || entry.seq != packet.SeqNum {
+		s.responses[packet.ConnID] = &cachedResponse{seq: packet.SeqNum, lastUsed: time.Now()}
+		s.responsesMu.Unlock()
+		return false
+	}
+	entry.lastUsed = time.Now()
+	response := entry.response
+	s.responsesMu.Unlock()
+
+	if response != nil {
+		s.sendResponse(packet, clientAddr, response)
+	}
+	return true
+}
+
+func (s *UDPServer) respond(packet *domain.Packet, clientAddr *net.UDPAddr, response string) error {
+	s.responsesMu.Lock()
+	if entry, ok := s.responses[packet.ConnID]; ok && entry.seq == packet.SeqNum {
+		entry.response = []byte(response)
+	}
+	s.responsesMu.Unlock()
+
+	return s.sendResponse(packet, clientAddr, []byte(response))
+}
+
+func (s *UDPServer) sendResponse(packet *domain.Packet, clientAddr *net.UDPAddr, response []byte) error {
+	responsePacket := domain.NewPacket(domain.PacketTypeResponse, packet.SeqNum+1, response)
+	responsePacket.ConnID = packet.ConnID
+	return s.relMgr.SendPacket(responsePacket, clientAddr)
+}
+
+func (s *UDPServer) expireHeartbeatState() {
+	now := time.Now()
+
+	s.responsesMu.Lock()
+	for connID, entry := range s.responses {
+		if now.Sub(entry.lastUsed) > s.config.SessionTimeout {
+			delete(s.responses, connID)
+		}
+	}
+	s.responsesMu.Unlock()
+
+	s.partialsMu.Lock()
+	for token, partial := range s.partials {
+		if now.Sub(partial.savedAt) > s.config.SessionTimeout {
+			delete(s.partials, token)
+		}
+	}
+	s.partialsMu.Unlock()
+}
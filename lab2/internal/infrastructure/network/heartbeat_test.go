@@ -0,0 +1,146 @@
+package network_test
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"NSSaDS/lab2/internal/infrastructure/network"
+	"NSSaDS/lab2/pkg/config"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blackhole drops everything the client sends from its cutoff-th DATA packet
+// on, until the client sends its next command unless forever is set, and
+// records the READY answers.
+type blackhole struct {
+	mu      sync.Mutex
+	cutoff  int
+	forever bool
+	data    int
+	active  bool
+	done    bool
+	ready   [][]string
+}
+
+func (b *blackhole) toServer(datagram []byte) [][]byte {
+	packet, err := domain.DeserializePacket(datagram)
+	if err != nil {
+		return [][]byte{datagram}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.active && packet.Type == domain.PacketTypeCommand && !b.forever:
+		b.active, b.done = false, true
+	case b.active:
+		return nil
+	case packet.Type == domain.PacketTypeData && !b.done:
+		if b.data++; b.data == b.cutoff {
+			b.active = true
+			return nil
+		}
+	}
+	return [][]byte{datagram}
+}
+
+func (b *blackhole) toClient(datagram []byte) [][]byte {
+	packet, err := domain.DeserializePacket(datagram)
+	if err == nil && packet.Type == domain.PacketTypeResponse && strings.HasPrefix(string(packet.Data), "READY") {
+		b.mu.Lock()
+		b.ready = append(b.ready, strings.Fields(string(packet.Data)))
+		b.mu.Unlock()
+	}
+	return [][]byte{datagram}
+}
+
+func (b *blackhole) readies() [][]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([][]string(nil), b.ready...)
+}
+
+func newHeartbeatConfig(t *testing.T) *config.Config {
+	cfg := newLoopbackConfig(t)
+	cfg.UDP.HeartbeatInterval = 50 * time.Millisecond
+	cfg.UDP.HeartbeatMisses = 3
+	cfg.UDP.PMTUDiscovery = false
+	return cfg
+}
+
+func TestUploadResumesAfterHeartbeatTimeout(t *testing.T) {
+	cfg := newHeartbeatConfig(t)
+	cfg.UDP.AutoReconnect = true
+	addr := startLoopbackServer(t, cfg)
+
+	hole := &blackhole{cutoff: 40}
+	client := dialLoopback(t, cfg, startRelay(t, addr, hole.toServer, hole.toClient))
+
+	payload := cfg.UDP.BufferSizes[len(cfg.UDP.BufferSizes)/2]
+	source := filepath.Join(t.TempDir(), "source.bin")
+	writeRandomFile(t, source, 100*payload+7, 36)
+	if _, err := client.UploadFile(source, "resumed.bin"); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if err := sameContent(source, filepath.Join(cfg.Server.UploadDir, "resumed.bin")); err != nil {
+		t.Fatalf("uploaded copy: %v", err)
+	}
+
+	ready := hole.readies()
+	if len(ready) != 2 {
+		t.Fatalf("got %d READY answers, want the first upload and its resumption: %q", len(ready), ready)
+	}
+	offset, _ := strconv.Atoi(ready[1][6])
+	if offset <= 0 || offset >= 40*payload {
+		t.Fatalf("resumed at byte %d, want within the %d bytes sent before the blackhole", offset, 40*payload)
+	}
+	if ready[1][7] != ready[0][7] {
+		t.Fatalf("resumed upload got token %s, first upload %s", ready[1][7], ready[0][7])
+	}
+}
+
+func TestInterruptedUploadBelongsToItsClient(t *testing.T) {
+	cfg := newHeartbeatConfig(t)
+	cfg.Server.MaxSessions = 1
+	addr := startLoopbackServer(t, cfg)
+
+	hole := &blackhole{cutoff: 20, forever: true}
+	owner := dialLoopback(t, cfg, startRelay(t, addr, hole.toServer, hole.toClient))
+
+	source := filepath.Join(t.TempDir(), "source.bin")
+	size := 60 * cfg.UDP.BufferSizes[len(cfg.UDP.BufferSizes)/2]
+	writeRandomFile(t, source, size, 36)
+	if _, err := owner.UploadFile(source, "owned.bin"); !errors.Is(err, network.ErrPeerDead) {
+		t.Fatalf("upload into a blackhole: err = %v, want ErrPeerDead", err)
+	}
+	ready := hole.readies()
+	if len(ready) != 1 {
+		t.Fatalf("got %d READY answers, want 1", len(ready))
+	}
+	token := ready[0][7]
+
+	// The owner only gives up after the server stopped pinging it, so the
+	// server has timed the session out and freed its only slot.
+	other := dialLoopback(t, cfg, addr)
+	args := []string{"other.bin", strconv.Itoa(size), "4096", "0", "0", "RESUME", token}
+	response, err := other.SendCommand("UPLOAD", args)
+	if err != nil {
+		t.Fatalf("UPLOAD %v: %v", args, err)
+	}
+	if parts := strings.Fields(response); len(parts) != 8 || parts[6] != "0" || parts[7] == token {
+		t.Fatalf("token of owned.bin resumed other.bin: %q", response)
+	}
+
+	args = []string{"owned.bin", strconv.Itoa(size), "4096", "0", "0", "RESUME", token}
+	response, err = other.SendCommand("UPLOAD", args)
+	if err != nil {
+		t.Fatalf("UPLOAD %v: %v", args, err)
+	}
+	if parts := strings.Fields(response); len(parts) != 8 || parts[6] == "0" || parts[7] != token {
+		t.Fatalf("token holder could not resume owned.bin: %q", response)
+	}
+}
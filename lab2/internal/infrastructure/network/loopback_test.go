@@ -0,0 +1,212 @@
+package network_test
+
+import (
+	"NSSaDS/lab2/internal/infrastructure/network"
+	"NSSaDS/lab2/internal/infrastructure/repository"
+	"NSSaDS/lab2/internal/usecase"
+	"NSSaDS/lab2/pkg/config"
+	"NSSaDS/lab2/pkg/netem"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newLoopbackConfig returns the default configuration with the server storing
+// uploads in a directory removed when the test ends.
+func newLoopbackConfig(t *testing.T) *config.Config {
+	cfg := config.NewConfig()
+	cfg.Server.UploadDir = filepath.Join(t.TempDir(), "server")
+	return cfg
+}
+
+// startLoopbackServer runs a UDP server on a free loopback port until the test
+// ends and returns its address once it answers ECHO.
+func startLoopbackServer(t *testing.T, cfg *config.Config) string {
+	t.Helper()
+	_, addr := startServer(t, cfg)
+	return addr
+}
+
+func startServer(t *testing.T, cfg *config.Config) (*network.UDPServer, string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("picking a port: %v", err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+
+	fileMgr := repository.NewFileManager(cfg.Server.UploadDir)
+	server := network.NewUDPServer(&cfg.Server, &cfg.UDP, usecase.NewCommandHandler(), fileMgr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Start(ctx, addr)
+		fileMgr.Close()
+	}()
+	t.Cleanup(func() {
+		cancel()
+		server.Stop()
+		<-done
+	})
+
+	clientCfg := cfg.Client
+	clientCfg.Timeout = 200 * time.Millisecond
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		client := network.NewUDPClient(&clientCfg, &cfg.UDP, nil)
+		if err := client.Connect(ctx, addr); err != nil {
+			t.Fatalf("connecting to %s: %v", addr, err)
+		}
+		_, err := client.SendCommand("ECHO", []string{"ready"})
+		client.Disconnect()
+
+		if err == nil {
+			return server, addr
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("server stopped during startup: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server on %s not ready: %v", addr, err)
+		}
+	}
+}
+
+// startProxy puts an impairment proxy in front of addr until the test ends.
+func startProxy(t *testing.T, cfg netem.Config, addr string) *netem.UDPProxy {
+	t.Helper()
+	proxy := netem.NewUDPProxy(cfg)
+	if err := proxy.Start("127.0.0.1:0", addr); err != nil {
+		t.Fatalf("starting proxy: %v", err)
+	}
+	t.Cleanup(func() { proxy.Stop() })
+	return proxy
+}
+
+// startRelay forwards datagrams between one client and target. Datagrams from
+// the client go through toServer and replies through toClient, which return what
+// to send instead; nil forwards them unchanged.
+func startRelay(t *testing.T, target string, toServer, toClient func(datagram []byte) [][]byte) string {
+	t.Helper()
+
+	targetAddr, err := net.ResolveUDPAddr("udp", target)
+	if err != nil {
+		t.Fatalf("resolving %s: %v", target, err)
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("relay listen: %v", err)
+	}
+	upstream, err := net.DialUDP("udp", nil, targetAddr)
+	if err != nil {
+		t.Fatalf("relay dial: %v", err)
+	}
+	for _, c := range []*net.UDPConn{conn, upstream} {
+		c.SetReadBuffer(4 * 1024 * 1024)
+		c.SetWriteBuffer(4 * 1024 * 1024)
+	}
+
+	var mu sync.Mutex
+	var client *net.UDPAddr
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 65536)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			client = addr
+			mu.Unlock()
+			for _, out := range rewriteDatagram(toServer, buf[:n]) {
+				upstream.Write(out)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 65536)
+		for {
+			n, err := upstream.Read(buf)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			addr := client
+			mu.Unlock()
+			if addr == nil {
+				continue
+			}
+			for _, out := range rewriteDatagram(toClient, buf[:n]) {
+				conn.WriteToUDP(out, addr)
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+		upstream.Close()
+		wg.Wait()
+	})
+	return conn.LocalAddr().String()
+}
+
+func rewriteDatagram(rewrite func(datagram []byte) [][]byte, datagram []byte) [][]byte {
+	if rewrite == nil {
+		return [][]byte{datagram}
+	}
+	return rewrite(append([]byte(nil), datagram...))
+}
+
+func dialLoopback(t *testing.T, cfg *config.Config, addr string) *network.UDPClient {
+	t.Helper()
+	client := network.NewUDPClient(&cfg.Client, &cfg.UDP, nil)
+	if err := client.Connect(context.Background(), addr); err != nil {
+		t.Fatalf("connecting to %s: %v", addr, err)
+	}
+	t.Cleanup(func() { client.Disconnect() })
+	return client
+}
+
+func writeRandomFile(t *testing.T, path string, size int, seed byte) {
+	t.Helper()
+	data := make([]byte, size)
+	rand.NewChaCha8([32]byte{seed}).Read(data)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+}
+
+func sameContent(source, copy string) error {
+	expected, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	actual, err := os.ReadFile(copy)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(expected, actual) {
+		return fmt.Errorf("sha256 %x, want %x (%d of %d bytes)",
+			sha256.Sum256(actual), sha256.Sum256(expected), len(actual), len(expected))
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	multicastAnnounceInterval = time.Second
+	maxNackRanges             = 128
+)
+
+var (
+	ErrNoReceivers       = errors.New("no multicast receivers joined")
+	ErrSenderLost        = errors.New("multicast sender stopped responding")
+	ErrMulticastChecksum = errors.New("received file does not match the announced checksum")
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+type MulticastStats struct {
+	FileName         string
+	FileSize         int64
+	Duration         time.Duration
+	DataPackets      uint32
+	Nacks            uint32
+	MulticastRepairs uint32
+	UnicastRepairs   uint32
+	Duplicates       uint32
+	Receivers        int
+	Completed        int
+	Dropped          int
+}
+
+func (s *MulticastStats) Bitrate() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.FileSize) / 1024 / 1024 / s.Duration.Seconds()
+}
+
+type multicastAnnouncement struct {
+	name     string
+	size     int64
+	payload  int
+	checksum uint32
+}
+
+func (a multicastAnnouncement) String() string {
+	return fmt.Sprintf("%s %d %d %08x", a.name, a.size, a.payload, a.checksum)
+}
+
+func parseAnnouncement(data []byte) (multicastAnnouncement, error) {
+	parts := strings.Fields(string(data))
+	if len(parts) != 4 {
+		return multicastAnnouncement{}, fmt.Errorf("invalid announcement: %q", data)
+	}
+
+	size, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || size < 0 {
+		return multicastAnnouncement{}, fmt.Errorf("invalid file size: %s", parts[1])
+	}
+
+	payload, err := strconv.Atoi(parts[2])
+	if err != nil || payload <= 0 || payload > domain.MaxPayloadSize {
+		return multicastAnnouncement{}, fmt.Errorf("invalid payload size: %s", parts[2])
+	}
+
+	checksum, err := strconv.ParseUint(parts[3], 16, 32)
+	if err != nil {
+		return multicastAnnouncement{}, fmt.Errorf("invalid checksum: %s", parts[3])
+	}
+
+	return multicastAnnouncement{
+		name:     filepath.Base(parts[0]),
+		size:     size,
+		payload:  payload,
+		checksum: uint32(checksum),
+	}, nil
+}
+
+func encodeRanges(ranges []domain.SeqRange) []byte {
+	ranges = ranges[:min(len(ranges), maxNackRanges)]
+
+	data := make([]byte, 8*len(ranges))
+	for i, r := range ranges {
+		binary.BigEndian.PutUint32(data[8*i:], r.Start)
+		binary.BigEndian.PutUint32(data[8*i+4:], r.End)
+	}
+	return data
+}
+
+func decodeRanges(data []byte) []domain.SeqRange {
+	ranges := make([]domain.SeqRange, 0, len(data)/8)
+	for i := 0; i+8 <= len(data) && len(ranges) < maxNackRanges; i += 8 {
+		r := domain.SeqRange{
+			Start: binary.BigEndian.Uint32(data[i:]),
+			End:   binary.BigEndian.Uint32(data[i+4:]),
+		}
+		if r.Start < r.End {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+func fileChecksum(file *os.File, size int64) (uint32, error) {
+	hash := crc32.New(castagnoli)
+	if _, err := io.Copy(hash, io.NewSectionReader(file, 0, size)); err != nil {
+		return 0, err
+	}
+	return hash.Sum32(), nil
+}
+
+func multicastInterface(name string) (*net.Interface, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown multicast interface %s: %w", name, err)
+	}
+	return ifi, nil
+}
+
+func newControlPacket(packetType uint8, sessionID uint32, data []byte) *domain.Packet {
+	packet := domain.NewPacket(packetType, 0, data)
+	packet.ConnID = sessionID
+	return packet
+}
+
+func sendControl(conn *net.UDPConn, packet *domain.Packet, addr *net.UDPAddr) error {
+	_, err := conn.WriteToUDP(packet.Serialize(), addr)
+	return err
+}
+
+func readPackets(conn *net.UDPConn, unicast bool, packets chan<- receivedPacket) {
+	buf := make([]byte, datagramBufferSize)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		packet, err := domain.DeserializePacket(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		packets <- receivedPacket{packet: packet, addr: addr, unicast: unicast}
+	}
+}
+
+type receivedPacket struct {
+	packet  *domain.Packet
+	addr    *net.UDPAddr
+	unicast bool
+}
@@ -0,0 +1,289 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"NSSaDS/lab2/pkg/config"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type MulticastReceiver struct {
+	udpConfig *config.UDPConfig
+	group     *net.UDPAddr
+	mconn     *net.UDPConn
+	conn      *net.UDPConn
+	packets   chan receivedPacket
+	sessionID uint32
+	sender    *net.UDPAddr
+	info      multicastAnnouncement
+	file      *os.File
+	received  *domain.ReceivedRanges
+	total     uint32
+	highest   uint32
+	hasData   bool
+	endSeen   bool
+	joined    bool
+	complete  bool
+	doneSent  int
+	start     time.Time
+	lastHeard time.Time
+	lastData  time.Time
+	lastSent  time.Time
+	stats     MulticastStats
+}
+
+func NewMulticastReceiver(udpCfg *config.UDPConfig) (*MulticastReceiver, error) {
+	group, err := net.ResolveUDPAddr("udp4", udpCfg.MulticastGroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve multicast group: %w", err)
+	}
+
+	ifi, err := multicastInterface(udpCfg.MulticastInterface)
+	if err != nil {
+		return nil, err
+	}
+
+	mconn, err := net.ListenMulticastUDP("udp4", ifi, group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join multicast group %s: %w", group, err)
+	}
+	setSocketBuffers(mconn, udpCfg.SocketBufferSize)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		mconn.Close()
+		return nil, fmt.Errorf("failed to create control socket: %w", err)
+	}
+	setSocketBuffers(conn, udpCfg.SocketBufferSize)
+
+	return &MulticastReceiver{
+		udpConfig: udpCfg,
+		group:     group,
+		mconn:     mconn,
+		conn:      conn,
+		packets:   make(chan receivedPacket, 1024),
+	}, nil
+}
+
+func (r *MulticastReceiver) Close() error {
+	if r.file != nil {
+		r.file.Close()
+	}
+	r.mconn.Close()
+	return r.conn.Close()
+}
+
+func (r *MulticastReceiver) Receive(ctx context.Context, dir string) (*MulticastStats, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	go readPackets(r.mconn, false, r.packets)
+	go readPackets(r.conn, true, r.packets)
+
+	fmt.Printf("Joined multicast group %s, waiting for an announcement\n", r.group)
+
+	ticker := time.NewTicker(r.udpConfig.MulticastNackInterval)
+	defer ticker.Stop()
+
+	for {
+		var done bool
+		var err error
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case p := <-r.packets:
+			done, err = r.handle(p, dir)
+		case <-ticker.C:
+			done, err = r.tick()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return &r.stats, nil
+		}
+	}
+}
+
+func (r *MulticastReceiver) handle(p receivedPacket, dir string) (bool, error) {
+	if r.sender == nil {
+		if p.packet.Type != domain.PacketTypeFileInfo {
+			return false, nil
+		}
+		return false, r.adopt(p, dir)
+	}
+
+	if p.packet.ConnID != r.sessionID || p.addr.String() != r.sender.String() {
+		return false, nil
+	}
+	r.lastHeard = time.Now()
+
+	switch p.packet.Type {
+	case domain.PacketTypeAck:
+		switch string(p.packet.Data) {
+		case "JOINED":
+			r.joined = true
+		case "DONE":
+			return r.complete, nil
+		}
+	case domain.PacketTypeData:
+		return false, r.accept(p)
+	case domain.PacketTypeFin:
+		r.endSeen = true
+	case domain.PacketTypePing:
+		r.send(newPong(p.packet))
+	}
+
+	return false, nil
+}
+
+func (r *MulticastReceiver) adopt(p receivedPacket, dir string) error {
+	info, err := parseAnnouncement(p.packet.Data)
+	if err != nil {
+		return nil
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, info.name), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if err := file.Truncate(info.size); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to size file: %w", err)
+	}
+
+	r.sessionID = p.packet.ConnID
+	r.sender = p.addr
+	r.info = info
+	r.file = file
+	r.received = domain.NewReceivedRanges()
+	r.total = packetCount(info.size, info.payload)
+	r.start = time.Now()
+	r.lastHeard = r.start
+	r.lastData = r.start
+	r.stats.FileName = info.name
+	r.stats.FileSize = info.size
+
+	fmt.Printf("Joining multicast session %08x from %s: %s (%d bytes)\n", r.sessionID, r.sender, info.name, info.size)
+
+	r.send(newControlPacket(domain.PacketTypeSyn, r.sessionID, []byte("JOIN")))
+
+	if r.total == 0 {
+		return r.finish()
+	}
+	return nil
+}
+
+func (r *MulticastReceiver) accept(p receivedPacket) error {
+	seq := p.packet.SeqNum
+	if r.complete || seq >= r.total {
+		return nil
+	}
+
+	if r.udpConfig.MulticastDropRate > 0 && rand.Float64() < r.udpConfig.MulticastDropRate {
+		return nil
+	}
+
+	if !r.received.Add(seq) {
+		r.stats.Duplicates = r.received.Duplicates
+		return nil
+	}
+
+	if _, err := r.file.WriteAt(p.packet.Data, int64(seq)*int64(r.info.payload)); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	r.stats.DataPackets++
+	if p.unicast {
+		r.stats.UnicastRepairs++
+	}
+	if !r.hasData || seq > r.highest {
+		r.highest = seq
+		r.hasData = true
+	}
+	r.lastData = time.Now()
+
+	if r.received.Count() == r.total {
+		return r.finish()
+	}
+	return nil
+}
+
+func (r *MulticastReceiver) finish() error {
+	checksum, err := fileChecksum(r.file, r.info.size)
+	if err != nil {
+		return fmt.Errorf("failed to checksum file: %w", err)
+	}
+	if checksum != r.info.checksum {
+		return fmt.Errorf("%w: got %08x, want %08x", ErrMulticastChecksum, checksum, r.info.checksum)
+	}
+
+	r.complete = true
+	r.stats.Duration = time.Since(r.start)
+	r.stats.Completed = 1
+	r.sendDone()
+
+	return nil
+}
+
+func (r *MulticastReceiver) sendDone() {
+	r.doneSent++
+	r.send(newControlPacket(domain.PacketTypeFin, r.sessionID, []byte("DONE")))
+}
+
+func (r *MulticastReceiver) tick() (bool, error) {
+	if r.sender == nil {
+		return false, nil
+	}
+
+	if time.Since(r.lastHeard) > r.udpConfig.MulticastReceiverTimeout {
+		if r.complete {
+			return true, nil
+		}
+		return false, fmt.Errorf("%w: silent for %v", ErrSenderLost, time.Since(r.lastHeard).Round(time.Millisecond))
+	}
+
+	switch {
+	case !r.joined:
+		r.send(newControlPacket(domain.PacketTypeSyn, r.sessionID, []byte("JOIN")))
+	case r.complete:
+		if r.doneSent > max(r.udpConfig.HeartbeatMisses, 1) {
+			return true, nil
+		}
+		r.sendDone()
+	default:
+		limit := uint32(0)
+		if r.hasData {
+			limit = r.highest + 1
+		}
+		if r.endSeen || (r.hasData && time.Since(r.lastData) >= r.udpConfig.MulticastNackInterval) {
+			limit = r.total
+		}
+
+		if missing := r.received.Missing(limit); len(missing) > 0 {
+			r.stats.Nacks++
+			r.send(newControlPacket(domain.PacketTypeNack, r.sessionID, encodeRanges(missing)))
+		}
+	}
+
+	if time.Since(r.lastSent) >= r.udpConfig.HeartbeatInterval {
+		r.send(newControlPacket(domain.PacketTypePing, r.sessionID, nil))
+	}
+
+	return false, nil
+}
+
+func (r *MulticastReceiver) send(packet *domain.Packet) {
+	r.lastSent = time.Now()
+	if err := sendControl(r.conn, packet, r.sender); err != nil {
+		fmt.Printf("Failed to send %d packet to %s: %v\n", packet.Type, r.sender, err)
+	}
+}
@@ -0,0 +1,441 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"NSSaDS/lab2/pkg/config"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+type MulticastSender struct {
+	udpConfig    *config.UDPConfig
+	conn         *net.UDPConn
+	transport    Transport
+	group        *net.UDPAddr
+	sessionID    uint32
+	packets      chan receivedPacket
+	pacer        pacer
+	file         *os.File
+	info         multicastAnnouncement
+	total        uint32
+	passDone     bool
+	receivers    map[string]*multicastPeer
+	repairs      map[uint32][]*net.UDPAddr
+	repairedAt   map[uint32]time.Time
+	lastAnnounce time.Time
+	stats        MulticastStats
+}
+
+type multicastPeer struct {
+	addr      *net.UDPAddr
+	joined    time.Time
+	lastHeard time.Time
+	nacks     uint32
+	done      bool
+}
+
+func NewMulticastSender(udpCfg *config.UDPConfig) (*MulticastSender, error) {
+	group, err := net.ResolveUDPAddr("udp4", udpCfg.MulticastGroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve multicast group: %w", err)
+	}
+	if !group.IP.IsMulticast() {
+		return nil, fmt.Errorf("%s is not a multicast address", group.IP)
+	}
+
+	ifi, err := multicastInterface(udpCfg.MulticastInterface)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create multicast socket: %w", err)
+	}
+
+	setSocketBuffers(conn, udpCfg.SocketBufferSize)
+
+	p := ipv4.NewPacketConn(conn)
+	if ifi != nil {
+		if err := p.SetMulticastInterface(ifi); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to set multicast interface: %w", err)
+		}
+	}
+	if err := p.SetMulticastTTL(max(udpCfg.MulticastTTL, 1)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set multicast TTL: %w", err)
+	}
+	if err := p.SetMulticastLoopback(true); err != nil {
+		fmt.Printf("Warning: failed to enable multicast loopback: %v\n", err)
+	}
+
+	return &MulticastSender{
+		udpConfig:  udpCfg,
+		conn:       conn,
+		transport:  NewTransport(conn, udpCfg.BatchSize),
+		group:      group,
+		sessionID:  newConnectionID(),
+		packets:    make(chan receivedPacket, 1024),
+		pacer:      pacer{rate: float64(udpCfg.MulticastRate)},
+		receivers:  make(map[string]*multicastPeer),
+		repairs:    make(map[uint32][]*net.UDPAddr),
+		repairedAt: make(map[uint32]time.Time),
+	}, nil
+}
+
+func (s *MulticastSender) Close() error {
+	if s.file != nil {
+		s.file.Close()
+	}
+	return s.conn.Close()
+}
+
+func (s *MulticastSender) Send(ctx context.Context, path string, expected int) (*MulticastStats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	s.file = file
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	checksum, err := fileChecksum(file, fileInfo.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum file: %w", err)
+	}
+
+	s.info = multicastAnnouncement{
+		name:     filepath.Base(path),
+		size:     fileInfo.Size(),
+		payload:  s.udpConfig.MulticastPayload,
+		checksum: checksum,
+	}
+	s.total = packetCount(s.info.size, s.info.payload)
+	s.stats.FileName = s.info.name
+	s.stats.FileSize = s.info.size
+
+	go readPackets(s.conn, true, s.packets)
+
+	fmt.Printf("Multicast session %08x: announcing %s (%d bytes) on %s, waiting for %d receivers\n",
+		s.sessionID, s.info.name, s.info.size, s.group, expected)
+
+	if err := s.waitForReceivers(ctx, expected); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	fmt.Printf("Multicasting %d packets of %d bytes to %d receivers\n", s.total, s.info.payload, len(s.receivers))
+
+	ticker := time.NewTicker(s.udpConfig.MulticastNackInterval)
+	defer ticker.Stop()
+
+	for seq := uint32(0); seq < s.total; {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+			if err := s.tick(); err != nil {
+				return nil, err
+			}
+		default:
+		}
+		s.drain()
+
+		n := min(uint32(s.transport.BatchSize()), s.total-seq)
+		seqs := make([]uint32, n)
+		for i := range seqs {
+			seqs[i] = seq + uint32(i)
+		}
+
+		if err := s.transmit(seqs, s.group); err != nil {
+			return nil, err
+		}
+		s.stats.DataPackets += n
+		seq += n
+	}
+	s.passDone = true
+
+	for !s.complete() {
+		if len(s.receivers) == 0 {
+			return &s.stats, fmt.Errorf("%w: all receivers were dropped", ErrNoReceivers)
+		}
+
+		select {
+		case <-ctx.Done():
+			return &s.stats, ctx.Err()
+		case p := <-s.packets:
+			s.handleControl(p)
+		case <-ticker.C:
+			if err := s.tick(); err != nil {
+				return &s.stats, err
+			}
+		}
+	}
+
+	s.stats.Duration = time.Since(start)
+	s.linger()
+
+	return &s.stats, nil
+}
+
+func (s *MulticastSender) waitForReceivers(ctx context.Context, expected int) error {
+	deadline := time.Now().Add(s.udpConfig.MulticastJoinTimeout)
+	s.announce()
+
+	ticker := time.NewTicker(s.udpConfig.MulticastNackInterval)
+	defer ticker.Stop()
+
+	for len(s.receivers) < expected {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case p := <-s.packets:
+			s.handleControl(p)
+		case <-ticker.C:
+			if time.Now().After(deadline) {
+				if len(s.receivers) == 0 {
+					return ErrNoReceivers
+				}
+				fmt.Printf("Only %d of %d receivers joined, starting anyway\n", len(s.receivers), expected)
+				return nil
+			}
+			if time.Since(s.lastAnnounce) >= multicastAnnounceInterval {
+				s.announce()
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *MulticastSender) linger() {
+	timer := time.NewTimer(s.udpConfig.MulticastNackInterval * 2)
+	defer timer.Stop()
+
+	for {
+		select {
+		case p := <-s.packets:
+			s.handleControl(p)
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+func (s *MulticastSender) drain() {
+	for {
+		select {
+		case p := <-s.packets:
+			s.handleControl(p)
+		default:
+			return
+		}
+	}
+}
+
+func (s *MulticastSender) tick() error {
+	if time.Since(s.lastAnnounce) >= multicastAnnounceInterval {
+		s.announce()
+	}
+
+	if err := s.flushRepairs(); err != nil {
+		return err
+	}
+
+	if s.passDone {
+		end := newControlPacket(domain.PacketTypeFin, s.sessionID, nil)
+		end.SeqNum = s.total
+		sendControl(s.conn, end, s.group)
+	}
+
+	now := time.Now()
+	for key, peer := range s.receivers {
+		if !peer.done && now.Sub(peer.lastHeard) > s.udpConfig.MulticastReceiverTimeout {
+			fmt.Printf("Receiver %s stopped responding, dropping it\n", peer.addr)
+			delete(s.receivers, key)
+			s.stats.Dropped++
+		}
+	}
+
+	return nil
+}
+
+func (s *MulticastSender) announce() {
+	s.lastAnnounce = time.Now()
+	packet := newControlPacket(domain.PacketTypeFileInfo, s.sessionID, []byte(s.info.String()))
+	if err := sendControl(s.conn, packet, s.group); err != nil {
+		fmt.Printf("Failed to send announcement: %v\n", err)
+	}
+}
+
+func (s *MulticastSender) complete() bool {
+	if len(s.receivers) == 0 {
+		return false
+	}
+	for _, peer := range s.receivers {
+		if !peer.done {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MulticastSender) handleControl(p receivedPacket) {
+	if p.packet.ConnID != s.sessionID {
+		return
+	}
+
+	key := p.addr.String()
+	peer := s.receivers[key]
+	now := time.Now()
+
+	if p.packet.Type == domain.PacketTypeSyn && peer == nil {
+		peer = &multicastPeer{addr: p.addr, joined: now}
+		s.receivers[key] = peer
+		s.stats.Receivers++
+		fmt.Printf("Receiver %s joined (%d joined)\n", p.addr, s.stats.Receivers)
+	}
+
+	if peer == nil {
+		return
+	}
+	peer.lastHeard = now
+
+	switch p.packet.Type {
+	case domain.PacketTypeSyn:
+		sendControl(s.conn, newControlPacket(domain.PacketTypeAck, s.sessionID, []byte("JOINED")), p.addr)
+	case domain.PacketTypeNack:
+		peer.nacks++
+		s.stats.Nacks++
+		s.queueRepairs(decodeRanges(p.packet.Data), p.addr)
+	case domain.PacketTypeFin:
+		if !peer.done {
+			peer.done = true
+			s.stats.Completed++
+			fmt.Printf("Receiver %s completed in %v (%d NACKs)\n", p.addr, now.Sub(peer.joined).Round(time.Millisecond), peer.nacks)
+		}
+		sendControl(s.conn, newControlPacket(domain.PacketTypeAck, s.sessionID, []byte("DONE")), p.addr)
+	case domain.PacketTypePing:
+		sendControl(s.conn, newPong(p.packet), p.addr)
+	}
+}
+
+func (s *MulticastSender) queueRepairs(ranges []domain.SeqRange, addr *net.UDPAddr) {
+	now := time.Now()
+	holdoff := 2 * s.udpConfig.MulticastNackInterval
+
+	for _, r := range ranges {
+		for seq := r.Start; seq < min(r.End, s.total); seq++ {
+			if now.Sub(s.repairedAt[seq]) < holdoff {
+				continue
+			}
+			requesters := s.repairs[seq]
+			if !slices.ContainsFunc(requesters, func(a *net.UDPAddr) bool { return a.String() == addr.String() }) {
+				s.repairs[seq] = append(requesters, addr)
+			}
+		}
+	}
+}
+
+func (s *MulticastSender) flushRepairs() error {
+	if len(s.repairs) == 0 {
+		return nil
+	}
+
+	seqs := make([]uint32, 0, len(s.repairs))
+	for seq := range s.repairs {
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+
+	if s.udpConfig.MulticastRate > 0 {
+		budget := int(float64(s.udpConfig.MulticastRate)*s.udpConfig.MulticastNackInterval.Seconds()) /
+			(s.info.payload + domain.PacketHeaderSize)
+		seqs = seqs[:min(len(seqs), max(budget, s.transport.BatchSize()))]
+	}
+
+	var group []uint32
+	unicast := make(map[string][]uint32)
+	addrs := make(map[string]*net.UDPAddr)
+
+	now := time.Now()
+	for _, seq := range seqs {
+		requesters := s.repairs[seq]
+		delete(s.repairs, seq)
+		s.repairedAt[seq] = now
+
+		if len(requesters) >= max(s.udpConfig.MulticastRepairThreshold, 1) {
+			group = append(group, seq)
+			continue
+		}
+		for _, addr := range requesters {
+			unicast[addr.String()] = append(unicast[addr.String()], seq)
+			addrs[addr.String()] = addr
+		}
+	}
+
+	if err := s.transmit(group, s.group); err != nil {
+		return err
+	}
+	s.stats.MulticastRepairs += uint32(len(group))
+
+	for key, seqs := range unicast {
+		if err := s.transmit(seqs, addrs[key]); err != nil {
+			return err
+		}
+		s.stats.UnicastRepairs += uint32(len(seqs))
+	}
+
+	return nil
+}
+
+func (s *MulticastSender) transmit(seqs []uint32, addr *net.UDPAddr) error {
+	payload := make([]byte, s.info.payload)
+
+	for len(seqs) > 0 {
+		batch := make([]Datagram, min(len(seqs), s.transport.BatchSize()))
+		bytes := 0
+
+		for i := range batch {
+			n, err := s.file.ReadAt(payload, int64(seqs[i])*int64(s.info.payload))
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("failed to read %s: %w", s.info.name, err)
+			}
+
+			packet := domain.NewPacket(domain.PacketTypeData, seqs[i], payload[:n])
+			packet.ConnID = s.sessionID
+
+			buf := getBuffer()
+			batch[i] = Datagram{Buf: buf, N: packet.SerializeTo(buf), Addr: addr}
+			bytes += batch[i].N
+		}
+
+		s.pacer.wait()
+		_, err := s.transport.WriteBatch(batch)
+		s.pacer.sent(bytes)
+
+		for i := range batch {
+			putBuffer(batch[i].Buf)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to send multicast data: %w", err)
+		}
+
+		seqs = seqs[len(batch):]
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package network_test
+
+import (
+	"NSSaDS/lab2/internal/infrastructure/network"
+	"NSSaDS/lab2/pkg/config"
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newMulticastConfig returns a configuration for a group on a free port,
+// skipping the test on hosts without a route for multicast traffic.
+func newMulticastConfig(t *testing.T) *config.UDPConfig {
+	t.Helper()
+	free, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		t.Fatalf("pick a port: %v", err)
+	}
+	port := free.LocalAddr().(*net.UDPAddr).Port
+	free.Close()
+
+	group := fmt.Sprintf("239.255.42.99:%d", port)
+	route, err := net.Dial("udp4", group)
+	if err != nil {
+		t.Skipf("no multicast route: %v", err)
+	}
+	route.Close()
+
+	cfg := config.NewConfig().UDP
+	cfg.MulticastGroup = group
+	return &cfg
+}
+
+func TestMulticastToTwoReceivers(t *testing.T) {
+	cfg := newMulticastConfig(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	dirs := []string{t.TempDir(), t.TempDir()}
+	results := make(chan error, len(dirs))
+	for _, dir := range dirs {
+		receiver, err := network.NewMulticastReceiver(cfg)
+		if err != nil {
+			t.Fatalf("join multicast group: %v", err)
+		}
+		t.Cleanup(func() { receiver.Close() })
+
+		go func() {
+			_, err := receiver.Receive(ctx, dir)
+			results <- err
+		}()
+	}
+
+	sender, err := network.NewMulticastSender(cfg)
+	if err != nil {
+		t.Fatalf("sender: %v", err)
+	}
+	defer sender.Close()
+
+	source := filepath.Join(t.TempDir(), "mcast.bin")
+	writeRandomFile(t, source, 1024*1024+17, 37)
+	stats, err := sender.Send(ctx, source, len(dirs))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if stats.Completed != len(dirs) {
+		t.Fatalf("%d of %d receivers completed", stats.Completed, len(dirs))
+	}
+
+	for range dirs {
+		if err := <-results; err != nil {
+			t.Fatalf("receive: %v", err)
+		}
+	}
+	for _, dir := range dirs {
+		if err := sameContent(source, filepath.Join(dir, "mcast.bin")); err != nil {
+			t.Fatalf("received copy: %v", err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package network_test
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestOversizedDataDropped(t *testing.T) {
+	cfg := newLoopbackConfig(t)
+	cfg.UDP.PMTUDiscovery = false
+	addr := startLoopbackServer(t, cfg)
+	payload := cfg.UDP.BufferSizes[len(cfg.UDP.BufferSizes)/2]
+
+	// The first copy of every fourth DATA packet, including the short last
+	// one, is padded past the negotiated payload size. The server must drop
+	// it unacknowledged so the client resends the original.
+	var mu sync.Mutex
+	padded := make(map[uint32]bool)
+	relay := startRelay(t, addr, func(datagram []byte) [][]byte {
+		packet, err := domain.DeserializePacket(datagram)
+		if err != nil || packet.Type != domain.PacketTypeData || packet.SeqNum%4 != 0 {
+			return [][]byte{datagram}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if padded[packet.SeqNum] {
+			return [][]byte{datagram}
+		}
+		padded[packet.SeqNum] = true
+		packet.Data = append(packet.Data, make([]byte, payload+1-len(packet.Data))...)
+		return [][]byte{packet.Serialize()}
+	}, nil)
+	client := dialLoopback(t, cfg, relay)
+
+	source := filepath.Join(t.TempDir(), "source.bin")
+	writeRandomFile(t, source, 40*payload+7, 35)
+	if _, err := client.UploadFile(source, "oversized.bin"); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if err := sameContent(source, filepath.Join(cfg.Server.UploadDir, "oversized.bin")); err != nil {
+		t.Fatalf("uploaded copy: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !padded[40] {
+		t.Fatalf("relay never padded the last DATA packet: %v", padded)
+	}
+}
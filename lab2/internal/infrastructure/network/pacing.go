@@ -0,0 +1,105 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"NSSaDS/lab2/pkg/config"
+	"runtime"
+	"time"
+)
+
+const (
+	pacingGain  = 1.25
+	pacingSlack = 100 * time.Microsecond
+)
+
+type pacer struct {
+	rate   float64
+	burst  int
+	next   time.Time
+	bytes  int64
+	budget time.Duration
+	waited time.Duration
+}
+
+func newTransferPacer(udpCfg *config.UDPConfig, payloadSize int) *pacer {
+	if !udpCfg.Pacing {
+		return &pacer{}
+	}
+
+	return &pacer{
+		rate:  float64(udpCfg.PacingRate),
+		burst: max(udpCfg.PacingBurst, 1) * (payloadSize + domain.PacketHeaderSize),
+	}
+}
+
+func (p *pacer) update(udpCfg *config.UDPConfig, payloadSize int, srtt time.Duration) {
+	if !udpCfg.Pacing || srtt <= 0 {
+		return
+	}
+
+	rate := pacingGain * float64(int(udpCfg.WindowSize)*(payloadSize+domain.PacketHeaderSize)) / srtt.Seconds()
+	if udpCfg.PacingRate > 0 {
+		rate = min(rate, float64(udpCfg.PacingRate))
+	}
+	p.rate = rate
+}
+
+func (p *pacer) delay() time.Duration {
+	if p.rate <= 0 {
+		return 0
+	}
+	return time.Until(p.next)
+}
+
+func (p *pacer) wait() {
+	if p.rate <= 0 {
+		return
+	}
+
+	if time.Until(p.next) > 0 {
+		start := time.Now()
+		sleepUntil(p.next)
+		p.waited += time.Since(start)
+	}
+}
+
+func (p *pacer) sent(bytes int) {
+	if p.rate <= 0 || bytes == 0 {
+		return
+	}
+
+	interval := time.Duration(float64(bytes) / p.rate * float64(time.Second))
+	credit := time.Duration(float64(p.burst) / p.rate * float64(time.Second))
+
+	now := time.Now()
+	if earliest := now.Add(-credit); p.next.Before(earliest) {
+		p.next = earliest
+	}
+	p.next = p.next.Add(interval)
+	p.bytes += int64(bytes)
+	p.budget += interval
+}
+
+func (p *pacer) averageRate() float64 {
+	if p.budget <= 0 {
+		return 0
+	}
+	return float64(p.bytes) / p.budget.Seconds()
+}
+
+func sleepUntil(deadline time.Time) {
+	if d := time.Until(deadline) - pacingSlack; d > 0 {
+		sleepFor(d)
+	}
+	for time.Now().Before(deadline) {
+		runtime.Gosched()
+	}
+}
+
+func packetBytes(packets []*domain.Packet) int {
+	bytes := 0
+	for _, packet := range packets {
+		bytes += domain.PacketHeaderSize + len(packet.Data)
+	}
+	return bytes
+}
@@ -0,0 +1,12 @@
+package network
+
+import (
+	"syscall"
+	"time"
+)
+
+func sleepFor(d time.Duration) {
+	ts := syscall.NsecToTimespec(int64(d))
+	for syscall.Nanosleep(&ts, &ts) == syscall.EINTR {
+	}
+}
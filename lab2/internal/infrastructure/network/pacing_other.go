@@ -0,0 +1,13 @@
+//go:build !linux
+
+package network
+
+import "time"
+
+const timerGranularity = 2 * time.Millisecond
+
+func sleepFor(d time.Duration) {
+	if d > timerGranularity {
+		time.Sleep(d - timerGranularity)
+	}
+}
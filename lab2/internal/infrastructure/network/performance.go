@@ -8,16 +8,23 @@ import (
 )
 
 type PerformanceMonitor struct {
-	mu          sync.RWMutex
-	startTime   time.Time
-	filename    string
-	totalBytes  int64
-	transferred int64
-	packetsSent uint32
-	packetsLost uint32
-	retransmits uint32
-	bitrates    []float64
-	bufferTests map[int]float64
+	mu           sync.RWMutex
+	startTime    time.Time
+	lastUpdate   time.Time
+	filename     string
+	totalBytes   int64
+	transferred  int64
+	packetsSent  uint32
+	packetsLost  uint32
+	retransmits  uint32
+	fecParity    uint32
+	fecRecovered uint32
+	duplicates   uint32
+	pacingRate   float64
+	pacingBurst  int
+	pacingWait   time.Duration
+	bitrates     []float64
+	bufferTests  map[int]float64
 }
 
 func NewPerformanceMonitor() *PerformanceMonitor {
@@ -35,9 +42,16 @@ func (pm *PerformanceMonitor) StartTransfer(filename string, totalBytes int64) {
 	pm.totalBytes = totalBytes
 	pm.transferred = 0
 	pm.startTime = time.Now()
+	pm.lastUpdate = pm.startTime
 	pm.packetsSent = 0
 	pm.packetsLost = 0
 	pm.retransmits = 0
+	pm.fecParity = 0
+	pm.fecRecovered = 0
+	pm.duplicates = 0
+	pm.pacingRate = 0
+	pm.pacingBurst = 0
+	pm.pacingWait = 0
 }
 
 func (pm *PerformanceMonitor) UpdateProgress(transferred int64) {
@@ -45,6 +59,7 @@ func (pm *PerformanceMonitor) UpdateProgress(transferred int64) {
 	defer pm.mu.Unlock()
 
 	pm.transferred = transferred
+	pm.lastUpdate = time.Now()
 
 	elapsed := time.Since(pm.startTime).Seconds()
 	if elapsed > 0 {
@@ -74,24 +89,29 @@ func (pm *PerformanceMonitor) GetProgress() *domain.TransferProgress {
 	}
 
 	return &domain.TransferProgress{
-		FileName:    pm.filename,
-		TotalBytes:  pm.totalBytes,
-		Transferred: pm.transferred,
-		StartTime:   pm.startTime,
-		Bitrate:     bitrate,
-		Percentage:  percentage,
-		PacketsSent: pm.packetsSent,
-		PacketsLost: pm.packetsLost,
-		Retransmits: pm.retransmits,
+		FileName:      pm.filename,
+		TotalBytes:    pm.totalBytes,
+		Transferred:   pm.transferred,
+		StartTime:     pm.startTime,
+		Bitrate:       bitrate,
+		Percentage:    percentage,
+		PacketsSent:   pm.packetsSent,
+		PacketsLost:   pm.packetsLost,
+		Retransmits:   pm.retransmits,
+		ParityPackets: pm.fecParity,
+		FECRecovered:  pm.fecRecovered,
+		Duplicates:    pm.duplicates,
 	}
 }
 
+// CalculateOptimalBufferSize returns the fastest buffer size measured by
+// buffer tuning, or 0 if no tuning ran.
 func (pm *PerformanceMonitor) CalculateOptimalBufferSize() (int, float64) {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
 	if len(pm.bufferTests) == 0 {
-		return 8192, 0.0
+		return 0, 0.0
 	}
 
 	var bestSize int
@@ -111,17 +131,14 @@ func (pm *PerformanceMonitor) CompareWithTCP(tcpBitrate float64) (float64, bool)
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
-	if len(pm.bitrates) == 0 {
+	elapsed := pm.lastUpdate.Sub(pm.startTime).Seconds()
+	if tcpBitrate <= 0 || elapsed <= 0 || pm.transferred == 0 {
 		return 0.0, false
 	}
 
-	var sum float64
-	for _, bitrate := range pm.bitrates {
-		sum += bitrate
-	}
-	avgUDPBitrate := sum / float64(len(pm.bitrates))
+	goodput := float64(pm.transferred) / elapsed / 1024 / 1024
 
-	ratio := avgUDPBitrate / tcpBitrate
+	ratio := goodput / tcpBitrate
 	isFaster := ratio >= 1.5
 
 	return ratio, isFaster
@@ -143,6 +160,30 @@ func (pm *PerformanceMonitor) UpdateStatistics(packetsSent, packetsLost, retrans
 	pm.retransmits = retransmits
 }
 
+func (pm *PerformanceMonitor) UpdateFEC(parityPackets, recovered uint32) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.fecParity = parityPackets
+	pm.fecRecovered = recovered
+}
+
+func (pm *PerformanceMonitor) UpdateDuplicates(duplicates uint32) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.duplicates = duplicates
+}
+
+func (pm *PerformanceMonitor) UpdatePacing(rate float64, burst int, waited time.Duration) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.pacingRate = rate
+	pm.pacingBurst = burst
+	pm.pacingWait = waited
+}
+
 func (pm *PerformanceMonitor) GetStatistics() (packetsSent, packetsLost, retransmits uint32, avgBitrateValue float64) {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
@@ -170,6 +211,15 @@ func (pm *PerformanceMonitor) PrintReport() {
 	fmt.Printf("Packets Lost: %d\n", pm.packetsLost)
 	fmt.Printf("Retransmissions: %d\n", pm.retransmits)
 
+	if pm.fecParity > 0 {
+		fmt.Printf("FEC Parity Packets: %d\n", pm.fecParity)
+		fmt.Printf("FEC Recovered: %d (vs %d retransmissions)\n", pm.fecRecovered, pm.retransmits)
+	}
+
+	if pm.duplicates > 0 {
+		fmt.Printf("Duplicates Discarded: %d\n", pm.duplicates)
+	}
+
 	if pm.packetsSent > 0 {
 		lossRate := float64(pm.packetsLost) / float64(pm.packetsSent) * 100
 		fmt.Printf("Packet Loss Rate: %.2f%%\n", lossRate)
@@ -181,5 +231,13 @@ func (pm *PerformanceMonitor) PrintReport() {
 		fmt.Printf("Average Bitrate: %.2f MB/s\n", avgBitrate)
 	}
 
+	if transferTime := pm.lastUpdate.Sub(pm.startTime).Seconds(); pm.pacingRate > 0 && transferTime > 0 {
+		pacingRate := pm.pacingRate / 1024 / 1024
+		achieved := float64(pm.transferred) / transferTime / 1024 / 1024
+		fmt.Printf("Pacing Rate: %.2f MB/s (burst %d packets, %v spent waiting)\n",
+			pacingRate, pm.pacingBurst, pm.pacingWait.Round(time.Millisecond))
+		fmt.Printf("Achieved Rate: %.2f MB/s (%.0f%% of pacing rate)\n", achieved, achieved/pacingRate*100)
+	}
+
 	fmt.Printf("========================\n")
 }
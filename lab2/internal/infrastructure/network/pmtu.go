@@ -0,0 +1,114 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+const (
+	pmtuMinPayload = 576 - 20 - 8 - domain.PacketHeaderSize
+	pmtuStep       = 16
+	probeReplyFlag = 1
+)
+
+var errProbeUnanswered = errors.New("server did not answer path MTU probes")
+
+func isMessageTooLong(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
+
+func newProbePacket(seq uint32, size int) *domain.Packet {
+	return domain.NewPacket(domain.PacketTypeProbe, seq, make([]byte, size))
+}
+
+func newProbeReply(probe *domain.Packet) *domain.Packet {
+	reply := domain.NewPacket(domain.PacketTypeProbe, probe.SeqNum, probe.Data)
+	reply.Flags = probeReplyFlag
+	reply.ConnID = probe.ConnID
+	return reply
+}
+
+func (c *UDPClient) probePathMTU() {
+	target := c.udpConfig.BufferSizes[len(c.udpConfig.BufferSizes)/2]
+
+	size, err := c.discoverPayloadSize(target)
+	if err != nil {
+		fmt.Printf("Warning: path MTU discovery failed, using %d byte payloads: %v\n", c.payloadSize(), err)
+		return
+	}
+
+	// Probing stops at the target, so only a smaller size is a known limit.
+	c.maxPayload = 0
+	if size < target {
+		c.maxPayload = size
+		fmt.Printf("Path MTU limits payload to %d bytes (configured %d)\n", size, target)
+	}
+}
+
+func (c *UDPClient) discoverPayloadSize(target int) (int, error) {
+	if !c.probe(min(pmtuMinPayload, target)) {
+		return 0, errProbeUnanswered
+	}
+
+	if target <= pmtuMinPayload || c.probe(target) {
+		return target, nil
+	}
+
+	low, high := pmtuMinPayload, target
+	for high-low > pmtuStep {
+		mid := (low + high) / 2
+		if c.probe(mid) {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	return low, nil
+}
+
+func (c *UDPClient) probe(size int) bool {
+	for attempt := 0; attempt < max(c.udpConfig.PMTUProbeRetries, 1); attempt++ {
+		c.probeSeq++
+		seq := c.probeSeq
+
+		if err := c.relMgr.SendPacket(newProbePacket(seq, size), c.serverAddr); err != nil {
+			if isMessageTooLong(err) {
+				return false
+			}
+			continue
+		}
+
+		deadline := time.Now().Add(c.udpConfig.PMTUProbeTimeout)
+		for time.Now().Before(deadline) {
+			packet, err := c.receive()
+			if err != nil {
+				if isTimeout(err) {
+					continue
+				}
+				return false
+			}
+
+			if packet != nil && packet.Type == domain.PacketTypeProbe && packet.Flags&probeReplyFlag != 0 &&
+				packet.SeqNum == seq && len(packet.Data) == size {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c *UDPClient) blackHoleSuspected(err error, payloadSize int) bool {
+	if !errors.Is(err, ErrTransferStalled) || !c.udpConfig.PMTUDiscovery {
+		return false
+	}
+
+	fmt.Printf("Transfer stalled with %d byte payloads, re-probing path MTU...\n", payloadSize)
+	c.probePathMTU()
+
+	return c.payloadSize() < payloadSize
+}
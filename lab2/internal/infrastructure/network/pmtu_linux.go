@@ -0,0 +1,27 @@
+package network
+
+import (
+	"net"
+	"syscall"
+)
+
+func enablePMTUProbe(conn *net.UDPConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		errV4 := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE)
+		errV6 := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_PROBE)
+		if errV4 != nil && errV6 != nil {
+			sockErr = errV4
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return sockErr
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package network
+
+import (
+	"errors"
+	"net"
+)
+
+func enablePMTUProbe(conn *net.UDPConn) error {
+	return errors.New("DF probing is only supported on Linux")
+}
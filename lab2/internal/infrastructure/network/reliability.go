@@ -2,19 +2,44 @@ package network
 
 import (
 	"NSSaDS/lab2/internal/domain"
+	"errors"
 	"fmt"
-	"math/rand"
 	"net"
 	"sync"
 	"time"
 )
 
+var errInvalidPacket = errors.New("invalid packet")
+
+type pendingPacket struct {
+	packet  *domain.Packet
+	addr    *net.UDPAddr
+	sentAt  time.Time
+	retries int
+}
+
+type peerReliability struct {
+	pending     map[uint32]*pendingPacket
+	packetsSent uint32
+	packetsLost uint32
+	retransmits uint32
+	srtt        time.Duration
+}
+
 type ReliabilityManager struct {
 	conn                  *net.UDPConn
+	transport             Transport
+	security              *securityManager
+	tracer                *Tracer
+	rxMutex               sync.Mutex
+	rxBatch               []Datagram
+	rxNext                int
+	rxCount               int
+	connID                uint32
 	packetsSent           uint32
 	packetsLost           uint32
 	retransmits           uint32
-	pendingPackets        map[uint32]*domain.Packet
+	peers                 map[uint32]*peerReliability
 	pendingMutex          sync.RWMutex
 	packetTimeout         time.Duration
 	maxRetransmissions    int
@@ -26,7 +51,8 @@ type ReliabilityManager struct {
 func NewReliabilityManager(conn *net.UDPConn, packetTimeout, retransmissionTimeout time.Duration, maxRetransmissions int) *ReliabilityManager {
 	rm := &ReliabilityManager{
 		conn:                  conn,
-		pendingPackets:        make(map[uint32]*domain.Packet),
+		transport:             NewTransport(conn, 1),
+		peers:                 make(map[uint32]*peerReliability),
 		packetTimeout:         packetTimeout,
 		maxRetransmissions:    maxRetransmissions,
 		retransmissionTimeout: retransmissionTimeout,
@@ -39,14 +65,97 @@ func NewReliabilityManager(conn *net.UDPConn, packetTimeout, retransmissionTimeo
 	return rm
 }
 
+func setSocketBuffers(conn *net.UDPConn, size int) {
+	if size <= 0 {
+		return
+	}
+	if err := conn.SetReadBuffer(size); err != nil {
+		fmt.Printf("Warning: failed to set read buffer: %v\n", err)
+	}
+	if err := conn.SetWriteBuffer(size); err != nil {
+		fmt.Printf("Warning: failed to set write buffer: %v\n", err)
+	}
+}
+
+func (rm *ReliabilityManager) SetConnectionID(connID uint32) {
+	rm.connID = connID
+}
+
+func (rm *ReliabilityManager) SetTransport(transport Transport) {
+	rm.rxMutex.Lock()
+	defer rm.rxMutex.Unlock()
+
+	rm.releaseRxBatch()
+	rm.transport = transport
+}
+
+func (rm *ReliabilityManager) SetTracer(tracer *Tracer) {
+	rm.tracer = tracer
+}
+
+func (rm *ReliabilityManager) EnableSecurity(psk []byte) {
+	rm.security = newSecurityManager(psk, pendingHandshakeRTOs*rm.retransmissionTimeout)
+}
+
+func (rm *ReliabilityManager) SecurityStatistics() (authFailures, replays uint32) {
+	if rm.security == nil {
+		return 0, 0
+	}
+	return rm.security.statistics()
+}
+
+func (rm *ReliabilityManager) encode(packet *domain.Packet, buf []byte, addr *net.UDPAddr) int {
+	if rm.security != nil {
+		packet = rm.security.seal(packet)
+	}
+	n := packet.SerializeTo(buf)
+	if rm.tracer != nil {
+		rm.tracer.Record(TraceSent, TraceOK, buf[:n], addr)
+	}
+	return n
+}
+
+func (rm *ReliabilityManager) BatchSize() int {
+	return rm.transport.BatchSize()
+}
+
 func (rm *ReliabilityManager) SendPacket(packet *domain.Packet, addr *net.UDPAddr) error {
+	return rm.SendPackets([]*domain.Packet{packet}, addr)
+}
+
+func (rm *ReliabilityManager) SendPackets(packets []*domain.Packet, addr *net.UDPAddr) error {
+	now := time.Now()
+
 	rm.pendingMutex.Lock()
-	rm.pendingPackets[packet.SeqNum] = packet
-	rm.packetsSent++
+	for _, packet := range packets {
+		if packet.ConnID == 0 {
+			packet.ConnID = rm.connID
+		}
+		peer := rm.peer(packet.ConnID)
+		if packet.Type == domain.PacketTypeData {
+			peer.pending[packet.SeqNum] = &pendingPacket{
+				packet: packet,
+				addr:   addr,
+				sentAt: now,
+			}
+		}
+		peer.packetsSent++
+		rm.packetsSent++
+	}
 	rm.pendingMutex.Unlock()
 
-	data := packet.Serialize()
-	_, err := rm.conn.WriteToUDP(data, addr)
+	batch := make([]Datagram, len(packets))
+	for i, packet := range packets {
+		buf := getBuffer()
+		batch[i] = Datagram{Buf: buf, N: rm.encode(packet, buf, addr), Addr: addr}
+	}
+
+	_, err := rm.transport.WriteBatch(batch)
+
+	for i := range batch {
+		putBuffer(batch[i].Buf)
+	}
+
 	if err != nil {
 		return fmt.Errorf("failed to send packet: %w", err)
 	}
@@ -54,27 +163,140 @@ func (rm *ReliabilityManager) SendPacket(packet *domain.Packet, addr *net.UDPAdd
 	return nil
 }
 
+func (rm *ReliabilityManager) peer(connID uint32) *peerReliability {
+	peer, exists := rm.peers[connID]
+	if !exists {
+		peer = &peerReliability{pending: make(map[uint32]*pendingPacket)}
+		rm.peers[connID] = peer
+	}
+	return peer
+}
+
+func (rm *ReliabilityManager) ClearPending(connID uint32) {
+	rm.pendingMutex.Lock()
+	defer rm.pendingMutex.Unlock()
+
+	if peer, exists := rm.peers[connID]; exists {
+		clear(peer.pending)
+	}
+}
+
+func (rm *ReliabilityManager) ResetPeer(connID uint32) {
+	rm.pendingMutex.Lock()
+	defer rm.pendingMutex.Unlock()
+
+	peer := rm.peer(connID)
+	clear(peer.pending)
+	peer.packetsSent, peer.packetsLost, peer.retransmits = 0, 0, 0
+}
+
+func (rm *ReliabilityManager) ForgetPeer(connID uint32) {
+	rm.pendingMutex.Lock()
+	defer rm.pendingMutex.Unlock()
+
+	delete(rm.peers, connID)
+}
+
 func (rm *ReliabilityManager) ReceivePacket() (*domain.Packet, *net.UDPAddr, error) {
-	buf := make([]byte, 65536)
-	n, addr, err := rm.conn.ReadFromUDP(buf)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read packet: %w", err)
+	rm.rxMutex.Lock()
+
+	if rm.rxNext >= rm.rxCount {
+		if len(rm.rxBatch) != rm.transport.BatchSize() {
+			rm.releaseRxBatch()
+			rm.rxBatch = make([]Datagram, rm.transport.BatchSize())
+			for i := range rm.rxBatch {
+				rm.rxBatch[i].Buf = getBuffer()
+			}
+		}
+
+		n, err := rm.transport.ReadBatch(rm.rxBatch)
+		if err != nil {
+			rm.rxMutex.Unlock()
+			return nil, nil, fmt.Errorf("failed to read packet: %w", err)
+		}
+		rm.rxNext, rm.rxCount = 0, n
 	}
 
-	packet, err := domain.DeserializePacket(buf[:n])
+	datagram := rm.rxBatch[rm.rxNext]
+	rm.rxNext++
+
+	addr := datagram.Addr
+	packet, err := domain.DeserializePacket(datagram.Buf[:datagram.N])
+
+	var raw []byte
+	if rm.tracer != nil {
+		raw = append(raw, datagram.Buf[:datagram.N]...)
+	}
+	rm.rxMutex.Unlock()
+
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to deserialize packet: %w", err)
+		rm.trace(raw, TraceMalformed, addr)
+		return nil, addr, fmt.Errorf("%w: %v", errInvalidPacket, err)
 	}
 
+	if rm.security != nil {
+		if err := rm.security.open(packet); err != nil {
+			rm.trace(raw, TraceDropped, addr)
+			return nil, addr, fmt.Errorf("%w: %v", errPacketDropped, err)
+		}
+	}
+	rm.trace(raw, TraceOK, addr)
+
 	if packet.Type == domain.PacketTypeAck {
 		rm.pendingMutex.Lock()
-		delete(rm.pendingPackets, packet.AckNum)
+		if peer, exists := rm.peers[packet.ConnID]; exists {
+			if pending, exists := peer.pending[packet.AckNum]; exists {
+				if pending.retries == 0 {
+					peer.observeRTT(time.Since(pending.sentAt))
+				}
+				delete(peer.pending, packet.AckNum)
+			}
+		}
 		rm.pendingMutex.Unlock()
 	}
 
 	return packet, addr, nil
 }
 
+func (rm *ReliabilityManager) ObserveRTT(connID uint32, sample time.Duration) {
+	rm.pendingMutex.Lock()
+	defer rm.pendingMutex.Unlock()
+
+	rm.peer(connID).observeRTT(sample)
+}
+
+func (p *peerReliability) observeRTT(sample time.Duration) {
+	if p.srtt == 0 {
+		p.srtt = sample
+		return
+	}
+	p.srtt += (sample - p.srtt) / 8
+}
+
+func (rm *ReliabilityManager) SmoothedRTT(connID uint32) time.Duration {
+	rm.pendingMutex.RLock()
+	defer rm.pendingMutex.RUnlock()
+
+	if peer, exists := rm.peers[connID]; exists {
+		return peer.srtt
+	}
+	return 0
+}
+
+func (rm *ReliabilityManager) trace(raw []byte, status TraceStatus, addr *net.UDPAddr) {
+	if rm.tracer != nil {
+		rm.tracer.Record(TraceReceived, status, raw, addr)
+	}
+}
+
+func (rm *ReliabilityManager) releaseRxBatch() {
+	for i := range rm.rxBatch {
+		putBuffer(rm.rxBatch[i].Buf)
+	}
+	rm.rxBatch = nil
+	rm.rxNext, rm.rxCount = 0, 0
+}
+
 func (rm *ReliabilityManager) HandleRetransmissions() {
 	rm.retransmissionLoop()
 }
@@ -86,6 +308,16 @@ func (rm *ReliabilityManager) GetStatistics() (packetsSent, packetsLost, retrans
 	return rm.packetsSent, rm.packetsLost, rm.retransmits
 }
 
+func (rm *ReliabilityManager) PeerStatistics(connID uint32) (packetsSent, packetsLost, retransmits uint32) {
+	rm.pendingMutex.RLock()
+	defer rm.pendingMutex.RUnlock()
+
+	if peer, exists := rm.peers[connID]; exists {
+		return peer.packetsSent, peer.packetsLost, peer.retransmits
+	}
+	return 0, 0, 0
+}
+
 func (rm *ReliabilityManager) retransmissionLoop() {
 	defer rm.wg.Done()
 
@@ -106,43 +338,57 @@ func (rm *ReliabilityManager) checkRetransmissions() {
 	rm.pendingMutex.Lock()
 	defer rm.pendingMutex.Unlock()
 
-	now := time.Now().UnixNano()
+	now := time.Now()
 
-	for seqNum, packet := range rm.pendingPackets {
-		elapsed := time.Duration(now - packet.Timestamp)
+	var batch []Datagram
+	var due []*pendingPacket
+	var duePeers []*peerReliability
 
-		if elapsed > rm.retransmissionTimeout {
-			retransmitCount := rm.getRetransmitCount(packet)
-			if retransmitCount >= rm.maxRetransmissions {
-				delete(rm.pendingPackets, seqNum)
-				rm.packetsLost++
+	for _, peer := range rm.peers {
+		for seqNum, pending := range peer.pending {
+			if now.Sub(pending.sentAt) <= rm.retransmissionTimeout {
 				continue
 			}
 
-			data := packet.Serialize()
-			if _, err := rm.conn.WriteToUDP(data, nil); err != nil {
-				fmt.Printf("Retransmission failed: %v\n", err)
-			} else {
-				rm.retransmits++
-				packet.Timestamp = now
+			if pending.retries >= rm.maxRetransmissions {
+				delete(peer.pending, seqNum)
+				peer.packetsLost++
+				rm.packetsLost++
+				continue
 			}
+
+			buf := getBuffer()
+			batch = append(batch, Datagram{Buf: buf, N: rm.encode(pending.packet, buf, pending.addr), Addr: pending.addr})
+			due = append(due, pending)
+			duePeers = append(duePeers, peer)
 		}
 	}
-}
 
-func (rm *ReliabilityManager) getRetransmitCount(packet *domain.Packet) int {
-	return 0
+	if len(batch) == 0 {
+		return
+	}
+
+	written, err := rm.transport.WriteBatch(batch)
+	if err != nil {
+		fmt.Printf("Retransmission failed: %v\n", err)
+	}
+
+	for i := range batch {
+		putBuffer(batch[i].Buf)
+		if i < written {
+			rm.retransmits++
+			duePeers[i].retransmits++
+			due[i].retries++
+			due[i].sentAt = now
+		}
+	}
 }
 
 func (rm *ReliabilityManager) Stop() {
 	close(rm.stopChan)
 	rm.wg.Wait()
-}
-
-func (rm *ReliabilityManager) SimulatePacketLoss(lossRate float64) {
-	rand.Seed(time.Now().UnixNano())
 
-	if rand.Float64() < lossRate {
-		rm.packetsLost++
+	if rm.tracer != nil {
+		rm.tracer.Flush()
 	}
 }
@@ -0,0 +1,40 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResumeNeedsTokenNameAndSize(t *testing.T) {
+	s := &UDPServer{partials: make(map[string]partialUpload)}
+	save := func() {
+		s.partials["token"] = partialUpload{filename: "a.bin", size: 100, offset: 40, savedAt: time.Now()}
+	}
+
+	save()
+	for _, tt := range []struct {
+		token, filename string
+		size            int64
+	}{
+		{"other", "a.bin", 100},
+		{"", "a.bin", 100},
+		{"token", "b.bin", 100},
+		{"token", "a.bin", 101},
+	} {
+		if offset := s.resumeOffset(tt.token, tt.filename, tt.size); offset != 0 {
+			t.Fatalf("resumeOffset(%q, %q, %d) = %d, want 0", tt.token, tt.filename, tt.size, offset)
+		}
+	}
+	if offset := s.resumeOffset("token", "a.bin", 100); offset != 40 {
+		t.Fatalf("resumeOffset with the right token = %d, want 40", offset)
+	}
+	if offset := s.resumeOffset("token", "a.bin", 100); offset != 0 {
+		t.Fatalf("token resumed twice, second offset %d", offset)
+	}
+
+	save()
+	s.forgetPartial("a.bin")
+	if offset := s.resumeOffset("token", "a.bin", 100); offset != 0 {
+		t.Fatalf("resumed a.bin at %d after it was rewritten", offset)
+	}
+}
@@ -0,0 +1,455 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	CipherAESGCM           = "aes-gcm"
+	CipherChaCha20Poly1305 = "chacha20-poly1305"
+
+	handshakeNonceSize = 32
+	sealCounterSize    = 8
+	sealTagSize        = 16
+	SealOverhead       = sealCounterSize + sealTagSize
+	replayWindowSize   = 1024
+
+	// A handshake that has not produced an authenticated packet within this
+	// many retransmission timeouts is forgotten, and at most
+	// maxPendingHandshakes of them are kept at once.
+	pendingHandshakeRTOs = 4
+	maxPendingHandshakes = 1024
+
+	synSecureFlag = 1
+	synReplyFlag  = 2
+)
+
+var (
+	ErrAuthFailed       = errors.New("packet authentication failed")
+	ErrReplayedPacket   = errors.New("replayed packet")
+	ErrUnknownCipher    = errors.New("unknown cipher")
+	ErrHandshakeTimeout = errors.New("server did not complete the secure handshake")
+	ErrKeyMismatch      = errors.New("server rejected the pre-shared key")
+	errPacketDropped    = errors.New("packet dropped")
+)
+
+var cipherIDs = map[string]byte{
+	CipherAESGCM:           1,
+	CipherChaCha20Poly1305: 2,
+}
+
+func cipherID(name string) (byte, error) {
+	if name == "" {
+		name = CipherAESGCM
+	}
+	id, ok := cipherIDs[name]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownCipher, name)
+	}
+	return id, nil
+}
+
+func newAEAD(id byte, key []byte) (cipher.AEAD, error) {
+	switch id {
+	case cipherIDs[CipherAESGCM]:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case cipherIDs[CipherChaCha20Poly1305]:
+		return chacha20poly1305.New(key)
+	default:
+		return nil, fmt.Errorf("%w: id %d", ErrUnknownCipher, id)
+	}
+}
+
+func newHandshakeNonce() []byte {
+	nonce := make([]byte, handshakeNonceSize)
+	rand.Read(nonce)
+	return nonce
+}
+
+type replayWindow struct {
+	top  uint64
+	bits [replayWindowSize / 64]uint64
+}
+
+func (w *replayWindow) seen(counter uint64) bool {
+	if counter == 0 {
+		return true
+	}
+	if counter > w.top {
+		return false
+	}
+	if w.top-counter >= replayWindowSize {
+		return true
+	}
+	bit := counter % replayWindowSize
+	return w.bits[bit/64]&(1<<(bit%64)) != 0
+}
+
+func (w *replayWindow) mark(counter uint64) {
+	if counter > w.top {
+		if counter-w.top >= replayWindowSize {
+			w.bits = [replayWindowSize / 64]uint64{}
+		} else {
+			for c := w.top + 1; c < counter; c++ {
+				bit := c % replayWindowSize
+				w.bits[bit/64] &^= 1 << (bit % 64)
+			}
+		}
+		w.top = counter
+	}
+	bit := counter % replayWindowSize
+	w.bits[bit/64] |= 1 << (bit % 64)
+}
+
+type secureSession struct {
+	mu          sync.Mutex
+	send        cipher.AEAD
+	recv        cipher.AEAD
+	sendCounter uint64
+	replay      replayWindow
+	clientNonce []byte
+	serverNonce []byte
+	lastSeen    time.Time
+}
+
+func newSecureSession(psk []byte, cipher byte, connID uint32, clientNonce, serverNonce []byte, isServer bool) (*secureSession, error) {
+	salt := append(append([]byte{}, clientNonce...), serverNonce...)
+
+	keys := make([][]byte, 2)
+	for i, direction := range []string{"client->server", "server->client"} {
+		info := fmt.Sprintf("NSSaDS lab2 v%d %s %08x", domain.PacketVersion, direction, connID)
+		key, err := hkdf.Key(sha256.New, psk, salt, info, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive session key: %w", err)
+		}
+		keys[i] = key
+	}
+
+	if isServer {
+		keys[0], keys[1] = keys[1], keys[0]
+	}
+
+	send, err := newAEAD(cipher, keys[0])
+	if err != nil {
+		return nil, err
+	}
+	recv, err := newAEAD(cipher, keys[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &secureSession{
+		send:        send,
+		recv:        recv,
+		clientNonce: clientNonce,
+		serverNonce: serverNonce,
+		lastSeen:    time.Now(),
+	}, nil
+}
+
+func sealAAD(p *domain.Packet) []byte {
+	aad := make([]byte, 24)
+	aad[0] = p.Type
+	aad[1] = p.Flags
+	binary.BigEndian.PutUint16(aad[2:4], p.Window)
+	binary.BigEndian.PutUint32(aad[4:8], p.ConnID)
+	binary.BigEndian.PutUint32(aad[8:12], p.SeqNum)
+	binary.BigEndian.PutUint32(aad[12:16], p.AckNum)
+	binary.BigEndian.PutUint64(aad[16:24], uint64(p.Timestamp))
+	return aad
+}
+
+func aeadNonce(counter uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], counter)
+	return nonce
+}
+
+func (s *secureSession) confirmation() []byte {
+	return s.send.Seal(nil, aeadNonce(0), nil, append(append([]byte{}, s.clientNonce...), s.serverNonce...))
+}
+
+func (s *secureSession) verifyConfirmation(tag []byte) bool {
+	_, err := s.recv.Open(nil, aeadNonce(0), tag, append(append([]byte{}, s.clientNonce...), s.serverNonce...))
+	return err == nil
+}
+
+func (s *secureSession) seal(p *domain.Packet) *domain.Packet {
+	s.mu.Lock()
+	s.sendCounter++
+	counter := s.sendCounter
+	s.mu.Unlock()
+
+	sealed := *p
+	sealed.Data = make([]byte, sealCounterSize, sealCounterSize+len(p.Data)+sealTagSize)
+	binary.BigEndian.PutUint64(sealed.Data, counter)
+	sealed.Data = s.send.Seal(sealed.Data, aeadNonce(counter), p.Data, sealAAD(p))
+	return &sealed
+}
+
+func (s *secureSession) open(p *domain.Packet) error {
+	if len(p.Data) < SealOverhead {
+		return ErrAuthFailed
+	}
+
+	counter := binary.BigEndian.Uint64(p.Data[:sealCounterSize])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.replay.seen(counter) {
+		return ErrReplayedPacket
+	}
+
+	plaintext, err := s.recv.Open(nil, aeadNonce(counter), p.Data[sealCounterSize:], sealAAD(p))
+	if err != nil {
+		return ErrAuthFailed
+	}
+
+	s.replay.mark(counter)
+	s.lastSeen = time.Now()
+	p.Data = plaintext
+	return nil
+}
+
+type securityManager struct {
+	mu               sync.RWMutex
+	psk              []byte
+	sessions         map[uint32]*secureSession
+	pending          map[uint32]*secureSession
+	handshakeTimeout time.Duration
+	authFailures     uint32
+	replays          uint32
+}
+
+func newSecurityManager(psk []byte, handshakeTimeout time.Duration) *securityManager {
+	return &securityManager{
+		psk:              psk,
+		sessions:         make(map[uint32]*secureSession),
+		pending:          make(map[uint32]*secureSession),
+		handshakeTimeout: handshakeTimeout,
+	}
+}
+
+func (sm *securityManager) session(connID uint32) *secureSession {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	return sm.sessions[connID]
+}
+
+func (sm *securityManager) pendingSession(connID uint32) *secureSession {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	return sm.pending[connID]
+}
+
+// accept answers a SYN with a pending session. Anyone can send a SYN, so the
+// session is only established by the first packet that passes authentication.
+
+func (sm *securityManager) accept(connID uint32, cipher byte, clientNonce []byte) (*secureSession, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if existing, ok := sm.sessions[connID]; ok {
+		if string(existing.clientNonce) == string(clientNonce) {
+			return existing, nil
+		}
+		sm.authFailures++
+		return nil, fmt.Errorf("%w: connection %08x already has a session", ErrAuthFailed, connID)
+	}
+
+	if pending, ok := sm.pending[connID]; ok {
+		if string(pending.clientNonce) == string(clientNonce) {
+			return pending, nil
+		}
+		sm.authFailures++
+		return nil, fmt.Errorf("%w: connection %08x already has a pending handshake", ErrAuthFailed, connID)
+	}
+
+	if len(sm.pending) >= maxPendingHandshakes {
+		sm.expirePending(time.Now())
+		if len(sm.pending) >= maxPendingHandshakes {
+			return nil, fmt.Errorf("too many pending handshakes (%d)", len(sm.pending))
+		}
+	}
+
+	session, err := newSecureSession(sm.psk, cipher, connID, clientNonce, newHandshakeNonce(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.pending[connID] = session
+	return session, nil
+}
+
+func (sm *securityManager) establish(connID uint32, session *secureSession) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.pending[connID] == session {
+		delete(sm.pending, connID)
+		sm.sessions[connID] = session
+	}
+}
+
+func (sm *securityManager) expirePending(now time.Time) {
+	for connID, session := range sm.pending {
+		session.mu.Lock()
+		expired := now.Sub(session.lastSeen) > sm.handshakeTimeout
+		session.mu.Unlock()
+
+		if expired {
+			delete(sm.pending, connID)
+		}
+	}
+}
+
+func (sm *securityManager) add(connID uint32, session *secureSession) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.sessions[connID] = session
+}
+
+func (sm *securityManager) expire(idle time.Duration) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	now := time.Now()
+	for connID, session := range sm.sessions {
+		session.mu.Lock()
+		expired := now.Sub(session.lastSeen) > idle
+		session.mu.Unlock()
+
+		if expired {
+			delete(sm.sessions, connID)
+		}
+	}
+	sm.expirePending(now)
+}
+
+func (sm *securityManager) seal(packet *domain.Packet) *domain.Packet {
+	if packet.Type == domain.PacketTypeSyn {
+		return packet
+	}
+	if session := sm.session(packet.ConnID); session != nil {
+		return session.seal(packet)
+	}
+	return packet
+}
+
+func (sm *securityManager) open(packet *domain.Packet) error {
+	if packet.Type == domain.PacketTypeSyn {
+		return nil
+	}
+
+	session := sm.session(packet.ConnID)
+	err := ErrAuthFailed
+	if session != nil {
+		err = session.open(packet)
+	} else if pending := sm.pendingSession(packet.ConnID); pending != nil {
+		if err = pending.open(packet); err == nil {
+			sm.establish(packet.ConnID, pending)
+		}
+	}
+
+	if err != nil {
+		sm.mu.Lock()
+		if errors.Is(err, ErrReplayedPacket) {
+			sm.replays++
+		} else {
+			sm.authFailures++
+		}
+		sm.mu.Unlock()
+	}
+
+	return err
+}
+
+func (sm *securityManager) statistics() (authFailures, replays uint32) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	return sm.authFailures, sm.replays
+}
+
+func (c *UDPClient) handshake() error {
+	id, err := cipherID(c.udpConfig.Cipher)
+	if err != nil {
+		return err
+	}
+
+	clientNonce := newHandshakeNonce()
+	syn := domain.NewPacket(domain.PacketTypeSyn, 0, append(append([]byte{}, clientNonce...), id))
+	syn.Flags = synSecureFlag
+
+	for attempt := 0; attempt < max(c.udpConfig.MaxRetransmissions, 1); attempt++ {
+		if err := c.relMgr.SendPacket(syn, c.serverAddr); err != nil {
+			return fmt.Errorf("failed to send handshake: %w", err)
+		}
+
+		deadline := time.Now().Add(c.udpConfig.RetransmissionTimeout)
+		for time.Now().Before(deadline) {
+			packet, err := c.receive()
+			if err != nil {
+				if isTimeout(err) {
+					continue
+				}
+				return fmt.Errorf("failed to receive handshake: %w", err)
+			}
+
+			if packet == nil || packet.Type != domain.PacketTypeSyn || packet.Flags != synSecureFlag|synReplyFlag ||
+				len(packet.Data) != handshakeNonceSize+sealTagSize {
+				continue
+			}
+
+			serverNonce := packet.Data[:handshakeNonceSize]
+			session, err := newSecureSession([]byte(c.udpConfig.PSK), id, c.relMgr.connID, clientNonce, serverNonce, false)
+			if err != nil {
+				return err
+			}
+			if !session.verifyConfirmation(packet.Data[handshakeNonceSize:]) {
+				return ErrKeyMismatch
+			}
+			c.relMgr.security.add(c.relMgr.connID, session)
+			return nil
+		}
+	}
+
+	return ErrHandshakeTimeout
+}
+
+func (s *UDPServer) handleSecureSyn(packet *domain.Packet, clientAddr *net.UDPAddr) {
+	if s.relMgr.security == nil || packet.Flags != synSecureFlag || len(packet.Data) != handshakeNonceSize+1 {
+		return
+	}
+
+	session, err := s.relMgr.security.accept(packet.ConnID, packet.Data[handshakeNonceSize], packet.Data[:handshakeNonceSize])
+	if err != nil {
+		return
+	}
+
+	reply := domain.NewPacket(domain.PacketTypeSyn, 0, append(append([]byte{}, session.serverNonce...), session.confirmation()...))
+	reply.Flags = synSecureFlag | synReplyFlag
+	reply.ConnID = packet.ConnID
+	s.relMgr.SendPacket(reply, clientAddr)
+}
@@ -0,0 +1,86 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSecureSessionEstablishedByAuthenticatedPacket(t *testing.T) {
+	psk := []byte("s3cret")
+	cipher := cipherIDs[CipherAESGCM]
+	sm := newSecurityManager(psk, time.Minute)
+
+	clientNonce := newHandshakeNonce()
+	pending, err := sm.accept(7, cipher, clientNonce)
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if again, err := sm.accept(7, cipher, clientNonce); err != nil || again != pending {
+		t.Fatalf("retransmitted SYN: got %p, %v, want the pending session", again, err)
+	}
+	if sm.session(7) != nil {
+		t.Fatalf("session established by a SYN alone")
+	}
+
+	forger, _ := newSecureSession([]byte("guess"), cipher, 7, clientNonce, pending.serverNonce, false)
+	forged := forger.seal(commandPacket(7, "ECHO forged"))
+	if err := sm.open(forged); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("forged packet: err = %v, want ErrAuthFailed", err)
+	}
+	if sm.session(7) != nil {
+		t.Fatalf("session established by a forged packet")
+	}
+
+	client, _ := newSecureSession(psk, cipher, 7, clientNonce, pending.serverNonce, false)
+	if err := sm.open(client.seal(commandPacket(7, "ECHO hi"))); err != nil {
+		t.Fatalf("authenticated packet: %v", err)
+	}
+	if sm.session(7) != pending || sm.pendingSession(7) != nil {
+		t.Fatalf("authenticated packet did not establish the session")
+	}
+}
+
+func TestSecurePendingHandshakesCappedAndExpired(t *testing.T) {
+	cipher := cipherIDs[CipherAESGCM]
+	sm := newSecurityManager([]byte("s3cret"), time.Minute)
+
+	for connID := uint32(1); connID <= 2*maxPendingHandshakes; connID++ {
+		sm.accept(connID, cipher, newHandshakeNonce())
+	}
+	if len(sm.sessions) != 0 || len(sm.pending) != maxPendingHandshakes {
+		t.Fatalf("after a SYN flood: %d sessions, %d pending, want 0 and %d",
+			len(sm.sessions), len(sm.pending), maxPendingHandshakes)
+	}
+	if _, err := sm.accept(0, cipher, newHandshakeNonce()); err == nil {
+		t.Fatalf("accepted a handshake past the pending limit")
+	}
+
+	agePending(sm, 2*time.Minute)
+	if _, err := sm.accept(0, cipher, newHandshakeNonce()); err != nil {
+		t.Fatalf("handshake after the flood expired: %v", err)
+	}
+	if len(sm.pending) != 1 {
+		t.Fatalf("%d pending handshakes left after expiry, want 1", len(sm.pending))
+	}
+
+	agePending(sm, 2*time.Minute)
+	sm.expire(time.Hour)
+	if len(sm.pending) != 0 {
+		t.Fatalf("%d pending handshakes left after expire", len(sm.pending))
+	}
+}
+
+// agePending pretends every pending handshake started age earlier.
+func agePending(sm *securityManager, age time.Duration) {
+	for _, session := range sm.pending {
+		session.lastSeen = session.lastSeen.Add(-age)
+	}
+}
+
+func commandPacket(connID uint32, command string) *domain.Packet {
+	packet := domain.NewPacket(domain.PacketTypeCommand, 1, []byte(command))
+	packet.ConnID = connID
+	return packet
+}
@@ -0,0 +1,73 @@
+package network_test
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSecureTransferDropsTamperedAndReplayed(t *testing.T) {
+	cfg := newLoopbackConfig(t)
+	cfg.UDP.PSK = "s3cret"
+	server, addr := startServer(t, cfg)
+
+	var mu sync.Mutex
+	data, tampered, replayed := 0, 0, 0
+	relay := startRelay(t, addr, func(datagram []byte) [][]byte {
+		packet, err := domain.DeserializePacket(datagram)
+		if err != nil || packet.Type != domain.PacketTypeData {
+			return [][]byte{datagram}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		data++
+		switch data % 10 {
+		case 3:
+			// A valid CRC gets the packet past framing to authentication.
+			packet.Data[len(packet.Data)-1] ^= 0x80
+			tampered++
+			return [][]byte{packet.Serialize(), datagram}
+		case 7:
+			replayed++
+			return [][]byte{datagram, datagram}
+		}
+		return [][]byte{datagram}
+	}, nil)
+
+	// Full-size payloads without path MTU discovery must still leave room for
+	// the seal.
+	clientCfg := *cfg
+	clientCfg.UDP.BufferSizes = []int{domain.MaxPayloadSize}
+	clientCfg.UDP.PMTUDiscovery = false
+	client := dialLoopback(t, &clientCfg, relay)
+
+	source := filepath.Join(t.TempDir(), "source.bin")
+	writeRandomFile(t, source, 2*1024*1024, 34)
+	if _, err := client.UploadFile(source, "secure.bin"); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if err := sameContent(source, filepath.Join(cfg.Server.UploadDir, "secure.bin")); err != nil {
+		t.Fatalf("uploaded copy: %v", err)
+	}
+
+	local := filepath.Join(t.TempDir(), "secure.bin")
+	if _, err := client.DownloadFile("secure.bin", local); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if err := sameContent(source, local); err != nil {
+		t.Fatalf("downloaded copy: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if tampered == 0 || replayed == 0 {
+		t.Fatalf("relay tampered with %d and replayed %d of %d DATA packets", tampered, replayed, data)
+	}
+	authFailures, replays := server.SecurityStatistics()
+	if int(authFailures) != tampered || int(replays) != replayed {
+		t.Fatalf("server counted %d unauthenticated and %d replayed packets, relay sent %d and %d",
+			authFailures, replays, tampered, replayed)
+	}
+}
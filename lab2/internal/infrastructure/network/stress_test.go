@@ -0,0 +1,130 @@
+package network_test
+
+import (
+	"NSSaDS/lab2/internal/infrastructure/network"
+	"NSSaDS/lab2/pkg/config"
+	"NSSaDS/lab2/pkg/netem"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// retryBusy repeats transfer while the server refuses it for being at its
+// session limit and counts the refusals in rejected.
+func retryBusy(rejected *atomic.Int64, transfer func() error) error {
+	deadline := time.Now().Add(time.Minute)
+	for {
+		err := transfer()
+		if err == nil || !strings.Contains(err.Error(), network.ErrServerBusy.Error()) || time.Now().After(deadline) {
+			return err
+		}
+		rejected.Add(1)
+		time.Sleep(time.Duration(50+rand.IntN(200)) * time.Millisecond)
+	}
+}
+
+type stressClient struct {
+	client *network.UDPClient
+	dir    string
+	remote string
+}
+
+func newStressClient(t *testing.T, cfg *config.Config, addr string, id, size int) *stressClient {
+	t.Helper()
+	c := &stressClient{
+		client: dialLoopback(t, cfg, addr),
+		dir:    t.TempDir(),
+		remote: fmt.Sprintf("stress_%03d.bin", id),
+	}
+	writeRandomFile(t, filepath.Join(c.dir, "source.bin"), size, byte(id))
+	return c
+}
+
+// run uploads the client's file, downloads it back and checks both copies.
+func (c *stressClient) run(uploadDir string, rejected *atomic.Int64) error {
+	source := filepath.Join(c.dir, "source.bin")
+	if err := retryBusy(rejected, func() error {
+		_, err := c.client.UploadFile(source, c.remote)
+		return err
+	}); err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+	if err := sameContent(source, filepath.Join(uploadDir, c.remote)); err != nil {
+		return fmt.Errorf("uploaded copy: %w", err)
+	}
+
+	local := filepath.Join(c.dir, "download.bin")
+	if err := retryBusy(rejected, func() error {
+		_, err := c.client.DownloadFile(c.remote, local)
+		return err
+	}); err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+	if err := sameContent(source, local); err != nil {
+		return fmt.Errorf("downloaded copy: %w", err)
+	}
+	return nil
+}
+
+func TestConcurrentTransfers(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxSessions int
+		loss        float64
+	}{
+		{"clean", 64, 0},
+		{"session-limit", 4, 0},
+		{"loss", 64, 0.03},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients, size := 16, 1024*1024
+			if testing.Short() {
+				clients, size = 8, 128*1024
+			}
+
+			cfg := newLoopbackConfig(t)
+			cfg.Server.MaxSessions = tt.maxSessions
+			addr := startLoopbackServer(t, cfg)
+			var proxy *netem.UDPProxy
+			if tt.loss > 0 {
+				proxy = startProxy(t, netem.Config{Loss: tt.loss, Seed: 40}, addr)
+				addr = proxy.Addr().String()
+			}
+
+			stress := make([]*stressClient, clients)
+			for i := range stress {
+				stress[i] = newStressClient(t, cfg, addr, i, size)
+			}
+
+			var rejected atomic.Int64
+			var wg sync.WaitGroup
+			errs := make(chan error, clients)
+			for i, c := range stress {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := c.run(cfg.Server.UploadDir, &rejected); err != nil {
+						errs <- fmt.Errorf("client %d: %w", i, err)
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Error(err)
+			}
+			t.Logf("%d clients verified, %d busy rejections retried", clients, rejected.Load())
+			if proxy != nil && proxy.Stats().Dropped == 0 {
+				t.Fatalf("proxy dropped nothing at %.0f%% loss", tt.loss*100)
+			}
+		})
+	}
+}
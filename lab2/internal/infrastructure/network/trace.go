@@ -0,0 +1,214 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const traceMagic = "L2TRACE1"
+
+type TraceDirection uint8
+
+const (
+	TraceSent     TraceDirection = 1
+	TraceReceived TraceDirection = 2
+)
+
+func (d TraceDirection) String() string {
+	switch d {
+	case TraceSent:
+		return "SENT"
+	case TraceReceived:
+		return "RECV"
+	default:
+		return fmt.Sprintf("DIR%d", uint8(d))
+	}
+}
+
+type TraceStatus uint8
+
+const (
+	TraceOK        TraceStatus = 0
+	TraceMalformed TraceStatus = 1
+	TraceDropped   TraceStatus = 2
+)
+
+func (s TraceStatus) String() string {
+	switch s {
+	case TraceOK:
+		return "ok"
+	case TraceMalformed:
+		return "bad-checksum"
+	case TraceDropped:
+		return "auth-dropped"
+	default:
+		return fmt.Sprintf("status%d", uint8(s))
+	}
+}
+
+var ErrNotATrace = errors.New("not a lab2 packet trace")
+
+type Tracer struct {
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	snapLen int
+	records uint64
+	err     error
+}
+
+type TraceRecord struct {
+	Time      time.Time
+	Direction TraceDirection
+	Status    TraceStatus
+	Addr      string
+	Length    int
+	Data      []byte
+}
+
+type TraceReader struct {
+	file *os.File
+	r    *bufio.Reader
+}
+
+func NewTracer(path string, snapLen int) (*Tracer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace file: %w", err)
+	}
+
+	t := &Tracer{
+		file:    file,
+		w:       bufio.NewWriterSize(file, 256*1024),
+		snapLen: snapLen,
+	}
+
+	if _, err := t.w.WriteString(traceMagic); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write trace header: %w", err)
+	}
+
+	return t, nil
+}
+
+func (t *Tracer) Record(direction TraceDirection, status TraceStatus, data []byte, addr *net.UDPAddr) {
+	captured := data
+	if t.snapLen > 0 && len(captured) > t.snapLen {
+		captured = captured[:t.snapLen]
+	}
+
+	var peer string
+	if addr != nil {
+		peer = addr.String()
+	}
+
+	var header [20]byte
+	binary.BigEndian.PutUint64(header[0:8], uint64(time.Now().UnixNano()))
+	header[8] = byte(direction)
+	header[9] = byte(status)
+	binary.BigEndian.PutUint16(header[10:12], uint16(len(peer)))
+	binary.BigEndian.PutUint32(header[12:16], uint32(len(data)))
+	binary.BigEndian.PutUint32(header[16:20], uint32(len(captured)))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return
+	}
+
+	t.w.Write(header[:])
+	t.w.WriteString(peer)
+	if _, err := t.w.Write(captured); err != nil {
+		t.err = err
+		fmt.Printf("Packet trace disabled: %v\n", err)
+		return
+	}
+	t.records++
+}
+
+func (t *Tracer) Records() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.records
+}
+
+func (t *Tracer) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.w.Flush()
+}
+
+func (t *Tracer) Close() error {
+	if err := t.Flush(); err != nil {
+		t.file.Close()
+		return err
+	}
+	return t.file.Close()
+}
+
+func OpenTrace(path string) (*TraceReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReaderSize(file, 256*1024)
+	magic := make([]byte, len(traceMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != traceMagic {
+		file.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNotATrace, path)
+	}
+
+	return &TraceReader{file: file, r: r}, nil
+}
+
+func (tr *TraceReader) Next() (*TraceRecord, error) {
+	var header [20]byte
+	if _, err := io.ReadFull(tr.r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	peer := make([]byte, binary.BigEndian.Uint16(header[10:12]))
+	data := make([]byte, binary.BigEndian.Uint32(header[16:20]))
+	if _, err := io.ReadFull(tr.r, peer); err != nil {
+		return nil, io.EOF
+	}
+	if _, err := io.ReadFull(tr.r, data); err != nil {
+		return nil, io.EOF
+	}
+
+	return &TraceRecord{
+		Time:      time.Unix(0, int64(binary.BigEndian.Uint64(header[0:8]))),
+		Direction: TraceDirection(header[8]),
+		Status:    TraceStatus(header[9]),
+		Addr:      string(peer),
+		Length:    int(binary.BigEndian.Uint32(header[12:16])),
+		Data:      data,
+	}, nil
+}
+
+func (tr *TraceReader) Close() error {
+	return tr.file.Close()
+}
+
+func (r *TraceRecord) Header() (*domain.Packet, error) {
+	return domain.DecodeHeader(r.Data)
+}
+
+func (r *TraceRecord) Complete() bool {
+	return len(r.Data) == r.Length
+}
@@ -0,0 +1,78 @@
+package network
+
+import (
+	"net"
+	"sync"
+)
+
+const datagramBufferSize = 65536
+
+type Datagram struct {
+	Buf  []byte
+	N    int
+	Addr *net.UDPAddr
+}
+
+type Transport interface {
+	ReadBatch(batch []Datagram) (int, error)
+	WriteBatch(batch []Datagram) (int, error)
+	BatchSize() int
+}
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, datagramBufferSize)
+		return &buf
+	},
+}
+
+func getBuffer() []byte {
+	return *bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(buf []byte) {
+	if cap(buf) < datagramBufferSize {
+		return
+	}
+	buf = buf[:datagramBufferSize]
+	bufferPool.Put(&buf)
+}
+
+func NewTransport(conn *net.UDPConn, batchSize int) Transport {
+	if batchSize <= 1 {
+		return &packetTransport{conn: conn}
+	}
+	return newBatchTransport(conn, batchSize)
+}
+
+type packetTransport struct {
+	conn *net.UDPConn
+}
+
+func (t *packetTransport) BatchSize() int {
+	return 1
+}
+
+func (t *packetTransport) ReadBatch(batch []Datagram) (int, error) {
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	n, addr, err := t.conn.ReadFromUDP(batch[0].Buf)
+	if err != nil {
+		return 0, err
+	}
+
+	batch[0].N = n
+	batch[0].Addr = addr
+	return 1, nil
+}
+
+func (t *packetTransport) WriteBatch(batch []Datagram) (int, error) {
+	for i := range batch {
+		if _, err := t.conn.WriteToUDP(batch[i].Buf[:batch[i].N], batch[i].Addr); err != nil {
+			return i, err
+		}
+	}
+	return len(batch), nil
+}
@@ -0,0 +1,81 @@
+package network
+
+import (
+	"net"
+	"sync"
+
+	"golang.org/x/net/ipv4"
+)
+
+type batchTransport struct {
+	conn      *ipv4.PacketConn
+	batchSize int
+	rxMu      sync.Mutex
+	rx        []ipv4.Message
+	txMu      sync.Mutex
+	tx        []ipv4.Message
+}
+
+func newBatchTransport(conn *net.UDPConn, batchSize int) Transport {
+	t := &batchTransport{
+		conn:      ipv4.NewPacketConn(conn),
+		batchSize: batchSize,
+		rx:        make([]ipv4.Message, batchSize),
+		tx:        make([]ipv4.Message, batchSize),
+	}
+	for i := range t.rx {
+		t.rx[i].Buffers = make([][]byte, 1)
+		t.tx[i].Buffers = make([][]byte, 1)
+	}
+	return t
+}
+
+func (t *batchTransport) BatchSize() int {
+	return t.batchSize
+}
+
+func (t *batchTransport) ReadBatch(batch []Datagram) (int, error) {
+	t.rxMu.Lock()
+	defer t.rxMu.Unlock()
+
+	count := min(len(batch), t.batchSize)
+	for i := 0; i < count; i++ {
+		t.rx[i].Buffers[0] = batch[i].Buf
+	}
+
+	n, err := t.conn.ReadBatch(t.rx[:count], 0)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < n; i++ {
+		batch[i].N = t.rx[i].N
+		batch[i].Addr, _ = t.rx[i].Addr.(*net.UDPAddr)
+	}
+	return n, nil
+}
+
+func (t *batchTransport) WriteBatch(batch []Datagram) (int, error) {
+	t.txMu.Lock()
+	defer t.txMu.Unlock()
+
+	written := 0
+	for written < len(batch) {
+		count := min(len(batch)-written, t.batchSize)
+		for i := 0; i < count; i++ {
+			datagram := &batch[written+i]
+			t.tx[i].Buffers[0] = datagram.Buf[:datagram.N]
+			t.tx[i].Addr = datagram.Addr
+		}
+
+		n, err := t.conn.WriteBatch(t.tx[:count], 0)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return written, nil
+}
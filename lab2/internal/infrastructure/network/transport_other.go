@@ -0,0 +1,9 @@
+//go:build !linux
+
+package network
+
+import "net"
+
+func newBatchTransport(conn *net.UDPConn, batchSize int) Transport {
+	return &packetTransport{conn: conn}
+}
@@ -0,0 +1,42 @@
+package network_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileNamesCannotEscapeUploadDir(t *testing.T) {
+	cfg := newLoopbackConfig(t)
+	addr := startLoopbackServer(t, cfg)
+	client := dialLoopback(t, cfg, addr)
+
+	parent := filepath.Dir(cfg.Server.UploadDir)
+	secret := filepath.Join(parent, "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatalf("writing %s: %v", secret, err)
+	}
+
+	for _, name := range []string{"../escaped.bin", "..", "/tmp/escaped.bin", `..\escaped.bin`, "sub/escaped.bin"} {
+		response, err := client.SendCommand("UPLOAD", []string{name, "6"})
+		if err != nil || !strings.Contains(response, "invalid file name") {
+			t.Fatalf("UPLOAD %q answered %q, %v", name, response, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escaped.bin")); !os.IsNotExist(err) {
+		t.Fatalf("upload escaped the upload directory: %v", err)
+	}
+
+	for _, name := range []string{"../secret.txt", "../../../../../../etc/hostname", ".."} {
+		response, err := client.SendCommand("DOWNLOAD", []string{name})
+		if err != nil || !strings.Contains(response, "invalid file name") {
+			t.Fatalf("DOWNLOAD %q answered %q, %v", name, response, err)
+		}
+	}
+
+	local := filepath.Join(t.TempDir(), "secret.txt")
+	if _, err := client.DownloadFile("../secret.txt", local); err == nil {
+		t.Fatalf("downloaded a file from outside the upload directory")
+	}
+}
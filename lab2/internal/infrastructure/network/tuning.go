@@ -0,0 +1,243 @@
+package network
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"time"
+)
+
+const tuneRemoteName = "buffer_tune.bin"
+
+type BufferTuneRun struct {
+	Goodput     float64 `json:"goodput_mbps"`
+	Elapsed     float64 `json:"elapsed_seconds"`
+	PacketsSent uint32  `json:"packets_sent"`
+	Retransmits uint32  `json:"retransmits"`
+	PacketsLost uint32  `json:"packets_lost"`
+	Error       string  `json:"error,omitempty"`
+}
+
+type BufferTuneResult struct {
+	PayloadSize    int             `json:"payload_size"`
+	Runs           []BufferTuneRun `json:"runs"`
+	Failures       int             `json:"failures"`
+	MeanGoodput    float64         `json:"mean_goodput_mbps"`
+	StdDevGoodput  float64         `json:"stddev_goodput_mbps"`
+	Confidence95   float64         `json:"ci95_goodput_mbps"`
+	MinGoodput     float64         `json:"min_goodput_mbps"`
+	MaxGoodput     float64         `json:"max_goodput_mbps"`
+	RetransmitRate float64         `json:"retransmit_rate"`
+	LossRate       float64         `json:"loss_rate"`
+}
+
+type BufferTuneReport struct {
+	FileSize    int64              `json:"file_size"`
+	Repeats     int                `json:"repeats"`
+	Results     []BufferTuneResult `json:"results"`
+	Best        int                `json:"best_payload_size"`
+	Recommended int                `json:"recommended_payload_size"`
+}
+
+func BufferSizeRange(minSize, maxSize, step int) []int {
+	if step <= 0 {
+		step = 1
+	}
+
+	var sizes []int
+	for size := max(minSize, 1); size <= min(maxSize, domain.MaxPayloadSize); size += step {
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+func (c *UDPClient) TuneBufferSizes(sizes []int, fileSize int64, repeats int) (*BufferTuneReport, error) {
+	if !c.connected {
+		return nil, fmt.Errorf("not connected to server")
+	}
+
+	if len(sizes) == 0 {
+		return nil, fmt.Errorf("no buffer sizes to test")
+	}
+
+	if fileSize <= 0 {
+		return nil, fmt.Errorf("invalid test file size: %d", fileSize)
+	}
+
+	repeats = max(repeats, 1)
+
+	testFile, err := createTestFile(fileSize)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(testFile)
+
+	report := &BufferTuneReport{
+		FileSize: fileSize,
+		Repeats:  repeats,
+	}
+
+	// FEC, the seal and the path MTU leave less room than a bare datagram.
+	limit := c.maxPayloadSize()
+	for _, size := range sizes {
+		if size <= 0 || size > limit {
+			fmt.Printf("Skipping buffer size %d: must be between 1 and %d\n", size, limit)
+			continue
+		}
+
+		result := BufferTuneResult{PayloadSize: size}
+
+		for run := 0; run < repeats; run++ {
+			result.Runs = append(result.Runs, c.tuneRun(testFile, fileSize, size))
+		}
+
+		result.summarize(packetCount(fileSize, size))
+		report.Results = append(report.Results, result)
+
+		if result.Failures < len(result.Runs) {
+			c.perfMonitor.RecordBufferTest(size, result.MeanGoodput)
+		}
+
+		fmt.Printf("Buffer size %d: %.2f ± %.2f MB/s, %.2f%% retransmitted, %d/%d runs failed\n",
+			size, result.MeanGoodput, result.Confidence95, result.RetransmitRate*100, result.Failures, len(result.Runs))
+	}
+
+	report.recommend()
+
+	if report.Recommended == 0 {
+		return report, fmt.Errorf("all buffer size runs failed")
+	}
+
+	return report, nil
+}
+
+func (c *UDPClient) tuneRun(testFile string, fileSize int64, payloadSize int) BufferTuneRun {
+	sentBefore, lostBefore, retransBefore := c.relMgr.GetStatistics()
+	start := time.Now()
+
+	_, err := c.upload(testFile, tuneRemoteName, payloadSize, false)
+
+	elapsed := time.Since(start).Seconds()
+	sentAfter, lostAfter, retransAfter := c.relMgr.GetStatistics()
+
+	run := BufferTuneRun{
+		Elapsed:     elapsed,
+		PacketsSent: sentAfter - sentBefore,
+		Retransmits: retransAfter - retransBefore,
+		PacketsLost: lostAfter - lostBefore,
+	}
+
+	if err != nil {
+		run.Error = err.Error()
+	} else if elapsed > 0 {
+		run.Goodput = float64(fileSize) / elapsed / 1024 / 1024
+	}
+
+	return run
+}
+
+func (r *BufferTuneResult) summarize(dataPackets uint32) {
+	var goodputs []float64
+	var retransmits, lost uint32
+
+	for _, run := range r.Runs {
+		retransmits += run.Retransmits
+		lost += run.PacketsLost
+
+		if run.Error != "" {
+			r.Failures++
+			continue
+		}
+		goodputs = append(goodputs, run.Goodput)
+	}
+
+	if attempted := float64(dataPackets) * float64(len(r.Runs)); attempted > 0 {
+		r.RetransmitRate = float64(retransmits) / attempted
+		r.LossRate = float64(lost) / attempted
+	}
+
+	if len(goodputs) == 0 {
+		return
+	}
+
+	r.MinGoodput, r.MaxGoodput = goodputs[0], goodputs[0]
+	var sum float64
+	for _, goodput := range goodputs {
+		sum += goodput
+		r.MinGoodput = min(r.MinGoodput, goodput)
+		r.MaxGoodput = max(r.MaxGoodput, goodput)
+	}
+	r.MeanGoodput = sum / float64(len(goodputs))
+
+	if len(goodputs) > 1 {
+		var variance float64
+		for _, goodput := range goodputs {
+			variance += (goodput - r.MeanGoodput) * (goodput - r.MeanGoodput)
+		}
+		r.StdDevGoodput = math.Sqrt(variance / float64(len(goodputs)-1))
+		r.Confidence95 = 1.96 * r.StdDevGoodput / math.Sqrt(float64(len(goodputs)))
+	}
+}
+
+func (r *BufferTuneReport) recommend() {
+	var best *BufferTuneResult
+	for i := range r.Results {
+		result := &r.Results[i]
+		if result.Failures == len(result.Runs) {
+			continue
+		}
+		if best == nil || result.MeanGoodput > best.MeanGoodput {
+			best = result
+		}
+	}
+
+	if best == nil {
+		return
+	}
+	r.Best = best.PayloadSize
+
+	candidates := make([]BufferTuneResult, len(r.Results))
+	copy(candidates, r.Results)
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].PayloadSize < candidates[j].PayloadSize
+	})
+
+	for _, result := range candidates {
+		if result.Failures > 0 {
+			continue
+		}
+		if result.MeanGoodput+result.Confidence95 >= best.MeanGoodput-best.Confidence95 {
+			r.Recommended = result.PayloadSize
+			return
+		}
+	}
+
+	r.Recommended = best.PayloadSize
+}
+
+func createTestFile(size int64) (string, error) {
+	file, err := os.CreateTemp("", "udp_tune_*.bin")
+	if err != nil {
+		return "", fmt.Errorf("failed to create test file: %w", err)
+	}
+	defer file.Close()
+
+	rng := rand.NewChaCha8([32]byte{})
+	buffer := make([]byte, 64*1024)
+
+	for written := int64(0); written < size; {
+		n := int(min(int64(len(buffer)), size-written))
+		rng.Read(buffer[:n])
+
+		if _, err := file.Write(buffer[:n]); err != nil {
+			os.Remove(file.Name())
+			return "", fmt.Errorf("failed to write test file: %w", err)
+		}
+		written += int64(n)
+	}
+
+	return file.Name(), nil
+}
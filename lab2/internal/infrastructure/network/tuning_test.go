@@ -0,0 +1,41 @@
+package network_test
+
+import (
+	"NSSaDS/lab2/internal/domain"
+	"NSSaDS/lab2/internal/infrastructure/network"
+	"testing"
+)
+
+func TestTuneSkipsSizesAboveEffectiveCap(t *testing.T) {
+	cfg := newLoopbackConfig(t)
+	cfg.UDP.PSK = "s3cret"
+	cfg.UDP.FECBlockSize = 4
+	cfg.UDP.FECParity = 2
+	addr := startLoopbackServer(t, cfg)
+	client := dialLoopback(t, cfg, addr)
+
+	// A full datagram leaves no room for the FEC length prefix or the seal.
+	report, err := client.TuneBufferSizes([]int{4096, domain.MaxPayloadSize}, 64*1024, 1)
+	if err != nil {
+		t.Fatalf("tune: %v", err)
+	}
+	if len(report.Results) != 1 || report.Results[0].PayloadSize != 4096 {
+		t.Fatalf("tuned %+v, want only the 4096 byte payload", report.Results)
+	}
+	if report.Results[0].Failures != 0 {
+		t.Fatalf("4096 byte payload failed: %+v", report.Results[0].Runs)
+	}
+}
+
+func TestOptimalBufferSizeOnlyFromTuning(t *testing.T) {
+	pm := network.NewPerformanceMonitor()
+	if size, bitrate := pm.CalculateOptimalBufferSize(); size != 0 || bitrate != 0 {
+		t.Fatalf("optimal size %d (%.2f MB/s) reported before any tuning", size, bitrate)
+	}
+
+	pm.RecordBufferTest(1024, 5)
+	pm.RecordBufferTest(4096, 9)
+	if size, bitrate := pm.CalculateOptimalBufferSize(); size != 4096 || bitrate != 9 {
+		t.Fatalf("optimal size %d (%.2f MB/s), want 4096 (9.00 MB/s)", size, bitrate)
+	}
+}
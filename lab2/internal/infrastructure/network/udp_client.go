@@ -4,7 +4,10 @@ import (
 	"NSSaDS/lab2/internal/domain"
 	"NSSaDS/lab2/pkg/config"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net"
 	"os"
 	"strconv"
@@ -22,8 +25,19 @@ type UDPClient struct {
 	fileMgr     domain.FileManager
 	perfMonitor *PerformanceMonitor
 	connected   bool
+	maxPayload  int
+	probeSeq    uint32
+	commandSeq  uint32
+	lastHeard   time.Time
+	lastPing    time.Time
+	pingsMissed int
+	resumeAt    int64
+	resumeToken string
+	tracer      *Tracer
 }
 
+var ErrTransferStalled = errors.New("transfer stalled")
+
 func NewUDPClient(cfg *config.ClientConfig, udpCfg *config.UDPConfig, fileMgr domain.FileManager) *UDPClient {
 	return &UDPClient{
 		config:      cfg,
@@ -35,30 +49,65 @@ func NewUDPClient(cfg *config.ClientConfig, udpCfg *config.UDPConfig, fileMgr do
 
 func (c *UDPClient) Connect(ctx context.Context, addr string) error {
 	var err error
-	c.conn, err = net.DialUDP("udp", nil, nil)
+	c.serverAddr, err = net.ResolveUDPAddr("udp", addr)
 	if err != nil {
-		return fmt.Errorf("failed to create UDP connection: %w", err)
+		return fmt.Errorf("failed to resolve server address: %w", err)
 	}
 
-	c.serverAddr, err = net.ResolveUDPAddr("udp", addr)
+	c.conn, err = net.ListenUDP("udp", nil)
 	if err != nil {
-		return fmt.Errorf("failed to resolve server address: %w", err)
+		return fmt.Errorf("failed to create UDP connection: %w", err)
 	}
 
+	setSocketBuffers(c.conn, c.udpConfig.SocketBufferSize)
+
 	c.relMgr = NewReliabilityManager(c.conn, c.udpConfig.PacketTimeout,
 		c.udpConfig.RetransmissionTimeout, c.udpConfig.MaxRetransmissions)
+	c.relMgr.SetConnectionID(newConnectionID())
+	c.relMgr.SetTransport(NewTransport(c.conn, c.udpConfig.BatchSize))
+
+	if c.udpConfig.TracePath != "" && c.tracer == nil {
+		c.tracer, err = NewTracer(c.udpConfig.TracePath, c.udpConfig.TraceSnapLen)
+		if err != nil {
+			c.relMgr.Stop()
+			c.conn.Close()
+			return err
+		}
+		fmt.Printf("Tracing packets to %s\n", c.udpConfig.TracePath)
+	}
+	if c.tracer != nil {
+		c.relMgr.SetTracer(c.tracer)
+	}
+
+	if c.udpConfig.PSK != "" {
+		c.relMgr.EnableSecurity([]byte(c.udpConfig.PSK))
+		if err := c.handshake(); err != nil {
+			c.relMgr.Stop()
+			c.conn.Close()
+			return fmt.Errorf("secure handshake failed: %w", err)
+		}
+		fmt.Printf("Secure mode: %s session established\n", c.udpConfig.Cipher)
+	}
 
 	c.connMgr = NewUDPConnectionManager(c.conn, c.relMgr, c.udpConfig)
 
 	c.connected = true
 	fmt.Printf("Connected to UDP server: %s\n", addr)
 
+	if c.udpConfig.PMTUDiscovery {
+		if err := enablePMTUProbe(c.conn); err != nil {
+			fmt.Printf("Warning: cannot set DF on probes, falling back to plain binary search: %v\n", err)
+		}
+		c.probePathMTU()
+	}
+
 	return nil
 }
 
 func (c *UDPClient) Disconnect() error {
 	if c.conn != nil {
 		c.connected = false
+		c.relMgr.Stop()
 		err := c.conn.Close()
 		c.conn = nil
 		return err
@@ -77,34 +126,96 @@ func (c *UDPClient) SendCommand(cmd string, args []string) (string, error) {
 	}
 
 	packet := domain.NewPacket(domain.PacketTypeCommand, 0, []byte(command))
-	if err := c.relMgr.SendPacket(packet, c.serverAddr); err != nil {
+	response, err := c.exchange(packet)
+	if err != nil {
 		return "", fmt.Errorf("failed to send command: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
-	defer cancel()
+	return response, nil
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return "", fmt.Errorf("command timeout")
-		default:
-			responsePacket, _, err := c.relMgr.ReceivePacket()
-			if err != nil {
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-					continue
-				}
-				return "", fmt.Errorf("failed to receive response: %w", err)
+func (c *UDPClient) exchange(packet *domain.Packet) (string, error) {
+	c.commandSeq++
+	packet.SeqNum = c.commandSeq
+	c.resetPeer()
+
+	deadline := time.Now().Add(c.config.Timeout)
+	var lastSent time.Time
+	attempts := 0
+
+	for time.Now().Before(deadline) {
+		if time.Since(lastSent) >= c.udpConfig.RetransmissionTimeout {
+			if err := c.relMgr.SendPacket(packet, c.serverAddr); err != nil {
+				return "", err
 			}
+			lastSent = time.Now()
+			attempts++
+		}
+
+		if err := c.checkPeer(); err != nil {
+			return "", err
+		}
 
-			if responsePacket.Type == domain.PacketTypeResponse {
-				return string(responsePacket.Data), nil
+		responsePacket, err := c.receive()
+		if err != nil {
+			if isTimeout(err) {
+				continue
 			}
+			return "", fmt.Errorf("failed to receive response: %w", err)
+		}
+
+		if responsePacket != nil && responsePacket.Type == domain.PacketTypeResponse &&
+			responsePacket.SeqNum == packet.SeqNum+1 {
+			if attempts == 1 {
+				c.relMgr.ObserveRTT(c.relMgr.connID, time.Since(lastSent))
+			}
+			return string(responsePacket.Data), nil
 		}
 	}
+
+	return "", fmt.Errorf("command timeout")
+}
+
+func (c *UDPClient) receive() (*domain.Packet, error) {
+	return c.receiveWithin(c.udpConfig.PacketTimeout)
+}
+
+func (c *UDPClient) receiveWithin(timeout time.Duration) (*domain.Packet, error) {
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+
+	packet, addr, err := c.relMgr.ReceivePacket()
+	if err != nil {
+		if errors.Is(err, errInvalidPacket) || errors.Is(err, errPacketDropped) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if addr.String() != c.serverAddr.String() || packet.ConnID != c.relMgr.connID {
+		return nil, nil
+	}
+
+	if c.heard(packet) {
+		return nil, nil
+	}
+
+	return packet, nil
 }
 
 func (c *UDPClient) UploadFile(localPath, remoteName string) (*domain.TransferProgress, error) {
+	payloadSize := c.payloadSize()
+
+	progress, err := c.upload(localPath, remoteName, payloadSize, false)
+	if c.blackHoleSuspected(err, payloadSize) {
+		progress, err = c.upload(localPath, remoteName, c.payloadSize(), false)
+	}
+
+	return c.resumeAfterPeerDeath(progress, err, func() (*domain.TransferProgress, error) {
+		return c.upload(localPath, remoteName, c.payloadSize(), true)
+	})
+}
+
+func (c *UDPClient) upload(localPath, remoteName string, payloadSize int, resume bool) (*domain.TransferProgress, error) {
 	if !c.connected {
 		return nil, fmt.Errorf("not connected to server")
 	}
@@ -114,28 +225,112 @@ func (c *UDPClient) UploadFile(localPath, remoteName string) (*domain.TransferPr
 		return nil, fmt.Errorf("failed to get file info: %w", err)
 	}
 
+	if err := validateFEC(c.udpConfig.FECBlockSize, c.udpConfig.FECParity); err != nil {
+		return nil, err
+	}
+
 	c.perfMonitor.StartTransfer(localPath, fileInfo.Size())
 
-	cmd := fmt.Sprintf("UPLOAD %s", remoteName)
-	response, err := c.SendCommand(cmd, []string{})
+	args := append([]string{remoteName, strconv.FormatInt(fileInfo.Size(), 10), strconv.Itoa(payloadSize)}, c.fecArgs()...)
+	if resume && c.resumeToken != "" {
+		args = append(args, "RESUME", c.resumeToken)
+	}
+
+	response, err := c.SendCommand("UPLOAD", args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send upload command: %w", err)
 	}
 
-	if !strings.Contains(response, "READY") {
+	if !strings.HasPrefix(response, "READY") {
 		return nil, fmt.Errorf("server not ready: %s", response)
 	}
 
-	return c.sendFile(localPath, fileInfo.Size())
+	var offset int64
+	parts := strings.Fields(response)
+	if len(parts) >= 7 {
+		offset, err = strconv.ParseInt(parts[6], 10, 64)
+		if err != nil || offset < 0 || offset > fileInfo.Size() {
+			return nil, fmt.Errorf("invalid resume offset: %s", parts[6])
+		}
+	}
+	c.resumeToken = ""
+	if len(parts) >= 8 {
+		c.resumeToken = parts[7]
+	}
+
+	if offset > 0 {
+		fmt.Printf("Resuming upload of %s at byte %d\n", remoteName, offset)
+	}
+
+	progress, err := c.sendFile(localPath, fileInfo.Size(), payloadSize, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err = c.finishTransfer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to finish upload: %w", err)
+	}
+
+	if !strings.HasPrefix(response, "UPLOADED") {
+		return nil, fmt.Errorf("upload not confirmed: %s", response)
+	}
+
+	parts = strings.Fields(response)
+	if len(parts) >= 3 {
+		received, err := strconv.ParseInt(parts[2], 10, 64)
+		if err == nil && received != fileInfo.Size() {
+			return nil, fmt.Errorf("size mismatch: sent %d bytes, server received %d", fileInfo.Size(), received)
+		}
+	}
+
+	if len(parts) >= 4 {
+		if recovered, err := strconv.ParseUint(parts[3], 10, 32); err == nil {
+			progress.FECRecovered = uint32(recovered)
+			c.perfMonitor.UpdateFEC(progress.ParityPackets, progress.FECRecovered)
+		}
+	}
+
+	if len(parts) >= 5 {
+		if duplicates, err := strconv.ParseUint(parts[4], 10, 32); err == nil {
+			progress.Duplicates = uint32(duplicates)
+			c.perfMonitor.UpdateDuplicates(progress.Duplicates)
+		}
+	}
+
+	return progress, nil
 }
 
 func (c *UDPClient) DownloadFile(remoteName, localPath string) (*domain.TransferProgress, error) {
+	payloadSize := c.payloadSize()
+
+	progress, err := c.download(remoteName, localPath, payloadSize, 0)
+	if c.blackHoleSuspected(err, payloadSize) {
+		progress, err = c.download(remoteName, localPath, c.payloadSize(), 0)
+	}
+
+	return c.resumeAfterPeerDeath(progress, err, func() (*domain.TransferProgress, error) {
+		return c.download(remoteName, localPath, c.payloadSize(), c.resumeAt)
+	})
+}
+
+func (c *UDPClient) download(remoteName, localPath string, requestedPayload int, offset int64) (*domain.TransferProgress, error) {
 	if !c.connected {
 		return nil, fmt.Errorf("not connected to server")
 	}
 
-	cmd := fmt.Sprintf("DOWNLOAD %s", remoteName)
-	response, err := c.SendCommand(cmd, []string{})
+	if err := validateFEC(c.udpConfig.FECBlockSize, c.udpConfig.FECParity); err != nil {
+		return nil, err
+	}
+
+	c.resumeAt = offset
+
+	args := append([]string{remoteName, strconv.Itoa(requestedPayload)}, c.fecArgs()...)
+	if offset > 0 {
+		args = append(args, strconv.FormatInt(offset, 10))
+	}
+
+	response, err := c.SendCommand("DOWNLOAD", args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send download command: %w", err)
 	}
@@ -145,7 +340,7 @@ func (c *UDPClient) DownloadFile(remoteName, localPath string) (*domain.Transfer
 	}
 
 	parts := strings.Fields(response)
-	if len(parts) < 3 {
+	if len(parts) < 3 || parts[0] != "FILE_INFO" {
 		return nil, fmt.Errorf("invalid file info response: %s", response)
 	}
 
@@ -154,108 +349,328 @@ func (c *UDPClient) DownloadFile(remoteName, localPath string) (*domain.Transfer
 		return nil, fmt.Errorf("invalid file size: %w", err)
 	}
 
+	payloadSize := requestedPayload
+	if len(parts) >= 4 {
+		payloadSize, err = strconv.Atoi(parts[3])
+		if err != nil || payloadSize <= 0 {
+			return nil, fmt.Errorf("invalid payload size: %s", parts[3])
+		}
+	}
+
+	var fecBlock, fecParity int
+	if len(parts) >= 6 {
+		fecBlock, _ = strconv.Atoi(parts[4])
+		fecParity, _ = strconv.Atoi(parts[5])
+		if err := validateFEC(fecBlock, fecParity); err != nil {
+			return nil, err
+		}
+	}
+
+	offset = 0
+	if len(parts) >= 7 {
+		offset, err = strconv.ParseInt(parts[6], 10, 64)
+		if err != nil || offset < 0 || offset > fileSize {
+			return nil, fmt.Errorf("invalid resume offset: %s", parts[6])
+		}
+	}
+
+	if offset > 0 {
+		fmt.Printf("Resuming download of %s at byte %d\n", remoteName, offset)
+	}
+
 	c.perfMonitor.StartTransfer(remoteName, fileSize)
 
-	return c.receiveFile(localPath, fileSize)
+	progress, err := c.receiveFile(localPath, fileSize, payloadSize, fecBlock, fecParity, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err = c.finishTransfer()
+	if err != nil {
+		fmt.Printf("Warning: failed to finish download: %v\n", err)
+		return progress, nil
+	}
+
+	parts = strings.Fields(response)
+	if len(parts) >= 4 && parts[0] == "DOWNLOADED" {
+		if retransmits, err := strconv.ParseUint(parts[3], 10, 32); err == nil {
+			progress.Retransmits = uint32(retransmits)
+			c.perfMonitor.UpdateStatistics(progress.PacketsSent, progress.PacketsLost, progress.Retransmits)
+		}
+	}
+
+	return progress, nil
+}
+
+func (c *UDPClient) payloadSize() int {
+	return min(c.udpConfig.BufferSizes[len(c.udpConfig.BufferSizes)/2], c.maxPayloadSize())
+}
+
+func (c *UDPClient) maxPayloadSize() int {
+	return maxPayloadSize(c.maxPayload, c.relMgr != nil && c.relMgr.security != nil, c.udpConfig.FECBlockSize)
+}
+
+func (c *UDPClient) fecArgs() []string {
+	return []string{strconv.Itoa(c.udpConfig.FECBlockSize), strconv.Itoa(c.udpConfig.FECParity)}
+}
+
+func (c *UDPClient) sendParity(packets []*domain.Packet) {
+	if len(packets) == 0 {
+		return
+	}
+
+	if err := c.relMgr.SendPackets(packets, c.serverAddr); err != nil {
+		fmt.Printf("Warning: failed to send parity packet: %v\n", err)
+	}
 }
 
-func (c *UDPClient) sendFile(localPath string, fileSize int64) (*domain.TransferProgress, error) {
+func (c *UDPClient) finishTransfer() (string, error) {
+	c.relMgr.ClearPending(c.relMgr.connID)
+
+	return c.exchange(domain.NewPacket(domain.PacketTypeFin, 0, nil))
+}
+
+func (c *UDPClient) stallTimeout() time.Duration {
+	return c.udpConfig.RetransmissionTimeout * time.Duration(c.udpConfig.MaxRetransmissions+1)
+}
+
+func (c *UDPClient) pollAck() bool {
+	packet, err := c.receiveWithin(time.Nanosecond)
+	if err != nil || packet == nil {
+		return false
+	}
+
+	if packet.Type == domain.PacketTypeAck {
+		c.connMgr.HandleAckPacket(packet, c.serverAddr)
+	}
+	return true
+}
+
+func (c *UDPClient) sendFile(localPath string, fileSize int64, payloadSize int, start int64) (*domain.TransferProgress, error) {
 	file, err := os.Open(localPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
-	buffer := make([]byte, c.udpConfig.BufferSizes[len(c.udpConfig.BufferSizes)/2])
-	var totalBytes int64
+	c.relMgr.ClearPending(c.relMgr.connID)
+	c.connMgr.ResetClient(c.relMgr.connID, c.serverAddr)
+
+	encoder := newFECEncoder(c.udpConfig.FECBlockSize, c.udpConfig.FECParity, c.relMgr.connID)
+	c.resetPeer()
+
+	offset := start
+	var queued []*domain.Packet
 	seqNum := uint32(0)
+	lastBase := uint32(0)
+	lastProgress := time.Now()
+	pacer := newTransferPacer(c.udpConfig, payloadSize)
+	batchSize := c.relMgr.BatchSize()
+	if pacer.burst > 0 {
+		batchSize = min(batchSize, max(c.udpConfig.PacingBurst, 1))
+	}
 
 	for {
-		n, err := file.Read(buffer)
-		if err != nil && err.Error() != "EOF" {
-			return nil, fmt.Errorf("file read error: %w", err)
+		paced := false
+
+		for {
+			for len(queued) < batchSize && offset < fileSize {
+				buffer := make([]byte, payloadSize)
+				n, err := file.ReadAt(buffer, offset)
+				if err != nil && err != io.EOF {
+					return nil, fmt.Errorf("file read error: %w", err)
+				}
+
+				packet := domain.NewPacket(domain.PacketTypeData, seqNum, buffer[:n])
+				packet.ConnID = c.relMgr.connID
+				queued = append(queued, packet)
+				offset += int64(n)
+				seqNum++
+			}
+
+			if len(queued) == 0 {
+				break
+			}
+
+			pacer.update(c.udpConfig, payloadSize, c.relMgr.SmoothedRTT(c.relMgr.connID))
+			if pacer.delay() > 0 {
+				paced = true
+				break
+			}
+
+			sent, err := c.connMgr.SendReliablePackets(queued, c.serverAddr)
+			if err != nil && !errors.Is(err, ErrWindowFull) {
+				return nil, fmt.Errorf("failed to send data packet: %w", err)
+			}
+			pacer.sent(packetBytes(queued[:sent]))
+
+			for _, packet := range queued[:sent] {
+				c.sendParity(encoder.Add(packet))
+			}
+			queued = queued[sent:]
+
+			if len(queued) > 0 {
+				break
+			}
+		}
+
+		done := offset >= fileSize && len(queued) == 0
+		if done {
+			c.sendParity(encoder.Flush())
 		}
 
-		if n == 0 {
+		base, next := c.connMgr.InFlight(c.relMgr.connID, c.serverAddr)
+		if base != lastBase {
+			lastBase = base
+			lastProgress = time.Now()
+			c.perfMonitor.UpdateProgress(min(start+int64(base)*int64(payloadSize), fileSize))
+		}
+
+		if done && base == next {
 			break
 		}
 
-		packet := domain.NewPacket(domain.PacketTypeData, seqNum, buffer[:n])
+		if err := c.checkPeer(); err != nil {
+			return nil, err
+		}
 
-		if err := c.connMgr.SendReliablePacket(packet, c.serverAddr); err != nil {
-			return nil, fmt.Errorf("failed to send data packet: %w", err)
+		if time.Since(lastProgress) > c.stallTimeout() {
+			return nil, fmt.Errorf("%w: no acknowledgement for %v", ErrTransferStalled, c.stallTimeout())
 		}
 
-		totalBytes += int64(n)
-		seqNum++
+		if paced {
+			if !c.pollAck() {
+				pacer.wait()
+			}
+			continue
+		}
 
-		c.perfMonitor.UpdateProgress(totalBytes)
+		packet, err := c.receive()
+		if err != nil {
+			if isTimeout(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to receive packet: %w", err)
+		}
 
-		c.testBufferSizes(totalBytes, fileSize)
+		if packet != nil && packet.Type == domain.PacketTypeAck {
+			c.connMgr.HandleAckPacket(packet, c.serverAddr)
+		}
 	}
 
+	c.perfMonitor.UpdateProgress(fileSize)
+	c.perfMonitor.UpdateStatistics(c.relMgr.GetStatistics())
+	c.perfMonitor.UpdateFEC(encoder.ParitySent(), 0)
+	c.perfMonitor.UpdatePacing(pacer.averageRate(), c.udpConfig.PacingBurst, pacer.waited)
+
 	progress := c.perfMonitor.GetProgress()
 	return progress, nil
 }
 
-func (c *UDPClient) receiveFile(localPath string, fileSize int64) (*domain.TransferProgress, error) {
-	file, err := os.Create(localPath)
+func (c *UDPClient) receiveFile(localPath string, fileSize int64, payloadSize, fecBlock, fecParity int,
+	offset int64) (*domain.TransferProgress, error) {
+
+	flags := os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(localPath, flags, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file: %w", err)
 	}
 	defer file.Close()
 
-	var totalBytes int64
-	expectedSeq := uint32(0)
+	totalPackets := packetCount(fileSize-offset, payloadSize)
+	decoder := newFECDecoder(fecBlock, fecParity, totalPackets)
+	received := domain.NewReceivedRanges()
+	var parityReceived uint32
+
+	defer func() {
+		c.resumeAt = min(offset+int64(received.Contiguous())*int64(payloadSize), fileSize)
+	}()
+
+	totalBytes := offset
+	lastProgress := time.Now()
+	c.resetPeer()
 
 	for totalBytes < fileSize {
-		packet, _, err := c.relMgr.ReceivePacket()
+		if err := c.checkPeer(); err != nil {
+			return nil, err
+		}
+
+		if time.Since(lastProgress) > c.stallTimeout() {
+			return nil, fmt.Errorf("%w: no data for %v", ErrTransferStalled, c.stallTimeout())
+		}
+
+		packet, err := c.receive()
 		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			if isTimeout(err) {
 				continue
 			}
 			return nil, fmt.Errorf("failed to receive packet: %w", err)
 		}
 
-		if packet.Type == domain.PacketTypeData && packet.SeqNum == expectedSeq {
-			_, err = file.Write(packet.Data)
-			if err != nil {
-				return nil, fmt.Errorf("failed to write file: %w", err)
+		if packet == nil {
+			continue
+		}
+
+		var packets []*domain.Packet
+		switch packet.Type {
+		case domain.PacketTypeData:
+			if len(packet.Data) > payloadSize {
+				continue
+			}
+			packets = append([]*domain.Packet{packet}, decoder.AddData(packet)...)
+		case domain.PacketTypeParity:
+			parityReceived++
+			packets = decoder.AddParity(packet)
+		default:
+			continue
+		}
+
+		for _, p := range packets {
+			if p.SeqNum >= totalPackets || len(p.Data) > payloadSize {
+				continue
 			}
 
-			totalBytes += int64(len(packet.Data))
-			expectedSeq++
+			if !received.Contains(p.SeqNum) {
+				if _, err := file.WriteAt(p.Data, offset+int64(p.SeqNum)*int64(payloadSize)); err != nil {
+					return nil, fmt.Errorf("failed to write file: %w", err)
+				}
 
-			ackPacket := domain.NewAckPacket(packet.SeqNum, expectedSeq, c.udpConfig.WindowSize)
-			c.relMgr.SendPacket(ackPacket, c.serverAddr)
+				totalBytes += int64(len(p.Data))
+				lastProgress = time.Now()
 
-			c.perfMonitor.UpdateProgress(totalBytes)
+				c.perfMonitor.UpdateProgress(totalBytes)
+			}
+			received.Add(p.SeqNum)
+
+			ackPacket := domain.NewAckPacket(p.SeqNum, p.SeqNum, c.udpConfig.WindowSize)
+			c.relMgr.SendPacket(ackPacket, c.serverAddr)
 		}
 	}
 
+	c.perfMonitor.UpdateStatistics(c.relMgr.GetStatistics())
+	c.perfMonitor.UpdateFEC(parityReceived, decoder.Recovered())
+	c.perfMonitor.UpdateDuplicates(received.Duplicates)
+
 	progress := c.perfMonitor.GetProgress()
 	return progress, nil
 }
 
-func (c *UDPClient) testBufferSizes(transferred, total int64) {
-	if transferred%int64(total/10) == 0 {
-		for _, bufferSize := range c.udpConfig.BufferSizes {
-			start := time.Now()
-
-			testData := make([]byte, bufferSize)
-			packet := domain.NewPacket(domain.PacketTypeData, 0, testData)
-
-			if err := c.relMgr.SendPacket(packet, c.serverAddr); err == nil {
-				elapsed := time.Since(start).Seconds()
-				if elapsed > 0 {
-					bitrate := float64(bufferSize) / elapsed / 1024 / 1024
-					c.perfMonitor.RecordBufferTest(bufferSize, bitrate)
-				}
-			}
+func newConnectionID() uint32 {
+	for {
+		if id := rand.Uint32(); id != 0 {
+			return id
 		}
 	}
 }
 
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 func (c *UDPClient) GetPerformanceReport() {
 	c.perfMonitor.PrintReport()
 
@@ -270,20 +685,21 @@ func (c *UDPClient) GetPerformanceReport() {
 		fmt.Printf("Packet Loss Rate: %.2f%%\n", lossRate)
 	}
 
-	fmt.Printf("Average Bitrate: %.2f MB/s\n", avgBitrate)
+	if c.udpConfig.PSK != "" {
+		authFailures, replays := c.relMgr.SecurityStatistics()
+		fmt.Printf("Secure Mode: %s\n", c.udpConfig.Cipher)
+		fmt.Printf("Dropped (authentication failed): %d\n", authFailures)
+		fmt.Printf("Dropped (replayed): %d\n", replays)
+	}
 
-	tcpBitrate := 10.0
-	ratio, isFaster := c.perfMonitor.CompareWithTCP(tcpBitrate)
+	fmt.Printf("Average Bitrate: %.2f MB/s\n", avgBitrate)
+	fmt.Printf("UDP vs TCP: run ./bin/bench for a measured comparison\n")
 
-	fmt.Printf("UDP vs TCP Performance Ratio: %.2f\n", ratio)
-	if isFaster {
-		fmt.Printf("UDP is %.2fx faster than TCP (meets requirement)\n", ratio)
+	if optimalSize, optimalBitrate := c.perfMonitor.CalculateOptimalBufferSize(); optimalSize > 0 {
+		fmt.Printf("Optimal Buffer Size: %d bytes (%.2f MB/s)\n", optimalSize, optimalBitrate)
 	} else {
-		fmt.Printf("UDP is %.2fx faster than TCP (does not meet 1.5x requirement)\n", ratio)
+		fmt.Printf("Optimal Buffer Size: not measured, run TEST to tune\n")
 	}
 
-	optimalSize, optimalBitrate := c.perfMonitor.CalculateOptimalBufferSize()
-	fmt.Printf("Optimal Buffer Size: %d bytes (%.2f MB/s)\n", optimalSize, optimalBitrate)
-
 	fmt.Printf("===============================\n")
 }
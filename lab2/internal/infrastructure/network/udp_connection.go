@@ -4,28 +4,32 @@ import (
 	"NSSaDS/lab2/internal/domain"
 	"NSSaDS/lab2/pkg/config"
 	"context"
-	"fmt"
+	"errors"
 	"net"
 	"sync"
 	"time"
 )
 
+var ErrWindowFull = errors.New("sliding window full")
+
 type UDPConnectionManager struct {
 	conn      *net.UDPConn
 	relMgr    *ReliabilityManager
 	udpConfig *config.UDPConfig
 	window    *domain.SlidingWindow
 	timeout   time.Duration
-	clients   map[string]*ClientSession
+	clients   map[uint32]*ClientSession
 	clientsMu sync.RWMutex
 }
 
 type ClientSession struct {
+	mu       sync.Mutex
 	Addr     *net.UDPAddr
 	LastSeen time.Time
 	Window   *domain.SlidingWindow
 	SeqNum   uint32
 	AckNum   uint32
+	acked    chan struct{}
 }
 
 func NewUDPConnectionManager(conn *net.UDPConn, relMgr *ReliabilityManager, udpConfig *config.UDPConfig) *UDPConnectionManager {
@@ -35,7 +39,7 @@ func NewUDPConnectionManager(conn *net.UDPConn, relMgr *ReliabilityManager, udpC
 		udpConfig: udpConfig,
 		window:    domain.NewSlidingWindow(udpConfig.WindowSize),
 		timeout:   udpConfig.PacketTimeout,
-		clients:   make(map[string]*ClientSession),
+		clients:   make(map[uint32]*ClientSession),
 	}
 }
 
@@ -52,12 +56,11 @@ func (ucm *UDPConnectionManager) SetWindowSize(size uint16) {
 	ucm.window.WindowSize = size
 }
 
-func (ucm *UDPConnectionManager) GetOrCreateClient(addr *net.UDPAddr) *ClientSession {
+func (ucm *UDPConnectionManager) GetOrCreateClient(connID uint32, addr *net.UDPAddr) *ClientSession {
 	ucm.clientsMu.Lock()
 	defer ucm.clientsMu.Unlock()
 
-	clientKey := addr.String()
-	session, exists := ucm.clients[clientKey]
+	session, exists := ucm.clients[connID]
 	if !exists {
 		session = &ClientSession{
 			Addr:     addr,
@@ -65,29 +68,104 @@ func (ucm *UDPConnectionManager) GetOrCreateClient(addr *net.UDPAddr) *ClientSes
 			Window:   domain.NewSlidingWindow(ucm.udpConfig.WindowSize),
 			SeqNum:   0,
 			AckNum:   0,
+			acked:    make(chan struct{}, 1),
 		}
-		ucm.clients[clientKey] = session
+		ucm.clients[connID] = session
 	}
 
+	session.Addr = addr
 	session.LastSeen = time.Now()
 	return session
 }
 
+func (ucm *UDPConnectionManager) RemoveClient(connID uint32) {
+	ucm.clientsMu.Lock()
+	defer ucm.clientsMu.Unlock()
+
+	delete(ucm.clients, connID)
+}
+
+func (ucm *UDPConnectionManager) ResetClient(connID uint32, addr *net.UDPAddr) *ClientSession {
+	session := ucm.GetOrCreateClient(connID, addr)
+
+	session.mu.Lock()
+	session.Window = domain.NewSlidingWindow(ucm.udpConfig.WindowSize)
+	session.SeqNum = 0
+	session.AckNum = 0
+	session.mu.Unlock()
+
+	return session
+}
+
 func (ucm *UDPConnectionManager) SendReliablePacket(packet *domain.Packet, addr *net.UDPAddr) error {
-	session := ucm.GetOrCreateClient(addr)
+	session := ucm.GetOrCreateClient(packet.ConnID, addr)
+
+	session.mu.Lock()
+	if !session.Window.CanSend() {
+		session.mu.Unlock()
+		return ErrWindowFull
+	}
+	session.Window.AddPacket(packet)
+	session.SeqNum = packet.SeqNum
+	session.mu.Unlock()
+
+	return ucm.relMgr.SendPacket(packet, addr)
+}
 
-	if session.Window.CanSend() {
-		session.Window.AddPacket(packet)
-		return ucm.relMgr.SendPacket(packet, addr)
+func (ucm *UDPConnectionManager) SendReliablePackets(packets []*domain.Packet, addr *net.UDPAddr) (int, error) {
+	if len(packets) == 0 {
+		return 0, nil
+	}
+	session := ucm.GetOrCreateClient(packets[0].ConnID, addr)
+
+	session.mu.Lock()
+	count := 0
+	for count < len(packets) && session.Window.CanSend() {
+		session.Window.AddPacket(packets[count])
+		session.SeqNum = packets[count].SeqNum
+		count++
+	}
+	session.mu.Unlock()
+
+	if count == 0 {
+		return 0, ErrWindowFull
 	}
 
-	return fmt.Errorf("sliding window full")
+	return count, ucm.relMgr.SendPackets(packets[:count], addr)
 }
 
 func (ucm *UDPConnectionManager) HandleAckPacket(packet *domain.Packet, addr *net.UDPAddr) {
-	session := ucm.GetOrCreateClient(addr)
+	session := ucm.GetOrCreateClient(packet.ConnID, addr)
+
+	session.mu.Lock()
 	session.Window.AckPacket(packet.AckNum)
 	session.AckNum = packet.AckNum
+	session.mu.Unlock()
+
+	select {
+	case session.acked <- struct{}{}:
+	default:
+	}
+}
+
+func (ucm *UDPConnectionManager) WaitForAck(connID uint32, addr *net.UDPAddr, timeout time.Duration) bool {
+	session := ucm.GetOrCreateClient(connID, addr)
+
+	select {
+	case <-session.acked:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (ucm *UDPConnectionManager) InFlight(connID uint32, addr *net.UDPAddr) (base, next uint32) {
+	session := ucm.GetOrCreateClient(connID, addr)
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	return session.Window.BaseSeq, session.Window.NextSeq
 }
 
 func (ucm *UDPConnectionManager) CleanupExpiredClients() {
@@ -95,21 +173,24 @@ func (ucm *UDPConnectionManager) CleanupExpiredClients() {
 	defer ucm.clientsMu.Unlock()
 
 	now := time.Now()
-	for key, session := range ucm.clients {
+	for connID, session := range ucm.clients {
 		if now.Sub(session.LastSeen) > 5*time.Minute {
-			delete(ucm.clients, key)
+			delete(ucm.clients, connID)
 		}
 	}
 }
 
-func (ucm *UDPConnectionManager) GetClientStatistics(addr *net.UDPAddr) (uint32, uint32, uint16) {
+func (ucm *UDPConnectionManager) GetClientStatistics(connID uint32) (uint32, uint32, uint16) {
 	ucm.clientsMu.RLock()
 	defer ucm.clientsMu.RUnlock()
 
-	session := ucm.clients[addr.String()]
+	session := ucm.clients[connID]
 	if session == nil {
 		return 0, 0, 0
 	}
 
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
 	return session.SeqNum, session.AckNum, session.Window.WindowSize
 }
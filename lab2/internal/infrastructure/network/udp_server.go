@@ -4,23 +4,52 @@ import (
 	"NSSaDS/lab2/internal/domain"
 	"NSSaDS/lab2/pkg/config"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+var ErrServerBusy = errors.New("too many concurrent transfers")
+
 type UDPServer struct {
 	config      *config.ServerConfig
 	udpConfig   *config.UDPConfig
 	conn        *net.UDPConn
 	handler     domain.CommandHandler
-	connMgr     domain.UDPConnectionManager
+	connMgr     *UDPConnectionManager
 	relMgr      *ReliabilityManager
 	fileMgr     domain.FileManager
-	perfMonitor *PerformanceMonitor
-	sessions    map[string]*domain.TransferSession
+	sessions    map[uint32]*serverSession
 	sessionsMu  sync.RWMutex
+	peers       map[uint32]*serverPeer
+	peersMu     sync.Mutex
+	dropped     uint64
+	responses   map[uint32]*cachedResponse
+	responsesMu sync.Mutex
+	partials    map[string]partialUpload
+	partialsMu  sync.Mutex
+	tracer      *Tracer
+}
+
+type serverSession struct {
+	mu           sync.Mutex
+	info         *domain.TransferSession
+	addr         *net.UDPAddr
+	connID       uint32
+	done         chan struct{}
+	stopped      bool
+	decoder      *fecDecoder
+	received     *domain.ReceivedRanges
+	totalPackets uint32
+	monitor      *PerformanceMonitor
+	lastHeard    time.Time
+	missed       int
 }
 
 func NewUDPServer(cfg *config.ServerConfig, udpCfg *config.UDPConfig, handler domain.CommandHandler,
@@ -31,7 +60,10 @@ func NewUDPServer(cfg *config.ServerConfig, udpCfg *config.UDPConfig, handler do
 		udpConfig: udpCfg,
 		handler:   handler,
 		fileMgr:   fileMgr,
-		sessions:  make(map[string]*domain.TransferSession),
+		sessions:  make(map[uint32]*serverSession),
+		peers:     make(map[uint32]*serverPeer),
+		responses: make(map[uint32]*cachedResponse),
+		partials:  make(map[string]partialUpload),
 	}
 }
 
@@ -48,15 +80,38 @@ func (s *UDPServer) Start(ctx context.Context, addr string) error {
 		return fmt.Errorf("failed to get UDP connection")
 	}
 
+	setSocketBuffers(s.conn, s.udpConfig.SocketBufferSize)
+
+	if s.udpConfig.PMTUDiscovery {
+		if err := enablePMTUProbe(s.conn); err != nil {
+			fmt.Printf("Warning: cannot set DF on outgoing datagrams: %v\n", err)
+		}
+	}
+
 	s.relMgr = NewReliabilityManager(s.conn, s.udpConfig.PacketTimeout,
 		s.udpConfig.RetransmissionTimeout, s.udpConfig.MaxRetransmissions)
+	s.relMgr.SetTransport(NewTransport(s.conn, s.udpConfig.BatchSize))
+
+	if s.udpConfig.TracePath != "" {
+		s.tracer, err = NewTracer(s.udpConfig.TracePath, s.udpConfig.TraceSnapLen)
+		if err != nil {
+			return err
+		}
+		s.relMgr.SetTracer(s.tracer)
+		fmt.Printf("Tracing packets to %s\n", s.udpConfig.TracePath)
+	}
+
+	if s.udpConfig.PSK != "" {
+		s.relMgr.EnableSecurity([]byte(s.udpConfig.PSK))
+		fmt.Printf("Secure mode enabled, unauthenticated packets are dropped\n")
+	}
 
 	s.connMgr = NewUDPConnectionManager(s.conn, s.relMgr, s.udpConfig)
-	s.perfMonitor = NewPerformanceMonitor()
 
-	fmt.Printf("UDP Server started on %s\n", addr)
+	fmt.Printf("UDP Server started on %s (up to %d concurrent transfers)\n", addr, s.config.MaxSessions)
 
 	go s.cleanupRoutine(ctx)
+	go s.heartbeatRoutine(ctx)
 
 	for {
 		select {
@@ -65,14 +120,21 @@ func (s *UDPServer) Start(ctx context.Context, addr string) error {
 		default:
 			packet, clientAddr, err := s.relMgr.ReceivePacket()
 			if err != nil {
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				if errors.Is(err, net.ErrClosed) {
+					return nil
+				}
+				if errors.Is(err, errPacketDropped) {
+					continue
+				}
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
 					continue
 				}
 				fmt.Printf("Error receiving packet: %v\n", err)
 				continue
 			}
 
-			go s.handlePacket(ctx, packet, clientAddr)
+			s.dispatch(ctx, packet, clientAddr)
 		}
 	}
 }
@@ -84,20 +146,46 @@ func (s *UDPServer) Stop() error {
 	if s.relMgr != nil {
 		s.relMgr.Stop()
 	}
+	if s.tracer != nil {
+		s.tracer.Close()
+	}
 	return nil
 }
 
+// SecurityStatistics reports how many packets secure mode dropped because they
+// failed authentication or were replayed.
+func (s *UDPServer) SecurityStatistics() (authFailures, replays uint32) {
+	return s.relMgr.SecurityStatistics()
+}
+
 func (s *UDPServer) SetHandler(handler domain.CommandHandler) {
 	s.handler = handler
 }
 
 func (s *UDPServer) handlePacket(ctx context.Context, packet *domain.Packet, clientAddr *net.UDPAddr) {
+	if session := s.getSession(packet.ConnID, clientAddr); session != nil {
+		session.heard()
+	}
+
 	switch packet.Type {
 	case domain.PacketTypeCommand:
 		s.handleCommand(ctx, packet, clientAddr)
 	case domain.PacketTypeData:
 		s.handleDataPacket(ctx, packet, clientAddr)
-	case domain.PacketTypeAck, domain.PacketTypeNack:
+	case domain.PacketTypeParity:
+		s.handleParityPacket(ctx, packet, clientAddr)
+	case domain.PacketTypeAck:
+		if session := s.getSession(packet.ConnID, clientAddr); session != nil {
+			s.connMgr.HandleAckPacket(packet, clientAddr)
+		}
+	case domain.PacketTypeNack:
+	case domain.PacketTypeProbe:
+		if packet.Flags&probeReplyFlag == 0 {
+			s.relMgr.SendPacket(newProbeReply(packet), clientAddr)
+		}
+	case domain.PacketTypePing:
+		s.relMgr.SendPacket(newPong(packet), clientAddr)
+	case domain.PacketTypePong:
 	case domain.PacketTypeSyn:
 		s.handleSynPacket(ctx, packet, clientAddr)
 	case domain.PacketTypeFin:
@@ -106,6 +194,10 @@ func (s *UDPServer) handlePacket(ctx context.Context, packet *domain.Packet, cli
 }
 
 func (s *UDPServer) handleCommand(ctx context.Context, packet *domain.Packet, clientAddr *net.UDPAddr) {
+	if s.replayResponse(packet, clientAddr) {
+		return
+	}
+
 	cmd := string(packet.Data)
 	args := []string{}
 
@@ -117,69 +209,532 @@ func (s *UDPServer) handleCommand(ctx context.Context, packet *domain.Packet, cl
 		}
 	}
 
-	response, err := s.handler.HandleCommand(ctx, cmd, args, clientAddr)
+	var response string
+	var err error
+	var download *serverSession
+
+	switch strings.ToUpper(cmd) {
+	case "UPLOAD":
+		response, err = s.handleUpload(args, packet.ConnID, clientAddr)
+	case "DOWNLOAD":
+		download, response, err = s.handleDownload(args, packet.ConnID, clientAddr)
+	default:
+		response, err = s.handler.HandleCommand(ctx, cmd, args, clientAddr)
+	}
+
 	if err != nil {
 		response = fmt.Sprintf("ERROR: %v", err)
 	}
 
-	responsePacket := domain.NewPacket(domain.PacketTypeResponse, packet.SeqNum+1, []byte(response))
-	if err := s.relMgr.SendPacket(responsePacket, clientAddr); err != nil {
+	if err := s.respond(packet, clientAddr, response); err != nil {
 		fmt.Printf("Failed to send response: %v\n", err)
+		return
+	}
+
+	if download != nil {
+		go s.streamFile(download)
 	}
 }
 
-func (s *UDPServer) handleDataPacket(ctx context.Context, packet *domain.Packet, clientAddr *net.UDPAddr) {
-	sessionID := fmt.Sprintf("%s_%d", clientAddr.String(), packet.SeqNum)
+func (s *UDPServer) handleUpload(args []string, connID uint32, clientAddr *net.UDPAddr) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf("usage: UPLOAD <filename> <size> [payload_size] [fec_block fec_parity] [RESUME token]")
+	}
 
-	s.sessionsMu.RLock()
-	session, exists := s.sessions[sessionID]
-	s.sessionsMu.RUnlock()
+	filename, err := parseFileName(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	fileSize, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || fileSize < 0 {
+		return "", fmt.Errorf("invalid file size: %s", args[1])
+	}
+
+	fecBlock, fecParity, err := parseFEC(args[min(3, len(args)):])
+	if err != nil {
+		return "", err
+	}
+
+	payloadSize, err := s.parsePayloadSize(args[2:], fecBlock)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.checkCapacity(connID); err != nil {
+		return "", err
+	}
+
+	var offset int64
+	token := ""
+	if len(args) > 6 && strings.EqualFold(args[5], "RESUME") {
+		if offset = s.resumeOffset(args[6], filename, fileSize); offset > 0 {
+			token = args[6]
+		}
+	}
+
+	if offset == 0 {
+		token = newResumeToken()
+		s.forgetPartial(filename)
+		s.fileMgr.DeleteFile(filename)
+		if err := s.fileMgr.SaveFile(filename, nil, 0); err != nil {
+			return "", fmt.Errorf("failed to create file: %w", err)
+		}
+	}
+
+	_, err = s.startSession(clientAddr, connID, &domain.TransferSession{
+		ID:           fmt.Sprintf("%08x", connID),
+		ClientAddr:   clientAddr.String(),
+		FileName:     filename,
+		FileSize:     fileSize,
+		Offset:       offset,
+		Transferred:  offset,
+		IsUpload:     true,
+		LastUpdate:   time.Now(),
+		WindowSize:   s.udpConfig.WindowSize,
+		BufferSize:   payloadSize,
+		FECBlockSize: fecBlock,
+		FECParity:    fecParity,
+		ResumeToken:  token,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Upload started: %s from %s (%d bytes from offset %d, payload %d, FEC %d+%d)\n",
+		filename, clientAddr, fileSize, offset, payloadSize, fecBlock, fecParity)
 
-	if !exists {
-		session = &domain.TransferSession{
-			ID:          sessionID,
-			ClientAddr:  clientAddr.String(),
-			Transferred: 0,
-			LastUpdate:  time.Now(),
-			WindowSize:  s.udpConfig.WindowSize,
-			BufferSize:  s.udpConfig.BufferSizes[len(s.udpConfig.BufferSizes)/2],
+	return fmt.Sprintf("READY %s %d %d %d %d %d %s", filename, fileSize, payloadSize, fecBlock, fecParity, offset, token), nil
+}
+
+func (s *UDPServer) handleDownload(args []string, connID uint32, clientAddr *net.UDPAddr) (*serverSession, string, error) {
+	if len(args) < 1 {
+		return nil, "", fmt.Errorf("usage: DOWNLOAD <filename> [payload_size] [fec_block fec_parity] [offset]")
+	}
+
+	filename, err := parseFileName(args[0])
+	if err != nil {
+		return nil, "", err
+	}
+
+	fileInfo, err := s.fileMgr.GetFileInfo(filename)
+	if err != nil {
+		return nil, "", fmt.Errorf("file not found: %s", filename)
+	}
+
+	fecBlock, fecParity, err := parseFEC(args[min(2, len(args)):])
+	if err != nil {
+		return nil, "", err
+	}
+
+	payloadSize, err := s.parsePayloadSize(args[1:], fecBlock)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var offset int64
+	if len(args) > 4 {
+		offset, err = strconv.ParseInt(args[4], 10, 64)
+		if err != nil || offset < 0 || offset > fileInfo.Size {
+			return nil, "", fmt.Errorf("invalid resume offset: %s", args[4])
+		}
+	}
+
+	session, err := s.startSession(clientAddr, connID, &domain.TransferSession{
+		ID:           fmt.Sprintf("%08x", connID),
+		ClientAddr:   clientAddr.String(),
+		FileName:     filename,
+		FileSize:     fileInfo.Size,
+		Offset:       offset,
+		Transferred:  offset,
+		IsUpload:     false,
+		LastUpdate:   time.Now(),
+		FilePath:     fileInfo.Path,
+		WindowSize:   s.udpConfig.WindowSize,
+		BufferSize:   payloadSize,
+		FECBlockSize: fecBlock,
+		FECParity:    fecParity,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	fmt.Printf("Download started: %s to %s (%d bytes from offset %d, payload %d, FEC %d+%d)\n",
+		filename, clientAddr, fileInfo.Size, offset, payloadSize, fecBlock, fecParity)
+
+	return session, fmt.Sprintf("FILE_INFO %s %d %d %d %d %d",
+		filename, fileInfo.Size, payloadSize, fecBlock, fecParity, offset), nil
+}
+
+// parseFileName accepts only plain names, so a client cannot reach files
+// outside the upload directory.
+func parseFileName(name string) (string, error) {
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid file name: %s", name)
+	}
+	return name, nil
+}
+
+func (s *UDPServer) parsePayloadSize(args []string, fecBlock int) (int, error) {
+	payloadSize := min(s.udpConfig.BufferSizes[len(s.udpConfig.BufferSizes)/2], s.maxPayloadSize(fecBlock))
+	if len(args) > 0 {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size <= 0 || size > s.maxPayloadSize(fecBlock) {
+			return 0, fmt.Errorf("invalid payload size: %s", args[0])
 		}
+		payloadSize = size
+	}
+	return payloadSize, nil
+}
+
+func (s *UDPServer) maxPayloadSize(fecBlock int) int {
+	return maxPayloadSize(0, s.relMgr.security != nil, fecBlock)
+}
+
+// maxPayloadSize is the largest DATA payload whose packets, including PARITY
+// packets and the seal in secure mode, fit in one datagram. pathMax is the
+// payload limit found by path MTU discovery, 0 if unknown.
+func maxPayloadSize(pathMax int, secure bool, fecBlock int) int {
+	size := domain.MaxPayloadSize
+	if secure {
+		size -= SealOverhead
+	}
+	if pathMax > 0 {
+		size = min(size, pathMax)
+	}
+	if fecBlock > 0 {
+		size -= fecLengthPrefix
+	}
+	return size
+}
+
+func parseFEC(args []string) (int, int, error) {
+	if len(args) < 2 {
+		return 0, 0, nil
+	}
+
+	blockSize, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid FEC block size: %s", args[0])
+	}
+
+	parity, err := strconv.Atoi(args[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid FEC parity count: %s", args[1])
+	}
+
+	if err := validateFEC(blockSize, parity); err != nil {
+		return 0, 0, err
+	}
+
+	return blockSize, parity, nil
+}
+
+func (s *UDPServer) checkCapacity(connID uint32) error {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+
+	return s.capacityError(connID)
+}
+
+func (s *UDPServer) capacityError(connID uint32) error {
+	if _, exists := s.sessions[connID]; exists || s.config.MaxSessions <= 0 || len(s.sessions) < s.config.MaxSessions {
+		return nil
+	}
+	return fmt.Errorf("%w (%d active), try again later", ErrServerBusy, len(s.sessions))
+}
 
-		s.sessionsMu.Lock()
-		s.sessions[sessionID] = session
+func (s *UDPServer) startSession(clientAddr *net.UDPAddr, connID uint32, info *domain.TransferSession) (*serverSession, error) {
+	session := &serverSession{
+		info:      info,
+		addr:      clientAddr,
+		connID:    connID,
+		done:      make(chan struct{}),
+		monitor:   NewPerformanceMonitor(),
+		lastHeard: time.Now(),
+	}
+
+	if info.IsUpload {
+		session.totalPackets = packetCount(info.FileSize-info.Offset, info.BufferSize)
+		session.received = domain.NewReceivedRanges()
+		session.decoder = newFECDecoder(info.FECBlockSize, info.FECParity, session.totalPackets)
+	}
+
+	s.sessionsMu.Lock()
+	if err := s.capacityError(connID); err != nil {
 		s.sessionsMu.Unlock()
+		return nil, err
+	}
+	if previous, exists := s.sessions[connID]; exists {
+		previous.stop()
+	}
+	s.sessions[connID] = session
+	s.sessionsMu.Unlock()
+
+	s.relMgr.ResetPeer(connID)
+	s.connMgr.ResetClient(connID, clientAddr)
+	session.monitor.StartTransfer(info.FileName, info.FileSize)
+
+	return session, nil
+}
+
+func (s *UDPServer) getSession(connID uint32, clientAddr *net.UDPAddr) *serverSession {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+
+	session := s.sessions[connID]
+	if session == nil || session.addr.String() != clientAddr.String() {
+		return nil
+	}
+	return session
+}
+
+func (s *UDPServer) removeSession(session *serverSession) {
+	s.sessionsMu.Lock()
+	if current, exists := s.sessions[session.connID]; exists && current == session {
+		delete(s.sessions, session.connID)
+	}
+	s.sessionsMu.Unlock()
+
+	session.stop()
+	s.relMgr.ClearPending(session.connID)
+}
+
+func (ss *serverSession) stop() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if !ss.stopped {
+		ss.stopped = true
+		close(ss.done)
+	}
+}
+
+func (s *UDPServer) handleDataPacket(ctx context.Context, packet *domain.Packet, clientAddr *net.UDPAddr) {
+	session := s.getSession(packet.ConnID, clientAddr)
+	if session == nil || !session.info.IsUpload || len(packet.Data) > session.info.BufferSize {
+		return
+	}
+
+	session.mu.Lock()
+	recovered := session.decoder.AddData(packet)
+	session.mu.Unlock()
+
+	s.acceptData(session, packet)
+	for _, p := range recovered {
+		s.acceptData(session, p)
+	}
+}
+
+func (s *UDPServer) handleParityPacket(ctx context.Context, packet *domain.Packet, clientAddr *net.UDPAddr) {
+	session := s.getSession(packet.ConnID, clientAddr)
+	if session == nil || !session.info.IsUpload {
+		return
 	}
 
-	if err := s.fileMgr.SaveFile(session.FileName, packet.Data, int64(packet.SeqNum)); err != nil {
-		fmt.Printf("Failed to save data: %v\n", err)
+	session.mu.Lock()
+	recovered := session.decoder.AddParity(packet)
+	session.mu.Unlock()
+
+	for _, p := range recovered {
+		s.acceptData(session, p)
+	}
+}
+
+func (s *UDPServer) acceptData(session *serverSession, packet *domain.Packet) {
+	// A payload larger than negotiated would overwrite the next packet's bytes,
+	// so it is dropped like a malformed packet and never acknowledged.
+	session.mu.Lock()
+	if packet.SeqNum >= session.totalPackets || len(packet.Data) > session.info.BufferSize {
+		session.mu.Unlock()
 		return
 	}
 
-	session.Transferred += int64(len(packet.Data))
-	session.LastUpdate = time.Now()
+	duplicate := session.received.Contains(packet.SeqNum)
+	if !duplicate {
+		offset := session.info.Offset + int64(packet.SeqNum)*int64(session.info.BufferSize)
+		if err := s.fileMgr.SaveFile(session.info.FileName, packet.Data, offset); err != nil {
+			session.mu.Unlock()
+			fmt.Printf("Failed to save data: %v\n", err)
+			return
+		}
+		session.info.Transferred += int64(len(packet.Data))
+	}
+
+	session.received.Add(packet.SeqNum)
+	session.info.LastUpdate = time.Now()
+	transferred := session.info.Transferred
+	session.mu.Unlock()
 
-	ackPacket := domain.NewAckPacket(packet.SeqNum, packet.SeqNum+1, s.udpConfig.WindowSize)
-	if err := s.relMgr.SendPacket(ackPacket, clientAddr); err != nil {
+	ackPacket := domain.NewAckPacket(packet.SeqNum, packet.SeqNum, s.udpConfig.WindowSize)
+	ackPacket.ConnID = session.connID
+	if err := s.relMgr.SendPacket(ackPacket, session.addr); err != nil {
 		fmt.Printf("Failed to send ACK: %v\n", err)
 	}
 
-	s.perfMonitor.UpdateProgress(session.Transferred)
+	session.monitor.UpdateProgress(transferred)
+}
+
+func (s *UDPServer) streamFile(session *serverSession) {
+	file, err := os.Open(session.info.FilePath)
+	if err != nil {
+		fmt.Printf("Failed to open %s: %v\n", session.info.FilePath, err)
+		s.removeSession(session)
+		return
+	}
+	defer file.Close()
+
+	payloadSize := session.info.BufferSize
+	encoder := newFECEncoder(session.info.FECBlockSize, session.info.FECParity, session.connID)
+	stallTimeout := s.udpConfig.RetransmissionTimeout * time.Duration(s.udpConfig.MaxRetransmissions+1)
+	lastProgress := time.Now()
+	lastBase := uint32(0)
+	seqNum := uint32(0)
+	offset := session.info.Offset
+	var queued []*domain.Packet
+	start := time.Now()
+	pacer := newTransferPacer(s.udpConfig, payloadSize)
+	batchSize := s.relMgr.BatchSize()
+	if pacer.burst > 0 {
+		batchSize = min(batchSize, max(s.udpConfig.PacingBurst, 1))
+	}
+
+	for {
+		select {
+		case <-session.done:
+			return
+		default:
+		}
+
+		base, next := s.connMgr.InFlight(session.connID, session.addr)
+		if base != lastBase {
+			lastBase = base
+			lastProgress = time.Now()
+
+			session.mu.Lock()
+			session.info.Transferred = min(session.info.Offset+int64(base)*int64(payloadSize), session.info.FileSize)
+			session.info.LastUpdate = lastProgress
+			transferred := session.info.Transferred
+			session.mu.Unlock()
+
+			session.monitor.UpdateProgress(transferred)
+		}
+
+		done := offset >= session.info.FileSize && len(queued) == 0
+		if done && base == next {
+			sent := session.info.FileSize - session.info.Offset
+			if rate := pacer.averageRate(); rate > 0 {
+				fmt.Printf("Download completed: %s to %s (%d bytes, paced at %.2f MB/s, achieved %.2f MB/s)\n",
+					session.info.FileName, session.addr, session.info.FileSize,
+					rate/1024/1024, float64(sent)/time.Since(start).Seconds()/1024/1024)
+			} else {
+				fmt.Printf("Download completed: %s to %s (%d bytes)\n", session.info.FileName, session.addr, session.info.FileSize)
+			}
+			return
+		}
+
+		if time.Since(lastProgress) > stallTimeout {
+			fmt.Printf("Download of %s to %s stalled, aborting\n", session.info.FileName, session.addr)
+			s.removeSession(session)
+			return
+		}
+
+		if done {
+			s.sendParity(encoder.Flush(), session.addr)
+			s.connMgr.WaitForAck(session.connID, session.addr, s.udpConfig.PacketTimeout)
+			continue
+		}
+
+		for len(queued) < batchSize && offset < session.info.FileSize {
+			buffer := make([]byte, payloadSize)
+			n, err := file.ReadAt(buffer, offset)
+			if err != nil && err != io.EOF {
+				fmt.Printf("Failed to read %s: %v\n", session.info.FilePath, err)
+				s.removeSession(session)
+				return
+			}
+
+			packet := domain.NewPacket(domain.PacketTypeData, seqNum, buffer[:n])
+			packet.ConnID = session.connID
+			queued = append(queued, packet)
+
+			offset += int64(n)
+			seqNum++
+		}
+
+		pacer.update(s.udpConfig, payloadSize, s.relMgr.SmoothedRTT(session.connID))
+		pacer.wait()
+
+		sent, err := s.connMgr.SendReliablePackets(queued, session.addr)
+		if err != nil && !errors.Is(err, ErrWindowFull) {
+			fmt.Printf("Failed to send data packet: %v\n", err)
+			s.removeSession(session)
+			return
+		}
+		pacer.sent(packetBytes(queued[:sent]))
+
+		for _, packet := range queued[:sent] {
+			s.sendParity(encoder.Add(packet), session.addr)
+		}
+		queued = queued[sent:]
+
+		if len(queued) > 0 {
+			s.connMgr.WaitForAck(session.connID, session.addr, s.udpConfig.PacketTimeout)
+		}
+	}
+}
+
+func (s *UDPServer) sendParity(packets []*domain.Packet, addr *net.UDPAddr) {
+	if len(packets) == 0 {
+		return
+	}
+
+	if err := s.relMgr.SendPackets(packets, addr); err != nil {
+		fmt.Printf("Failed to send parity packet: %v\n", err)
+	}
 }
 
 func (s *UDPServer) handleSynPacket(ctx context.Context, packet *domain.Packet, clientAddr *net.UDPAddr) {
+	if packet.Flags&synSecureFlag != 0 || s.relMgr.security != nil {
+		s.handleSecureSyn(packet, clientAddr)
+		return
+	}
+
 	synAck := domain.NewPacket(domain.PacketTypeAck, packet.SeqNum+1, []byte("SYN-ACK"))
+	synAck.ConnID = packet.ConnID
 	s.relMgr.SendPacket(synAck, clientAddr)
 }
 
 func (s *UDPServer) handleFinPacket(ctx context.Context, packet *domain.Packet, clientAddr *net.UDPAddr) {
-	sessionID := fmt.Sprintf("%s_%d", clientAddr.String(), packet.SeqNum)
+	if s.replayResponse(packet, clientAddr) {
+		return
+	}
 
-	s.sessionsMu.Lock()
-	delete(s.sessions, sessionID)
-	s.sessionsMu.Unlock()
+	response := "ERROR: no active transfer"
+
+	if session := s.getSession(packet.ConnID, clientAddr); session != nil {
+		s.removeSession(session)
 
-	finAck := domain.NewPacket(domain.PacketTypeAck, packet.SeqNum+1, []byte("FIN-ACK"))
-	s.relMgr.SendPacket(finAck, clientAddr)
+		session.mu.Lock()
+		info := *session.info
+		recovered := session.decoder.Recovered()
+		var duplicates uint32
+		if session.received != nil {
+			duplicates = session.received.Duplicates
+		}
+		session.mu.Unlock()
+
+		if info.IsUpload {
+			response = fmt.Sprintf("UPLOADED %s %d %d %d", info.FileName, info.Transferred, recovered, duplicates)
+			fmt.Printf("Upload completed: %s from %s (%d of %d bytes, %d recovered by FEC, %d duplicates discarded)\n",
+				info.FileName, clientAddr, info.Transferred, info.FileSize, recovered, duplicates)
+		} else {
+			_, _, retransmits := s.relMgr.PeerStatistics(session.connID)
+			response = fmt.Sprintf("DOWNLOADED %s %d %d", info.FileName, info.Transferred, retransmits)
+		}
+	}
+
+	s.respond(packet, clientAddr, response)
 }
 
 func (s *UDPServer) cleanupRoutine(ctx context.Context) {
@@ -192,17 +747,38 @@ func (s *UDPServer) cleanupRoutine(ctx context.Context) {
 			return
 		case <-ticker.C:
 			s.cleanupExpiredSessions()
+			s.expireHeartbeatState()
+			s.reportDropped()
+			s.reportSecurity()
 		}
 	}
 }
 
+func (s *UDPServer) reportSecurity() {
+	if s.relMgr.security == nil {
+		return
+	}
+
+	s.relMgr.security.expire(s.config.SessionTimeout)
+
+	authFailures, replays := s.SecurityStatistics()
+	if authFailures > 0 || replays > 0 {
+		fmt.Printf("Secure mode: dropped %d unauthenticated and %d replayed packets\n", authFailures, replays)
+	}
+}
+
 func (s *UDPServer) cleanupExpiredSessions() {
 	s.sessionsMu.Lock()
 	defer s.sessionsMu.Unlock()
 
 	now := time.Now()
 	for id, session := range s.sessions {
-		if now.Sub(session.LastUpdate) > s.config.SessionTimeout {
+		session.mu.Lock()
+		expired := now.Sub(session.info.LastUpdate) > s.config.SessionTimeout
+		session.mu.Unlock()
+
+		if expired {
+			session.stop()
 			delete(s.sessions, id)
 		}
 	}
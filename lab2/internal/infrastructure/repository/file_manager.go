@@ -33,10 +33,15 @@ func NewFileManager(uploadDir string) *FileManager {
 	return fm
 }
 
+// path keeps every file inside the upload directory whatever the client sent.
+func (fm *FileManager) path(filename string) string {
+	return filepath.Join(fm.uploadDir, filepath.Base(filename))
+}
+
 func (fm *FileManager) SaveFile(filename string, data []byte, offset int64) error {
-	filePath := filepath.Join(fm.uploadDir, filename)
+	filePath := fm.path(filename)
 
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
@@ -57,7 +62,7 @@ func (fm *FileManager) SaveFile(filename string, data []byte, offset int64) erro
 }
 
 func (fm *FileManager) ReadFile(filename string) ([]byte, error) {
-	filePath := filepath.Join(fm.uploadDir, filename)
+	filePath := fm.path(filename)
 
 	data, err := os.ReadFile(filePath)
 	if err != nil {
@@ -68,7 +73,7 @@ func (fm *FileManager) ReadFile(filename string) ([]byte, error) {
 }
 
 func (fm *FileManager) GetFileInfo(filename string) (*domain.FileInfo, error) {
-	filePath := filepath.Join(fm.uploadDir, filename)
+	filePath := fm.path(filename)
 
 	stat, err := os.Stat(filePath)
 	if err != nil {
@@ -84,7 +89,7 @@ func (fm *FileManager) GetFileInfo(filename string) (*domain.FileInfo, error) {
 }
 
 func (fm *FileManager) DeleteFile(filename string) error {
-	filePath := filepath.Join(fm.uploadDir, filename)
+	filePath := fm.path(filename)
 
 	err := os.Remove(filePath)
 	if err != nil {
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileManagerStaysInUploadDir(t *testing.T) {
+	root := t.TempDir()
+	uploadDir := filepath.Join(root, "uploads")
+	fm := NewFileManager(uploadDir)
+
+	if err := fm.SaveFile("../escaped.bin", []byte("data"), 0); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped.bin")); !os.IsNotExist(err) {
+		t.Fatalf("SaveFile wrote outside the upload directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(uploadDir, "escaped.bin")); err != nil {
+		t.Fatalf("SaveFile did not write into the upload directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("writing secret: %v", err)
+	}
+	if _, err := fm.ReadFile("../secret.txt"); err == nil {
+		t.Fatalf("ReadFile read a file outside the upload directory")
+	}
+	if info, err := fm.GetFileInfo("../../secret.txt"); err == nil {
+		t.Fatalf("GetFileInfo found %s outside the upload directory", info.Path)
+	}
+}
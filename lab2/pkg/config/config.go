@@ -20,6 +20,7 @@ type ServerConfig struct {
 	BufferSize     int           `json:"buffer_size"`
 	UploadDir      string        `json:"upload_dir"`
 	SessionTimeout time.Duration `json:"session_timeout"`
+	MaxSessions    int           `json:"max_sessions"`
 }
 
 type ClientConfig struct {
@@ -32,15 +33,43 @@ type ClientConfig struct {
 }
 
 type UDPConfig struct {
-	WindowSize            uint16        `json:"window_size"`
-	PacketTimeout         time.Duration `json:"packet_timeout"`
-	RetransmissionTimeout time.Duration `json:"retransmission_timeout"`
-	MaxRetransmissions    int           `json:"max_retransmissions"`
-	BufferSizes           []int         `json:"buffer_sizes"`
-	TestDuration          time.Duration `json:"test_duration"`
-	MinBufferSize         int           `json:"min_buffer_size"`
-	MaxBufferSize         int           `json:"max_buffer_size"`
-	BufferStep            int           `json:"buffer_step"`
+	WindowSize               uint16        `json:"window_size"`
+	PacketTimeout            time.Duration `json:"packet_timeout"`
+	RetransmissionTimeout    time.Duration `json:"retransmission_timeout"`
+	MaxRetransmissions       int           `json:"max_retransmissions"`
+	BufferSizes              []int         `json:"buffer_sizes"`
+	TestDuration             time.Duration `json:"test_duration"`
+	MinBufferSize            int           `json:"min_buffer_size"`
+	MaxBufferSize            int           `json:"max_buffer_size"`
+	BufferStep               int           `json:"buffer_step"`
+	SocketBufferSize         int           `json:"socket_buffer_size"`
+	BatchSize                int           `json:"batch_size"`
+	PSK                      string        `json:"psk"`
+	Cipher                   string        `json:"cipher"`
+	FECBlockSize             int           `json:"fec_block_size"`
+	FECParity                int           `json:"fec_parity"`
+	PMTUDiscovery            bool          `json:"pmtu_discovery"`
+	PMTUProbeTimeout         time.Duration `json:"pmtu_probe_timeout"`
+	PMTUProbeRetries         int           `json:"pmtu_probe_retries"`
+	HeartbeatInterval        time.Duration `json:"heartbeat_interval"`
+	HeartbeatMisses          int           `json:"heartbeat_misses"`
+	AutoReconnect            bool          `json:"auto_reconnect"`
+	ReconnectAttempts        int           `json:"reconnect_attempts"`
+	Pacing                   bool          `json:"pacing"`
+	PacingRate               int           `json:"pacing_rate"`
+	PacingBurst              int           `json:"pacing_burst"`
+	MulticastGroup           string        `json:"multicast_group"`
+	MulticastInterface       string        `json:"multicast_interface"`
+	MulticastTTL             int           `json:"multicast_ttl"`
+	MulticastPayload         int           `json:"multicast_payload"`
+	MulticastRate            int           `json:"multicast_rate"`
+	MulticastRepairThreshold int           `json:"multicast_repair_threshold"`
+	MulticastNackInterval    time.Duration `json:"multicast_nack_interval"`
+	MulticastJoinTimeout     time.Duration `json:"multicast_join_timeout"`
+	MulticastReceiverTimeout time.Duration `json:"multicast_receiver_timeout"`
+	MulticastDropRate        float64       `json:"multicast_drop_rate"`
+	TracePath                string        `json:"trace_path"`
+	TraceSnapLen             int           `json:"trace_snap_len"`
 }
 
 func NewConfig() *Config {
@@ -55,6 +84,7 @@ func NewConfig() *Config {
 			BufferSize:     8192,
 			UploadDir:      "./uploads",
 			SessionTimeout: 5 * time.Minute,
+			MaxSessions:    64,
 		},
 		Client: ClientConfig{
 			KeepAlive:      true,
@@ -65,15 +95,34 @@ func NewConfig() *Config {
 			Timeout:        30 * time.Second,
 		},
 		UDP: UDPConfig{
-			WindowSize:            64,
-			PacketTimeout:         100 * time.Millisecond,
-			RetransmissionTimeout: 500 * time.Millisecond,
-			MaxRetransmissions:    5,
-			BufferSizes:           []int{512, 1024, 2048, 4096, 8192, 16384, 32768},
-			TestDuration:          30 * time.Second,
-			MinBufferSize:         256,
-			MaxBufferSize:         65536,
-			BufferStep:            256,
+			WindowSize:               64,
+			PacketTimeout:            100 * time.Millisecond,
+			RetransmissionTimeout:    500 * time.Millisecond,
+			MaxRetransmissions:       5,
+			BufferSizes:              []int{512, 1024, 2048, 4096, 8192, 16384, 32768},
+			TestDuration:             30 * time.Second,
+			MinBufferSize:            256,
+			MaxBufferSize:            65536,
+			BufferStep:               256,
+			SocketBufferSize:         4 * 1024 * 1024,
+			BatchSize:                32,
+			Cipher:                   "aes-gcm",
+			PMTUDiscovery:            true,
+			PMTUProbeTimeout:         200 * time.Millisecond,
+			PMTUProbeRetries:         3,
+			HeartbeatInterval:        500 * time.Millisecond,
+			HeartbeatMisses:          4,
+			ReconnectAttempts:        3,
+			Pacing:                   true,
+			PacingBurst:              4,
+			MulticastGroup:           "239.255.42.99:9999",
+			MulticastTTL:             1,
+			MulticastPayload:         1400,
+			MulticastRate:            20 * 1024 * 1024,
+			MulticastRepairThreshold: 2,
+			MulticastNackInterval:    200 * time.Millisecond,
+			MulticastJoinTimeout:     10 * time.Second,
+			MulticastReceiverTimeout: 10 * time.Second,
 		},
 	}
 }
@@ -0,0 +1,299 @@
+package netem
+
+import (
+	"container/heap"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Config struct {
+	Loss               float64       `json:"loss"`
+	Duplicate          float64       `json:"duplicate"`
+	Reorder            float64       `json:"reorder"`
+	ReorderDelay       time.Duration `json:"reorder_delay"`
+	Corrupt            float64       `json:"corrupt"`
+	Delay              time.Duration `json:"delay"`
+	Jitter             time.Duration `json:"jitter"`
+	Bandwidth          int64         `json:"bandwidth"`
+	QueueSize          int           `json:"queue_size"`
+	TCPRetransmitDelay time.Duration `json:"tcp_retransmit_delay"`
+	Seed               uint64        `json:"seed"`
+}
+
+type Stats struct {
+	Received   uint64
+	Forwarded  uint64
+	Dropped    uint64
+	QueueDrops uint64
+	Duplicated uint64
+	Reordered  uint64
+	Corrupted  uint64
+}
+
+type counters struct {
+	received   atomic.Uint64
+	forwarded  atomic.Uint64
+	dropped    atomic.Uint64
+	queueDrops atomic.Uint64
+	duplicated atomic.Uint64
+	reordered  atomic.Uint64
+	corrupted  atomic.Uint64
+}
+
+func (c *counters) snapshot() Stats {
+	return Stats{
+		Received:   c.received.Load(),
+		Forwarded:  c.forwarded.Load(),
+		Dropped:    c.dropped.Load(),
+		QueueDrops: c.queueDrops.Load(),
+		Duplicated: c.duplicated.Load(),
+		Reordered:  c.reordered.Load(),
+		Corrupted:  c.corrupted.Load(),
+	}
+}
+
+const defaultReorderDelay = 10 * time.Millisecond
+
+type impairer struct {
+	mu       sync.Mutex
+	config   Config
+	rng      *rand.Rand
+	linkFree time.Time
+	stats    *counters
+}
+
+func newImpairer(cfg Config, stream uint64, stats *counters) *impairer {
+	if cfg.ReorderDelay == 0 {
+		cfg.ReorderDelay = defaultReorderDelay
+	}
+
+	return &impairer{
+		config: cfg,
+		rng:    rand.New(rand.NewPCG(cfg.Seed, stream)),
+		stats:  stats,
+	}
+}
+
+func (im *impairer) chance(p float64) bool {
+	return p > 0 && im.rng.Float64() < p
+}
+
+func (im *impairer) latency() time.Duration {
+	d := im.config.Delay
+	if im.config.Jitter > 0 {
+		d += time.Duration(im.rng.Int64N(int64(2*im.config.Jitter))) - im.config.Jitter
+	}
+	return max(d, 0)
+}
+
+func (im *impairer) transmit(now time.Time, size int, limitQueue bool) (time.Time, bool) {
+	if im.config.Bandwidth <= 0 {
+		return now, true
+	}
+
+	if im.linkFree.Before(now) {
+		im.linkFree = now
+	}
+
+	if limitQueue && im.config.QueueSize > 0 {
+		queued := int64(im.linkFree.Sub(now).Seconds() * float64(im.config.Bandwidth))
+		if queued+int64(size) > int64(im.config.QueueSize) {
+			return time.Time{}, false
+		}
+	}
+
+	im.linkFree = im.linkFree.Add(time.Duration(float64(size) / float64(im.config.Bandwidth) * float64(time.Second)))
+	return im.linkFree, true
+}
+
+func (im *impairer) datagram(data []byte) []delivery {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	im.stats.received.Add(1)
+
+	if im.chance(im.config.Loss) {
+		im.stats.dropped.Add(1)
+		return nil
+	}
+
+	copies := 1
+	if im.chance(im.config.Duplicate) {
+		copies = 2
+		im.stats.duplicated.Add(1)
+	}
+
+	now := time.Now()
+	deliveries := make([]delivery, 0, copies)
+
+	for i := 0; i < copies; i++ {
+		departure, ok := im.transmit(now, len(data), true)
+		if !ok {
+			im.stats.queueDrops.Add(1)
+			continue
+		}
+
+		payload := make([]byte, len(data))
+		copy(payload, data)
+
+		if len(payload) > 0 && im.chance(im.config.Corrupt) {
+			bit := im.rng.IntN(len(payload) * 8)
+			payload[bit/8] ^= 1 << (bit % 8)
+			im.stats.corrupted.Add(1)
+		}
+
+		at := departure.Add(im.latency())
+		if im.chance(im.config.Reorder) {
+			at = at.Add(im.config.ReorderDelay)
+			im.stats.reordered.Add(1)
+		}
+
+		deliveries = append(deliveries, delivery{at: at, data: payload})
+	}
+
+	return deliveries
+}
+
+func (im *impairer) stream(now time.Time, size int, last time.Time) time.Time {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	im.stats.received.Add(1)
+
+	departure, _ := im.transmit(now, size, false)
+
+	at := departure.Add(im.latency())
+	if im.chance(im.config.Loss) {
+		im.stats.dropped.Add(1)
+		at = at.Add(im.config.TCPRetransmitDelay)
+	}
+
+	if at.Before(last) {
+		at = last
+	}
+
+	return at
+}
+
+type delivery struct {
+	at    time.Time
+	seq   uint64
+	data  []byte
+	write func([]byte)
+}
+
+type deliveryQueue []*delivery
+
+func (q deliveryQueue) Len() int { return len(q) }
+
+func (q deliveryQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+
+func (q deliveryQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *deliveryQueue) Push(x any) { *q = append(*q, x.(*delivery)) }
+
+func (q *deliveryQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+type scheduler struct {
+	mu      sync.Mutex
+	queue   deliveryQueue
+	seq     uint64
+	wake    chan struct{}
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	stats   *counters
+	stopped bool
+}
+
+func newScheduler(stats *counters) *scheduler {
+	s := &scheduler{
+		wake:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		stats: stats,
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s
+}
+
+func (s *scheduler) schedule(d delivery, write func([]byte)) {
+	s.mu.Lock()
+	d.seq = s.seq
+	d.write = write
+	s.seq++
+	heap.Push(&s.queue, &d)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) run() {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		wait := time.Hour
+		var due []*delivery
+		now := time.Now()
+		for s.queue.Len() > 0 {
+			next := s.queue[0]
+			if next.at.After(now) {
+				wait = next.at.Sub(now)
+				break
+			}
+			due = append(due, heap.Pop(&s.queue).(*delivery))
+		}
+		s.mu.Unlock()
+
+		for _, d := range due {
+			d.write(d.data)
+			s.stats.forwarded.Add(1)
+		}
+
+		if len(due) > 0 {
+			continue
+		}
+
+		timer.Reset(wait)
+
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *scheduler) close() {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	s.wg.Wait()
+}
@@ -0,0 +1,175 @@
+package netem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+type TCPProxy struct {
+	config     Config
+	listener   net.Listener
+	targetAddr string
+	upstream   *impairer
+	downstream *impairer
+	stats      counters
+	conns      map[net.Conn]struct{}
+	connsMu    sync.Mutex
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+}
+
+func NewTCPProxy(cfg Config) *TCPProxy {
+	if cfg.TCPRetransmitDelay == 0 {
+		cfg.TCPRetransmitDelay = 200 * time.Millisecond
+	}
+
+	p := &TCPProxy{
+		config: cfg,
+		conns:  make(map[net.Conn]struct{}),
+	}
+
+	p.upstream = newImpairer(cfg, 1, &p.stats)
+	p.downstream = newImpairer(cfg, 2, &p.stats)
+
+	return p
+}
+
+func (p *TCPProxy) Start(listenAddr, targetAddr string) error {
+	var err error
+	p.listener, err = net.Listen("tcp", listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on TCP: %w", err)
+	}
+
+	p.targetAddr = targetAddr
+
+	p.wg.Add(1)
+	go p.acceptLoop()
+
+	return nil
+}
+
+func (p *TCPProxy) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+func (p *TCPProxy) Stats() Stats {
+	return p.stats.snapshot()
+}
+
+func (p *TCPProxy) Stop() error {
+	p.closeOnce.Do(func() {
+		p.listener.Close()
+
+		p.connsMu.Lock()
+		for conn := range p.conns {
+			conn.Close()
+		}
+		p.connsMu.Unlock()
+
+		p.wg.Wait()
+	})
+	return nil
+}
+
+func (p *TCPProxy) acceptLoop() {
+	defer p.wg.Done()
+
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			fmt.Printf("Proxy accept error: %v\n", err)
+			continue
+		}
+
+		target, err := net.Dial("tcp", p.targetAddr)
+		if err != nil {
+			fmt.Printf("Proxy failed to connect to %s: %v\n", p.targetAddr, err)
+			client.Close()
+			continue
+		}
+
+		p.track(client, target)
+
+		p.wg.Add(2)
+		go p.pipe(client, target, p.upstream)
+		go p.pipe(target, client, p.downstream)
+	}
+}
+
+func (p *TCPProxy) track(conns ...net.Conn) {
+	p.connsMu.Lock()
+	defer p.connsMu.Unlock()
+
+	for _, conn := range conns {
+		p.conns[conn] = struct{}{}
+	}
+}
+
+func (p *TCPProxy) untrack(conn net.Conn) {
+	p.connsMu.Lock()
+	defer p.connsMu.Unlock()
+
+	delete(p.conns, conn)
+}
+
+func (p *TCPProxy) pipe(src, dst net.Conn, im *impairer) {
+	defer p.wg.Done()
+
+	sched := newScheduler(&p.stats)
+	defer sched.close()
+
+	var last time.Time
+	var writes sync.WaitGroup
+	buffer := make([]byte, 32*1024)
+
+	for {
+		n, err := src.Read(buffer)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buffer[:n])
+
+			last = im.stream(time.Now(), n, last)
+
+			writes.Add(1)
+			sched.schedule(delivery{at: last, data: data}, func(data []byte) {
+				defer writes.Done()
+				dst.Write(data)
+			})
+		}
+
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				fmt.Printf("Proxy read error: %v\n", err)
+			}
+			break
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		writes.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Until(last) + time.Second):
+	}
+
+	if tcpConn, ok := dst.(*net.TCPConn); ok {
+		tcpConn.CloseWrite()
+	} else {
+		dst.Close()
+	}
+
+	src.Close()
+	p.untrack(src)
+}
@@ -0,0 +1,176 @@
+package netem
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+const socketBufferSize = 4 * 1024 * 1024
+
+type UDPProxy struct {
+	config     Config
+	conn       *net.UDPConn
+	targetAddr *net.UDPAddr
+	upstream   *impairer
+	downstream *impairer
+	scheduler  *scheduler
+	stats      counters
+	peers      map[string]*net.UDPConn
+	peersMu    sync.Mutex
+	wg         sync.WaitGroup
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+func NewUDPProxy(cfg Config) *UDPProxy {
+	p := &UDPProxy{
+		config: cfg,
+		peers:  make(map[string]*net.UDPConn),
+		closed: make(chan struct{}),
+	}
+
+	p.upstream = newImpairer(cfg, 1, &p.stats)
+	p.downstream = newImpairer(cfg, 2, &p.stats)
+
+	return p
+}
+
+func (p *UDPProxy) Start(listenAddr, targetAddr string) error {
+	var err error
+	p.targetAddr, err = net.ResolveUDPAddr("udp", targetAddr)
+	if err != nil {
+		return fmt.Errorf("failed to resolve target address: %w", err)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to resolve listen address: %w", err)
+	}
+
+	p.conn, err = net.ListenUDP("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on UDP: %w", err)
+	}
+
+	setSocketBuffers(p.conn)
+
+	p.scheduler = newScheduler(&p.stats)
+
+	p.wg.Add(1)
+	go p.serveClients()
+
+	return nil
+}
+
+func (p *UDPProxy) Addr() net.Addr {
+	return p.conn.LocalAddr()
+}
+
+func (p *UDPProxy) Stats() Stats {
+	return p.stats.snapshot()
+}
+
+func (p *UDPProxy) Stop() error {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		p.conn.Close()
+
+		p.peersMu.Lock()
+		for _, peer := range p.peers {
+			peer.Close()
+		}
+		p.peersMu.Unlock()
+
+		p.wg.Wait()
+		p.scheduler.close()
+	})
+	return nil
+}
+
+func (p *UDPProxy) serveClients() {
+	defer p.wg.Done()
+
+	buffer := make([]byte, 65536)
+
+	for {
+		n, clientAddr, err := p.conn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			fmt.Printf("Proxy read error: %v\n", err)
+			continue
+		}
+
+		peer, err := p.peerFor(clientAddr)
+		if err != nil {
+			fmt.Printf("Proxy failed to open upstream socket: %v\n", err)
+			continue
+		}
+
+		for _, d := range p.upstream.datagram(buffer[:n]) {
+			p.scheduler.schedule(d, func(data []byte) {
+				peer.Write(data)
+			})
+		}
+	}
+}
+
+func (p *UDPProxy) peerFor(clientAddr *net.UDPAddr) (*net.UDPConn, error) {
+	p.peersMu.Lock()
+	defer p.peersMu.Unlock()
+
+	key := clientAddr.String()
+	if peer, exists := p.peers[key]; exists {
+		return peer, nil
+	}
+
+	select {
+	case <-p.closed:
+		return nil, net.ErrClosed
+	default:
+	}
+
+	peer, err := net.DialUDP("udp", nil, p.targetAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	setSocketBuffers(peer)
+
+	p.peers[key] = peer
+
+	p.wg.Add(1)
+	go p.serveTarget(peer, clientAddr)
+
+	return peer, nil
+}
+
+func (p *UDPProxy) serveTarget(peer *net.UDPConn, clientAddr *net.UDPAddr) {
+	defer p.wg.Done()
+
+	buffer := make([]byte, 65536)
+
+	for {
+		n, err := peer.Read(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		for _, d := range p.downstream.datagram(buffer[:n]) {
+			p.scheduler.schedule(d, func(data []byte) {
+				p.conn.WriteToUDP(data, clientAddr)
+			})
+		}
+	}
+}
+
+func setSocketBuffers(conn *net.UDPConn) {
+	conn.SetReadBuffer(socketBufferSize)
+	conn.SetWriteBuffer(socketBufferSize)
+}
@@ -0,0 +1,12 @@
+package main
+
+import "NSSaDS/pkg/admin"
+
+func main() {
+	admin.RunClient("lab3-admin", []string{
+		"list-clients          - ID, address, fd, idle time, chunk size and bytes in/out",
+		"kick-client <id>      - Disconnect a client",
+		"set-max-clients <n>   - Change the client limit",
+		"drain                 - Stop accepting and shut down when the last client leaves",
+	})
+}
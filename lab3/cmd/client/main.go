@@ -8,6 +8,7 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 )
@@ -17,7 +18,7 @@ func main() {
 	port := flag.Int("port", 8080, "Server port")
 	flag.Parse()
 
-	addr := fmt.Sprintf("%s:%d", *host, *port)
+	addr := net.JoinHostPort(*host, strconv.Itoa(*port))
 	conn, err := net.Dial("tcp", addr)
 	if err != nil {
 		fmt.Printf("Failed to connect to %s: %v\n", addr, err)
@@ -25,8 +26,17 @@ func main() {
 	}
 	defer conn.Close()
 
+	// Servers falling back from TCP_INFO measure RTT with PING lines, which
+	// answerPing handles out of sight of the user.
+	if _, err := fmt.Fprintf(conn, "%s\n", pingOptIn); err != nil {
+		fmt.Printf("Failed to send to %s: %v\n", addr, err)
+		os.Exit(1)
+	}
+
 	fmt.Printf("Connected to %s\n", addr)
-	fmt.Println("Type commands (ECHO, TIME, HELP, CLOSE, EXIT, QUIT)")
+	fmt.Println("Type commands (ECHO, TIME, DELAY, STATS, NICK, JOIN, PART, MSG, WHO, HELP, CLOSE, EXIT, QUIT)")
+	fmt.Println("  UPLOAD <local_path> <remote_name>   - Upload a file to server")
+	fmt.Println("  DOWNLOAD <remote_name> <local_path> - Download a file from server")
 	fmt.Println("Example: ECHO Hello World")
 	fmt.Print("> ")
 
@@ -49,6 +59,8 @@ func main() {
 		}
 	}()
 
+	var fileTransfers transfers
+
 	responseChan := make(chan string)
 	go func() {
 		reader := bufio.NewReader(conn)
@@ -58,7 +70,19 @@ func main() {
 				close(responseChan)
 				return
 			}
-			responseChan <- strings.TrimSpace(response)
+
+			response = strings.TrimSpace(response)
+			if fileTransfers.answerPing(conn, response) {
+				continue
+			}
+
+			response, err = fileTransfers.receive(reader, response)
+			if err != nil {
+				fmt.Printf("\n%v\n", err)
+				close(responseChan)
+				return
+			}
+			responseChan <- response
 		}
 	}()
 
@@ -83,10 +107,19 @@ func main() {
 				continue
 			}
 
-			_, err := conn.Write([]byte(input + "\n"))
+			fields := strings.Fields(input)
+			switch strings.ToUpper(fields[0]) {
+			case "UPLOAD":
+				err = fileTransfers.startUpload(conn, fields[1:])
+			case "DOWNLOAD":
+				err = fileTransfers.startDownload(conn, fields[1:])
+			default:
+				_, err = conn.Write([]byte(input + "\n"))
+			}
 			if err != nil {
 				fmt.Printf("Error sending command: %v\n", err)
-				return
+				fmt.Print("> ")
+				continue
 			}
 
 		case response, ok := <-responseChan:
@@ -96,6 +129,7 @@ func main() {
 			}
 
 			fmt.Printf("Server: %s\n", response)
+			fileTransfers.handleResponse(conn, response)
 			fmt.Print("> ")
 		}
 	}
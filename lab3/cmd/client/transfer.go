@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type transfers struct {
+	mu       sync.Mutex
+	upload   *os.File
+	download string
+}
+
+func (t *transfers) startUpload(conn net.Conn, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: UPLOAD <local_path> <remote_name>")
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	t.mu.Lock()
+	t.upload = file
+	t.mu.Unlock()
+
+	if _, err := fmt.Fprintf(conn, "UPLOAD %s %d\n", args[1], info.Size()); err != nil {
+		t.finishUpload()
+		return err
+	}
+	return nil
+}
+
+func (t *transfers) finishUpload() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.upload != nil {
+		t.upload.Close()
+		t.upload = nil
+	}
+}
+
+func (t *transfers) handleResponse(conn net.Conn, response string) {
+	t.mu.Lock()
+	file := t.upload
+	t.mu.Unlock()
+
+	if file == nil {
+		return
+	}
+	if !strings.HasPrefix(response, "READY_TO_RECEIVE") {
+		t.finishUpload()
+		return
+	}
+
+	start := time.Now()
+	n, err := io.Copy(conn, file)
+	t.finishUpload()
+	if err != nil {
+		fmt.Printf("Upload failed after %d bytes: %v\n", n, err)
+		return
+	}
+	fmt.Printf("Sent %d bytes in %v, waiting for confirmation...\n", n, time.Since(start).Round(time.Millisecond))
+}
+
+const (
+	pingOptIn      = "RTT PING"
+	pingOptInReply = "RTT ping probes on"
+)
+
+func (t *transfers) answerPing(conn net.Conn, line string) bool {
+	if line == pingOptInReply {
+		return true
+	}
+	token, ok := strings.CutPrefix(line, "PING ")
+	if !ok {
+		return false
+	}
+
+	t.mu.Lock()
+	uploading := t.upload != nil
+	t.mu.Unlock()
+
+	if !uploading {
+		fmt.Fprintf(conn, "PONG %s\n", token)
+	}
+	return true
+}
+
+func (t *transfers) startDownload(conn net.Conn, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: DOWNLOAD <remote_name> <local_path>")
+	}
+
+	t.mu.Lock()
+	t.download = args[1]
+	t.mu.Unlock()
+
+	_, err := fmt.Fprintf(conn, "DOWNLOAD %s\n", args[0])
+	return err
+}
+
+func (t *transfers) receive(reader *bufio.Reader, line string) (string, error) {
+	parts := strings.Fields(line)
+	isInfo := len(parts) == 3 && parts[0] == "FILE_INFO"
+
+	t.mu.Lock()
+	localPath := t.download
+	if isInfo || strings.HasPrefix(line, "Error") {
+		t.download = ""
+	}
+	t.mu.Unlock()
+
+	if localPath == "" || !isInfo {
+		return line, nil
+	}
+
+	size, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid file info response: %s", line)
+	}
+
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		localPath = filepath.Join(localPath, parts[1])
+	}
+	file, err := os.Create(localPath)
+	if err != nil {
+		io.CopyN(io.Discard, reader, size)
+		return fmt.Sprintf("Download failed: %v", err), nil
+	}
+	defer file.Close()
+
+	start := time.Now()
+	n, err := io.CopyN(file, reader, size)
+	if err != nil {
+		return "", fmt.Errorf("download interrupted after %d of %d bytes: %w", n, size, err)
+	}
+
+	elapsed := time.Since(start).Seconds()
+	return fmt.Sprintf("File downloaded successfully: %s (%.2f MB, %.2f MB/s)",
+		localPath, float64(n)/1024/1024, float64(n)/1024/1024/elapsed), nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type chatMessage struct {
+	sender int
+	seq    int
+}
+
+func runChat(common *commonFlags, members, messages, size int) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out := os.Stdout
+	addr := common.start(ctx)
+	ok := true
+
+	check := func(name string, err error) {
+		if err != nil {
+			fmt.Fprintf(out, "FAIL %-12s %v\n", name, err)
+			ok = false
+			return
+		}
+		fmt.Fprintf(out, "ok   %s\n", name)
+	}
+
+	conns := make([]*lineConn, members)
+	for i := range conns {
+		conn, err := dial(addr)
+		if err != nil {
+			check("join", err)
+			return false
+		}
+		defer conn.Close()
+		conns[i] = conn
+
+		if err := chatJoin(conn, fmt.Sprintf("u%d", i)); err != nil {
+			check("join", fmt.Errorf("member %d: %w", i, err))
+			return false
+		}
+	}
+
+	slow, err := dial(addr)
+	if err != nil {
+		check("join", err)
+		return false
+	}
+	defer slow.Close()
+	if err := chatJoin(slow, "slow"); err != nil {
+		check("join", err)
+		return false
+	}
+
+	who, err := conns[0].command("WHO #load", 5*time.Second)
+	for err == nil && !strings.HasPrefix(who, "WHO ") {
+		who, err = conns[0].reader.ReadString('\n')
+		who = strings.TrimSpace(who)
+	}
+	if err == nil && len(strings.Fields(who))-2 != members+1 {
+		err = fmt.Errorf("WHO listed %q, want %d nicknames", who, members+1)
+	}
+	check("join", err)
+
+	padding := strings.Repeat("p", size)
+	received := make([][]chatMessage, members)
+	errs := make([]error, members)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		wg.Add(2)
+		go func(i int, conn *lineConn) {
+			defer wg.Done()
+			for seq := 0; seq < messages; seq++ {
+				if _, err := fmt.Fprintf(conn, "MSG #load %d %d %s\n", i, seq, padding); err != nil {
+					return
+				}
+			}
+		}(i, conn)
+		go func(i int, conn *lineConn) {
+			defer wg.Done()
+			received[i], errs[i] = chatReceive(conn, (members-1)*messages, messages)
+		}(i, conn)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var orderErr error
+	for i, err := range errs {
+		if err != nil {
+			orderErr = fmt.Errorf("member %d: %w", i, err)
+			break
+		}
+	}
+	if orderErr == nil {
+		orderErr = chatCheckOrder(received)
+	}
+	total := members * (members - 1) * messages
+	fmt.Fprintf(out, "     %d members x %d messages of %d bytes: %d deliveries in %v (%.0f/s)\n",
+		members, messages, size, total, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+	check("ordering", orderErr)
+	check("slow-receiver", chatSlowReceiver(slow, out))
+
+	return ok
+}
+
+func chatJoin(conn *lineConn, nick string) error {
+	if response, err := conn.command("NICK "+nick, 5*time.Second); err != nil || response != "NICK "+nick {
+		return fmt.Errorf("NICK: got %q, %v", response, err)
+	}
+	if response, err := conn.command("JOIN #load", 5*time.Second); err != nil || response != "JOINED #load "+nick {
+		return fmt.Errorf("JOIN: got %q, %v", response, err)
+	}
+	return nil
+}
+
+func chatReceive(conn *lineConn, want, sent int) ([]chatMessage, error) {
+	conn.SetReadDeadline(time.Now().Add(time.Minute))
+	defer conn.SetReadDeadline(time.Time{})
+
+	got := make([]chatMessage, 0, want)
+	acks := 0
+	for len(got) < want || acks < sent {
+		line, err := conn.reader.ReadString('\n')
+		if err != nil {
+			return got, fmt.Errorf("after %d messages and %d acks: %w", len(got), acks, err)
+		}
+
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 2 && fields[0] == "SENT":
+			acks++
+		case len(fields) >= 5 && fields[0] == "MSG" && fields[1] == "#load":
+			sender, err1 := strconv.Atoi(fields[3])
+			seq, err2 := strconv.Atoi(fields[4])
+			if err1 != nil || err2 != nil || fields[2] != "u"+fields[3] {
+				return got, fmt.Errorf("malformed message %q", strings.TrimSpace(line))
+			}
+			got = append(got, chatMessage{sender: sender, seq: seq})
+		}
+	}
+	return got, nil
+}
+
+func chatCheckOrder(received [][]chatMessage) error {
+	for i, messages := range received {
+		next := make(map[int]int)
+		for _, m := range messages {
+			if m.seq != next[m.sender] {
+				return fmt.Errorf("member %d got message %d from u%d, expected %d", i, m.seq, m.sender, next[m.sender])
+			}
+			next[m.sender]++
+		}
+	}
+
+	for a := range received {
+		for b := a + 1; b < len(received); b++ {
+			without := func(messages []chatMessage) []chatMessage {
+				return slices.DeleteFunc(slices.Clone(messages), func(m chatMessage) bool {
+					return m.sender == a || m.sender == b
+				})
+			}
+			if !slices.Equal(without(received[a]), without(received[b])) {
+				return fmt.Errorf("members %d and %d saw the room in different orders", a, b)
+			}
+		}
+	}
+	return nil
+}
+
+func chatSlowReceiver(conn *lineConn, out io.Writer) error {
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	n, err := io.Copy(io.Discard, conn.reader)
+	if err != nil && !strings.Contains(err.Error(), "reset") {
+		return fmt.Errorf("slow receiver still connected after reading %d bytes: %w", n, err)
+	}
+	fmt.Fprintf(out, "     receiver that never read was disconnected, %d bytes were delivered to it\n", n)
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+func runFraming(common *commonFlags, flood int) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out := os.Stdout
+	addr := common.start(ctx)
+	ok := true
+
+	check := func(name string, err error) {
+		if err != nil {
+			fmt.Fprintf(out, "FAIL %-12s %v\n", name, err)
+			ok = false
+			return
+		}
+		fmt.Fprintf(out, "ok   %s\n", name)
+	}
+
+	check("pipelined", framingPipelined(addr))
+	check("fragmented", framingFragmented(addr))
+	check("quit", framingQuit(addr))
+	check("half-close", framingHalfClose(addr))
+	check("flood", framingFlood(addr, flood, out))
+
+	return ok
+}
+
+func expectLines(conn *lineConn, expected ...string) error {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	for i, want := range expected {
+		line, err := conn.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("response %d: %w", i+1, err)
+		}
+		if got := strings.TrimRight(line, "\r\n"); want != "*" && got != want {
+			return fmt.Errorf("response %d: got %q, want %q", i+1, got, want)
+		}
+	}
+	return nil
+}
+
+func expectEOF(conn *lineConn) error {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if line, err := conn.reader.ReadString('\n'); err != io.EOF {
+		return fmt.Errorf("expected the server to close, got %q, %v", line, err)
+	}
+	return nil
+}
+
+func framingPipelined(addr string) error {
+	conn, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ECHO a\nECHO b\r\n\nTIME\nECHO c d\n")); err != nil {
+		return err
+	}
+	return expectLines(conn, "a", "b", "*", "c d")
+}
+
+func framingFragmented(addr string) error {
+	conn, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, part := range []string{"EC", "HO frag", "mented\r", "\nECHO ", "two\n"} {
+		if _, err := conn.Write([]byte(part)); err != nil {
+			return err
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return expectLines(conn, "fragmented", "two")
+}
+
+func framingQuit(addr string) error {
+	conn, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ECHO last\nQUIT\nECHO ignored\n")); err != nil {
+		return err
+	}
+	if err := expectLines(conn, "last", "Connection closing..."); err != nil {
+		return err
+	}
+	return expectEOF(conn)
+}
+
+func framingHalfClose(addr string) error {
+	conn, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ECHO bye\n")); err != nil {
+		return err
+	}
+	if err := closeWrite(conn); err != nil {
+		return err
+	}
+
+	if err := expectLines(conn, "bye"); err != nil {
+		return err
+	}
+	return expectEOF(conn)
+}
+
+func closeWrite(conn *lineConn) error {
+	return conn.Conn.(*net.TCPConn).CloseWrite()
+}
+
+func framingFlood(addr string, count int, out io.Writer) error {
+	flooder, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer flooder.Close()
+
+	other, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer other.Close()
+
+	padding := strings.Repeat("x", 100)
+	var written atomic.Int64
+	writeDone := make(chan error, 1)
+	go func() {
+		for i := 0; i < count; i++ {
+			n, err := fmt.Fprintf(flooder, "ECHO %d %s\n", i, padding)
+			written.Add(int64(n))
+			if err != nil {
+				writeDone <- err
+				return
+			}
+		}
+		writeDone <- nil
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+
+	var worst time.Duration
+	for i := 0; i < 20; i++ {
+		start := time.Now()
+		if response, err := other.command("ECHO still here", 2*time.Second); err != nil || response != "still here" {
+			return fmt.Errorf("other client blocked by the flood: got %q, %v", response, err)
+		}
+		worst = max(worst, time.Since(start))
+	}
+
+	select {
+	case err := <-writeDone:
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "     flood writer finished without blocking (%d bytes), raise -flood to hit the high-water mark\n", written.Load())
+	default:
+		fmt.Fprintf(out, "     flood writer blocked after %d bytes of %d commands, other client worst round trip %v\n",
+			written.Load(), count, worst.Round(time.Microsecond))
+	}
+
+	flooder.SetReadDeadline(time.Now().Add(30 * time.Second))
+	for i := 0; i < count; i++ {
+		line, err := flooder.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("flood response %d: %w", i, err)
+		}
+		if want := fmt.Sprintf("%d %s\n", i, padding); line != want {
+			return fmt.Errorf("flood response %d out of order: %q", i, strings.TrimSpace(line))
+		}
+	}
+
+	if err := <-writeDone; err != nil {
+		return err
+	}
+	return nil
+}
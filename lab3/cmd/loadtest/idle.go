@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+func runIdle(common *commonFlags, clients int, hold time.Duration) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out := os.Stdout
+	addr := common.start(ctx)
+
+	fmt.Fprintf(out, "Opening %d idle connections to %s...\n", clients, addr)
+	start := time.Now()
+
+	conns := make([]*lineConn, clients)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var dialErr error
+	var errMu sync.Mutex
+
+	for w := 0; w < 128; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				conn, err := dial(addr)
+				if err != nil {
+					errMu.Lock()
+					dialErr = err
+					errMu.Unlock()
+					continue
+				}
+				conns[i] = conn
+			}
+		}()
+	}
+	for i := range conns {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	defer func() {
+		for _, conn := range conns {
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}()
+
+	if dialErr != nil {
+		fmt.Fprintf(out, "FAILED: could not open every connection: %v\n", dialErr)
+		return false
+	}
+	fmt.Fprintf(out, "Opened %d connections in %v\n", clients, time.Since(start).Round(time.Millisecond))
+
+	active, err := dial(addr)
+	if err != nil {
+		fmt.Fprintf(out, "FAILED: active client: %v\n", err)
+		return false
+	}
+	defer active.Close()
+
+	ok := true
+	step := clients / 10
+	if step == 0 {
+		step = 1
+	}
+	for i := 0; i < clients; i += step {
+		expected := fmt.Sprintf("idle %d", i)
+		if response, err := conns[i].command("ECHO "+expected, 5*time.Second); err != nil || response != expected {
+			fmt.Fprintf(out, "FAILED: idle connection %d: got %q, %v\n", i, response, err)
+			ok = false
+		}
+	}
+
+	var latencies []time.Duration
+	deadline := time.Now().Add(hold)
+	for time.Now().Before(deadline) {
+		sent := time.Now()
+		response, err := active.command("ECHO ping", 5*time.Second)
+		if err != nil || response != "ping" {
+			fmt.Fprintf(out, "FAILED: active client: got %q, %v\n", response, err)
+			ok = false
+			break
+		}
+		latencies = append(latencies, time.Since(sent))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	closed := 0
+	for _, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+		_, err := conn.reader.Peek(1)
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			closed++
+		}
+	}
+	if closed > 0 {
+		fmt.Fprintf(out, "FAILED: %d of %d idle connections were closed by the server\n", closed, clients)
+		ok = false
+	}
+
+	fmt.Fprintf(out, "Held %d idle connections for %v: %d still open\n", clients, hold, clients-closed)
+	fmt.Fprintf(out, "Active client: %d round trips, p50 %v, p99 %v, max %v\n", len(latencies),
+		percentile(latencies, 0.5), percentile(latencies, 0.99), percentile(latencies, 1))
+
+	return ok
+}
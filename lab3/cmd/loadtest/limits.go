@@ -0,0 +1,263 @@
+package main
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const admitWait = 50 * time.Millisecond
+
+func runLimits(common *commonFlags, maxClients, perIP int, rate float64, burst, banThreshold int, ban time.Duration) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	common.configure = func(config *domain.ServerConfig) {
+		config.MaxClients = maxClients
+		config.MaxClientsPerIP = perIP
+		config.AcceptRate = rate
+		config.AcceptBurst = burst
+		config.BanThreshold = banThreshold
+		config.BanWindow = 10 * time.Second
+		config.BanDuration = ban
+	}
+
+	out := os.Stdout
+	addr := common.start(ctx)
+	ok := true
+
+	check := func(name string, err error) {
+		if err != nil {
+			fmt.Fprintf(out, "FAIL %-12s %v\n", name, err)
+			ok = false
+			return
+		}
+		fmt.Fprintf(out, "ok   %s\n", name)
+	}
+
+	check("per-ip", limitsPerIP(addr, perIP))
+	check("rate", limitsRate(addr, burst, banThreshold, out))
+	check("ban-expiry", limitsBanExpiry(addr, ban))
+	check("full", limitsFull(addr, maxClients, banThreshold, out))
+
+	return ok
+}
+
+func dialFrom(addr, ip string) (*lineConn, error) {
+	dialer := net.Dialer{
+		Timeout:   5 * time.Second,
+		LocalAddr: &net.TCPAddr{IP: net.ParseIP(ip)},
+	}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &lineConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func admitted(conn *lineConn) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(admitWait))
+	defer conn.SetReadDeadline(time.Time{})
+
+	line, err := conn.reader.ReadString('\n')
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func expectRejected(conn *lineConn, reason string) error {
+	line, err := admitted(conn)
+	if err != nil {
+		return err
+	}
+	if want := "Error: server busy (" + reason; !strings.HasPrefix(line, want) {
+		return fmt.Errorf("got %q, want %q...", line, want)
+	}
+	return expectEOF(conn)
+}
+
+func quit(conn *lineConn) error {
+	defer conn.Close()
+	if _, err := conn.Write([]byte("QUIT\n")); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.Copy(io.Discard, conn.reader); err != nil {
+		return err
+	}
+	return nil
+}
+
+func openAdmitted(addr, ip string) (*lineConn, error) {
+	conn, err := dialFrom(addr, ip)
+	if err != nil {
+		return nil, err
+	}
+	if line, err := admitted(conn); err != nil || line != "" {
+		conn.Close()
+		return nil, fmt.Errorf("connection from %s rejected: %q, %v", ip, line, err)
+	}
+	if response, err := conn.command("ECHO "+ip, 5*time.Second); err != nil || response != ip {
+		conn.Close()
+		return nil, fmt.Errorf("ECHO from %s: got %q, %v", ip, response, err)
+	}
+	return conn, nil
+}
+
+func limitsPerIP(addr string, perIP int) error {
+	var conns []*lineConn
+	defer func() {
+		for _, conn := range conns {
+			quit(conn)
+		}
+	}()
+
+	for i := 0; i < perIP; i++ {
+		conn, err := openAdmitted(addr, "127.0.0.2")
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+	}
+
+	conn, err := dialFrom(addr, "127.0.0.2")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := expectRejected(conn, "too many connections from your address"); err != nil {
+		return fmt.Errorf("connection %d from 127.0.0.2: %w", perIP+1, err)
+	}
+
+	other, err := openAdmitted(addr, "127.0.0.3")
+	if err != nil {
+		return fmt.Errorf("other address while 127.0.0.2 is at its cap: %w", err)
+	}
+	conns = append(conns, other)
+	return nil
+}
+
+func limitsRate(addr string, burst, banThreshold int, out io.Writer) error {
+	accepted, limited := 0, 0
+	start := time.Now()
+	for attempt := 0; attempt < burst+banThreshold+10; attempt++ {
+		conn, err := dialFrom(addr, "127.0.0.4")
+		if err != nil {
+			return err
+		}
+		line, err := admitted(conn)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+
+		switch {
+		case line == "":
+			accepted++
+			if err := quit(conn); err != nil {
+				return err
+			}
+			continue
+		case strings.HasPrefix(line, "Error: server busy (accept rate exceeded)"):
+			limited++
+		case strings.HasPrefix(line, "Error: server busy (banned"):
+			conn.Close()
+			fmt.Fprintf(out, "     %d accepted, %d rate limited, then %q after %v\n", accepted, limited, line, time.Since(start).Round(time.Millisecond))
+			if accepted < burst || accepted > burst+1 {
+				return fmt.Errorf("%d connections accepted before the rate limit, want a burst of %d", accepted, burst)
+			}
+			if limited != banThreshold {
+				return fmt.Errorf("banned after %d rate-limited connections, want %d", limited, banThreshold)
+			}
+			return nil
+		default:
+			conn.Close()
+			return fmt.Errorf("unexpected response %q", line)
+		}
+		conn.Close()
+	}
+	return fmt.Errorf("never banned: %d accepted, %d rate limited", accepted, limited)
+}
+
+func limitsBanExpiry(addr string, ban time.Duration) error {
+	conn, err := dialFrom(addr, "127.0.0.4")
+	if err != nil {
+		return err
+	}
+	if err := expectRejected(conn, "banned"); err != nil {
+		conn.Close()
+		return fmt.Errorf("during the ban: %w", err)
+	}
+	conn.Close()
+
+	time.Sleep(ban)
+	conn, err = openAdmitted(addr, "127.0.0.4")
+	if err != nil {
+		return fmt.Errorf("after the ban: %w", err)
+	}
+	return quit(conn)
+}
+
+func limitsFull(addr string, maxClients, banThreshold int, out io.Writer) error {
+	var conns []*lineConn
+	defer func() {
+		for _, conn := range conns {
+			quit(conn)
+		}
+	}()
+
+	for i := 1; i <= maxClients; i++ {
+		conn, err := openAdmitted(addr, fmt.Sprintf("127.0.1.%d", i))
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+	}
+
+	conn, err := dialFrom(addr, fmt.Sprintf("127.0.1.%d", maxClients+1))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := expectRejected(conn, "max clients reached"); err != nil {
+		return err
+	}
+
+	response, err := conns[0].command("STATS SERVER", 5*time.Second)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "     %s\n", response)
+
+	stats := make(map[string]int)
+	for _, field := range strings.Fields(response) {
+		if key, value, found := strings.Cut(field, "="); found {
+			stats[key], _ = strconv.Atoi(value)
+		}
+	}
+	for key, want := range map[string]int{
+		"rejected_full":   1,
+		"rejected_per_ip": 1,
+		"rejected_rate":   banThreshold,
+		"rejected_banned": 2,
+		"bans":            1,
+	} {
+		if stats[key] != want {
+			return fmt.Errorf("%s=%d, want %d", key, stats[key], want)
+		}
+	}
+	return nil
+}
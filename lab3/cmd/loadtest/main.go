@@ -0,0 +1,255 @@
+package main
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"NSSaDS/lab3/internal/infrastructure/network"
+	"NSSaDS/lab3/internal/infrastructure/repository"
+	"NSSaDS/lab3/internal/usecase"
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  loadtest idle [-clients N] [-poller epoll] [-hold 5s]   - Hold thousands of idle clients while one stays active")
+	fmt.Fprintln(os.Stderr, "  loadtest framing [-flood N]                             - Pipelined, fragmented and flooding clients")
+	fmt.Fprintln(os.Stderr, "  loadtest transfer [-size N] [-downloads N] [-echoers N] - Downloads interleaved with ECHO traffic")
+	fmt.Fprintln(os.Stderr, "  loadtest slow [-burst N] [-stalled N]                   - Accept bursts, EOF and clients that never read")
+	fmt.Fprintln(os.Stderr, "  loadtest timers [-timeout 500ms]                        - Idle, read and write deadlines and DELAY under traffic")
+	fmt.Fprintln(os.Stderr, "  loadtest chat [-members N] [-messages N] [-size N]      - Room fan-out ordering and a receiver that never reads")
+	fmt.Fprintln(os.Stderr, "  loadtest limits [-per-ip N] [-rate R] [-burst N]        - Per-IP caps, accept rate limiting and bans (Linux loopback)")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "idle":
+		fs := flag.NewFlagSet("idle", flag.ExitOnError)
+		common := addCommonFlags(fs)
+		clients := fs.Int("clients", 5000, "Idle connections to open")
+		hold := fs.Duration("hold", 5*time.Second, "How long to keep the idle connections open")
+		fs.Parse(os.Args[2:])
+		if !runIdle(common, *clients, *hold) {
+			os.Exit(1)
+		}
+	case "framing":
+		fs := flag.NewFlagSet("framing", flag.ExitOnError)
+		common := addCommonFlags(fs)
+		flood := fs.Int("flood", 100000, "Commands the flooding client sends before reading any response")
+		fs.Parse(os.Args[2:])
+		if !runFraming(common, *flood) {
+			os.Exit(1)
+		}
+	case "transfer":
+		fs := flag.NewFlagSet("transfer", flag.ExitOnError)
+		common := addCommonFlags(fs)
+		size := fs.Int64("size", 32*1024*1024, "Size of the uploaded and downloaded file")
+		downloads := fs.Int("downloads", 2, "Concurrent downloads of the file")
+		echoers := fs.Int("echoers", 4, "Clients sending ECHO during the downloads")
+		maxLatency := fs.Duration("max-latency", 100*time.Millisecond, "Fail if an ECHO round trip takes longer during the downloads")
+		fs.Parse(os.Args[2:])
+		if !runTransfer(common, *size, *downloads, *echoers, *maxLatency) {
+			os.Exit(1)
+		}
+	case "slow":
+		fs := flag.NewFlagSet("slow", flag.ExitOnError)
+		common := addCommonFlags(fs)
+		burst := fs.Int("burst", 500, "Connections opened at once to exercise the accept loop")
+		stalled := fs.Int("stalled", 8, "Clients that stop reading, half mid-download and half mid-ECHO flood")
+		duration := fs.Duration("duration", 2*time.Second, "How long to measure ECHO next to the slow clients")
+		maxLatency := fs.Duration("max-latency", 100*time.Millisecond, "Fail if an ECHO round trip takes longer")
+		fs.Parse(os.Args[2:])
+		if !runSlow(common, *burst, *stalled, *duration, *maxLatency) {
+			os.Exit(1)
+		}
+	case "timers":
+		fs := flag.NewFlagSet("timers", flag.ExitOnError)
+		common := addCommonFlags(fs)
+		timeout := fs.Duration("timeout", 500*time.Millisecond, "Idle, read and write timeout of the in-process server")
+		fs.Parse(os.Args[2:])
+		if !runTimers(common, *timeout) {
+			os.Exit(1)
+		}
+	case "chat":
+		fs := flag.NewFlagSet("chat", flag.ExitOnError)
+		common := addCommonFlags(fs)
+		members := fs.Int("members", 20, "Room members sending at the same time")
+		messages := fs.Int("messages", 500, "Messages each member sends")
+		size := fs.Int("size", 1024, "Padding bytes per message")
+		fs.Parse(os.Args[2:])
+		if !runChat(common, *members, *messages, *size) {
+			os.Exit(1)
+		}
+	case "limits":
+		fs := flag.NewFlagSet("limits", flag.ExitOnError)
+		common := addCommonFlags(fs)
+		clients := fs.Int("clients", 8, "Client limit of the in-process server")
+		perIP := fs.Int("per-ip", 3, "Concurrent connections allowed from one address")
+		rate := fs.Float64("rate", 2, "New connections per second allowed from one address")
+		burst := fs.Int("burst", 5, "Connections one address may open at once")
+		banThreshold := fs.Int("ban-threshold", 5, "Rejected connections after which an address is banned")
+		ban := fs.Duration("ban", time.Second, "Ban duration")
+		fs.Parse(os.Args[2:])
+		if !runLimits(common, *clients, *perIP, *rate, *burst, *banThreshold, *ban) {
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
+
+type commonFlags struct {
+	server     *string
+	poller     *string
+	maxClients *int
+	verbose    *bool
+	uploadDir  string
+	configure  func(config *domain.ServerConfig)
+}
+
+func addCommonFlags(fs *flag.FlagSet) *commonFlags {
+	return &commonFlags{
+		server:     fs.String("server", "", "Existing server to test (default starts one in-process)"),
+		poller:     fs.String("poller", "epoll", "Poller of the in-process server: "+strings.Join(network.PollerNames, ", ")),
+		maxClients: fs.Int("max-clients", 100000, "Client limit of the in-process server"),
+		verbose:    fs.Bool("v", false, "Show server logs"),
+	}
+}
+
+func (c *commonFlags) start(ctx context.Context) string {
+	if !*c.verbose {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", os.DevNull, err)
+		}
+		os.Stdout = devNull
+	}
+
+	if *c.server != "" {
+		return *c.server
+	}
+
+	uploadDir, err := os.MkdirTemp("", "lab3_loadtest_*")
+	if err != nil {
+		log.Fatalf("Failed to create upload directory: %v", err)
+	}
+	c.uploadDir = uploadDir
+	go func() {
+		<-ctx.Done()
+		os.RemoveAll(uploadDir)
+	}()
+
+	config := &domain.ServerConfig{
+		Host:        "127.0.0.1",
+		MaxClients:  *c.maxClients,
+		PingTimeout: domain.DefaultPingTimeout,
+		ChunkSize:   domain.DefaultChunkSize,
+	}
+	if c.configure != nil {
+		c.configure(config)
+	}
+
+	addr, err := startServer(ctx, *c.poller, uploadDir, config)
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+	return addr
+}
+
+func startServer(ctx context.Context, pollerName, uploadDir string, config *domain.ServerConfig) (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	config.Port = listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	poller, err := network.NewPoller(pollerName)
+	if err != nil {
+		return "", err
+	}
+
+	handler := usecase.NewCommandHandler()
+	fileManager := repository.NewFileManager(uploadDir)
+	server := network.NewTCPServer(network.NewSelectMultiplexer(poller, handler, nil, fileManager), handler)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Start(ctx, config)
+	}()
+
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		select {
+		case err := <-errChan:
+			return "", err
+		default:
+		}
+
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return addr, nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("server on %s not ready: %w", addr, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+type lineConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func dial(addr string) (*lineConn, error) {
+	var conn net.Conn
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		conn, err = net.DialTimeout("tcp", addr, 5*time.Second)
+		if err == nil {
+			return &lineConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+		}
+		time.Sleep(time.Duration(attempt+1) * 50 * time.Millisecond)
+	}
+	return nil, err
+}
+
+func (c *lineConn) command(line string, timeout time.Duration) (string, error) {
+	c.SetDeadline(time.Now().Add(timeout))
+	defer c.SetDeadline(time.Time{})
+
+	if _, err := c.Write([]byte(line + "\n")); err != nil {
+		return "", err
+	}
+
+	response, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(response, "\r\n"), nil
+}
+
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
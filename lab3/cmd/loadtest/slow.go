@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func runSlow(common *commonFlags, burst, stalled int, duration, maxLatency time.Duration) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out := os.Stdout
+	addr := common.start(ctx)
+	ok := true
+
+	check := func(name string, err error) {
+		if err != nil {
+			fmt.Fprintf(out, "FAIL %-12s %v\n", name, err)
+			ok = false
+			return
+		}
+		fmt.Fprintf(out, "ok   %s\n", name)
+	}
+
+	check("accept-burst", slowAcceptBurst(addr, burst, out))
+	check("eof", slowUnterminatedEOF(addr))
+	check("slow-clients", slowClients(addr, stalled, duration, maxLatency, out))
+
+	return ok
+}
+
+func slowAcceptBurst(addr string, clients int, out io.Writer) error {
+	start := time.Now()
+	errs := make(chan error, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := dial(addr)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+
+			expected := fmt.Sprintf("burst %d", i)
+			if response, err := conn.command("ECHO "+expected, 5*time.Second); err != nil || response != expected {
+				errs <- fmt.Errorf("client %d: got %q, %v", i, response, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "     %d simultaneous connections served in %v\n", clients, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func slowUnterminatedEOF(addr string) error {
+	conn, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ECHO first\nECHO tail")); err != nil {
+		return err
+	}
+	if err := closeWrite(conn); err != nil {
+		return err
+	}
+
+	if err := expectLines(conn, "first", "tail"); err != nil {
+		return err
+	}
+	return expectEOF(conn)
+}
+
+func slowClients(addr string, stalled int, duration, maxLatency time.Duration, out io.Writer) error {
+	data := make([]byte, 8*1024*1024)
+	rand.NewChaCha8([32]byte{7}).Read(data)
+
+	uploader, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer uploader.Close()
+	if err := upload(uploader, "slow.bin", data); err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	defer func() {
+		stop.Store(true)
+		wg.Wait()
+	}()
+
+	silent, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer silent.Close()
+
+	var blockedWriters atomic.Int32
+	payload := strings.Repeat("s", 8*1024)
+	for i := 0; i < stalled; i++ {
+		conn, err := dial(addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		if i%2 == 0 {
+			if _, err := conn.Write([]byte("DOWNLOAD slow.bin\n")); err != nil {
+				return err
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
+				conn.SetWriteDeadline(time.Now().Add(50 * time.Millisecond))
+				if _, err := conn.Write([]byte("ECHO " + payload + "\n")); err != nil {
+					if !stop.Load() {
+						blockedWriters.Add(1)
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	trickler, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer trickler.Close()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, b := range []byte("ECHO trickled") {
+			if stop.Load() {
+				return
+			}
+			trickler.Write([]byte{b})
+			time.Sleep(duration / 20)
+		}
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	latencies := echoLatencies(addr, 1, duration, nil)
+	worst := percentile(latencies, 1)
+	fmt.Fprintf(out, "     %d stalled readers (%d writers blocked), 1 silent, 1 trickling: ECHO p50 %v, p99 %v, max %v (%d round trips)\n",
+		stalled, blockedWriters.Load(), percentile(latencies, 0.5), percentile(latencies, 0.99), worst, len(latencies))
+
+	if len(latencies) == 0 {
+		return fmt.Errorf("no ECHO completed next to the slow clients")
+	}
+	if worst > maxLatency {
+		return fmt.Errorf("ECHO took %v next to the slow clients (limit %v)", worst, maxLatency)
+	}
+
+	stop.Store(true)
+	wg.Wait()
+	if _, err := trickler.Write([]byte("\n")); err != nil {
+		return err
+	}
+	return expectLines(trickler, "trickled")
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func runTimers(common *commonFlags, timeout time.Duration) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	common.configure = func(config *domain.ServerConfig) {
+		config.PingTimeout = timeout
+		config.ReadTimeout = timeout
+		config.WriteTimeout = timeout
+		config.TransferTimeout = timeout
+		config.SelectTimeout = 10 * timeout
+	}
+
+	out := os.Stdout
+	addr := common.start(ctx)
+	ok := true
+
+	check := func(name string, err error) {
+		if err != nil {
+			fmt.Fprintf(out, "FAIL %-12s %v\n", name, err)
+			ok = false
+			return
+		}
+		fmt.Fprintf(out, "ok   %s\n", name)
+	}
+
+	var running atomic.Bool
+	running.Store(true)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		echoLatencies(addr, 2, 0, &running)
+	}()
+	defer func() {
+		running.Store(false)
+		wg.Wait()
+	}()
+
+	check("delay", timersDelay(addr, out))
+	check("idle", timersExpect(addr, "", "Error: idle timeout", timeout, out))
+	check("read", timersExpect(addr, "ECHO unfinished", "Error: read timeout", timeout, out))
+	check("write", timersWrite(addr, timeout, out))
+
+	return ok
+}
+
+func timersDelay(addr string, out io.Writer) error {
+	conn, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if _, err := conn.Write([]byte("DELAY 200ms second\nDELAY 50ms first\n")); err != nil {
+		return err
+	}
+	if err := expectLines(conn, "Scheduled in 200ms", "Scheduled in 50ms", "first"); err != nil {
+		return err
+	}
+	first := time.Since(start)
+	if err := expectLines(conn, "second"); err != nil {
+		return err
+	}
+	second := time.Since(start)
+
+	fmt.Fprintf(out, "     DELAY 50ms answered after %v, DELAY 200ms after %v\n", first.Round(time.Millisecond), second.Round(time.Millisecond))
+	if first > 150*time.Millisecond || second > 300*time.Millisecond {
+		return fmt.Errorf("timers fired late")
+	}
+	return nil
+}
+
+func timersExpect(addr, send, want string, timeout time.Duration, out io.Writer) error {
+	conn, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if send != "" {
+		if _, err := conn.Write([]byte(send)); err != nil {
+			return err
+		}
+	}
+	if err := expectLines(conn, want); err != nil {
+		return err
+	}
+	if err := expectEOF(conn); err != nil {
+		return err
+	}
+
+	elapsed := time.Since(start)
+	fmt.Fprintf(out, "     %q after %v while other clients stay busy\n", want, elapsed.Round(time.Millisecond))
+	if elapsed < timeout || elapsed > 2*timeout {
+		return fmt.Errorf("closed after %v, want about %v", elapsed, timeout)
+	}
+	return nil
+}
+
+func timersWrite(addr string, timeout time.Duration, out io.Writer) error {
+	conn, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	payload := "ECHO " + strings.Repeat("w", 8*1024) + "\n"
+	start := time.Now()
+	for time.Since(start) < 10*timeout {
+		conn.SetWriteDeadline(time.Now().Add(timeout / 5))
+		if _, err := conn.Write([]byte(payload)); err != nil {
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				fmt.Fprintf(out, "     stalled reader cut off after %v (%v)\n", time.Since(start).Round(time.Millisecond), err)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("client that never reads was not disconnected after %v", time.Since(start))
+}
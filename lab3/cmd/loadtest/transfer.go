@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func runTransfer(common *commonFlags, size int64, downloads, echoers int, maxLatency time.Duration) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out := os.Stdout
+	addr := common.start(ctx)
+
+	data := make([]byte, size)
+	rand.NewChaCha8([32]byte{42}).Read(data)
+	want := sha256.Sum256(data)
+
+	uploader, err := dial(addr)
+	if err != nil {
+		fmt.Fprintf(out, "FAILED: %v\n", err)
+		return false
+	}
+	defer uploader.Close()
+
+	start := time.Now()
+	if err := upload(uploader, "loadtest.bin", data); err != nil {
+		fmt.Fprintf(out, "FAILED: upload: %v\n", err)
+		return false
+	}
+	fmt.Fprintf(out, "Uploaded %d bytes in %v\n", size, time.Since(start).Round(time.Millisecond))
+
+	baseline := echoLatencies(addr, 1, 200*time.Millisecond, nil)
+
+	var running atomic.Bool
+	running.Store(true)
+	echoDone := make(chan []time.Duration, 1)
+	go func() {
+		echoDone <- echoLatencies(addr, echoers, 0, &running)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	start = time.Now()
+	results := make([]error, downloads)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = downloadAndVerify(addr, "loadtest.bin", size, want)
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	running.Store(false)
+	during := <-echoDone
+
+	ok := true
+	for i, err := range results {
+		if err != nil {
+			fmt.Fprintf(out, "FAILED: download %d: %v\n", i, err)
+			ok = false
+		}
+	}
+
+	worst := percentile(during, 1)
+	fmt.Fprintf(out, "Downloaded %d x %d bytes in %v (%.2f MB/s total)\n", downloads, size,
+		elapsed.Round(time.Millisecond), float64(size)*float64(downloads)/elapsed.Seconds()/1024/1024)
+	fmt.Fprintf(out, "ECHO before downloads: p50 %v, p99 %v (%d round trips)\n",
+		percentile(baseline, 0.5), percentile(baseline, 0.99), len(baseline))
+	fmt.Fprintf(out, "ECHO during downloads: p50 %v, p99 %v, max %v (%d round trips from %d clients)\n",
+		percentile(during, 0.5), percentile(during, 0.99), worst, len(during), echoers)
+
+	if len(during) == 0 {
+		fmt.Fprintf(out, "FAILED: no ECHO completed during the downloads\n")
+		ok = false
+	} else if worst > maxLatency {
+		fmt.Fprintf(out, "FAILED: ECHO took %v during the downloads (limit %v)\n", worst, maxLatency)
+		ok = false
+	}
+
+	return ok
+}
+
+func upload(conn *lineConn, name string, data []byte) error {
+	response, err := conn.command(fmt.Sprintf("UPLOAD %s %d", name, len(data)), 5*time.Second)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(response, "READY_TO_RECEIVE") {
+		return fmt.Errorf("unexpected response %q", response)
+	}
+
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.Write(data); err != nil {
+		return err
+	}
+	response, err = conn.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(response, "File uploaded successfully") {
+		return fmt.Errorf("unexpected response %q", strings.TrimSpace(response))
+	}
+	return nil
+}
+
+func downloadAndVerify(addr, name string, size int64, want [32]byte) error {
+	conn, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	response, err := conn.command("DOWNLOAD "+name, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(response)
+	if len(fields) != 3 || fields[0] != "FILE_INFO" || fields[2] != strconv.FormatInt(size, 10) {
+		return fmt.Errorf("unexpected response %q", response)
+	}
+
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	hash := sha256.New()
+	if _, err := io.CopyN(hash, conn.reader, size); err != nil {
+		return err
+	}
+	if got := hash.Sum(nil); !bytes.Equal(got, want[:]) {
+		return fmt.Errorf("sha256 %x, want %x", got, want)
+	}
+
+	if response, err := conn.command("ECHO done", 5*time.Second); err != nil || response != "done" {
+		return fmt.Errorf("line mode after download: got %q, %v", response, err)
+	}
+	return nil
+}
+
+func echoLatencies(addr string, clients int, duration time.Duration, running *atomic.Bool) []time.Duration {
+	var mu sync.Mutex
+	var latencies []time.Duration
+	deadline := time.Now().Add(duration)
+
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := dial(addr)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			expected := fmt.Sprintf("echo %d", i)
+			for {
+				if running != nil && !running.Load() || running == nil && time.Now().After(deadline) {
+					return
+				}
+
+				sent := time.Now()
+				if response, err := conn.command("ECHO "+expected, 5*time.Second); err != nil || response != expected {
+					return
+				}
+
+				mu.Lock()
+				latencies = append(latencies, time.Since(sent))
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return latencies
+}
@@ -3,13 +3,17 @@ package main
 import (
 	"NSSaDS/lab3/internal/domain"
 	"NSSaDS/lab3/internal/infrastructure/network"
+	"NSSaDS/lab3/internal/infrastructure/repository"
 	"NSSaDS/lab3/internal/usecase"
+	"NSSaDS/pkg/admin"
 	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -20,22 +24,61 @@ func main() {
 	maxClients := flag.Int("max-clients", 100, "Maximum number of clients")
 	pingTimeout := flag.Duration("ping-timeout", 30*time.Second, "Ping timeout duration")
 	chunkSize := flag.Int("chunk-size", 1024, "Default chunk size in bytes")
-	selectTimeout := flag.Duration("select-timeout", 10*time.Millisecond, "Select timeout duration")
+	selectTimeout := flag.Duration("select-timeout", 10*time.Millisecond, "Longest poller wait when no timer is due sooner")
+	readTimeout := flag.Duration("read-timeout", 10*time.Second, "Close clients that leave a command line unfinished this long")
+	writeTimeout := flag.Duration("write-timeout", 30*time.Second, "Close clients that accept no queued output for this long")
+	transferTimeout := flag.Duration("transfer-timeout", 30*time.Second, "Close clients whose upload or download makes no progress for this long")
+	highWater := flag.Int("output-high-water", 256*1024, "Queued output bytes after which a client is no longer read from")
+	uploadDir := flag.String("upload-dir", "./uploads", "Directory for uploaded and downloadable files")
+	rttSource := flag.String("rtt-source", "auto", "RTT measurement: auto (TCP_INFO, PING/PONG fallback), ping")
+	rttInterval := flag.Duration("rtt-interval", time.Second, "How often each client's RTT is re-measured")
+	maxPerIP := flag.Int("max-clients-per-ip", 0, "Concurrent connections allowed from one IP address (0 = unlimited)")
+	acceptRate := flag.Float64("accept-rate", 0, "New connections per second allowed from one IP address (0 = unlimited)")
+	acceptBurst := flag.Int("accept-burst", 0, "Connections one IP address may open at once before -accept-rate applies (default ceil(rate))")
+	banThreshold := flag.Int("ban-threshold", 5, "Rejected connections within -ban-window after which an IP address is banned (0 = never)")
+	banWindow := flag.Duration("ban-window", 10*time.Second, "Window in which rejected connections count towards a ban")
+	banDuration := flag.Duration("ban-duration", time.Minute, "How long a banned IP address is refused")
+	adminSocket := flag.String("admin-socket", "", "Unix socket for lab3-admin (disabled when empty)")
+	adminMode := flag.String("admin-mode", "0600", "Permissions of the admin socket; they decide who may use it")
+	pollerName := flag.String("poller", "select", "I/O multiplexing backend: "+strings.Join(network.PollerNames, ", "))
 	flag.Parse()
 
+	if *rttSource != "auto" && *rttSource != network.RTTSourcePing {
+		log.Fatalf("Unknown RTT source %q (choose auto or ping)", *rttSource)
+	}
+
+	poller, err := network.NewPoller(*pollerName)
+	if err != nil {
+		log.Fatalf("Failed to create poller: %v", err)
+	}
+
 	commandHandler := usecase.NewCommandHandler()
 
-	multiplexer := network.NewSelectMultiplexer(commandHandler, nil, nil)
+	fileManager := repository.NewFileManager(*uploadDir)
+
+	multiplexer := network.NewSelectMultiplexer(poller, commandHandler, nil, fileManager)
 
 	server := network.NewTCPServer(multiplexer, commandHandler)
 
 	config := &domain.ServerConfig{
-		Host:          *host,
-		Port:          *port,
-		MaxClients:    *maxClients,
-		PingTimeout:   *pingTimeout,
-		ChunkSize:     *chunkSize,
-		SelectTimeout: *selectTimeout,
+		Host:            *host,
+		Port:            *port,
+		MaxClients:      *maxClients,
+		PingTimeout:     *pingTimeout,
+		ChunkSize:       *chunkSize,
+		SelectTimeout:   *selectTimeout,
+		OutputHighWater: *highWater,
+		RTTSource:       *rttSource,
+		RTTInterval:     *rttInterval,
+		ReadTimeout:     *readTimeout,
+		WriteTimeout:    *writeTimeout,
+		TransferTimeout: *transferTimeout,
+		MaxClientsPerIP: *maxPerIP,
+		AcceptRate:      *acceptRate,
+		AcceptBurst:     *acceptBurst,
+		BanThreshold:    *banThreshold,
+		BanWindow:       *banWindow,
+		BanDuration:     *banDuration,
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -44,26 +87,42 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	if *adminSocket != "" {
+		mode, err := strconv.ParseUint(*adminMode, 8, 32)
+		if err != nil {
+			log.Fatalf("Invalid admin socket mode %q: %v", *adminMode, err)
+		}
+		adminServer := admin.NewServer(*adminSocket, os.FileMode(mode))
+		admin.RegisterControl(adminServer, multiplexer)
+		if err := adminServer.Start(); err != nil {
+			log.Fatalf("Failed to start admin socket: %v", err)
+		}
+		defer adminServer.Stop()
+		log.Printf("Admin socket listening on %s (mode %s)", *adminSocket, *adminMode)
+	}
+
 	errChan := make(chan error, 1)
 	go func() {
 		log.Printf("Starting server on %s:%d", config.Host, config.Port)
-		if err := server.Start(ctx, config); err != nil {
-			errChan <- fmt.Errorf("server error: %w", err)
-		}
+		errChan <- server.Start(ctx, config)
 	}()
 
 	fmt.Println("\n=== Lab3: TCP Server with Select() Multiplexing ===")
 	fmt.Printf("Host: %s\n", config.Host)
 	fmt.Printf("Port: %d\n", config.Port)
-	fmt.Printf("Max Clients: %d\n", config.MaxClients)
+	fmt.Printf("Max Clients: %d (per IP: %d, accept rate per IP: %g/s)\n", config.MaxClients, config.MaxClientsPerIP, config.AcceptRate)
 	fmt.Printf("Ping Timeout: %v\n", config.PingTimeout)
 	fmt.Printf("Chunk Size: %d bytes\n", config.ChunkSize)
 	fmt.Printf("Select Timeout: %v\n", config.SelectTimeout)
-	fmt.Println("\nMultiplexing Method: select() system call")
+	fmt.Printf("Read/Write/Transfer Timeouts: %v/%v/%v\n", config.ReadTimeout, config.WriteTimeout, config.TransferTimeout)
+	fmt.Printf("Upload Directory: %s\n", *uploadDir)
+	fmt.Printf("\nMultiplexing Method: %s() system call\n", poller.Name())
 	fmt.Println("Single-threaded concurrent client handling")
 	fmt.Println("\nSupported commands:")
 	fmt.Println("  ECHO <text>     - Echo the provided text")
 	fmt.Println("  TIME            - Get current server time")
+	fmt.Println("  UPLOAD <file> <size> - Upload <size> bytes sent after READY_TO_RECEIVE")
+	fmt.Println("  DOWNLOAD <file>      - Download a file, sent raw after FILE_INFO <file> <size>")
 	fmt.Println("  CLOSE/EXIT/QUIT - Close connection")
 	fmt.Println("  HELP            - Show this help message")
 	fmt.Println("\nUse telnet or netcat to connect:")
@@ -71,7 +130,7 @@ func main() {
 	fmt.Printf("  nc %s %d\n", config.Host, config.Port)
 	fmt.Println("\nFeatures:")
 	fmt.Println("  ✓ Single-threaded operation")
-	fmt.Println("  ✓ select() I/O multiplexing")
+	fmt.Println("  ✓ select(), poll() or epoll() I/O multiplexing")
 	fmt.Println("  ✓ Dynamic chunk sizing (t = ping * 10)")
 	fmt.Println("  ✓ Non-blocking file transfers")
 	fmt.Println("  ✓ Concurrent client handling")
@@ -86,10 +145,12 @@ func main() {
 		}
 
 		log.Println("Server stopped")
-		os.Exit(0)
 
 	case err := <-errChan:
-		log.Printf("Server error: %v", err)
-		os.Exit(1)
+		if err != nil {
+			log.Printf("Server error: %v", err)
+			os.Exit(1)
+		}
+		log.Println("Server drained and stopped")
 	}
 }
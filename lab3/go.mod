@@ -2,4 +2,8 @@ module NSSaDS/lab3
 
 go 1.26
 
+require NSSaDS v0.0.0
+
 require golang.org/x/sys v0.41.0
+
+replace NSSaDS => ../lab1
@@ -16,6 +16,36 @@ type CommandHandler interface {
 	RegisterCommand(command Command)
 }
 
+type Session struct {
+	ClientID   string
+	Scheduler  Scheduler
+	Send       func(response string)
+	closeHooks []func()
+}
+
+func (s *Session) OnClose(fn func()) {
+	s.closeHooks = append(s.closeHooks, fn)
+}
+
+func (s *Session) Close() {
+	hooks := s.closeHooks
+	s.closeHooks = nil
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+type sessionKey struct{}
+
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(*Session)
+	return session, ok
+}
+
 type FileInfo struct {
 	Name    string
 	Size    int64
@@ -42,6 +72,7 @@ type TransferSession struct {
 	LastUpdate  time.Time
 	FilePath    string
 	IsActive    bool
+	StartTime   time.Time
 }
 
 type ClientConnection struct {
@@ -53,15 +84,53 @@ type ClientConnection struct {
 	ChunkSize    int
 	FileTransfer *TransferSession
 	FD           uintptr
+	Output       []byte
+	Interest     PollEvents
+	ReadPaused   bool
+	Closing      bool
+	ConnectedAt  time.Time
+	BytesIn      int64
+	BytesOut     int64
+	RTT          time.Duration
+	RTTSource    string
+	RTTUpdated   time.Time
+	PingSent     time.Time
+	PingProbes   bool
+	Deadline     TimerID
+	PartialSince time.Time
+	WriteSince   time.Time
+	Session      *Session
+	Deferred     []string
 }
 
 type ServerConfig struct {
-	Host          string
-	Port          int
-	MaxClients    int
-	PingTimeout   time.Duration
-	ChunkSize     int
-	SelectTimeout time.Duration
+	Host            string
+	Port            int
+	MaxClients      int
+	PingTimeout     time.Duration
+	ChunkSize       int
+	SelectTimeout   time.Duration
+	OutputHighWater int
+	RTTSource       string
+	RTTInterval     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	TransferTimeout time.Duration
+	MaxClientsPerIP int
+	AcceptRate      float64
+	AcceptBurst     int
+	BanThreshold    int
+	BanWindow       time.Duration
+	BanDuration     time.Duration
+}
+
+type AdmissionStats struct {
+	Accepted       int64
+	RejectedFull   int64
+	RejectedPerIP  int64
+	RejectedRate   int64
+	RejectedBanned int64
+	Bans           int64
 }
 
 type SelectResult struct {
@@ -76,4 +145,16 @@ const (
 	DefaultSelectTimeout = 10 * time.Millisecond
 	MaxChunkSize         = 8192
 	MinChunkSize         = 512
+
+	DefaultOutputHighWater = 256 * 1024
+	DefaultRTTInterval     = time.Second
+	DefaultReadTimeout     = 10 * time.Second
+	DefaultWriteTimeout    = 30 * time.Second
+	DefaultTransferTimeout = 30 * time.Second
+	DefaultBanWindow       = 10 * time.Second
+	DefaultBanDuration     = time.Minute
+	SessionCleanupInterval = time.Minute
+	MaxLineLength          = 64 * 1024
+	SlowConsumerFactor     = 4
+	MaxDeferred            = 1024
 )
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"NSSaDS/pkg/admin"
 	"context"
 	"net"
 	"time"
@@ -32,6 +33,7 @@ type ConnectionManager interface {
 type FileManager interface {
 	SaveFile(filename string, data []byte, offset int64) error
 	ReadFile(filename string) ([]byte, error)
+	ReadChunk(filename string, offset int64, buf []byte) (int, error)
 	GetFileInfo(filename string) (*FileInfo, error)
 	DeleteFile(filename string) error
 	CreateTransferSession(session *TransferSession) error
@@ -43,12 +45,27 @@ type FileManager interface {
 
 type Multiplexer interface {
 	Start(ctx context.Context, config *ServerConfig) error
+	Prepare(config *ServerConfig) error
 	Stop() error
 	AddConnection(conn net.Conn) error
 	RemoveConnection(clientID string) error
 	ProcessConnections() error
 	CalculateOptimalChunkSize(ping time.Duration) int
 	SetHandler(handler CommandHandler)
+	Scheduler() Scheduler
+	ServerControl
+}
+
+type ClientInfo = admin.ClientInfo
+
+type ServerControl = admin.ServerControl
+
+type TimerID uint64
+
+type Scheduler interface {
+	After(delay time.Duration, fn func()) TimerID
+	Every(interval time.Duration, fn func()) TimerID
+	Cancel(id TimerID) bool
 }
 
 type SelectSystem interface {
@@ -58,6 +75,31 @@ type SelectSystem interface {
 	FDZero(set *FdSet)
 }
 
+type PollEvents uint32
+
+const (
+	PollRead PollEvents = 1 << iota
+	PollWrite
+	PollHangup
+	PollError
+)
+
+type PollEvent struct {
+	FD     int
+	Events PollEvents
+}
+
+type Poller interface {
+	Name() string
+	Add(fd int, events PollEvents) error
+	Modify(fd int, events PollEvents) error
+	Remove(fd int) error
+	Wait(events []PollEvent, timeout time.Duration) (int, error)
+	Close() error
+}
+
+const FdSetSize = 1024
+
 type FdSet struct {
 	Bits [32]int32
 }
@@ -0,0 +1,141 @@
+package fake
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const Unlimited = -1
+
+type Conn struct {
+	fd        int
+	local     net.Addr
+	remote    net.Addr
+	input     []byte
+	eof       bool
+	err       error
+	readLimit int
+	window    int
+	output    bytes.Buffer
+	closed    bool
+	rtt       time.Duration
+}
+
+func NewConn(fd int, local, remote net.Addr) *Conn {
+	return &Conn{
+		fd:     fd,
+		local:  local,
+		remote: remote,
+		window: Unlimited,
+		rtt:    time.Millisecond,
+	}
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	switch {
+	case c.closed:
+		return 0, unix.EBADF
+	case c.err != nil:
+		return 0, c.err
+	case len(c.input) == 0 && c.eof:
+		return 0, io.EOF
+	case len(c.input) == 0:
+		return 0, unix.EAGAIN
+	}
+
+	if c.readLimit > 0 && len(b) > c.readLimit {
+		b = b[:c.readLimit]
+	}
+	n := copy(b, c.input)
+	c.input = c.input[n:]
+	return n, nil
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	switch {
+	case c.closed:
+		return 0, unix.EBADF
+	case c.err != nil:
+		return 0, c.err
+	case c.window == 0:
+		return 0, unix.EAGAIN
+	}
+
+	n := len(b)
+	if c.window != Unlimited && n > c.window {
+		n = c.window
+	}
+	c.output.Write(b[:n])
+	if c.window != Unlimited {
+		c.window -= n
+	}
+	return n, nil
+}
+
+func (c *Conn) Close() error {
+	if c.closed {
+		return unix.EBADF
+	}
+	c.closed = true
+	return nil
+}
+
+func (c *Conn) FD() int                            { return c.fd }
+func (c *Conn) LocalAddr() net.Addr                { return c.local }
+func (c *Conn) RemoteAddr() net.Addr               { return c.remote }
+func (c *Conn) SetDeadline(t time.Time) error      { return nil }
+func (c *Conn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *Conn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (c *Conn) RTT() (time.Duration, error) {
+	if c.rtt <= 0 {
+		return 0, unix.ENOPROTOOPT
+	}
+	return c.rtt, nil
+}
+
+func (c *Conn) Feed(data string) {
+	c.input = append(c.input, data...)
+}
+
+func (c *Conn) FeedBytes(data []byte) {
+	c.input = append(c.input, data...)
+}
+
+func (c *Conn) Unread() int {
+	return len(c.input)
+}
+
+func (c *Conn) CloseWrite() {
+	c.eof = true
+}
+
+func (c *Conn) Reset() {
+	c.err = unix.ECONNRESET
+}
+
+func (c *Conn) SetReadLimit(n int) {
+	c.readLimit = n
+}
+
+func (c *Conn) SetWindow(n int) {
+	c.window = n
+}
+
+func (c *Conn) SetRTT(rtt time.Duration) {
+	c.rtt = rtt
+}
+
+func (c *Conn) TakeOutput() []byte {
+	out := bytes.Clone(c.output.Bytes())
+	c.output.Reset()
+	return out
+}
+
+func (c *Conn) Closed() bool {
+	return c.closed
+}
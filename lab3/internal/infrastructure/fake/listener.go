@@ -0,0 +1,61 @@
+package fake
+
+import (
+	"NSSaDS/lab3/internal/infrastructure/network"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+type Listener struct {
+	fd        int
+	addr      net.Addr
+	backlog   []*Conn
+	acceptErr error
+	closed    bool
+}
+
+func NewListener(fd int, addr net.Addr) *Listener {
+	return &Listener{fd: fd, addr: addr}
+}
+
+func (l *Listener) FD() int        { return l.fd }
+func (l *Listener) Addr() net.Addr { return l.addr }
+
+func (l *Listener) Accept() (network.Conn, error) {
+	if l.closed {
+		return nil, unix.EBADF
+	}
+	if err := l.acceptErr; err != nil {
+		l.acceptErr = nil
+		return nil, err
+	}
+	if len(l.backlog) == 0 {
+		return nil, unix.EAGAIN
+	}
+
+	conn := l.backlog[0]
+	l.backlog = l.backlog[1:]
+	return conn, nil
+}
+
+func (l *Listener) Close() error {
+	l.closed = true
+	return nil
+}
+
+func (l *Listener) Queue(conn *Conn) {
+	l.backlog = append(l.backlog, conn)
+}
+
+func (l *Listener) FailAccept(err error) {
+	l.acceptErr = err
+}
+
+func (l *Listener) Backlog() int {
+	return len(l.backlog)
+}
+
+func (l *Listener) Closed() bool {
+	return l.closed
+}
@@ -0,0 +1,83 @@
+package fake
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"NSSaDS/lab3/internal/infrastructure/network"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	ListenerFD  = 1000
+	firstConnFD = 1001
+)
+
+type Network struct {
+	Clock    *Clock
+	Poller   *Poller
+	Listener *Listener
+	nextFD   int
+	nextPort int
+}
+
+func NewNetwork(start time.Time) *Network {
+	clock := NewClock(start)
+	return &Network{
+		Clock:    clock,
+		Poller:   NewPoller(clock),
+		nextFD:   firstConnFD,
+		nextPort: 40000,
+	}
+}
+
+func (n *Network) Listen(host string, port int) (network.Listener, error) {
+	addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	n.Listener = NewListener(ListenerFD, addr)
+	return n.Listener, nil
+}
+
+func (n *Network) Options() []network.Option {
+	return []network.Option{
+		network.WithListener(n.Listen),
+		network.WithClock(n.Clock.Now),
+	}
+}
+
+func (n *Network) Dial(ip string) *Conn {
+	remote := &net.TCPAddr{IP: net.ParseIP(ip), Port: n.nextPort}
+	n.nextPort++
+
+	conn := NewConn(n.nextFD, n.Listener.Addr(), remote)
+	n.nextFD++
+	n.Listener.Queue(conn)
+	return conn
+}
+
+func (n *Network) Connect(ip string) *Conn {
+	conn := n.Dial(ip)
+	n.Poller.Script(domain.PollEvent{FD: ListenerFD, Events: domain.PollRead})
+	return conn
+}
+
+func (n *Network) Send(conn *Conn, data string) {
+	conn.Feed(data)
+	n.Readable(conn)
+}
+
+func (n *Network) Readable(conn *Conn) {
+	n.Poller.Script(domain.PollEvent{FD: conn.FD(), Events: domain.PollRead})
+}
+
+func (n *Network) Writable(conn *Conn, window int) {
+	conn.SetWindow(window)
+	n.Poller.Script(domain.PollEvent{FD: conn.FD(), Events: domain.PollWrite})
+}
+
+func (n *Network) Hangup(conn *Conn) {
+	conn.CloseWrite()
+	n.Poller.Script(domain.PollEvent{FD: conn.FD(), Events: domain.PollRead | domain.PollHangup})
+}
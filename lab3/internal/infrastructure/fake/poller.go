@@ -0,0 +1,98 @@
+package fake
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+type Poller struct {
+	clock    *Clock
+	interest map[int]domain.PollEvents
+	script   [][]domain.PollEvent
+	waits    []time.Duration
+}
+
+func NewPoller(clock *Clock) *Poller {
+	return &Poller{
+		clock:    clock,
+		interest: make(map[int]domain.PollEvents),
+	}
+}
+
+func (p *Poller) Name() string {
+	return "fake"
+}
+
+func (p *Poller) Add(fd int, events domain.PollEvents) error {
+	if _, exists := p.interest[fd]; exists {
+		return unix.EEXIST
+	}
+	p.interest[fd] = events
+	return nil
+}
+
+func (p *Poller) Modify(fd int, events domain.PollEvents) error {
+	if _, exists := p.interest[fd]; !exists {
+		return unix.ENOENT
+	}
+	p.interest[fd] = events
+	return nil
+}
+
+func (p *Poller) Remove(fd int) error {
+	if _, exists := p.interest[fd]; !exists {
+		return unix.ENOENT
+	}
+	delete(p.interest, fd)
+	return nil
+}
+
+func (p *Poller) Wait(events []domain.PollEvent, timeout time.Duration) (int, error) {
+	p.waits = append(p.waits, timeout)
+	if len(p.script) == 0 {
+		p.clock.Advance(timeout)
+		return 0, nil
+	}
+
+	batch := p.script[0]
+	p.script = p.script[1:]
+
+	n := 0
+	for _, event := range batch {
+		interest, watched := p.interest[event.FD]
+		if !watched || n == len(events) {
+			continue
+		}
+		if ready := event.Events & (interest | domain.PollHangup | domain.PollError); ready != 0 {
+			events[n] = domain.PollEvent{FD: event.FD, Events: ready}
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (p *Poller) Close() error {
+	return nil
+}
+
+func (p *Poller) Script(events ...domain.PollEvent) {
+	p.script = append(p.script, events)
+}
+
+func (p *Poller) Pending() int {
+	return len(p.script)
+}
+
+func (p *Poller) Interest(fd int) (domain.PollEvents, bool) {
+	events, watched := p.interest[fd]
+	return events, watched
+}
+
+func (p *Poller) LastWait() time.Duration {
+	if len(p.waits) == 0 {
+		return 0
+	}
+	return p.waits[len(p.waits)-1]
+}
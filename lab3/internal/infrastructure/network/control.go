@@ -0,0 +1,122 @@
+package network
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNotRunning    = errors.New("event loop is not running")
+	ErrUnknownClient = errors.New("no such client")
+)
+
+const loopCallTimeout = 5 * time.Second
+
+// post queues fn for the event loop and wakes it up. It reports false once
+// the loop has stopped, when fn would never run.
+func (sm *selectMultiplexer) post(fn func()) bool {
+	sm.loopMutex.Lock()
+	defer sm.loopMutex.Unlock()
+
+	if !sm.running {
+		return false
+	}
+	sm.tasks = append(sm.tasks, fn)
+	if sm.wakeup != nil {
+		sm.wakeup.Signal()
+	}
+	return true
+}
+
+func (sm *selectMultiplexer) runTasks() {
+	sm.loopMutex.Lock()
+	tasks := sm.tasks
+	sm.tasks = nil
+	sm.loopMutex.Unlock()
+
+	for _, fn := range tasks {
+		fn()
+	}
+}
+
+// callLoop runs fn on the event loop and waits for its result. fn only sends
+// into a buffered channel, so if the wait times out and fn runs later it
+// writes nowhere the caller can still see.
+func callLoop[T any](sm *selectMultiplexer, fn func() T) (T, error) {
+	result := make(chan T, 1)
+	var zero T
+	if !sm.post(func() { result <- fn() }) {
+		return zero, ErrNotRunning
+	}
+
+	select {
+	case r := <-result:
+		return r, nil
+	case <-time.After(loopCallTimeout):
+		return zero, ErrNotRunning
+	}
+}
+
+func (sm *selectMultiplexer) ListClients() ([]domain.ClientInfo, error) {
+	clients, err := callLoop(sm, func() []domain.ClientInfo {
+		var clients []domain.ClientInfo
+		now := sm.now()
+		for _, client := range sm.clients {
+			clients = append(clients, domain.ClientInfo{
+				ID:        client.ID,
+				Addr:      client.Conn.RemoteAddr().String(),
+				FD:        int(client.FD),
+				Idle:      now.Sub(client.LastPing),
+				ChunkSize: client.ChunkSize,
+				BytesIn:   client.BytesIn,
+				BytesOut:  client.BytesOut,
+			})
+		}
+		return clients
+	})
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+	return clients, err
+}
+
+func (sm *selectMultiplexer) KickClient(id string) error {
+	kickErr, err := callLoop(sm, func() error {
+		client, exists := sm.clients[id]
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrUnknownClient, id)
+		}
+
+		fmt.Printf("Client %s kicked by administrator\n", id)
+		if len(client.Output) == 0 && client.FileTransfer == nil {
+			writeNonBlocking(client.Conn, []byte("Error: disconnected by administrator\n"))
+		}
+		sm.RemoveConnection(id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return kickErr
+}
+
+func (sm *selectMultiplexer) SetMaxClients(n int) (int, error) {
+	return callLoop(sm, func() int {
+		old := sm.config.MaxClients
+		sm.config.MaxClients = n
+		fmt.Printf("Max clients changed by administrator: %d -> %d\n", old, n)
+		return old
+	})
+}
+
+func (sm *selectMultiplexer) Drain() (int, error) {
+	return callLoop(sm, func() int {
+		if !sm.draining {
+			sm.draining = true
+			sm.closeListener()
+			fmt.Printf("Draining: no longer accepting clients\n")
+		}
+		return len(sm.clients)
+	})
+}
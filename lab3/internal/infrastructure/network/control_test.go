@@ -0,0 +1,53 @@
+package network_test
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"NSSaDS/lab3/internal/infrastructure/network"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestControlCallsDuringLoopAndAfterStop(t *testing.T) {
+	s := newSimServer(t, func(config *domain.ServerConfig) {
+		config.PingTimeout = time.Hour
+	})
+	control := s.mux.(domain.ServerControl)
+	s.connect("10.0.0.1")
+
+	type listResult struct {
+		clients []domain.ClientInfo
+		err     error
+	}
+	results := make(chan listResult)
+	go func() {
+		for i := 0; i < 20; i++ {
+			clients, err := control.ListClients()
+			results <- listResult{clients, err}
+		}
+		close(results)
+	}()
+
+	for received := 0; received < 20; {
+		s.step()
+		select {
+		case r, ok := <-results:
+			if !ok {
+				t.Fatalf("results closed after %d calls", received)
+			}
+			if r.err != nil || len(r.clients) != 1 || r.clients[0].ID != "client_1" {
+				t.Fatalf("ListClients = %+v, %v", r.clients, r.err)
+			}
+			received++
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	s.mux.Stop()
+	if _, err := control.ListClients(); !errors.Is(err, network.ErrNotRunning) {
+		t.Fatalf("ListClients after Stop: err = %v, want ErrNotRunning", err)
+	}
+	if err := control.KickClient("client_1"); !errors.Is(err, network.ErrNotRunning) {
+		t.Fatalf("KickClient after Stop: err = %v, want ErrNotRunning", err)
+	}
+}
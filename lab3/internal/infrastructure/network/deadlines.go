@@ -0,0 +1,91 @@
+package network
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"fmt"
+	"time"
+)
+
+func (sm *selectMultiplexer) clientDeadline(client *domain.ClientConnection) (time.Time, string) {
+	transferring := client.FileTransfer != nil && client.FileTransfer.IsActive
+
+	var when time.Time
+	var reason string
+	switch {
+	case transferring:
+		when, reason = client.FileTransfer.LastUpdate.Add(sm.config.TransferTimeout), "transfer"
+	case len(client.Output) > 0:
+		when, reason = client.WriteSince.Add(sm.config.WriteTimeout), "write"
+	case !client.PartialSince.IsZero():
+		when, reason = client.PartialSince.Add(sm.config.ReadTimeout), "read"
+	default:
+		when, reason = client.LastPing.Add(sm.config.PingTimeout), "idle"
+	}
+
+	if transferring && len(client.Output) > 0 {
+		if write := client.WriteSince.Add(sm.config.WriteTimeout); write.Before(when) {
+			when, reason = write, "write"
+		}
+	}
+	if !client.PartialSince.IsZero() {
+		if read := client.PartialSince.Add(sm.config.ReadTimeout); read.Before(when) {
+			when, reason = read, "read"
+		}
+	}
+	return when, reason
+}
+
+func (sm *selectMultiplexer) armDeadline(client *domain.ClientConnection) {
+	when, _ := sm.clientDeadline(client)
+	if current, armed := sm.timers.When(client.Deadline); armed {
+		if when.Before(current) {
+			sm.timers.Reset(client.Deadline, when)
+		}
+		return
+	}
+	client.Deadline = sm.timers.At(when, func() { sm.expireDeadline(client) })
+}
+
+func (sm *selectMultiplexer) expireDeadline(client *domain.ClientConnection) {
+	if !client.IsActive {
+		return
+	}
+
+	when, reason := sm.clientDeadline(client)
+	if sm.now().Before(when) {
+		client.Deadline = sm.timers.At(when, func() { sm.expireDeadline(client) })
+		return
+	}
+
+	fmt.Printf("Client %s %s timeout, disconnecting\n", client.ID, reason)
+	if len(client.Output) == 0 && client.FileTransfer == nil {
+		writeNonBlocking(client.Conn, []byte(fmt.Sprintf("Error: %s timeout\n", reason)))
+	}
+	sm.RemoveConnection(client.ID)
+}
+
+func (sm *selectMultiplexer) newSession(client *domain.ClientConnection) *domain.Session {
+	return &domain.Session{
+		ClientID:  client.ID,
+		Scheduler: sm.timers,
+		Send: func(response string) {
+			if !client.IsActive || client.Closing {
+				return
+			}
+			if len(client.Output) >= sm.config.OutputHighWater*domain.SlowConsumerFactor || len(client.Deferred) >= domain.MaxDeferred {
+				fmt.Printf("Client %s is not reading its messages (%d bytes queued), disconnecting\n", client.ID, len(client.Output))
+				sm.RemoveConnection(client.ID)
+				return
+			}
+			if isDownloading(client) {
+				client.Deferred = append(client.Deferred, response)
+				return
+			}
+			sm.queueOutput(client, response)
+			if err := sm.updateInterest(client); err != nil {
+				fmt.Printf("Error sending to client %s: %v\n", client.ID, err)
+				sm.RemoveConnection(client.ID)
+			}
+		},
+	}
+}
@@ -0,0 +1,12 @@
+//go:build darwin
+
+package network
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"errors"
+)
+
+func newEpollPoller() (domain.Poller, error) {
+	return nil, errors.New("epoll is only available on Linux, use -poller poll")
+}
@@ -0,0 +1,97 @@
+//go:build linux
+
+package network
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+type epollPoller struct {
+	epfd   int
+	events []unix.EpollEvent
+}
+
+func newEpollPoller() (domain.Poller, error) {
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("epoll_create1: %w", err)
+	}
+	return &epollPoller{epfd: epfd}, nil
+}
+
+func (ep *epollPoller) Name() string {
+	return "epoll"
+}
+
+func (ep *epollPoller) Add(fd int, events domain.PollEvents) error {
+	return ep.control(unix.EPOLL_CTL_ADD, fd, events)
+}
+
+func (ep *epollPoller) Modify(fd int, events domain.PollEvents) error {
+	return ep.control(unix.EPOLL_CTL_MOD, fd, events)
+}
+
+func (ep *epollPoller) Remove(fd int) error {
+	if err := unix.EpollCtl(ep.epfd, unix.EPOLL_CTL_DEL, fd, nil); err != nil {
+		return fmt.Errorf("epoll_ctl del fd %d: %w", fd, err)
+	}
+	return nil
+}
+
+func (ep *epollPoller) control(op, fd int, events domain.PollEvents) error {
+	// RDHUP is level-triggered like IN, so it is only wanted while reads are:
+	// a peer that half-closed a client with paused reads would spin the loop.
+	event := unix.EpollEvent{Fd: int32(fd)}
+	if events&domain.PollRead != 0 {
+		event.Events |= unix.EPOLLIN | unix.EPOLLRDHUP
+	}
+	if events&domain.PollWrite != 0 {
+		event.Events |= unix.EPOLLOUT
+	}
+
+	if err := unix.EpollCtl(ep.epfd, op, fd, &event); err != nil {
+		return fmt.Errorf("epoll_ctl fd %d: %w", fd, err)
+	}
+	return nil
+}
+
+func (ep *epollPoller) Wait(events []domain.PollEvent, timeout time.Duration) (int, error) {
+	if cap(ep.events) < len(events) {
+		ep.events = make([]unix.EpollEvent, len(events))
+	}
+
+	n, err := unix.EpollWait(ep.epfd, ep.events[:len(events)], pollTimeoutMillis(timeout))
+	if err != nil || n <= 0 {
+		return 0, err
+	}
+
+	for i := 0; i < n; i++ {
+		mask := ep.events[i].Events
+
+		var ready domain.PollEvents
+		if mask&unix.EPOLLIN != 0 {
+			ready |= domain.PollRead
+		}
+		if mask&unix.EPOLLOUT != 0 {
+			ready |= domain.PollWrite
+		}
+		if mask&(unix.EPOLLHUP|unix.EPOLLRDHUP) != 0 {
+			ready |= domain.PollHangup
+		}
+		if mask&unix.EPOLLERR != 0 {
+			ready |= domain.PollError
+		}
+
+		events[i] = domain.PollEvent{FD: int(ep.events[i].Fd), Events: ready}
+	}
+
+	return n, nil
+}
+
+func (ep *epollPoller) Close() error {
+	return unix.Close(ep.epfd)
+}
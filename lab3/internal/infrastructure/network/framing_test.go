@@ -0,0 +1,102 @@
+//go:build linux || darwin
+
+package network_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLineFraming(t *testing.T) {
+	for _, pollerName := range []string{"select", "epoll"} {
+		t.Run(pollerName, func(t *testing.T) {
+			s := startLoopbackServer(t, pollerName, nil)
+
+			t.Run("pipelined", func(t *testing.T) {
+				conn := s.dial(t)
+				if _, err := conn.Write([]byte("ECHO a\nECHO b\r\n\nTIME\nECHO c d\n")); err != nil {
+					t.Fatal(err)
+				}
+				conn.expectLines(t, "a", "b", "*", "c d")
+			})
+
+			t.Run("fragmented", func(t *testing.T) {
+				conn := s.dial(t)
+				for _, part := range []string{"EC", "HO frag", "mented\r", "\nECHO ", "two\n"} {
+					if _, err := conn.Write([]byte(part)); err != nil {
+						t.Fatal(err)
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				conn.expectLines(t, "fragmented", "two")
+			})
+
+			t.Run("quit", func(t *testing.T) {
+				conn := s.dial(t)
+				if _, err := conn.Write([]byte("ECHO last\nQUIT\nECHO ignored\n")); err != nil {
+					t.Fatal(err)
+				}
+				conn.expectLines(t, "last", "Connection closing...")
+				conn.expectEOF(t)
+			})
+
+			t.Run("half-close", func(t *testing.T) {
+				conn := s.dial(t)
+				if _, err := conn.Write([]byte("ECHO first\nECHO tail")); err != nil {
+					t.Fatal(err)
+				}
+				if err := conn.CloseWrite(); err != nil {
+					t.Fatal(err)
+				}
+				conn.expectLines(t, "first", "tail")
+				conn.expectEOF(t)
+			})
+
+			t.Run("interleaved", func(t *testing.T) {
+				const clients, lines = 8, 200
+				conns := make([]*lineConn, clients)
+				for i := range conns {
+					conns[i] = s.dial(t)
+				}
+
+				var wg sync.WaitGroup
+				errs := make(chan error, clients)
+				for i, conn := range conns {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for j := 0; j < lines; j++ {
+							// Split every command at a different offset so that
+							// lines of different clients arrive interleaved.
+							line := fmt.Sprintf("ECHO c%d-%d\n", i, j)
+							cut := (i + j) % len(line)
+							if _, err := conn.Write([]byte(line[:cut])); err != nil {
+								errs <- err
+								return
+							}
+							if _, err := conn.Write([]byte(line[cut:])); err != nil {
+								errs <- err
+								return
+							}
+						}
+					}()
+				}
+				wg.Wait()
+				close(errs)
+				if err := <-errs; err != nil {
+					t.Fatalf("writing: %v", err)
+				}
+
+				for i, conn := range conns {
+					want := make([]string, lines)
+					for j := range want {
+						want[j] = fmt.Sprintf("c%d-%d", i, j)
+					}
+					conn.expectLines(t, want...)
+				}
+			})
+		})
+	}
+}
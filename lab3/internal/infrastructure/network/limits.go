@@ -0,0 +1,184 @@
+package network
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+const (
+	rejectBanned = "banned"
+	rejectRate   = "accept rate exceeded"
+	rejectPerIP  = "too many connections from your address"
+	rejectFull   = "max clients reached"
+)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type strikes struct {
+	count int
+	since time.Time
+}
+
+type admission struct {
+	connected map[string]int
+	buckets   map[string]*tokenBucket
+	strikes   map[string]*strikes
+	bans      map[string]time.Time
+	stats     domain.AdmissionStats
+}
+
+func newAdmission() *admission {
+	return &admission{
+		connected: make(map[string]int),
+		buckets:   make(map[string]*tokenBucket),
+		strikes:   make(map[string]*strikes),
+		bans:      make(map[string]time.Time),
+	}
+}
+
+func remoteIP(addr net.Addr) string {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func (sm *selectMultiplexer) acceptBurst() float64 {
+	if sm.config.AcceptBurst > 0 {
+		return float64(sm.config.AcceptBurst)
+	}
+	return math.Max(1, math.Ceil(sm.config.AcceptRate))
+}
+
+func (sm *selectMultiplexer) takeToken(ip string, now time.Time) bool {
+	if sm.config.AcceptRate <= 0 {
+		return true
+	}
+
+	burst := sm.acceptBurst()
+	bucket, exists := sm.limits.buckets[ip]
+	if !exists {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		sm.limits.buckets[ip] = bucket
+	}
+
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*sm.config.AcceptRate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+func (sm *selectMultiplexer) admit(ip string, now time.Time) string {
+	limits := sm.limits
+
+	if until, banned := limits.bans[ip]; banned {
+		if now.Before(until) {
+			limits.stats.RejectedBanned++
+			return rejectBanned
+		}
+		delete(limits.bans, ip)
+	}
+
+	reason := ""
+	switch {
+	case !sm.takeToken(ip, now):
+		limits.stats.RejectedRate++
+		reason = rejectRate
+	case sm.config.MaxClientsPerIP > 0 && limits.connected[ip] >= sm.config.MaxClientsPerIP:
+		limits.stats.RejectedPerIP++
+		reason = rejectPerIP
+	case len(sm.clients) >= sm.config.MaxClients:
+		limits.stats.RejectedFull++
+		return rejectFull
+	default:
+		return ""
+	}
+
+	sm.strike(ip, now)
+	return reason
+}
+
+func (sm *selectMultiplexer) strike(ip string, now time.Time) {
+	if sm.config.BanThreshold <= 0 {
+		return
+	}
+
+	s, exists := sm.limits.strikes[ip]
+	if !exists || now.Sub(s.since) > sm.config.BanWindow {
+		s = &strikes{since: now}
+		sm.limits.strikes[ip] = s
+	}
+	s.count++
+
+	if s.count >= sm.config.BanThreshold {
+		delete(sm.limits.strikes, ip)
+		sm.limits.bans[ip] = now.Add(sm.config.BanDuration)
+		sm.limits.stats.Bans++
+		fmt.Printf("Banned %s for %v after %d rejected connections in %v\n", ip, sm.config.BanDuration, s.count, sm.config.BanWindow)
+	}
+}
+
+func (sm *selectMultiplexer) reject(conn Conn, ip, reason string) {
+	if reason != rejectBanned {
+		fmt.Printf("Rejecting connection from %s: %s\n", conn.RemoteAddr(), reason)
+	}
+
+	message := fmt.Sprintf("Error: server busy (%s)\n", reason)
+	if reason == rejectBanned {
+		message = fmt.Sprintf("Error: server busy (banned for %v)\n", sm.limits.bans[ip].Sub(sm.now()).Round(time.Second))
+	}
+	writeNonBlocking(conn, []byte(message))
+	conn.Close()
+}
+
+func (sm *selectMultiplexer) pruneLimits() {
+	now := sm.now()
+	limits := sm.limits
+
+	for ip, until := range limits.bans {
+		if !now.Before(until) {
+			delete(limits.bans, ip)
+		}
+	}
+	for ip, s := range limits.strikes {
+		if now.Sub(s.since) > sm.config.BanWindow {
+			delete(limits.strikes, ip)
+		}
+	}
+	if sm.config.AcceptRate > 0 {
+		refill := time.Duration(sm.acceptBurst() / sm.config.AcceptRate * float64(time.Second))
+		for ip, bucket := range limits.buckets {
+			if now.Sub(bucket.last) > refill {
+				delete(limits.buckets, ip)
+			}
+		}
+	}
+}
+
+func (sm *selectMultiplexer) serverStats() string {
+	now := sm.now()
+	banned := 0
+	for _, until := range sm.limits.bans {
+		if now.Before(until) {
+			banned++
+		}
+	}
+
+	s := sm.limits.stats
+	return fmt.Sprintf("STATS server clients=%d/%d per_ip=%d accepted=%d rejected_full=%d rejected_per_ip=%d rejected_rate=%d rejected_banned=%d bans=%d banned_now=%d",
+		len(sm.clients), sm.config.MaxClients, sm.config.MaxClientsPerIP, s.Accepted,
+		s.RejectedFull, s.RejectedPerIP, s.RejectedRate, s.RejectedBanned, s.Bans, banned)
+}
@@ -0,0 +1,207 @@
+//go:build linux || darwin
+
+package network_test
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"NSSaDS/lab3/internal/infrastructure/network"
+	"NSSaDS/lab3/internal/infrastructure/repository"
+	"NSSaDS/lab3/internal/usecase"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+type loopbackServer struct {
+	addr string
+}
+
+// startLoopbackServer runs a real multiplexer with the named poller on a free
+// loopback port until the test ends.
+func startLoopbackServer(t *testing.T, pollerName string, configure func(config *domain.ServerConfig)) *loopbackServer {
+	t.Helper()
+
+	poller, err := network.NewPoller(pollerName)
+	if err != nil {
+		t.Skipf("poller %s: %v", pollerName, err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("picking a port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	config := &domain.ServerConfig{
+		Host:        "127.0.0.1",
+		Port:        port,
+		MaxClients:  100000,
+		PingTimeout: time.Minute,
+	}
+	if configure != nil {
+		configure(config)
+	}
+
+	uploadDir := t.TempDir()
+	handler := usecase.NewCommandHandler()
+	server := network.NewTCPServer(network.NewSelectMultiplexer(poller, handler, nil, repository.NewFileManager(uploadDir)), handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Start(ctx, config)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(port))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return &loopbackServer{addr: addr}
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("server stopped during startup: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server on %s not ready: %v", addr, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type lineConn struct {
+	*net.TCPConn
+	reader *bufio.Reader
+}
+
+func (s *loopbackServer) dial(t *testing.T) *lineConn {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", s.addr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("dial %s: %v", s.addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &lineConn{TCPConn: conn.(*net.TCPConn), reader: bufio.NewReader(conn)}
+}
+
+func (c *lineConn) command(line string) (string, error) {
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+	defer c.SetDeadline(time.Time{})
+
+	if _, err := c.Write([]byte(line + "\n")); err != nil {
+		return "", err
+	}
+	response, err := c.reader.ReadString('\n')
+	return strings.TrimRight(response, "\r\n"), err
+}
+
+func (c *lineConn) expectLines(t *testing.T, want ...string) {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer c.SetReadDeadline(time.Time{})
+
+	for i, w := range want {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("response %d: %v", i+1, err)
+		}
+		if got := strings.TrimRight(line, "\r\n"); w != "*" && got != w {
+			t.Fatalf("response %d: got %q, want %q", i+1, got, w)
+		}
+	}
+}
+
+func (c *lineConn) expectEOF(t *testing.T) {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if line, err := c.reader.ReadString('\n'); err != io.EOF {
+		t.Fatalf("expected the server to close, got %q, %v", line, err)
+	}
+}
+
+// maxLatency reports the slowest of the ECHO round trips made on conn during d.
+func maxLatency(t *testing.T, conn *lineConn, d time.Duration) time.Duration {
+	t.Helper()
+	var worst time.Duration
+	rounds := 0
+	for deadline := time.Now().Add(d); time.Now().Before(deadline); rounds++ {
+		start := time.Now()
+		if response, err := conn.command("ECHO ping"); err != nil || response != "ping" {
+			t.Fatalf("ECHO round trip %d: got %q, %v", rounds, response, err)
+		}
+		worst = max(worst, time.Since(start))
+		time.Sleep(5 * time.Millisecond)
+	}
+	return worst
+}
+
+func openFileLimit(t *testing.T) int {
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &limit); err != nil {
+		t.Fatalf("getrlimit: %v", err)
+	}
+	return int(min(limit.Cur, 1<<20))
+}
+
+func TestIdleConnections(t *testing.T) {
+	for _, pollerName := range network.PollerNames {
+		t.Run(pollerName, func(t *testing.T) {
+			clients := 2000
+			if testing.Short() {
+				clients = 300
+			}
+			if pollerName == "select" {
+				// Both ends of every connection live in this process and
+				// select() cannot watch descriptors past FD_SETSIZE.
+				clients = min(clients, (domain.FdSetSize-64)/2)
+			}
+			// The client and the server end of each connection share the
+			// process limit.
+			clients = min(clients, (openFileLimit(t)-64)/2)
+
+			s := startLoopbackServer(t, pollerName, nil)
+			conns := make([]*lineConn, clients)
+			for i := range conns {
+				conns[i] = s.dial(t)
+			}
+
+			active := s.dial(t)
+			for i := 0; i < clients; i += max(clients/10, 1) {
+				want := fmt.Sprintf("idle %d", i)
+				if got, err := conns[i].command("ECHO " + want); err != nil || got != want {
+					t.Fatalf("idle connection %d: got %q, %v", i, got, err)
+				}
+			}
+
+			if worst := maxLatency(t, active, 300*time.Millisecond); worst > time.Second {
+				t.Fatalf("ECHO took %v next to %d idle connections", worst, clients)
+			}
+
+			for i, conn := range conns {
+				conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+				_, err := conn.reader.Peek(1)
+				var netErr net.Error
+				if !errors.As(err, &netErr) || !netErr.Timeout() {
+					t.Fatalf("idle connection %d was closed by the server: %v", i, err)
+				}
+			}
+		})
+	}
+}
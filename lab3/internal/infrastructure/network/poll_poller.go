@@ -0,0 +1,124 @@
+//go:build linux || darwin
+
+package network
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+type pollPoller struct {
+	fds   []unix.PollFd
+	index map[int]int
+	next  int
+}
+
+func newPollPoller() *pollPoller {
+	return &pollPoller{
+		index: make(map[int]int),
+	}
+}
+
+func (pp *pollPoller) Name() string {
+	return "poll"
+}
+
+func (pp *pollPoller) Add(fd int, events domain.PollEvents) error {
+	if _, exists := pp.index[fd]; exists {
+		return fmt.Errorf("fd %d: %w", fd, ErrAlreadyWatched)
+	}
+
+	pp.index[fd] = len(pp.fds)
+	pp.fds = append(pp.fds, unix.PollFd{Fd: int32(fd), Events: toPollEvents(events)})
+	return nil
+}
+
+func (pp *pollPoller) Modify(fd int, events domain.PollEvents) error {
+	i, exists := pp.index[fd]
+	if !exists {
+		return fmt.Errorf("fd %d: %w", fd, ErrNotWatched)
+	}
+	pp.fds[i].Events = toPollEvents(events)
+	return nil
+}
+
+func (pp *pollPoller) Remove(fd int) error {
+	i, exists := pp.index[fd]
+	if !exists {
+		return fmt.Errorf("fd %d: %w", fd, ErrNotWatched)
+	}
+
+	last := len(pp.fds) - 1
+	if i != last {
+		pp.fds[i] = pp.fds[last]
+		pp.index[int(pp.fds[i].Fd)] = i
+	}
+	pp.fds = pp.fds[:last]
+	delete(pp.index, fd)
+	return nil
+}
+
+func (pp *pollPoller) Wait(events []domain.PollEvent, timeout time.Duration) (int, error) {
+	n, err := unix.Poll(pp.fds, pollTimeoutMillis(timeout))
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	// The scan starts where the last one stopped, so descriptors beyond a full
+	// events slice are served first next time instead of starving.
+	count, start := 0, pp.next
+	for j := range pp.fds {
+		if count == len(events) {
+			break
+		}
+		i := (start + j) % len(pp.fds)
+		if pp.fds[i].Revents == 0 {
+			continue
+		}
+
+		events[count] = domain.PollEvent{FD: int(pp.fds[i].Fd), Events: fromPollEvents(pp.fds[i].Revents)}
+		pp.fds[i].Revents = 0
+		pp.next = i + 1
+		count++
+	}
+	pp.next %= len(pp.fds)
+
+	return count, nil
+}
+
+func (pp *pollPoller) Close() error {
+	pp.fds = nil
+	pp.index = make(map[int]int)
+	return nil
+}
+
+func toPollEvents(events domain.PollEvents) int16 {
+	var mask int16
+	if events&domain.PollRead != 0 {
+		mask |= unix.POLLIN
+	}
+	if events&domain.PollWrite != 0 {
+		mask |= unix.POLLOUT
+	}
+	return mask
+}
+
+func fromPollEvents(mask int16) domain.PollEvents {
+	var events domain.PollEvents
+	if mask&unix.POLLIN != 0 {
+		events |= domain.PollRead
+	}
+	if mask&unix.POLLOUT != 0 {
+		events |= domain.PollWrite
+	}
+	if mask&unix.POLLHUP != 0 {
+		events |= domain.PollHangup
+	}
+	if mask&(unix.POLLERR|unix.POLLNVAL) != 0 {
+		events |= domain.PollError
+	}
+	return events
+}
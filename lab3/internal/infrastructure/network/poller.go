@@ -0,0 +1,157 @@
+//go:build linux || darwin
+
+package network
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUnknownPoller  = errors.New("unknown poller")
+	ErrFDOutOfRange   = fmt.Errorf("file descriptor does not fit in select() FD_SETSIZE (%d)", domain.FdSetSize)
+	ErrNotWatched     = errors.New("file descriptor is not registered")
+	ErrAlreadyWatched = errors.New("file descriptor is already registered")
+)
+
+var PollerNames = []string{"select", "poll", "epoll"}
+
+func NewPoller(name string) (domain.Poller, error) {
+	switch name {
+	case "select":
+		return NewSelectPoller(&UnixSelectSystem{}), nil
+	case "poll":
+		return newPollPoller(), nil
+	case "epoll":
+		return newEpollPoller()
+	default:
+		return nil, fmt.Errorf("%w %q (choose select, poll or epoll)", ErrUnknownPoller, name)
+	}
+}
+
+type selectPoller struct {
+	system domain.SelectSystem
+	fds    map[int]domain.PollEvents
+	maxFD  int
+}
+
+func NewSelectPoller(system domain.SelectSystem) domain.Poller {
+	return &selectPoller{
+		system: system,
+		fds:    make(map[int]domain.PollEvents),
+		maxFD:  -1,
+	}
+}
+
+func (sp *selectPoller) Name() string {
+	return "select"
+}
+
+func (sp *selectPoller) Add(fd int, events domain.PollEvents) error {
+	if fd < 0 || fd >= domain.FdSetSize {
+		return fmt.Errorf("fd %d: %w", fd, ErrFDOutOfRange)
+	}
+	if _, exists := sp.fds[fd]; exists {
+		return fmt.Errorf("fd %d: %w", fd, ErrAlreadyWatched)
+	}
+
+	sp.fds[fd] = events
+	if fd > sp.maxFD {
+		sp.maxFD = fd
+	}
+	return nil
+}
+
+func (sp *selectPoller) Modify(fd int, events domain.PollEvents) error {
+	if _, exists := sp.fds[fd]; !exists {
+		return fmt.Errorf("fd %d: %w", fd, ErrNotWatched)
+	}
+	sp.fds[fd] = events
+	return nil
+}
+
+func (sp *selectPoller) Remove(fd int) error {
+	if _, exists := sp.fds[fd]; !exists {
+		return fmt.Errorf("fd %d: %w", fd, ErrNotWatched)
+	}
+
+	delete(sp.fds, fd)
+	if fd == sp.maxFD {
+		sp.maxFD = -1
+		for other := range sp.fds {
+			if other > sp.maxFD {
+				sp.maxFD = other
+			}
+		}
+	}
+	return nil
+}
+
+func (sp *selectPoller) Wait(events []domain.PollEvent, timeout time.Duration) (int, error) {
+	readFds := &domain.FdSet{}
+	writeFds := &domain.FdSet{}
+	exceptFds := &domain.FdSet{}
+
+	for fd, interest := range sp.fds {
+		if interest&domain.PollRead != 0 {
+			sp.system.FDSet(fd, readFds)
+		}
+		if interest&domain.PollWrite != 0 {
+			sp.system.FDSet(fd, writeFds)
+		}
+		sp.system.FDSet(fd, exceptFds)
+	}
+
+	var tv *domain.Timeval
+	if timeout >= 0 {
+		tv = &domain.Timeval{
+			Sec:  int64(timeout / time.Second),
+			Usec: int32((timeout % time.Second) / time.Microsecond),
+		}
+	}
+
+	n, err := sp.system.Select(sp.maxFD+1, readFds, writeFds, exceptFds, tv)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	count := 0
+	for fd := range sp.fds {
+		if count == len(events) {
+			break
+		}
+
+		var ready domain.PollEvents
+		if sp.system.FDIsSet(fd, readFds) {
+			ready |= domain.PollRead
+		}
+		if sp.system.FDIsSet(fd, writeFds) {
+			ready |= domain.PollWrite
+		}
+		if sp.system.FDIsSet(fd, exceptFds) {
+			ready |= domain.PollError
+		}
+
+		if ready != 0 {
+			events[count] = domain.PollEvent{FD: fd, Events: ready}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (sp *selectPoller) Close() error {
+	sp.fds = make(map[int]domain.PollEvents)
+	sp.maxFD = -1
+	return nil
+}
+
+func pollTimeoutMillis(timeout time.Duration) int {
+	if timeout < 0 {
+		return -1
+	}
+	return int((timeout + time.Millisecond - 1) / time.Millisecond)
+}
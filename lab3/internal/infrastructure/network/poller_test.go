@@ -0,0 +1,101 @@
+//go:build linux || darwin
+
+package network_test
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"NSSaDS/lab3/internal/infrastructure/network"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// newPoller returns the named poller, skipping the test where it does not exist.
+func newPoller(t *testing.T, name string) domain.Poller {
+	t.Helper()
+	poller, err := network.NewPoller(name)
+	if err != nil {
+		t.Skipf("%s: %v", name, err)
+	}
+	t.Cleanup(func() { poller.Close() })
+	return poller
+}
+
+func socketPair(t *testing.T) (int, int) {
+	t.Helper()
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("socketpair: %v", err)
+	}
+	t.Cleanup(func() {
+		unix.Close(fds[0])
+		unix.Close(fds[1])
+	})
+	return fds[0], fds[1]
+}
+
+func TestPollersServeEveryReadyFD(t *testing.T) {
+	for _, name := range []string{"poll", "epoll"} {
+		t.Run(name, func(t *testing.T) {
+			poller := newPoller(t, name)
+
+			ready := make(map[int]bool)
+			for range 6 {
+				fd, peer := socketPair(t)
+				if _, err := unix.Write(peer, []byte("x")); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+				if err := poller.Add(fd, domain.PollRead); err != nil {
+					t.Fatalf("add: %v", err)
+				}
+				ready[fd] = false
+			}
+
+			// Nothing is read, so every descriptor stays ready and a short
+			// events slice must still reach all of them in turn.
+			events := make([]domain.PollEvent, 2)
+			for range len(ready) / len(events) {
+				n, err := poller.Wait(events, 0)
+				if err != nil || n != len(events) {
+					t.Fatalf("wait: %d events, %v", n, err)
+				}
+				for _, event := range events[:n] {
+					ready[event.FD] = true
+				}
+			}
+			for fd, served := range ready {
+				if !served {
+					t.Fatalf("fd %d never reported while others stayed ready: %v", fd, ready)
+				}
+			}
+		})
+	}
+}
+
+func TestPollersIgnoreHalfCloseWhileReadsPaused(t *testing.T) {
+	for _, name := range []string{"poll", "epoll"} {
+		t.Run(name, func(t *testing.T) {
+			poller := newPoller(t, name)
+			fd, peer := socketPair(t)
+			if err := poller.Add(fd, 0); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+			if err := unix.Shutdown(peer, unix.SHUT_WR); err != nil {
+				t.Fatalf("shutdown: %v", err)
+			}
+
+			events := make([]domain.PollEvent, 4)
+			if n, err := poller.Wait(events, 0); err != nil || n != 0 {
+				t.Fatalf("paused reads: %d events %v, %v", n, events[:n], err)
+			}
+
+			if err := poller.Modify(fd, domain.PollRead); err != nil {
+				t.Fatalf("modify: %v", err)
+			}
+			n, err := poller.Wait(events, 0)
+			if err != nil || n != 1 || events[0].FD != fd {
+				t.Fatalf("resumed reads: %d events %v, %v", n, events[:n], err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package network
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RTTSourceTCPInfo = "tcp_info"
+	RTTSourcePing    = "ping"
+)
+
+func (sm *selectMultiplexer) refreshRTT(client *domain.ClientConnection) {
+	now := sm.now()
+	if !client.RTTUpdated.IsZero() && now.Sub(client.RTTUpdated) < sm.config.RTTInterval {
+		return
+	}
+
+	if conn, ok := client.Conn.(Conn); ok && sm.config.RTTSource != RTTSourcePing {
+		if rtt, err := conn.RTT(); err == nil {
+			if rtt > 0 {
+				sm.setRTT(client, rtt, RTTSourceTCPInfo)
+			}
+			return
+		}
+	}
+
+	// PING lines would confuse clients that do not expect them, so they are
+	// only sent to clients that asked for them with RTT PING.
+	if client.FileTransfer == nil && client.PingProbes {
+		client.PingSent = now
+		client.RTTUpdated = now
+		sm.queueOutput(client, fmt.Sprintf("PING %d", now.UnixNano()))
+	}
+}
+
+func (sm *selectMultiplexer) setPingProbes(client *domain.ClientConnection, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: RTT PING|OFF")
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		client.PingProbes = true
+		client.RTTUpdated = time.Time{}
+		return "RTT ping probes on", nil
+	case "OFF":
+		client.PingProbes = false
+		client.PingSent = time.Time{}
+		return "RTT ping probes off", nil
+	default:
+		return "", fmt.Errorf("usage: RTT PING|OFF")
+	}
+}
+
+func (sm *selectMultiplexer) handlePong(client *domain.ClientConnection, args []string) {
+	if client.PingSent.IsZero() || len(args) < 1 {
+		return
+	}
+	if token, err := strconv.ParseInt(args[0], 10, 64); err != nil || token != client.PingSent.UnixNano() {
+		return
+	}
+
+	sample := sm.now().Sub(client.PingSent)
+	client.PingSent = time.Time{}
+
+	rtt := sample
+	if client.RTTSource == RTTSourcePing && client.RTT > 0 {
+		rtt = client.RTT + (sample-client.RTT)/8
+	}
+	sm.setRTT(client, rtt, RTTSourcePing)
+}
+
+func (sm *selectMultiplexer) setRTT(client *domain.ClientConnection, rtt time.Duration, source string) {
+	client.RTT = rtt
+	client.RTTSource = source
+	client.RTTUpdated = sm.now()
+
+	if size := sm.calculateOptimalChunkSize(rtt); size != client.ChunkSize {
+		fmt.Printf("Client %s chunk size %d -> %d (rtt %v from %s)\n", client.ID, client.ChunkSize, size, rtt, source)
+		client.ChunkSize = size
+	}
+}
+
+func (sm *selectMultiplexer) clientStats(client *domain.ClientConnection) string {
+	rtt := "unknown"
+	if client.RTT > 0 {
+		rtt = fmt.Sprintf("%v (%s, %v ago)", client.RTT, client.RTTSource, sm.now().Sub(client.RTTUpdated).Round(time.Millisecond))
+	}
+
+	transfer := "none"
+	if session := client.FileTransfer; session != nil && session.IsActive {
+		direction := "download"
+		if session.IsUpload {
+			direction = "upload"
+		}
+		percent := 100.0
+		if session.FileSize > 0 {
+			percent = float64(session.Transferred) / float64(session.FileSize) * 100
+		}
+		transfer = fmt.Sprintf("%s %s %d/%d bytes (%.1f%%)", direction, session.FileName, session.Transferred, session.FileSize, percent)
+	}
+
+	deadline, reason := sm.clientDeadline(client)
+
+	return fmt.Sprintf("STATS %s rtt=%s chunk=%d bytes_in=%d bytes_out=%d connected=%v transfer=%s timeout=%s in %v",
+		client.ID, rtt, client.ChunkSize, client.BytesIn, client.BytesOut,
+		sm.now().Sub(client.ConnectedAt).Round(time.Second), transfer, reason, deadline.Sub(sm.now()).Round(time.Second))
+}
@@ -0,0 +1,15 @@
+package network
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func tcpInfoRTT(fd int) (time.Duration, error) {
+	info, err := unix.GetsockoptTCPConnectionInfo(fd, unix.IPPROTO_TCP, unix.TCP_CONNECTION_INFO)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(info.Srtt) * time.Millisecond, nil
+}
@@ -2,17 +2,27 @@ package network
 
 import (
 	"NSSaDS/lab3/internal/domain"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 )
 
+const maxPollEvents = 256
+
 type selectMultiplexer struct {
-	listener     net.Listener
+	listener     Listener
+	listen       ListenFunc
+	now          func() time.Time
+	listenAddr   net.Addr
 	clients      map[string]*domain.ClientConnection
+	fdClients    map[int]*domain.ClientConnection
 	clientsMutex sync.RWMutex
 	handler      domain.CommandHandler
 	connManager  domain.ConnectionManager
@@ -20,146 +30,208 @@ type selectMultiplexer struct {
 	config       *domain.ServerConfig
 	running      bool
 	listenerFD   int
-	selectSystem domain.SelectSystem
+	acceptPaused bool
+	poller       domain.Poller
+	events       []domain.PollEvent
+	readBuf      []byte
+	timers       *timerQueue
+	wakeup       *wakeup
+	tasks        []func()
+	loopMutex    sync.Mutex
+	draining     bool
+	limits       *admission
+	nextClientID int
 }
 
-func NewSelectMultiplexer(handler domain.CommandHandler, connManager domain.ConnectionManager, fileManager domain.FileManager) domain.Multiplexer {
-	return &selectMultiplexer{
-		clients:      make(map[string]*domain.ClientConnection),
-		handler:      handler,
-		connManager:  connManager,
-		fileManager:  fileManager,
-		selectSystem: &UnixSelectSystem{},
+type Option func(sm *selectMultiplexer)
+
+func WithListener(listen ListenFunc) Option {
+	return func(sm *selectMultiplexer) {
+		sm.listen = listen
 	}
 }
 
-func (sm *selectMultiplexer) Start(ctx context.Context, config *domain.ServerConfig) error {
-	sm.config = config
-	sm.running = true
-
-	if sm.config.SelectTimeout == 0 {
-		sm.config.SelectTimeout = domain.DefaultSelectTimeout
-	}
-	if sm.config.ChunkSize == 0 {
-		sm.config.ChunkSize = domain.DefaultChunkSize
+func WithClock(now func() time.Time) Option {
+	return func(sm *selectMultiplexer) {
+		sm.now = now
+		sm.timers = newTimerQueue(now)
 	}
+}
 
-	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", config.Host, config.Port))
-	if err != nil {
-		return fmt.Errorf("failed to start server: %w", err)
+func NewSelectMultiplexer(poller domain.Poller, handler domain.CommandHandler, connManager domain.ConnectionManager, fileManager domain.FileManager, opts ...Option) domain.Multiplexer {
+	sm := &selectMultiplexer{
+		clients:     make(map[string]*domain.ClientConnection),
+		fdClients:   make(map[int]*domain.ClientConnection),
+		handler:     handler,
+		connManager: connManager,
+		fileManager: fileManager,
+		poller:      poller,
+		events:      make([]domain.PollEvent, maxPollEvents),
+		listenerFD:  -1,
+		listen:      ListenTCP,
+		now:         time.Now,
+		timers:      newTimerQueue(time.Now),
+		limits:      newAdmission(),
 	}
-	sm.listener = listener
-
-	tcpListener := listener.(*net.TCPListener)
-	file, err := tcpListener.File()
-	if err != nil {
-		return fmt.Errorf("failed to get listener file descriptor: %w", err)
+	for _, opt := range opts {
+		opt(sm)
 	}
-	file.Close()
-	sm.listenerFD = int(file.Fd())
+	return sm
+}
 
-	fmt.Printf("Server started on %s:%d (using select() multiplexing)\n", config.Host, config.Port)
-	fmt.Printf("Select timeout: %v, Default chunk size: %d\n", sm.config.SelectTimeout, sm.config.ChunkSize)
+func (sm *selectMultiplexer) Start(ctx context.Context, config *domain.ServerConfig) error {
+	if err := sm.Prepare(config); err != nil {
+		return err
+	}
 
-	for sm.running {
+	for sm.isRunning() {
 		select {
 		case <-ctx.Done():
 			return sm.Stop()
 		default:
-			if err := sm.processSelectLoop(); err != nil {
-				if sm.running {
+			if err := sm.processEventLoop(); err != nil {
+				if sm.isRunning() {
 					fmt.Printf("Select loop error: %v\n", err)
 				}
 			}
+			if sm.draining && len(sm.clients) == 0 {
+				fmt.Println("Drained: last client left")
+				return sm.Stop()
+			}
 		}
 	}
 
 	return nil
 }
 
-func (sm *selectMultiplexer) processSelectLoop() error {
-	readFds := &domain.FdSet{}
-	writeFds := &domain.FdSet{}
-	exceptFds := &domain.FdSet{}
-
-	sm.selectSystem.FDZero(readFds)
-	sm.selectSystem.FDZero(writeFds)
-	sm.selectSystem.FDZero(exceptFds)
+func (sm *selectMultiplexer) Prepare(config *domain.ServerConfig) error {
+	sm.config = config
+	sm.loopMutex.Lock()
+	sm.running = true
+	sm.loopMutex.Unlock()
 
-	sm.selectSystem.FDSet(sm.listenerFD, readFds)
-	maxFD := sm.listenerFD
+	if sm.config.SelectTimeout == 0 {
+		sm.config.SelectTimeout = domain.DefaultSelectTimeout
+	}
+	if sm.config.ChunkSize == 0 {
+		sm.config.ChunkSize = domain.DefaultChunkSize
+	}
+	if sm.config.OutputHighWater == 0 {
+		sm.config.OutputHighWater = domain.DefaultOutputHighWater
+	}
+	if sm.config.RTTInterval == 0 {
+		sm.config.RTTInterval = domain.DefaultRTTInterval
+	}
+	if sm.config.PingTimeout == 0 {
+		sm.config.PingTimeout = domain.DefaultPingTimeout
+	}
+	if sm.config.ReadTimeout == 0 {
+		sm.config.ReadTimeout = domain.DefaultReadTimeout
+	}
+	if sm.config.WriteTimeout == 0 {
+		sm.config.WriteTimeout = domain.DefaultWriteTimeout
+	}
+	if sm.config.TransferTimeout == 0 {
+		sm.config.TransferTimeout = domain.DefaultTransferTimeout
+	}
 
-	sm.clientsMutex.RLock()
-	for _, client := range sm.clients {
-		if client.IsActive && client.FD != 0 {
-			fd := int(client.FD)
-			sm.selectSystem.FDSet(fd, readFds)
+	if sm.config.BanWindow == 0 {
+		sm.config.BanWindow = domain.DefaultBanWindow
+	}
+	if sm.config.BanDuration == 0 {
+		sm.config.BanDuration = domain.DefaultBanDuration
+	}
 
-			if client.FileTransfer != nil && client.FileTransfer.IsActive {
-				sm.selectSystem.FDSet(fd, writeFds)
-			}
+	sm.timers.Every(domain.SessionCleanupInterval, sm.pruneLimits)
+	if sm.fileManager != nil {
+		sm.timers.Every(domain.SessionCleanupInterval, func() {
+			sm.fileManager.CleanupExpiredSessions()
+		})
+	}
 
-			if fd > maxFD {
-				maxFD = fd
-			}
-		}
+	listener, err := sm.listen(config.Host, config.Port)
+	if err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
 	}
-	sm.clientsMutex.RUnlock()
+	sm.listener = listener
+	sm.listenerFD = listener.FD()
+	sm.listenAddr = listener.Addr()
 
-	timeout := &domain.Timeval{
-		Sec:  0,
-		Usec: int32(sm.config.SelectTimeout.Microseconds()),
+	if err := sm.poller.Add(sm.listenerFD, domain.PollRead); err != nil {
+		sm.closeListener()
+		return fmt.Errorf("failed to watch listener: %w", err)
 	}
 
-	n, err := sm.selectSystem.Select(maxFD+1, readFds, writeFds, exceptFds, timeout)
+	wakeup, err := newWakeup()
 	if err != nil {
-		if err == syscall.EINTR {
-			return nil
-		}
-		return fmt.Errorf("select error: %w", err)
+		return fmt.Errorf("failed to create wakeup pipe: %w", err)
 	}
-
-	if n == 0 {
-		sm.checkPingTimeouts()
-		return nil
+	if err := sm.poller.Add(wakeup.readFD, domain.PollRead); err != nil {
+		wakeup.Close()
+		return fmt.Errorf("failed to watch wakeup pipe: %w", err)
 	}
+	sm.loopMutex.Lock()
+	sm.wakeup = wakeup
+	sm.loopMutex.Unlock()
 
-	return sm.processReadyFDs(readFds, writeFds, exceptFds)
+	fmt.Printf("Server started on %s (FD: %d, using %s() multiplexing)\n", sm.listenAddr, sm.listenerFD, sm.poller.Name())
+	fmt.Printf("Max wait: %v, Default chunk size: %d (until RTT is measured)\n", sm.config.SelectTimeout, sm.config.ChunkSize)
+	fmt.Printf("Timeouts: idle %v, read %v, write %v, transfer %v\n", sm.config.PingTimeout, sm.config.ReadTimeout, sm.config.WriteTimeout, sm.config.TransferTimeout)
+	return nil
 }
 
-func (sm *selectMultiplexer) processReadyFDs(readFds, writeFds, exceptFds *domain.FdSet) error {
-	if sm.selectSystem.FDIsSet(sm.listenerFD, readFds) {
-		if err := sm.handleNewConnection(); err != nil {
-			fmt.Printf("Error handling new connection: %v\n", err)
+func (sm *selectMultiplexer) processEventLoop() error {
+	n, err := sm.poller.Wait(sm.events, sm.timers.Timeout(sm.config.SelectTimeout))
+	if err != nil {
+		if errors.Is(err, syscall.EINTR) {
+			return nil
 		}
+		return fmt.Errorf("%s error: %w", sm.poller.Name(), err)
 	}
 
-	sm.clientsMutex.RLock()
-	clientsCopy := make(map[string]*domain.ClientConnection)
-	for id, client := range sm.clients {
-		clientsCopy[id] = client
-	}
-	sm.clientsMutex.RUnlock()
+	err = sm.processReadyFDs(sm.events[:n])
+	sm.runTasks()
+	sm.timers.RunExpired()
+	return err
+}
+
+func (sm *selectMultiplexer) processReadyFDs(events []domain.PollEvent) error {
+	sm.loopMutex.Lock()
+	wakeup := sm.wakeup
+	sm.loopMutex.Unlock()
 
-	for clientID, client := range clientsCopy {
-		if !client.IsActive || client.FD == 0 {
+	for _, event := range events {
+		if wakeup != nil && event.FD == wakeup.readFD {
+			wakeup.Drain()
 			continue
 		}
+		if event.FD == sm.listenerFD {
+			if err := sm.acceptConnections(); err != nil {
+				fmt.Printf("Error accepting connections: %v\n", err)
+			}
+			continue
+		}
+
+		sm.clientsMutex.RLock()
+		client, exists := sm.fdClients[event.FD]
+		sm.clientsMutex.RUnlock()
 
-		fd := int(client.FD)
+		if !exists || !client.IsActive {
+			continue
+		}
 
-		if sm.selectSystem.FDIsSet(fd, readFds) {
-			if err := sm.handleClientRead(clientID, client); err != nil {
-				fmt.Printf("Error handling read from client %s: %v\n", clientID, err)
-				sm.RemoveConnection(clientID)
+		if event.Events&(domain.PollRead|domain.PollHangup|domain.PollError) != 0 {
+			if err := sm.handleClientRead(client.ID, client); err != nil {
+				fmt.Printf("Error handling read from client %s: %v\n", client.ID, err)
+				sm.RemoveConnection(client.ID)
 				continue
 			}
 		}
 
-		if sm.selectSystem.FDIsSet(fd, writeFds) {
-			if err := sm.handleClientWrite(clientID, client); err != nil {
-				fmt.Printf("Error handling write to client %s: %v\n", clientID, err)
-				sm.RemoveConnection(clientID)
+		if event.Events&domain.PollWrite != 0 {
+			if err := sm.handleClientWrite(client.ID, client); err != nil {
+				fmt.Printf("Error handling write to client %s: %v\n", client.ID, err)
+				sm.RemoveConnection(client.ID)
 				continue
 			}
 		}
@@ -168,121 +240,284 @@ func (sm *selectMultiplexer) processReadyFDs(readFds, writeFds, exceptFds *domai
 	return nil
 }
 
-func (sm *selectMultiplexer) handleNewConnection() error {
-	conn, err := sm.listener.Accept()
-	if err != nil {
-		return fmt.Errorf("accept error: %w", err)
+func (sm *selectMultiplexer) acceptConnections() error {
+	for {
+		conn, err := sm.listener.Accept()
+		switch {
+		case err == nil:
+		case isWouldBlock(err):
+			return nil
+		case isAcceptRetryable(err):
+			continue
+		case isAcceptExhausted(err):
+			sm.pauseAccept()
+			return fmt.Errorf("accept paused until a client disconnects: %w", err)
+		default:
+			return fmt.Errorf("accept error: %w", err)
+		}
+
+		if err := sm.registerConnection(conn); err != nil {
+			fmt.Printf("Error registering connection: %v\n", err)
+		}
 	}
+}
+
+func (sm *selectMultiplexer) pauseAccept() {
+	if !sm.acceptPaused {
+		sm.acceptPaused = true
+		sm.poller.Modify(sm.listenerFD, 0)
+	}
+}
+
+func (sm *selectMultiplexer) resumeAccept() {
+	if sm.acceptPaused && sm.listenerFD >= 0 {
+		sm.acceptPaused = false
+		sm.poller.Modify(sm.listenerFD, domain.PollRead)
+	}
+}
+
+func (sm *selectMultiplexer) registerConnection(conn Conn) error {
+	now := sm.now()
+	ip := remoteIP(conn.RemoteAddr())
 
 	sm.clientsMutex.RLock()
-	clientCount := len(sm.clients)
+	reason := sm.admit(ip, now)
 	sm.clientsMutex.RUnlock()
 
-	if clientCount >= sm.config.MaxClients {
-		fmt.Printf("Max clients reached, rejecting connection\n")
-		conn.Close()
+	if reason != "" {
+		sm.reject(conn, ip, reason)
 		return nil
 	}
 
-	clientID := fmt.Sprintf("client_%d", time.Now().UnixNano())
+	sm.nextClientID++
+	clientID := fmt.Sprintf("client_%d", sm.nextClientID)
 	client := &domain.ClientConnection{
-		ID:        clientID,
-		Conn:      conn,
-		LastPing:  time.Now(),
-		Buffer:    make([]byte, 0, sm.config.ChunkSize),
-		IsActive:  true,
-		ChunkSize: sm.calculateOptimalChunkSize(sm.config.PingTimeout),
+		ID:          clientID,
+		Conn:        conn,
+		FD:          uintptr(conn.FD()),
+		LastPing:    now,
+		IsActive:    true,
+		ChunkSize:   sm.config.ChunkSize,
+		Interest:    domain.PollRead,
+		ConnectedAt: now,
 	}
+	client.Session = sm.newSession(client)
 
-	tcpConn := conn.(*net.TCPConn)
-	file, err := tcpConn.File()
-	if err != nil {
+	fd := conn.FD()
+	if err := sm.poller.Add(fd, domain.PollRead); err != nil {
 		conn.Close()
-		return fmt.Errorf("failed to get connection file descriptor: %w", err)
+		return fmt.Errorf("failed to watch client: %w", err)
 	}
-	file.Close()
-	client.FD = file.Fd()
 
 	sm.clientsMutex.Lock()
 	sm.clients[clientID] = client
+	sm.fdClients[fd] = client
+	sm.limits.connected[ip]++
+	sm.limits.stats.Accepted++
 	sm.clientsMutex.Unlock()
 
-	fmt.Printf("New client connected: %s (FD: %d, chunk size: %d)\n", clientID, client.FD, client.ChunkSize)
+	sm.refreshRTT(client)
+	if err := sm.updateInterest(client); err != nil {
+		return err
+	}
+
+	fmt.Printf("New client connected: %s (FD: %d, rtt: %v, chunk size: %d)\n", clientID, client.FD, client.RTT, client.ChunkSize)
 
 	return nil
 }
 
 func (sm *selectMultiplexer) handleClientRead(clientID string, client *domain.ClientConnection) error {
-	client.LastPing = time.Now()
-
-	buffer := make([]byte, client.ChunkSize)
-	n, err := client.Conn.Read(buffer)
-	if err != nil {
-		return fmt.Errorf("read error: %w", err)
+	if client.Closing || client.ReadPaused {
+		return nil
 	}
+	client.LastPing = sm.now()
+	sm.refreshRTT(client)
 
+	buffer := sm.buffer(client.ChunkSize)
+	n, err := client.Conn.Read(buffer)
 	if n > 0 {
-		if err := sm.processClientData(clientID, buffer[:n]); err != nil {
-			return fmt.Errorf("process data error: %w", err)
+		client.BytesIn += int64(n)
+		client.Buffer = append(client.Buffer, buffer[:n]...)
+		if procErr := sm.processClientData(clientID, client); procErr != nil {
+			return fmt.Errorf("process data error: %w", procErr)
 		}
 	}
 
-	return nil
+	switch {
+	case err == nil, isWouldBlock(err):
+	case errors.Is(err, io.EOF):
+		sm.finishInput(clientID, client)
+	default:
+		return fmt.Errorf("read error: %w", err)
+	}
+
+	return sm.updateInterest(client)
+}
+
+func (sm *selectMultiplexer) finishInput(clientID string, client *domain.ClientConnection) {
+	if client.FileTransfer == nil && len(client.Buffer) > 0 {
+		client.Buffer = append(client.Buffer, '\n')
+		sm.processClientData(clientID, client)
+	}
+	client.Buffer = nil
+	client.Closing = true
 }
 
 func (sm *selectMultiplexer) handleClientWrite(clientID string, client *domain.ClientConnection) error {
-	if client.FileTransfer != nil && client.FileTransfer.IsActive {
-		return sm.handleFileTransfer(clientID, client)
+	if len(client.Output) > 0 {
+		n, err := writeNonBlocking(client.Conn, client.Output)
+		client.BytesOut += int64(n)
+		if n > 0 {
+			client.WriteSince = sm.now()
+		}
+		client.Output = client.Output[n:]
+		if len(client.Output) == 0 {
+			client.Output = nil
+		}
+		if err != nil {
+			return fmt.Errorf("write error: %w", err)
+		}
+
+		if client.ReadPaused && len(client.Output) <= sm.config.OutputHighWater/2 {
+			client.ReadPaused = false
+			if err := sm.processClientData(clientID, client); err != nil {
+				return fmt.Errorf("process data error: %w", err)
+			}
+		}
 	}
-	return nil
+
+	if isDownloading(client) {
+		if err := sm.stepDownload(client); err != nil {
+			return err
+		}
+	}
+	return sm.updateInterest(client)
 }
 
-func (sm *selectMultiplexer) processClientData(clientID string, data []byte) error {
-	commandStr := string(data)
-	commandStr = trimString(commandStr)
+func (sm *selectMultiplexer) updateInterest(client *domain.ClientConnection) error {
+	if client.Closing && len(client.Output) == 0 {
+		return sm.RemoveConnection(client.ID)
+	}
+	sm.armDeadline(client)
 
-	if commandStr == "" {
+	var events domain.PollEvents
+	if !client.Closing && !client.ReadPaused && !isDownloading(client) {
+		events |= domain.PollRead
+	}
+	if len(client.Output) > 0 || isDownloading(client) {
+		events |= domain.PollWrite
+	}
+
+	if events == client.Interest {
 		return nil
 	}
+	client.Interest = events
+	return sm.poller.Modify(int(client.FD), events)
+}
 
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+func (sm *selectMultiplexer) processClientData(clientID string, client *domain.ClientConnection) error {
+	for !client.Closing && !client.ReadPaused {
+		if transfer := client.FileTransfer; transfer != nil && transfer.IsActive {
+			if !transfer.IsUpload {
+				break
+			}
+			if err := sm.consumeUpload(client); err != nil {
+				return err
+			}
+			if client.FileTransfer != nil {
+				break
+			}
+			continue
+		}
 
-		response, err := sm.handler.HandleCommand(ctx, commandStr, []string{})
-		if err != nil {
-			response = fmt.Sprintf("Error: %v", err)
+		i := bytes.IndexByte(client.Buffer, '\n')
+		if i < 0 {
+			if len(client.Buffer) > domain.MaxLineLength {
+				sm.queueOutput(client, fmt.Sprintf("Error: line longer than %d bytes", domain.MaxLineLength))
+				client.Buffer = nil
+				client.Closing = true
+			}
+			break
 		}
 
-		sm.clientsMutex.RLock()
-		client, exists := sm.clients[clientID]
-		sm.clientsMutex.RUnlock()
+		line := trimString(string(client.Buffer[:i]))
+		client.Buffer = client.Buffer[i+1:]
+		if line != "" {
+			sm.executeCommand(clientID, client, line)
+		}
 
-		if exists && client.IsActive {
-			client.Conn.Write([]byte(response + "\n"))
+		if len(client.Output) >= sm.config.OutputHighWater {
+			client.ReadPaused = true
 		}
-	}()
+	}
+
+	if len(client.Buffer) == 0 {
+		client.Buffer = nil
+	}
 
+	switch {
+	case len(client.Buffer) == 0 || client.ReadPaused || client.FileTransfer != nil:
+		client.PartialSince = time.Time{}
+	case client.PartialSince.IsZero():
+		client.PartialSince = sm.now()
+	}
 	return nil
 }
 
-func (sm *selectMultiplexer) handleFileTransfer(clientID string, client *domain.ClientConnection) error {
-	if client.FileTransfer != nil {
-		client.FileTransfer.Transferred += int64(client.ChunkSize)
+func (sm *selectMultiplexer) executeCommand(clientID string, client *domain.ClientConnection, line string) {
+	ctx, cancel := context.WithTimeout(domain.WithSession(context.Background(), client.Session), 5*time.Second)
+	defer cancel()
+
+	var response string
+	var err error
 
-		if client.FileTransfer.Transferred >= client.FileTransfer.FileSize {
-			client.FileTransfer.IsActive = false
-			fmt.Printf("File transfer completed for client %s: %s\n", clientID, client.FileTransfer.FileName)
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "UPLOAD":
+		response, err = sm.startUpload(client, fields[1:])
+	case "DOWNLOAD":
+		response, err = sm.startDownload(client, fields[1:])
+	case "STATS":
+		if len(fields) > 1 && strings.EqualFold(fields[1], "SERVER") {
+			response = sm.serverStats()
+		} else {
+			response = sm.clientStats(client)
 		}
+	case "RTT":
+		response, err = sm.setPingProbes(client, fields[1:])
+	case "PONG":
+		sm.handlePong(client, fields[1:])
+		return
+	default:
+		response, err = sm.handler.HandleCommand(ctx, line, []string{})
 	}
-	return nil
+	if err != nil {
+		response = fmt.Sprintf("Error: %v", err)
+	}
+	sm.queueOutput(client, response)
+
+	if err == nil && isCloseCommand(line) {
+		fmt.Printf("Client %s requested close\n", clientID)
+		client.Closing = true
+	}
+}
+
+func (sm *selectMultiplexer) queueOutput(client *domain.ClientConnection, response string) {
+	if len(client.Output) == 0 {
+		client.WriteSince = sm.now()
+	}
+	client.Output = append(client.Output, response...)
+	client.Output = append(client.Output, '\n')
 }
 
 func (sm *selectMultiplexer) calculateOptimalChunkSize(ping time.Duration) int {
 	targetLatency := ping * 10
 
-	bytesPerMs := 1024 * 1024 / 1000
-	chunkSize := int(targetLatency.Milliseconds()) * bytesPerMs
+	bytesPerSecond := 1024 * 1024
+	chunkSize := int(targetLatency.Seconds() * float64(bytesPerSecond))
 
 	if chunkSize < domain.MinChunkSize {
 		chunkSize = domain.MinChunkSize
@@ -294,29 +529,21 @@ func (sm *selectMultiplexer) calculateOptimalChunkSize(ping time.Duration) int {
 	return chunkSize
 }
 
-func (sm *selectMultiplexer) checkPingTimeouts() {
-	now := time.Now()
-	sm.clientsMutex.RLock()
-	clientsCopy := make(map[string]*domain.ClientConnection)
-	for id, client := range sm.clients {
-		clientsCopy[id] = client
-	}
-	sm.clientsMutex.RUnlock()
-
-	for clientID, client := range clientsCopy {
-		if now.Sub(client.LastPing) > sm.config.PingTimeout {
-			fmt.Printf("Client %s ping timeout, disconnecting\n", clientID)
-			sm.RemoveConnection(clientID)
-		}
-	}
+func (sm *selectMultiplexer) isRunning() bool {
+	sm.loopMutex.Lock()
+	defer sm.loopMutex.Unlock()
+	return sm.running
 }
 
 func (sm *selectMultiplexer) Stop() error {
+	sm.loopMutex.Lock()
 	sm.running = false
+	wakeup := sm.wakeup
+	sm.wakeup = nil
+	sm.tasks = nil
+	sm.loopMutex.Unlock()
 
-	if sm.listener != nil {
-		sm.listener.Close()
-	}
+	sm.closeListener()
 
 	sm.clientsMutex.Lock()
 	for clientID := range sm.clients {
@@ -326,42 +553,99 @@ func (sm *selectMultiplexer) Stop() error {
 	}
 	sm.clientsMutex.Unlock()
 
+	if wakeup != nil {
+		sm.poller.Remove(wakeup.readFD)
+		wakeup.Close()
+	}
+	sm.poller.Close()
+
 	fmt.Println("Select multiplexer stopped")
 	return nil
 }
 
+func (sm *selectMultiplexer) closeListener() {
+	if sm.listener != nil {
+		sm.poller.Remove(sm.listenerFD)
+		sm.listener.Close()
+		sm.listener = nil
+	}
+	sm.listenerFD = -1
+}
+
 func (sm *selectMultiplexer) AddConnection(conn net.Conn) error {
-	return sm.handleNewConnection()
+	adopted, err := adoptConn(conn)
+	if err != nil {
+		return fmt.Errorf("failed to adopt connection: %w", err)
+	}
+	return sm.registerConnection(adopted)
 }
 
 func (sm *selectMultiplexer) RemoveConnection(clientID string) error {
 	sm.clientsMutex.Lock()
-	defer sm.clientsMutex.Unlock()
 
 	client, exists := sm.clients[clientID]
 	if !exists {
+		sm.clientsMutex.Unlock()
 		return nil
 	}
 
+	client.IsActive = false
+	sm.timers.Cancel(client.Deadline)
+	sm.abortTransfer(client)
+	sm.poller.Remove(int(client.FD))
 	client.Conn.Close()
 	delete(sm.clients, clientID)
+	delete(sm.fdClients, int(client.FD))
+	ip := remoteIP(client.Conn.RemoteAddr())
+	if sm.limits.connected[ip]--; sm.limits.connected[ip] <= 0 {
+		delete(sm.limits.connected, ip)
+	}
+	sm.resumeAccept()
+	sm.clientsMutex.Unlock()
 
 	fmt.Printf("Client disconnected: %s\n", clientID)
+	if client.Session != nil {
+		client.Session.Close()
+	}
 	return nil
 }
 
 func (sm *selectMultiplexer) ProcessConnections() error {
-	return sm.processSelectLoop()
+	return sm.processEventLoop()
 }
 
 func (sm *selectMultiplexer) SetHandler(handler domain.CommandHandler) {
 	sm.handler = handler
 }
 
+func (sm *selectMultiplexer) Scheduler() domain.Scheduler {
+	return sm.timers
+}
+
 func (sm *selectMultiplexer) CalculateOptimalChunkSize(ping time.Duration) int {
 	return sm.calculateOptimalChunkSize(ping)
 }
 
+func (sm *selectMultiplexer) buffer(size int) []byte {
+	if cap(sm.readBuf) < size {
+		sm.readBuf = make([]byte, size)
+	}
+	return sm.readBuf[:size]
+}
+
+func isDownloading(client *domain.ClientConnection) bool {
+	return client.FileTransfer != nil && client.FileTransfer.IsActive && !client.FileTransfer.IsUpload
+}
+
+func isCloseCommand(line string) bool {
+	name, _, _ := strings.Cut(line, " ")
+	switch strings.ToUpper(name) {
+	case "CLOSE", "EXIT", "QUIT":
+		return true
+	}
+	return false
+}
+
 func trimString(s string) string {
 	for len(s) > 0 && (s[len(s)-1] == '\n' || s[len(s)-1] == '\r' || s[len(s)-1] == ' ') {
 		s = s[:len(s)-1]
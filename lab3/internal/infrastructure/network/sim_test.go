@@ -0,0 +1,547 @@
+package network_test
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"NSSaDS/lab3/internal/infrastructure/fake"
+	"NSSaDS/lab3/internal/infrastructure/network"
+	"NSSaDS/lab3/internal/infrastructure/repository"
+	"NSSaDS/lab3/internal/usecase"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var simEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type simServer struct {
+	t         *testing.T
+	net       *fake.Network
+	mux       domain.Multiplexer
+	uploadDir string
+}
+
+func newSimServer(t *testing.T, configure func(config *domain.ServerConfig)) *simServer {
+	t.Helper()
+
+	config := &domain.ServerConfig{
+		Host:          "127.0.0.1",
+		Port:          8080,
+		MaxClients:    100,
+		PingTimeout:   domain.DefaultPingTimeout,
+		ChunkSize:     domain.DefaultChunkSize,
+		SelectTimeout: time.Second,
+	}
+	if configure != nil {
+		configure(config)
+	}
+
+	uploadDir := t.TempDir()
+	fakeNet := fake.NewNetwork(simEpoch)
+	handler := usecase.NewCommandHandler()
+	mux := network.NewSelectMultiplexer(fakeNet.Poller, handler, nil, repository.NewFileManager(uploadDir), fakeNet.Options()...)
+	if err := mux.Prepare(config); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	t.Cleanup(func() { mux.Stop() })
+
+	return &simServer{t: t, net: fakeNet, mux: mux, uploadDir: uploadDir}
+}
+
+func (s *simServer) step() {
+	s.t.Helper()
+	if err := s.mux.ProcessConnections(); err != nil {
+		s.t.Fatalf("ProcessConnections: %v", err)
+	}
+}
+
+func (s *simServer) elapsed() time.Duration {
+	return s.net.Clock.Now().Sub(simEpoch)
+}
+
+func (s *simServer) watched(conn *fake.Conn) bool {
+	_, watched := s.net.Poller.Interest(conn.FD())
+	return watched
+}
+
+func (s *simServer) wantsWrite(conn *fake.Conn) bool {
+	events, _ := s.net.Poller.Interest(conn.FD())
+	return events&domain.PollWrite != 0
+}
+
+func (s *simServer) connect(ip string) *fake.Conn {
+	s.t.Helper()
+	conn := s.net.Connect(ip)
+	s.step()
+	if !s.watched(conn) {
+		s.t.Fatalf("%s was not accepted: %q", ip, conn.TakeOutput())
+	}
+	return conn
+}
+
+func (s *simServer) send(conn *fake.Conn, data string) {
+	s.t.Helper()
+	s.net.Send(conn, data)
+	s.step()
+}
+
+func (s *simServer) flush(conn *fake.Conn) string {
+	s.t.Helper()
+	for s.watched(conn) && s.wantsWrite(conn) {
+		s.net.Writable(conn, fake.Unlimited)
+		s.step()
+	}
+	return string(conn.TakeOutput())
+}
+
+func (s *simServer) command(conn *fake.Conn, line string) string {
+	s.t.Helper()
+	s.send(conn, line+"\n")
+	return strings.TrimRight(s.flush(conn), "\n")
+}
+
+func (s *simServer) idle() {
+	s.t.Helper()
+	if pending := s.net.Poller.Pending(); pending > 0 {
+		s.t.Fatalf("%d scripted events not consumed", pending)
+	}
+	s.step()
+}
+
+func TestSimAccept(t *testing.T) {
+	s := newSimServer(t, nil)
+
+	var conns []*fake.Conn
+	for i := 0; i < 3; i++ {
+		conns = append(conns, s.net.Dial("10.0.0.1"))
+	}
+	s.net.Connect("10.0.0.2")
+	s.step()
+	if backlog := s.net.Listener.Backlog(); backlog != 0 {
+		t.Fatalf("%d connections left in the backlog after one readiness event", backlog)
+	}
+	for _, conn := range conns {
+		if !s.watched(conn) {
+			t.Fatalf("connection %d was not registered", conn.FD())
+		}
+	}
+
+	s.net.Listener.FailAccept(unix.EMFILE)
+	waiting := s.net.Connect("10.0.0.3")
+	s.step()
+	if events, _ := s.net.Poller.Interest(fake.ListenerFD); events != 0 || s.watched(waiting) {
+		t.Fatalf("accept not paused after EMFILE (listener interest %v)", events)
+	}
+
+	s.net.Poller.Script(domain.PollEvent{FD: fake.ListenerFD, Events: domain.PollRead})
+	s.step()
+	if s.watched(waiting) {
+		t.Fatalf("paused listener still accepted")
+	}
+
+	if response := s.command(conns[0], "QUIT"); !conns[0].Closed() {
+		t.Fatalf("QUIT answered %q but the connection stayed open", response)
+	}
+	if events, _ := s.net.Poller.Interest(fake.ListenerFD); events != domain.PollRead {
+		t.Fatalf("accept not resumed after a disconnect (listener interest %v)", events)
+	}
+
+	s.net.Poller.Script(domain.PollEvent{FD: fake.ListenerFD, Events: domain.PollRead})
+	s.step()
+	if !s.watched(waiting) {
+		t.Fatalf("queued connection not accepted after resume")
+	}
+}
+
+func TestSimFraming(t *testing.T) {
+	s := newSimServer(t, nil)
+	conn := s.connect("10.0.0.1")
+
+	conn.SetReadLimit(3)
+	conn.Feed("ECHO hello\nECHO world\n")
+	reads := 0
+	for conn.Unread() > 0 {
+		s.net.Readable(conn)
+		s.step()
+		reads++
+	}
+	conn.SetReadLimit(0)
+	if reads != 8 {
+		t.Fatalf("read 22 bytes in %d reads, want 8", reads)
+	}
+
+	for s.wantsWrite(conn) {
+		s.net.Writable(conn, 4)
+		s.step()
+	}
+	if got := string(conn.TakeOutput()); got != "hello\nworld\n" {
+		t.Fatalf("partial writes: got %q, want %q", got, "hello\nworld\n")
+	}
+
+	s.net.Readable(conn)
+	s.step()
+	if !s.watched(conn) || conn.Closed() {
+		t.Fatalf("spurious readiness (EAGAIN) dropped the client")
+	}
+
+	if got := s.command(conn, "ECHO still here"); got != "still here" {
+		t.Fatalf("ECHO after EAGAIN: got %q", got)
+	}
+
+	conn.Feed("ECHO tail")
+	s.net.Hangup(conn)
+	s.step()
+	for s.watched(conn) && !s.wantsWrite(conn) {
+		s.net.Readable(conn)
+		s.step()
+	}
+	if got := s.flush(conn); got != "tail\n" {
+		t.Fatalf("unterminated line before EOF: got %q, want %q", got, "tail\n")
+	}
+	if !conn.Closed() {
+		t.Fatalf("connection open after EOF and flush")
+	}
+
+	reset := s.connect("10.0.0.2")
+	reset.Reset()
+	s.net.Readable(reset)
+	s.step()
+	if s.watched(reset) || !reset.Closed() {
+		t.Fatalf("client not removed after ECONNRESET")
+	}
+}
+
+func TestSimWhitespaceLines(t *testing.T) {
+	s := newSimServer(t, nil)
+	conn := s.connect("10.0.0.1")
+
+	s.send(conn, "\t\n \t \r\n\v\f\n")
+	if !s.watched(conn) || conn.Closed() {
+		t.Fatalf("whitespace-only lines dropped the client")
+	}
+	if got := s.command(conn, "ECHO after blanks"); got != "after blanks" {
+		t.Fatalf("ECHO after whitespace-only lines: got %q", got)
+	}
+}
+
+func TestSimTimeouts(t *testing.T) {
+	s := newSimServer(t, func(config *domain.ServerConfig) {
+		config.PingTimeout = 30 * time.Second
+		config.ReadTimeout = 5 * time.Second
+	})
+
+	idle := s.connect("10.0.0.1")
+	connected := s.elapsed()
+	steps := 0
+	for s.watched(idle) {
+		s.idle()
+		if wait := s.net.Poller.LastWait(); wait > time.Second {
+			t.Fatalf("waited %v, select timeout is 1s", wait)
+		}
+		steps++
+	}
+	if got := string(idle.TakeOutput()); got != "Error: idle timeout\n" {
+		t.Fatalf("idle client got %q", got)
+	}
+	if after := s.elapsed() - connected; after != 30*time.Second || steps != 30 {
+		t.Fatalf("idle timeout after %v in %d waits, want 30s in 30", after, steps)
+	}
+
+	partial := s.connect("10.0.0.2")
+	s.send(partial, "ECHO unfinished")
+	started := s.elapsed()
+	for s.watched(partial) {
+		s.idle()
+	}
+	if got := string(partial.TakeOutput()); got != "Error: read timeout\n" {
+		t.Fatalf("partial line got %q", got)
+	}
+	if after := s.elapsed() - started; after != 5*time.Second {
+		t.Fatalf("read timeout after %v, want 5s", after)
+	}
+
+	conn := s.connect("10.0.0.3")
+	s.send(conn, "DELAY 1500ms second\nDELAY 250ms first\n")
+	if got := s.flush(conn); got != "Scheduled in 1.5s\nScheduled in 250ms\n" {
+		t.Fatalf("DELAY acknowledgements: got %q", got)
+	}
+
+	started = s.elapsed()
+	var waits []time.Duration
+	var received []string
+	for len(received) < 2 {
+		s.idle()
+		waits = append(waits, s.net.Poller.LastWait())
+		if response := s.flush(conn); response != "" {
+			received = append(received, fmt.Sprintf("%s@%v", strings.TrimSpace(response), s.elapsed()-started))
+		}
+	}
+	if got := strings.Join(received, " "); got != "first@250ms second@1.5s" {
+		t.Fatalf("DELAY replies %q after waits %v", got, waits)
+	}
+	if got := fmt.Sprint(waits); got != "[250ms 1s 250ms]" {
+		t.Fatalf("waits %s, want the poller timeout taken from the timer heap", got)
+	}
+}
+
+func TestSimDelay(t *testing.T) {
+	s := newSimServer(t, nil)
+
+	conn := s.connect("10.0.0.1")
+	s.send(conn, "DELAY 700ms c\nDELAY 100ms a\nDELAY 400ms b\n")
+	if got := s.flush(conn); got != "Scheduled in 700ms\nScheduled in 100ms\nScheduled in 400ms\n" {
+		t.Fatalf("DELAY acknowledgements: got %q", got)
+	}
+
+	started := s.elapsed()
+	var received []string
+	for len(received) < 3 {
+		s.idle()
+		for _, line := range strings.Fields(s.flush(conn)) {
+			received = append(received, fmt.Sprintf("%s@%v", line, s.elapsed()-started))
+		}
+	}
+	if got := strings.Join(received, " "); got != "a@100ms b@400ms c@700ms" {
+		t.Fatalf("DELAY replies %q", got)
+	}
+
+	var batch strings.Builder
+	for range usecase.MaxPendingDelays - 1 {
+		batch.WriteString("DELAY 1m late\n")
+	}
+	batch.WriteString("DELAY 100ms soon\n")
+	s.send(conn, batch.String())
+	if got := strings.Count(s.flush(conn), "Scheduled in"); got != usecase.MaxPendingDelays {
+		t.Fatalf("%d of %d DELAY commands scheduled", got, usecase.MaxPendingDelays)
+	}
+	if got, want := s.command(conn, "DELAY 1s over"), "Error: "+usecase.ErrTooManyDelays.Error(); got != want {
+		t.Fatalf("DELAY over the cap: got %q, want %q", got, want)
+	}
+
+	for got := ""; got == ""; got = s.flush(conn) {
+		s.idle()
+	}
+	if got := s.command(conn, "DELAY 250ms again"); got != "Scheduled in 250ms" {
+		t.Fatalf("DELAY after a pending one fired: got %q", got)
+	}
+
+	// With the client gone nothing is due before the select timeout.
+	s.net.Hangup(conn)
+	s.step()
+	if s.watched(conn) {
+		t.Fatalf("client still watched after hanging up")
+	}
+	s.idle()
+	if wait := s.net.Poller.LastWait(); wait != time.Second {
+		t.Fatalf("waited %v after the client left, its DELAY timers were not cancelled", wait)
+	}
+}
+
+func TestSimPingProbes(t *testing.T) {
+	s := newSimServer(t, func(config *domain.ServerConfig) {
+		config.RTTSource = network.RTTSourcePing
+	})
+
+	conn := s.connect("10.0.0.1")
+	for range 3 {
+		s.net.Clock.Advance(2 * time.Second)
+		if got := s.command(conn, "ECHO quiet"); got != "quiet" {
+			t.Fatalf("client that did not opt in got %q", got)
+		}
+	}
+
+	if got := s.command(conn, "RTT PING"); got != "RTT ping probes on" {
+		t.Fatalf("RTT PING: got %q", got)
+	}
+	lines := strings.Split(s.command(conn, "ECHO probed"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "PING ") || lines[1] != "probed" {
+		t.Fatalf("client that opted in got %q, want a PING and the echo", lines)
+	}
+
+	s.net.Clock.Advance(30 * time.Millisecond)
+	if got := s.command(conn, "PONG "+strings.TrimPrefix(lines[0], "PING ")); got != "" {
+		t.Fatalf("PONG answered %q", got)
+	}
+	if got := s.command(conn, "STATS"); !strings.Contains(got, "rtt=30ms (ping") {
+		t.Fatalf("STATS after PONG: %q", got)
+	}
+
+	if got := s.command(conn, "RTT OFF"); got != "RTT ping probes off" {
+		t.Fatalf("RTT OFF: got %q", got)
+	}
+	s.net.Clock.Advance(2 * time.Second)
+	if got := s.command(conn, "ECHO quiet"); got != "quiet" {
+		t.Fatalf("client that opted out got %q", got)
+	}
+	if got := s.command(conn, "RTT"); got != "Error: usage: RTT PING|OFF" {
+		t.Fatalf("RTT without an argument: got %q", got)
+	}
+}
+
+func TestSimLimits(t *testing.T) {
+	s := newSimServer(t, func(config *domain.ServerConfig) {
+		config.MaxClients = 2
+		config.MaxClientsPerIP = 1
+	})
+
+	first := s.connect("10.0.0.1")
+
+	rejected := s.net.Connect("10.0.0.1")
+	s.step()
+	if got, want := string(rejected.TakeOutput()), "Error: server busy (too many connections from your address)\n"; got != want {
+		t.Fatalf("second connection from one address got %q, want %q", got, want)
+	}
+	if !rejected.Closed() {
+		t.Fatalf("rejected connection left open")
+	}
+
+	s.connect("10.0.0.2")
+	full := s.net.Connect("10.0.0.3")
+	s.step()
+	if got, want := string(full.TakeOutput()), "Error: server busy (max clients reached)\n"; got != want {
+		t.Fatalf("connection over MaxClients got %q, want %q", got, want)
+	}
+
+	want := "STATS server clients=2/2 per_ip=1 accepted=2 rejected_full=1 rejected_per_ip=1 rejected_rate=0 rejected_banned=0 bans=0 banned_now=0"
+	if got := s.command(first, "STATS SERVER"); got != want {
+		t.Fatalf("STATS SERVER:\n got %q\nwant %q", got, want)
+	}
+
+	// A disconnect frees both the per-address and the server-wide slot.
+	s.net.Hangup(first)
+	s.step()
+	again := s.connect("10.0.0.1")
+	want = "STATS server clients=2/2 per_ip=1 accepted=3 rejected_full=1 rejected_per_ip=1 rejected_rate=0 rejected_banned=0 bans=0 banned_now=0"
+	if got := s.command(again, "STATS SERVER"); got != want {
+		t.Fatalf("STATS SERVER after a disconnect:\n got %q\nwant %q", got, want)
+	}
+}
+
+// refused connects from ip and returns the error the server closed it with.
+func (s *simServer) refused(ip string) string {
+	s.t.Helper()
+	conn := s.net.Connect(ip)
+	s.step()
+	if !conn.Closed() {
+		s.t.Fatalf("connection from %s was accepted", ip)
+	}
+	return strings.TrimRight(string(conn.TakeOutput()), "\n")
+}
+
+func TestSimAcceptRate(t *testing.T) {
+	s := newSimServer(t, func(config *domain.ServerConfig) {
+		config.PingTimeout = time.Hour
+		config.AcceptRate = 2
+		config.AcceptBurst = 2
+		config.BanThreshold = 3
+		config.BanWindow = 10 * time.Second
+		config.BanDuration = 30 * time.Second
+	})
+
+	s.connect("10.0.0.1")
+	s.connect("10.0.0.1")
+	if got, want := s.refused("10.0.0.1"), "Error: server busy (accept rate exceeded)"; got != want {
+		t.Fatalf("connection over the burst got %q, want %q", got, want)
+	}
+	other := s.connect("10.0.0.2")
+
+	// Tokens come back at AcceptRate per second.
+	s.net.Clock.Advance(500 * time.Millisecond)
+	s.connect("10.0.0.1")
+	s.refused("10.0.0.1")
+
+	// The third rejection within BanWindow bans the address.
+	s.refused("10.0.0.1")
+	if got, want := s.refused("10.0.0.1"), "Error: server busy (banned for 30s)"; got != want {
+		t.Fatalf("connection after three rejections got %q, want %q", got, want)
+	}
+	s.net.Clock.Advance(20 * time.Second)
+	if got, want := s.refused("10.0.0.1"), "Error: server busy (banned for 10s)"; got != want {
+		t.Fatalf("banned address got %q, want %q", got, want)
+	}
+
+	s.net.Clock.Advance(10 * time.Second)
+	s.connect("10.0.0.1")
+
+	want := "STATS server clients=5/100 per_ip=0 accepted=5 rejected_full=0 rejected_per_ip=0 rejected_rate=3 rejected_banned=2 bans=1 banned_now=0"
+	if got := s.command(other, "STATS SERVER"); got != want {
+		t.Fatalf("STATS SERVER:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestSimTransfer(t *testing.T) {
+	s := newSimServer(t, nil)
+
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	conn := s.connect("10.0.0.1")
+	if got := s.command(conn, "UPLOAD sim.bin 10000"); got != "READY_TO_RECEIVE sim.bin 10000" {
+		t.Fatalf("UPLOAD answered %q", got)
+	}
+
+	for offset := 0; offset < len(data); offset += 3000 {
+		conn.FeedBytes(data[offset:min(offset+3000, len(data))])
+		for conn.Unread() > 0 {
+			s.net.Readable(conn)
+			s.step()
+		}
+		s.net.Clock.Advance(time.Second)
+	}
+	if got := s.flush(conn); !strings.HasPrefix(got, "File uploaded successfully: sim.bin (") {
+		t.Fatalf("upload finished with %q", got)
+	}
+	stored, err := os.ReadFile(filepath.Join(s.uploadDir, "sim.bin"))
+	if err != nil {
+		t.Fatalf("reading uploaded file: %v", err)
+	}
+	if !bytes.Equal(stored, data) {
+		t.Fatalf("uploaded file differs from the sent data")
+	}
+
+	s.send(conn, "DOWNLOAD sim.bin\n")
+	var received []byte
+	writes := 0
+	for s.wantsWrite(conn) {
+		s.net.Writable(conn, 1500)
+		s.step()
+		received = append(received, conn.TakeOutput()...)
+		writes++
+	}
+	header := "FILE_INFO sim.bin 10000\n"
+	if !bytes.HasPrefix(received, []byte(header)) || !bytes.Equal(received[len(header):], data) {
+		t.Fatalf("download through a 1500-byte window returned %d bytes", len(received))
+	}
+	if want := (len(header) + len(data) + 1499) / 1500; writes != want {
+		t.Fatalf("download took %d windowed writes, want %d", writes, want)
+	}
+
+	if got := s.command(conn, "STATS"); !strings.Contains(got, " transfer=none ") {
+		t.Fatalf("transfer still reported after download: %q", got)
+	}
+	if got := s.command(conn, "ECHO after"); got != "after" {
+		t.Fatalf("ECHO after download: got %q", got)
+	}
+
+	aborted := s.connect("10.0.0.2")
+	s.send(aborted, "DOWNLOAD sim.bin\n")
+	s.net.Writable(aborted, 1500)
+	s.step()
+	aborted.Reset()
+	s.net.Writable(aborted, 1500)
+	s.step()
+	if s.watched(aborted) || !aborted.Closed() {
+		t.Fatalf("client not removed after a reset mid-download")
+	}
+
+	if got := s.command(conn, "STATS SERVER"); !strings.HasPrefix(got, "STATS server clients=1/") {
+		t.Fatalf("unexpected server stats after the reset: %q", got)
+	}
+}
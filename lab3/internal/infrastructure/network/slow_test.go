@@ -0,0 +1,149 @@
+//go:build linux || darwin
+
+package network_test
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"bufio"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSlowClients(t *testing.T) {
+	const highWater = 16 * 1024
+
+	for _, pollerName := range []string{"select", "epoll"} {
+		t.Run(pollerName, func(t *testing.T) {
+			s := startLoopbackServer(t, pollerName, func(config *domain.ServerConfig) {
+				config.OutputHighWater = highWater
+			})
+
+			t.Run("accept-burst", func(t *testing.T) {
+				clients := 200
+				if testing.Short() {
+					clients = 50
+				}
+				errs := make(chan error, clients)
+				var wg sync.WaitGroup
+				for i := 0; i < clients; i++ {
+					conn := s.dial(t)
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						want := fmt.Sprintf("burst %d", i)
+						if got, err := conn.command("ECHO " + want); err != nil || got != want {
+							errs <- fmt.Errorf("client %d: got %q, %v", i, got, err)
+						}
+					}()
+				}
+				wg.Wait()
+				close(errs)
+				if err := <-errs; err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			t.Run("flood-stops-at-high-water", func(t *testing.T) {
+				flooder := s.dial(t)
+				other := s.dial(t)
+
+				count := 200000
+				padding := strings.Repeat("x", 200)
+				var mu sync.Mutex
+				written := 0
+				writeDone := make(chan error, 1)
+				go func() {
+					w := bufio.NewWriterSize(flooder, 64*1024)
+					for i := 0; i < count; i++ {
+						if _, err := fmt.Fprintf(w, "ECHO %d %s\n", i, padding); err != nil {
+							writeDone <- err
+							return
+						}
+						mu.Lock()
+						written = i + 1
+						mu.Unlock()
+					}
+					writeDone <- w.Flush()
+				}()
+
+				// Wait until the flooder stops making progress: the server has
+				// stopped reading it because its replies are not being read.
+				last := -1
+				for {
+					time.Sleep(200 * time.Millisecond)
+					mu.Lock()
+					n := written
+					mu.Unlock()
+					if n == last || n == count {
+						break
+					}
+					last = n
+				}
+				if last == count {
+					t.Fatalf("all %d commands were accepted without reading a reply", count)
+				}
+
+				if worst := maxLatency(t, other, 300*time.Millisecond); worst > 500*time.Millisecond {
+					t.Fatalf("ECHO took %v next to the blocked flooder", worst)
+				}
+
+				flooder.SetReadDeadline(time.Now().Add(30 * time.Second))
+				for i := 0; i < count; i++ {
+					line, err := flooder.reader.ReadString('\n')
+					if err != nil {
+						t.Fatalf("flood reply %d: %v", i, err)
+					}
+					if want := fmt.Sprintf("%d %s\n", i, padding); line != want {
+						t.Fatalf("flood reply %d out of order: %.20q", i, line)
+					}
+				}
+				if err := <-writeDone; err != nil {
+					t.Fatalf("flood writer: %v", err)
+				}
+			})
+
+			t.Run("stalled-readers", func(t *testing.T) {
+				size := 8 * 1024 * 1024
+				if testing.Short() {
+					size = 2 * 1024 * 1024
+				}
+				s.dial(t).upload(t, "slow.bin", make([]byte, size))
+
+				for i := 0; i < 4; i++ {
+					if _, err := s.dial(t).Write([]byte("DOWNLOAD slow.bin\n")); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				trickler := s.dial(t)
+				trickled := make(chan error, 1)
+				go func() {
+					for _, b := range []byte("ECHO trickled") {
+						if _, err := trickler.Write([]byte{b}); err != nil {
+							trickled <- err
+							return
+						}
+						time.Sleep(15 * time.Millisecond)
+					}
+					trickled <- nil
+				}()
+
+				active := s.dial(t)
+				if worst := maxLatency(t, active, 300*time.Millisecond); worst > 500*time.Millisecond {
+					t.Fatalf("ECHO took %v next to stalled downloads and a trickling client", worst)
+				}
+
+				if err := <-trickled; err != nil {
+					t.Fatalf("trickling: %v", err)
+				}
+				if _, err := trickler.Write([]byte("\n")); err != nil {
+					t.Fatal(err)
+				}
+				trickler.expectLines(t, "trickled")
+			})
+		})
+	}
+}
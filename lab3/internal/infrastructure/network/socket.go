@@ -0,0 +1,236 @@
+//go:build linux || darwin
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var ErrNotSocket = errors.New("connection does not expose a file descriptor")
+
+type Conn interface {
+	net.Conn
+	FD() int
+	RTT() (time.Duration, error)
+}
+
+type Listener interface {
+	FD() int
+	Addr() net.Addr
+	Accept() (Conn, error)
+	Close() error
+}
+
+type ListenFunc func(host string, port int) (Listener, error)
+
+type fdListener struct {
+	fd   int
+	addr net.Addr
+}
+
+func ListenTCP(host string, port int) (Listener, error) {
+	fd, addr, err := listenTCP(host, port)
+	if err != nil {
+		return nil, err
+	}
+	return &fdListener{fd: fd, addr: addr}, nil
+}
+
+func (l *fdListener) FD() int        { return l.fd }
+func (l *fdListener) Addr() net.Addr { return l.addr }
+
+func (l *fdListener) Accept() (Conn, error) {
+	fd, sa, err := acceptNonBlocking(l.fd)
+	if err != nil {
+		return nil, err
+	}
+	return newFDConn(fd, sa), nil
+}
+
+func (l *fdListener) Close() error {
+	if l.fd < 0 {
+		return nil
+	}
+	err := unix.Close(l.fd)
+	l.fd = -1
+	return err
+}
+
+type fdConn struct {
+	fd     int
+	local  net.Addr
+	remote net.Addr
+}
+
+func newFDConn(fd int, remote unix.Sockaddr) *fdConn {
+	conn := &fdConn{fd: fd, remote: sockaddrToTCPAddr(remote)}
+	if local, err := unix.Getsockname(fd); err == nil {
+		conn.local = sockaddrToTCPAddr(local)
+	}
+	return conn
+}
+
+func adoptConn(conn net.Conn) (*fdConn, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil, ErrNotSocket
+	}
+	rawConn, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	fd := -1
+	var dupErr error
+	if err := rawConn.Control(func(sysfd uintptr) {
+		fd, dupErr = unix.Dup(int(sysfd))
+	}); err != nil {
+		return nil, err
+	}
+	if dupErr != nil {
+		return nil, dupErr
+	}
+
+	unix.CloseOnExec(fd)
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	adopted := &fdConn{fd: fd, local: conn.LocalAddr(), remote: conn.RemoteAddr()}
+	conn.Close()
+	return adopted, nil
+}
+
+func (c *fdConn) Read(b []byte) (int, error) {
+	for {
+		n, err := unix.Read(c.fd, b)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 && len(b) > 0 {
+			return 0, io.EOF
+		}
+		return n, nil
+	}
+}
+
+func (c *fdConn) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		n, err := unix.Write(c.fd, b[written:])
+		if n > 0 {
+			written += n
+		}
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (c *fdConn) Close() error {
+	if c.fd < 0 {
+		return nil
+	}
+	err := unix.Close(c.fd)
+	c.fd = -1
+	return err
+}
+
+func (c *fdConn) FD() int                            { return c.fd }
+func (c *fdConn) RTT() (time.Duration, error)        { return tcpInfoRTT(c.fd) }
+func (c *fdConn) LocalAddr() net.Addr                { return c.local }
+func (c *fdConn) RemoteAddr() net.Addr               { return c.remote }
+func (c *fdConn) SetDeadline(t time.Time) error      { return nil }
+func (c *fdConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *fdConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func writeNonBlocking(conn net.Conn, data []byte) (int, error) {
+	n, err := conn.Write(data)
+	if errors.Is(err, unix.EAGAIN) {
+		return n, nil
+	}
+	return n, err
+}
+
+func isWouldBlock(err error) bool {
+	return errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EWOULDBLOCK)
+}
+
+func isAcceptRetryable(err error) bool {
+	return errors.Is(err, unix.EINTR) || errors.Is(err, unix.ECONNABORTED)
+}
+
+func isAcceptExhausted(err error) bool {
+	return errors.Is(err, unix.EMFILE) || errors.Is(err, unix.ENFILE) ||
+		errors.Is(err, unix.ENOBUFS) || errors.Is(err, unix.ENOMEM)
+}
+
+func listenTCP(host string, port int) (int, net.Addr, error) {
+	addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return -1, nil, err
+	}
+
+	family := unix.AF_INET
+	var sa unix.Sockaddr
+	if ip4 := addr.IP.To4(); addr.IP == nil || ip4 != nil {
+		inet4 := &unix.SockaddrInet4{Port: addr.Port}
+		copy(inet4.Addr[:], ip4)
+		sa = inet4
+	} else {
+		family = unix.AF_INET6
+		inet6 := &unix.SockaddrInet6{Port: addr.Port}
+		copy(inet6.Addr[:], addr.IP.To16())
+		sa = inet6
+	}
+
+	fd, err := newNonBlockingSocket(family)
+	if err != nil {
+		return -1, nil, fmt.Errorf("socket: %w", err)
+	}
+	if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+		unix.Close(fd)
+		return -1, nil, fmt.Errorf("setsockopt SO_REUSEADDR: %w", err)
+	}
+	if err := unix.Bind(fd, sa); err != nil {
+		unix.Close(fd)
+		return -1, nil, fmt.Errorf("bind %s: %w", addr, err)
+	}
+	if err := unix.Listen(fd, unix.SOMAXCONN); err != nil {
+		unix.Close(fd)
+		return -1, nil, fmt.Errorf("listen %s: %w", addr, err)
+	}
+
+	local, err := unix.Getsockname(fd)
+	if err != nil {
+		unix.Close(fd)
+		return -1, nil, err
+	}
+	return fd, sockaddrToTCPAddr(local), nil
+}
+
+func sockaddrToTCPAddr(sa unix.Sockaddr) net.Addr {
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		return &net.TCPAddr{IP: net.IP(sa.Addr[:]).To16(), Port: sa.Port}
+	case *unix.SockaddrInet6:
+		return &net.TCPAddr{IP: net.IP(sa.Addr[:]), Port: sa.Port}
+	}
+	return &net.TCPAddr{}
+}
@@ -0,0 +1,11 @@
+package network
+
+import "golang.org/x/sys/unix"
+
+func newNonBlockingSocket(family int) (int, error) {
+	return unix.Socket(family, unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+}
+
+func acceptNonBlocking(listenerFD int) (int, unix.Sockaddr, error) {
+	return unix.Accept4(listenerFD, unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC)
+}
@@ -0,0 +1,171 @@
+package network
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"container/heap"
+	"sync"
+	"time"
+)
+
+type timer struct {
+	id       domain.TimerID
+	when     time.Time
+	interval time.Duration
+	fn       func()
+	index    int
+}
+
+type timerHeap []*timer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].when.Before(h[j].when) }
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	t := x.(*timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	t.index = -1
+	return t
+}
+
+type timerQueue struct {
+	mu     sync.Mutex
+	heap   timerHeap
+	timers map[domain.TimerID]*timer
+	nextID domain.TimerID
+	now    func() time.Time
+}
+
+func newTimerQueue(now func() time.Time) *timerQueue {
+	return &timerQueue{
+		timers: make(map[domain.TimerID]*timer),
+		now:    now,
+	}
+}
+
+func (q *timerQueue) After(delay time.Duration, fn func()) domain.TimerID {
+	return q.schedule(q.now().Add(delay), 0, fn)
+}
+
+func (q *timerQueue) At(when time.Time, fn func()) domain.TimerID {
+	return q.schedule(when, 0, fn)
+}
+
+func (q *timerQueue) Every(interval time.Duration, fn func()) domain.TimerID {
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	return q.schedule(q.now().Add(interval), interval, fn)
+}
+
+func (q *timerQueue) schedule(when time.Time, interval time.Duration, fn func()) domain.TimerID {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	t := &timer{id: q.nextID, when: when, interval: interval, fn: fn}
+	heap.Push(&q.heap, t)
+	q.timers[t.id] = t
+	return t.id
+}
+
+func (q *timerQueue) Reset(id domain.TimerID, when time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t, exists := q.timers[id]
+	if !exists {
+		return false
+	}
+	t.when = when
+	heap.Fix(&q.heap, t.index)
+	return true
+}
+
+func (q *timerQueue) When(id domain.TimerID) (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t, exists := q.timers[id]
+	if !exists {
+		return time.Time{}, false
+	}
+	return t.when, true
+}
+
+func (q *timerQueue) Cancel(id domain.TimerID) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t, exists := q.timers[id]
+	if !exists {
+		return false
+	}
+	heap.Remove(&q.heap, t.index)
+	delete(q.timers, id)
+	return true
+}
+
+func (q *timerQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.heap)
+}
+
+func (q *timerQueue) Timeout(max time.Duration) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.heap) == 0 {
+		return max
+	}
+	wait := q.heap[0].when.Sub(q.now())
+	if wait < 0 {
+		return 0
+	}
+	if max >= 0 && wait > max {
+		return max
+	}
+	return wait
+}
+
+func (q *timerQueue) RunExpired() int {
+	now := q.now()
+	fired := 0
+	for {
+		q.mu.Lock()
+		if len(q.heap) == 0 || q.heap[0].when.After(now) {
+			q.mu.Unlock()
+			return fired
+		}
+
+		t := q.heap[0]
+		if t.interval > 0 {
+			t.when = t.when.Add(t.interval)
+			if !t.when.After(now) {
+				t.when = now.Add(t.interval)
+			}
+			heap.Fix(&q.heap, 0)
+		} else {
+			heap.Pop(&q.heap)
+			delete(q.timers, t.id)
+		}
+		q.mu.Unlock()
+
+		t.fn()
+		fired++
+	}
+}
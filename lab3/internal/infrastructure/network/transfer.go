@@ -0,0 +1,185 @@
+package network
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+var ErrTransfersDisabled = errors.New("file transfers are not enabled on this server")
+
+func (sm *selectMultiplexer) newTransferSession(client *domain.ClientConnection, filename string, size int64, upload bool) (*domain.TransferSession, error) {
+	now := sm.now()
+	session := &domain.TransferSession{
+		ID:         fmt.Sprintf("%s_%s_%d", client.ID, filename, now.UnixNano()),
+		ClientAddr: client.Conn.RemoteAddr().String(),
+		FileName:   filename,
+		FileSize:   size,
+		IsUpload:   upload,
+		LastUpdate: now,
+		IsActive:   true,
+		StartTime:  now,
+	}
+
+	if err := sm.fileManager.CreateTransferSession(session); err != nil {
+		return nil, fmt.Errorf("failed to create transfer session: %w", err)
+	}
+
+	client.FileTransfer = session
+	return session, nil
+}
+
+func (sm *selectMultiplexer) startUpload(client *domain.ClientConnection, args []string) (string, error) {
+	if sm.fileManager == nil {
+		return "", ErrTransfersDisabled
+	}
+	if len(args) < 2 {
+		return "", fmt.Errorf("usage: UPLOAD <filename> <size>")
+	}
+
+	size, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || size < 0 {
+		return "", fmt.Errorf("invalid file size: %s", args[1])
+	}
+
+	filename := filepath.Base(args[0])
+	if err := sm.fileManager.SaveFile(filename, nil, 0); err != nil {
+		return "", err
+	}
+
+	if _, err := sm.newTransferSession(client, filename, size, true); err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Upload started: %s from %s (%d bytes, chunk size %d)\n", filename, client.ID, size, client.ChunkSize)
+	return fmt.Sprintf("READY_TO_RECEIVE %s %d", filename, size), nil
+}
+
+func (sm *selectMultiplexer) startDownload(client *domain.ClientConnection, args []string) (string, error) {
+	if sm.fileManager == nil {
+		return "", ErrTransfersDisabled
+	}
+	if len(args) < 1 {
+		return "", fmt.Errorf("usage: DOWNLOAD <filename>")
+	}
+
+	info, err := sm.fileManager.GetFileInfo(args[0])
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", args[0])
+	}
+
+	session, err := sm.newTransferSession(client, info.Name, info.Size, false)
+	if err != nil {
+		return "", err
+	}
+	session.FilePath = info.Path
+
+	fmt.Printf("Download started: %s to %s (%d bytes, chunk size %d)\n", info.Name, client.ID, info.Size, client.ChunkSize)
+	return fmt.Sprintf("FILE_INFO %s %d", info.Name, info.Size), nil
+}
+
+func (sm *selectMultiplexer) consumeUpload(client *domain.ClientConnection) error {
+	session := client.FileTransfer
+
+	n := min(int64(len(client.Buffer)), session.FileSize-session.Transferred)
+	if n > 0 {
+		if err := sm.fileManager.SaveFile(session.FileName, client.Buffer[:n], session.Transferred); err != nil {
+			return err
+		}
+		client.Buffer = client.Buffer[n:]
+		session.Transferred += n
+		session.LastUpdate = sm.now()
+		sm.fileManager.UpdateTransferSession(session)
+	}
+
+	if session.Transferred == session.FileSize {
+		sm.queueOutput(client, fmt.Sprintf("File uploaded successfully: %s (%s)", session.FileName, transferSummary(session)))
+		sm.finishTransfer(client)
+	}
+	return nil
+}
+
+func (sm *selectMultiplexer) stepDownload(client *domain.ClientConnection) error {
+	session := client.FileTransfer
+	if len(client.Output) > 0 {
+		return nil
+	}
+
+	sm.refreshRTT(client)
+
+	if remaining := session.FileSize - session.Transferred; remaining > 0 {
+		chunk := sm.buffer(int(min(int64(client.ChunkSize), remaining)))
+		n, err := sm.fileManager.ReadChunk(session.FileName, session.Transferred, chunk)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%s shrank to %d bytes during download", session.FileName, session.Transferred)
+		}
+
+		written, err := writeNonBlocking(client.Conn, chunk[:n])
+		client.BytesOut += int64(written)
+		if written < n {
+			if len(client.Output) == 0 {
+				client.WriteSince = sm.now()
+			}
+			client.Output = append(client.Output, chunk[written:n]...)
+		}
+		session.Transferred += int64(n)
+		session.LastUpdate = sm.now()
+		client.LastPing = session.LastUpdate
+		sm.fileManager.UpdateTransferSession(session)
+
+		if err != nil {
+			return fmt.Errorf("write error: %w", err)
+		}
+	}
+
+	if session.Transferred == session.FileSize {
+		sm.finishTransfer(client)
+		return sm.processClientData(client.ID, client)
+	}
+	return nil
+}
+
+func (sm *selectMultiplexer) finishTransfer(client *domain.ClientConnection) {
+	session := client.FileTransfer
+	session.IsActive = false
+	client.FileTransfer = nil
+	sm.fileManager.DeleteTransferSession(session.ID)
+
+	for _, response := range client.Deferred {
+		sm.queueOutput(client, response)
+	}
+	client.Deferred = nil
+
+	direction := "Download"
+	if session.IsUpload {
+		direction = "Upload"
+	}
+	fmt.Printf("%s completed: %s for %s (%s)\n", direction, session.FileName, client.ID, transferSummary(session))
+}
+
+func (sm *selectMultiplexer) abortTransfer(client *domain.ClientConnection) {
+	session := client.FileTransfer
+	if session == nil || !session.IsActive {
+		return
+	}
+
+	session.IsActive = false
+	client.FileTransfer = nil
+	sm.fileManager.DeleteTransferSession(session.ID)
+
+	fmt.Printf("Transfer interrupted: %s for %s (%d of %d bytes)\n", session.FileName, client.ID, session.Transferred, session.FileSize)
+}
+
+func transferSummary(session *domain.TransferSession) string {
+	elapsed := session.LastUpdate.Sub(session.StartTime).Seconds()
+	mb := float64(session.Transferred) / 1024 / 1024
+	if elapsed <= 0 {
+		return fmt.Sprintf("%.2f MB", mb)
+	}
+	return fmt.Sprintf("%.2f MB, %.2f MB/s", mb, mb/elapsed)
+}
@@ -0,0 +1,122 @@
+//go:build linux || darwin
+
+package network_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func (c *lineConn) upload(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if got, err := c.command(fmt.Sprintf("UPLOAD %s %d", name, len(data))); err != nil || !strings.HasPrefix(got, "READY_TO_RECEIVE") {
+		t.Fatalf("UPLOAD answered %q, %v", got, err)
+	}
+
+	c.SetDeadline(time.Now().Add(time.Minute))
+	defer c.SetDeadline(time.Time{})
+	if _, err := c.Write(data); err != nil {
+		t.Fatalf("uploading %s: %v", name, err)
+	}
+	if line, err := c.reader.ReadString('\n'); err != nil || !strings.HasPrefix(line, "File uploaded successfully") {
+		t.Fatalf("upload finished with %q, %v", line, err)
+	}
+}
+
+func TestDownloadInterleavedWithEcho(t *testing.T) {
+	size := 16 * 1024 * 1024
+	if testing.Short() {
+		size = 4 * 1024 * 1024
+	}
+	data := make([]byte, size)
+	rand.NewChaCha8([32]byte{42}).Read(data)
+
+	for _, pollerName := range []string{"select", "epoll"} {
+		t.Run(pollerName, func(t *testing.T) {
+			s := startLoopbackServer(t, pollerName, nil)
+			s.dial(t).upload(t, "transfer.bin", data)
+
+			var stop atomic.Bool
+			var mu sync.Mutex
+			var worst time.Duration
+			rounds := 0
+			echoErrs := make(chan error, 4)
+			var echoers sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				conn := s.dial(t)
+				echoers.Add(1)
+				go func() {
+					defer echoers.Done()
+					want := fmt.Sprintf("echo %d", i)
+					for !stop.Load() {
+						start := time.Now()
+						if got, err := conn.command("ECHO " + want); err != nil || got != want {
+							echoErrs <- fmt.Errorf("echoer %d: got %q, %v", i, got, err)
+							return
+						}
+						mu.Lock()
+						worst = max(worst, time.Since(start))
+						rounds++
+						mu.Unlock()
+					}
+				}()
+			}
+
+			downloads := make([]*lineConn, 2)
+			for i := range downloads {
+				downloads[i] = s.dial(t)
+			}
+			var wg sync.WaitGroup
+			errs := make(chan error, len(downloads))
+			for i, conn := range downloads {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					got, err := conn.command("DOWNLOAD transfer.bin")
+					if want := fmt.Sprintf("FILE_INFO transfer.bin %d", size); err != nil || got != want {
+						errs <- fmt.Errorf("download %d: got %q, %v", i, got, err)
+						return
+					}
+					conn.SetReadDeadline(time.Now().Add(time.Minute))
+					received := make([]byte, size)
+					if _, err := io.ReadFull(conn.reader, received); err != nil {
+						errs <- fmt.Errorf("download %d: %w", i, err)
+						return
+					}
+					if !bytes.Equal(received, data) {
+						errs <- fmt.Errorf("download %d: content differs from the upload", i)
+						return
+					}
+					if got, err := conn.command("ECHO done"); err != nil || got != "done" {
+						errs <- fmt.Errorf("download %d: line mode after download: got %q, %v", i, got, err)
+					}
+				}()
+			}
+			wg.Wait()
+			stop.Store(true)
+			echoers.Wait()
+			close(errs)
+			close(echoErrs)
+
+			for err := range errs {
+				t.Error(err)
+			}
+			for err := range echoErrs {
+				t.Error(err)
+			}
+			if rounds == 0 {
+				t.Fatalf("no ECHO completed during the downloads")
+			}
+			if worst > 500*time.Millisecond {
+				t.Fatalf("ECHO took %v during the downloads (%d round trips)", worst, rounds)
+			}
+		})
+	}
+}
@@ -1,70 +1,79 @@
+//go:build linux || darwin
+
 package network
 
 import (
 	"NSSaDS/lab3/internal/domain"
-	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 type UnixSelectSystem struct{}
 
 func (uss *UnixSelectSystem) Select(nfd int, readFds, writeFds, exceptFds *domain.FdSet, timeout *domain.Timeval) (int, error) {
-
-	syscallReadFds := &syscall.FdSet{}
-	syscallWriteFds := &syscall.FdSet{}
-	syscallExceptFds := &syscall.FdSet{}
-	syscallTimeout := &syscall.Timeval{
-		Sec:  timeout.Sec,
-		Usec: timeout.Usec,
+	if nfd > domain.FdSetSize {
+		nfd = domain.FdSetSize
 	}
 
-	for i := 0; i < 32; i++ {
-		if i < len(readFds.Bits) {
-			syscallReadFds.Bits[i] = readFds.Bits[i]
-		}
-		if i < len(writeFds.Bits) {
-			syscallWriteFds.Bits[i] = writeFds.Bits[i]
-		}
-		if i < len(exceptFds.Bits) {
-			syscallExceptFds.Bits[i] = exceptFds.Bits[i]
-		}
+	unixReadFds := uss.toUnix(nfd, readFds)
+	unixWriteFds := uss.toUnix(nfd, writeFds)
+	unixExceptFds := uss.toUnix(nfd, exceptFds)
+
+	var unixTimeout *unix.Timeval
+	if timeout != nil {
+		tv := unix.NsecToTimeval((time.Duration(timeout.Sec)*time.Second + time.Duration(timeout.Usec)*time.Microsecond).Nanoseconds())
+		unixTimeout = &tv
 	}
 
-	err := syscall.Select(nfd, syscallReadFds, syscallWriteFds, syscallExceptFds, syscallTimeout)
+	n, err := unix.Select(nfd, unixReadFds, unixWriteFds, unixExceptFds, unixTimeout)
 	if err != nil {
 		return 0, err
 	}
 
-	count := 0
-	for i := 0; i < 32; i++ {
-		if i < len(readFds.Bits) {
-			readFds.Bits[i] = syscallReadFds.Bits[i]
-			if syscallReadFds.Bits[i] != 0 {
-				count++
-			}
-		}
-		if i < len(writeFds.Bits) {
-			writeFds.Bits[i] = syscallWriteFds.Bits[i]
-			if syscallWriteFds.Bits[i] != 0 {
-				count++
-			}
-		}
-		if i < len(exceptFds.Bits) {
-			exceptFds.Bits[i] = syscallExceptFds.Bits[i]
-			if syscallExceptFds.Bits[i] != 0 {
-				count++
-			}
+	uss.fromUnix(nfd, unixReadFds, readFds)
+	uss.fromUnix(nfd, unixWriteFds, writeFds)
+	uss.fromUnix(nfd, unixExceptFds, exceptFds)
+
+	return n, nil
+}
+
+func (uss *UnixSelectSystem) toUnix(nfd int, set *domain.FdSet) *unix.FdSet {
+	if set == nil {
+		return nil
+	}
+
+	unixSet := &unix.FdSet{}
+	for fd := 0; fd < nfd; fd++ {
+		if uss.FDIsSet(fd, set) {
+			unixSet.Set(fd)
 		}
 	}
+	return unixSet
+}
+
+func (uss *UnixSelectSystem) fromUnix(nfd int, unixSet *unix.FdSet, set *domain.FdSet) {
+	if set == nil {
+		return
+	}
 
-	return count, nil
+	uss.FDZero(set)
+	for fd := 0; fd < nfd; fd++ {
+		if unixSet.IsSet(fd) {
+			uss.FDSet(fd, set)
+		}
+	}
 }
 
 func (uss *UnixSelectSystem) FDSet(fd int, set *domain.FdSet) {
+	if fd < 0 || fd >= domain.FdSetSize {
+		return
+	}
 	set.Bits[fd/32] |= 1 << (uint(fd) % 32)
 }
 
 func (uss *UnixSelectSystem) FDIsSet(fd int, set *domain.FdSet) bool {
-	if fd/32 >= len(set.Bits) {
+	if fd < 0 || fd >= domain.FdSetSize {
 		return false
 	}
 	return set.Bits[fd/32]&(1<<(uint(fd)%32)) != 0
@@ -0,0 +1,57 @@
+//go:build linux || darwin
+
+package network
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+type wakeup struct {
+	readFD  int
+	writeFD int
+}
+
+func newWakeup() (*wakeup, error) {
+	var fds [2]int
+	if err := unix.Pipe(fds[:]); err != nil {
+		return nil, err
+	}
+	for _, fd := range fds {
+		unix.CloseOnExec(fd)
+		if err := unix.SetNonblock(fd, true); err != nil {
+			unix.Close(fds[0])
+			unix.Close(fds[1])
+			return nil, err
+		}
+	}
+	return &wakeup{readFD: fds[0], writeFD: fds[1]}, nil
+}
+
+func (w *wakeup) Signal() {
+	for {
+		_, err := unix.Write(w.writeFD, []byte{1})
+		if !errors.Is(err, unix.EINTR) {
+			return
+		}
+	}
+}
+
+func (w *wakeup) Drain() {
+	var buf [64]byte
+	for {
+		n, err := unix.Read(w.readFD, buf[:])
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if n <= 0 || err != nil {
+			return
+		}
+	}
+}
+
+func (w *wakeup) Close() {
+	unix.Close(w.readFD)
+	unix.Close(w.writeFD)
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type FileManager struct {
+	uploadDir     string
+	sessions      map[string]*domain.TransferSession
+	sessionsMutex sync.RWMutex
+}
+
+func NewFileManager(uploadDir string) *FileManager {
+	fm := &FileManager{
+		uploadDir: uploadDir,
+		sessions:  make(map[string]*domain.TransferSession),
+	}
+
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		fmt.Printf("Warning: failed to create upload directory: %v\n", err)
+	}
+
+	return fm
+}
+
+func (fm *FileManager) path(filename string) string {
+	return filepath.Join(fm.uploadDir, filepath.Base(filename))
+}
+
+func (fm *FileManager) SaveFile(filename string, data []byte, offset int64) error {
+	flags := os.O_CREATE | os.O_WRONLY
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(fm.path(filename), flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteAt(data, offset); err != nil {
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+
+	return nil
+}
+
+func (fm *FileManager) ReadFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(fm.path(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return data, nil
+}
+
+func (fm *FileManager) ReadChunk(filename string, offset int64, buf []byte) (int, error) {
+	file, err := os.Open(fm.path(filename))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	n, err := file.ReadAt(buf, offset)
+	if n > 0 {
+		return n, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	return 0, nil
+}
+
+func (fm *FileManager) GetFileInfo(filename string) (*domain.FileInfo, error) {
+	filePath := fm.path(filename)
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+	if stat.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", filename)
+	}
+
+	return &domain.FileInfo{
+		Name:    filepath.Base(filename),
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+		Path:    filePath,
+	}, nil
+}
+
+func (fm *FileManager) DeleteFile(filename string) error {
+	if err := os.Remove(fm.path(filename)); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	return nil
+}
+
+func (fm *FileManager) CreateTransferSession(session *domain.TransferSession) error {
+	fm.sessionsMutex.Lock()
+	defer fm.sessionsMutex.Unlock()
+
+	fm.sessions[session.ID] = session
+	return nil
+}
+
+func (fm *FileManager) GetTransferSession(clientAddr, filename string) (*domain.TransferSession, error) {
+	fm.sessionsMutex.RLock()
+	defer fm.sessionsMutex.RUnlock()
+
+	for _, session := range fm.sessions {
+		if session.ClientAddr == clientAddr && session.FileName == filename {
+			return session, nil
+		}
+	}
+
+	return nil, fmt.Errorf("session not found")
+}
+
+func (fm *FileManager) UpdateTransferSession(session *domain.TransferSession) error {
+	fm.sessionsMutex.Lock()
+	defer fm.sessionsMutex.Unlock()
+
+	if _, exists := fm.sessions[session.ID]; exists {
+		fm.sessions[session.ID] = session
+		return nil
+	}
+
+	return fmt.Errorf("session not found")
+}
+
+func (fm *FileManager) DeleteTransferSession(sessionID string) error {
+	fm.sessionsMutex.Lock()
+	defer fm.sessionsMutex.Unlock()
+
+	delete(fm.sessions, sessionID)
+	return nil
+}
+
+func (fm *FileManager) CleanupExpiredSessions() error {
+	fm.sessionsMutex.Lock()
+	defer fm.sessionsMutex.Unlock()
+
+	now := time.Now()
+	for id, session := range fm.sessions {
+		if now.Sub(session.LastUpdate) > 5*time.Minute {
+			delete(fm.sessions, id)
+		}
+	}
+
+	return nil
+}
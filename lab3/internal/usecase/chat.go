@@ -0,0 +1,301 @@
+package usecase
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrNoSession     = errors.New("chat is not available on this connection")
+	ErrInvalidNick   = errors.New("nickname must be 1-32 letters, digits, '_' or '-'")
+	ErrInvalidRoom   = errors.New("room name must start with '#' and be 2-33 characters")
+	ErrNickTaken     = errors.New("nickname is already in use")
+	ErrNotInRoom     = errors.New("you are not in that room")
+	ErrUnknownTarget = errors.New("no such room or nickname")
+)
+
+const maxNameLength = 32
+
+type chatMember struct {
+	nick    string
+	session *domain.Session
+	rooms   map[string]bool
+}
+
+type Chat struct {
+	members map[string]*chatMember
+	nicks   map[string]*chatMember
+	rooms   map[string]map[string]*chatMember
+}
+
+func NewChat() *Chat {
+	return &Chat{
+		members: make(map[string]*chatMember),
+		nicks:   make(map[string]*chatMember),
+		rooms:   make(map[string]map[string]*chatMember),
+	}
+}
+
+func (c *Chat) member(ctx context.Context) (*chatMember, error) {
+	session, ok := domain.SessionFromContext(ctx)
+	if !ok || session.Send == nil {
+		return nil, ErrNoSession
+	}
+	if m, exists := c.members[session.ClientID]; exists {
+		return m, nil
+	}
+
+	// Client IDs are valid nicknames, so someone may already have taken this
+	// one with NICK; the newcomer then gets a numbered variant instead.
+	nick := session.ClientID
+	for i := 2; c.nicks[nick] != nil; i++ {
+		nick = fmt.Sprintf("%s-%d", session.ClientID, i)
+	}
+
+	m := &chatMember{nick: nick, session: session, rooms: make(map[string]bool)}
+	c.members[session.ClientID] = m
+	c.nicks[m.nick] = m
+	session.OnClose(func() {
+		c.leave(m)
+	})
+	return m, nil
+}
+
+func (c *Chat) leave(m *chatMember) {
+	for _, room := range sortedKeys(m.rooms) {
+		c.part(m, room)
+	}
+	if c.nicks[m.nick] == m {
+		delete(c.nicks, m.nick)
+	}
+	delete(c.members, m.session.ClientID)
+}
+
+func (c *Chat) broadcast(room string, except *chatMember, line string) {
+	for _, m := range sortedMembers(c.rooms[room]) {
+		if m != except {
+			m.session.Send(line)
+		}
+	}
+}
+
+func (c *Chat) part(m *chatMember, room string) {
+	delete(m.rooms, room)
+	delete(c.rooms[room], m.nick)
+	if len(c.rooms[room]) == 0 {
+		delete(c.rooms, room)
+		return
+	}
+	c.broadcast(room, m, fmt.Sprintf("PARTED %s %s", room, m.nick))
+}
+
+func validNick(nick string) bool {
+	if len(nick) == 0 || len(nick) > maxNameLength {
+		return false
+	}
+	for _, r := range nick {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func validRoom(room string) bool {
+	return strings.HasPrefix(room, "#") && validNick(room[1:])
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedMembers(members map[string]*chatMember) []*chatMember {
+	list := make([]*chatMember, 0, len(members))
+	for _, m := range members {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].nick < list[j].nick })
+	return list
+}
+
+type NickCommand struct {
+	chat *Chat
+}
+
+func (c *NickCommand) Execute(ctx context.Context, args []string) (string, error) {
+	m, err := c.chat.member(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(args) != 1 {
+		return "NICK " + m.nick, nil
+	}
+
+	nick := args[0]
+	if !validNick(nick) {
+		return "", ErrInvalidNick
+	}
+	if other, taken := c.chat.nicks[nick]; taken && other != m {
+		return "", ErrNickTaken
+	}
+	if nick == m.nick {
+		return "NICK " + nick, nil
+	}
+
+	old := m.nick
+	delete(c.chat.nicks, old)
+	m.nick = nick
+	c.chat.nicks[nick] = m
+	for _, room := range sortedKeys(m.rooms) {
+		delete(c.chat.rooms[room], old)
+		c.chat.rooms[room][nick] = m
+		c.chat.broadcast(room, m, fmt.Sprintf("NICK %s %s %s", room, old, nick))
+	}
+	return "NICK " + nick, nil
+}
+
+func (c *NickCommand) Name() string {
+	return "NICK"
+}
+
+type JoinCommand struct {
+	chat *Chat
+}
+
+func (c *JoinCommand) Execute(ctx context.Context, args []string) (string, error) {
+	m, err := c.chat.member(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: JOIN #room")
+	}
+
+	room := args[0]
+	if !validRoom(room) {
+		return "", ErrInvalidRoom
+	}
+	if !m.rooms[room] {
+		if c.chat.rooms[room] == nil {
+			c.chat.rooms[room] = make(map[string]*chatMember)
+		}
+		c.chat.broadcast(room, m, fmt.Sprintf("JOINED %s %s", room, m.nick))
+		c.chat.rooms[room][m.nick] = m
+		m.rooms[room] = true
+	}
+	return fmt.Sprintf("JOINED %s %s", room, m.nick), nil
+}
+
+func (c *JoinCommand) Name() string {
+	return "JOIN"
+}
+
+type PartCommand struct {
+	chat *Chat
+}
+
+func (c *PartCommand) Execute(ctx context.Context, args []string) (string, error) {
+	m, err := c.chat.member(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: PART #room")
+	}
+
+	room := args[0]
+	if !m.rooms[room] {
+		return "", ErrNotInRoom
+	}
+	c.chat.part(m, room)
+	return fmt.Sprintf("PARTED %s %s", room, m.nick), nil
+}
+
+func (c *PartCommand) Name() string {
+	return "PART"
+}
+
+type MsgCommand struct {
+	chat *Chat
+}
+
+func (c *MsgCommand) Execute(ctx context.Context, args []string) (string, error) {
+	m, err := c.chat.member(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(args) < 2 {
+		return "", fmt.Errorf("usage: MSG <#room|nick> <text>")
+	}
+
+	target, text := args[0], trailingText(ctx, 2, args[1:])
+	if strings.HasPrefix(target, "#") {
+		if !m.rooms[target] {
+			return "", ErrNotInRoom
+		}
+		c.chat.broadcast(target, m, fmt.Sprintf("MSG %s %s %s", target, m.nick, text))
+		return "SENT " + target, nil
+	}
+
+	recipient, exists := c.chat.nicks[target]
+	if !exists {
+		return "", ErrUnknownTarget
+	}
+	if recipient != m {
+		recipient.session.Send(fmt.Sprintf("MSG %s %s %s", recipient.nick, m.nick, text))
+	}
+	return "SENT " + target, nil
+}
+
+func (c *MsgCommand) Name() string {
+	return "MSG"
+}
+
+type WhoCommand struct {
+	chat *Chat
+}
+
+func (c *WhoCommand) Execute(ctx context.Context, args []string) (string, error) {
+	if _, err := c.chat.member(ctx); err != nil {
+		return "", err
+	}
+
+	var members []*chatMember
+	label := "*"
+	switch len(args) {
+	case 0:
+		for _, m := range c.chat.nicks {
+			members = append(members, m)
+		}
+	case 1:
+		label = args[0]
+		if !validRoom(label) {
+			return "", ErrInvalidRoom
+		}
+		for _, m := range c.chat.rooms[label] {
+			members = append(members, m)
+		}
+	default:
+		return "", fmt.Errorf("usage: WHO [#room]")
+	}
+
+	nicks := make([]string, len(members))
+	for i, m := range members {
+		nicks[i] = m.nick
+	}
+	sort.Strings(nicks)
+	return strings.TrimSpace(fmt.Sprintf("WHO %s %s", label, strings.Join(nicks, " "))), nil
+}
+
+func (c *WhoCommand) Name() string {
+	return "WHO"
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"NSSaDS/lab3/internal/domain"
+	"context"
+	"testing"
+)
+
+type chatClient struct {
+	session  *domain.Session
+	received []string
+}
+
+func newChatClient(id string) *chatClient {
+	c := &chatClient{}
+	c.session = &domain.Session{ClientID: id, Send: func(line string) {
+		c.received = append(c.received, line)
+	}}
+	return c
+}
+
+func (c *chatClient) run(t *testing.T, handler *CommandHandler, line string) string {
+	t.Helper()
+	response, err := handler.HandleCommand(domain.WithSession(context.Background(), c.session), line, nil)
+	if err != nil {
+		t.Fatalf("%s: %q failed: %v", c.session.ClientID, line, err)
+	}
+	return response
+}
+
+func TestChatDefaultNickAvoidsTakenNick(t *testing.T) {
+	handler := NewCommandHandler()
+	alice := newChatClient("client_1")
+	bob := newChatClient("client_2")
+	carol := newChatClient("client_3")
+
+	if got := alice.run(t, handler, "NICK client_2"); got != "NICK client_2" {
+		t.Fatalf("NICK answered %q", got)
+	}
+	if got := bob.run(t, handler, "NICK"); got != "NICK client_2-2" {
+		t.Fatalf("bob's default nick: got %q, want %q", got, "NICK client_2-2")
+	}
+	if got := carol.run(t, handler, "WHO"); got != "WHO * client_2 client_2-2 client_3" {
+		t.Fatalf("WHO: got %q", got)
+	}
+
+	bob.session.Close()
+	if got := carol.run(t, handler, "WHO"); got != "WHO * client_2 client_3" {
+		t.Fatalf("WHO after bob left: got %q", got)
+	}
+	carol.run(t, handler, "MSG client_2 hi")
+	if len(alice.received) != 1 || alice.received[0] != "MSG client_2 client_3 hi" {
+		t.Fatalf("alice received %q after bob left", alice.received)
+	}
+}
+
+func TestChatLeaveKeepsOtherMembersNick(t *testing.T) {
+	handler := NewCommandHandler()
+	alice := newChatClient("client_1")
+	bob := newChatClient("client_2")
+
+	bob.run(t, handler, "NICK bob")
+	alice.run(t, handler, "NICK bob-away")
+	bob.run(t, handler, "NICK client_9")
+	bob.session.Close()
+
+	if got := alice.run(t, handler, "WHO"); got != "WHO * bob-away" {
+		t.Fatalf("WHO: got %q", got)
+	}
+}
+
+func TestChatMsgKeepsSpacing(t *testing.T) {
+	handler := NewCommandHandler()
+	alice := newChatClient("client_1")
+	bob := newChatClient("client_2")
+
+	alice.run(t, handler, "NICK alice")
+	bob.run(t, handler, "NICK bob")
+	alice.run(t, handler, "JOIN #go")
+	bob.run(t, handler, "JOIN #go")
+
+	alice.run(t, handler, "MSG   bob \t two  spaces\tand a tab ")
+	alice.run(t, handler, "msg #go    x   =   1")
+
+	want := []string{
+		"JOINED #go bob",
+		"MSG bob alice two  spaces\tand a tab",
+		"MSG #go alice x   =   1",
+	}
+	if len(bob.received) != 2 || bob.received[0] != want[1] || bob.received[1] != want[2] {
+		t.Fatalf("bob received %q, want %q", bob.received, want[1:])
+	}
+	if len(alice.received) != 1 || alice.received[0] != want[0] {
+		t.Fatalf("alice received %q, want %q", alice.received, want[:1])
+	}
+}
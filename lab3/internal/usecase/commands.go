@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode"
 )
 
 type EchoCommand struct{}
@@ -28,6 +29,72 @@ func (c *TimeCommand) Name() string {
 	return "TIME"
 }
 
+const (
+	MaxDelay         = time.Minute
+	MaxPendingDelays = 32
+)
+
+var ErrTooManyDelays = fmt.Errorf("too many pending DELAY messages (at most %d)", MaxPendingDelays)
+
+// DelayCommand keeps the timers each client has pending so that a client can
+// not fill the timer heap and its timers are cancelled when it disconnects.
+type DelayCommand struct {
+	pending map[string]map[domain.TimerID]bool
+}
+
+func (c *DelayCommand) Execute(ctx context.Context, args []string) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf("usage: DELAY <duration> <text>")
+	}
+
+	delay, err := time.ParseDuration(args[0])
+	if err != nil || delay < 0 || delay > MaxDelay {
+		return "", fmt.Errorf("invalid delay %q (0 to %v)", args[0], MaxDelay)
+	}
+
+	session, ok := domain.SessionFromContext(ctx)
+	if !ok || session.Scheduler == nil {
+		return "", fmt.Errorf("DELAY is not available on this connection")
+	}
+
+	timers := c.timers(session)
+	if len(timers) >= MaxPendingDelays {
+		return "", ErrTooManyDelays
+	}
+
+	text := strings.Join(args[1:], " ")
+	var id domain.TimerID
+	id = session.Scheduler.After(delay, func() {
+		delete(timers, id)
+		session.Send(text)
+	})
+	timers[id] = true
+	return fmt.Sprintf("Scheduled in %v", delay), nil
+}
+
+func (c *DelayCommand) timers(session *domain.Session) map[domain.TimerID]bool {
+	if c.pending == nil {
+		c.pending = make(map[string]map[domain.TimerID]bool)
+	}
+	if timers, exists := c.pending[session.ClientID]; exists {
+		return timers
+	}
+
+	timers := make(map[domain.TimerID]bool)
+	c.pending[session.ClientID] = timers
+	session.OnClose(func() {
+		for id := range timers {
+			session.Scheduler.Cancel(id)
+		}
+		delete(c.pending, session.ClientID)
+	})
+	return timers
+}
+
+func (c *DelayCommand) Name() string {
+	return "DELAY"
+}
+
 type CloseCommand struct{}
 
 func (c *CloseCommand) Execute(ctx context.Context, args []string) (string, error) {
@@ -64,6 +131,17 @@ func (c *HelpCommand) Execute(ctx context.Context, args []string) (string, error
 	help := `Available commands:
   ECHO <text>     - Echo the provided text
   TIME            - Get current server time
+  DELAY <d> <text> - Send <text> back after duration <d> (e.g. 1.5s, 32 pending at most)
+  UPLOAD <file> <size> - Upload a file, send <size> raw bytes after READY_TO_RECEIVE
+  DOWNLOAD <file>      - Download a file, raw bytes follow FILE_INFO <file> <size>
+  STATS           - Show measured RTT, chunk size and traffic for this connection
+  STATS SERVER    - Show accepted and rejected connection counters
+  RTT PING|OFF    - Receive PING <token> lines to answer with PONG <token> for RTT
+  NICK [name]     - Show or change your chat nickname
+  JOIN #room      - Join a chat room
+  PART #room      - Leave a chat room
+  MSG <#room|nick> <text> - Send a message to a room or a nickname
+  WHO [#room]     - List nicknames online or in a room
   CLOSE/EXIT/QUIT - Close connection
   HELP            - Show this help message`
 	return help, nil
@@ -84,11 +162,19 @@ func NewCommandHandler() *CommandHandler {
 
 	handler.RegisterCommand(&EchoCommand{})
 	handler.RegisterCommand(&TimeCommand{})
+	handler.RegisterCommand(&DelayCommand{})
 	handler.RegisterCommand(&CloseCommand{})
 	handler.RegisterCommand(&QuitCommand{})
 	handler.RegisterCommand(&ExitCommand{})
 	handler.RegisterCommand(&HelpCommand{})
 
+	chat := NewChat()
+	handler.RegisterCommand(&NickCommand{chat: chat})
+	handler.RegisterCommand(&JoinCommand{chat: chat})
+	handler.RegisterCommand(&PartCommand{chat: chat})
+	handler.RegisterCommand(&MsgCommand{chat: chat})
+	handler.RegisterCommand(&WhoCommand{chat: chat})
+
 	return handler
 }
 
@@ -113,5 +199,27 @@ func (h *CommandHandler) HandleCommand(ctx context.Context, cmd string, args []s
 		return "", fmt.Errorf("unknown command: %s. Type HELP for available commands", commandName)
 	}
 
-	return command.Execute(ctx, commandArgs)
+	return command.Execute(context.WithValue(ctx, commandLineKey{}, cmd), commandArgs)
+}
+
+type commandLineKey struct{}
+
+// trailingText returns the command line after its first skip fields with the
+// spacing preserved. Without the line in ctx it falls back to joining rest.
+func trailingText(ctx context.Context, skip int, rest []string) string {
+	line, ok := ctx.Value(commandLineKey{}).(string)
+	if !ok {
+		return strings.Join(rest, " ")
+	}
+
+	line = strings.TrimSpace(line)
+	for i := 0; i < skip; i++ {
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		end := strings.IndexFunc(line, unicode.IsSpace)
+		if end < 0 {
+			return ""
+		}
+		line = line[end:]
+	}
+	return strings.TrimLeftFunc(line, unicode.IsSpace)
 }
@@ -0,0 +1,12 @@
+package main
+
+import "NSSaDS/pkg/admin"
+
+func main() {
+	admin.RunClient("lab4-admin", []string{
+		"list-clients          - ID, address, fd, idle time, packet size and bytes in/out",
+		"kick-client <id>      - Disconnect a client",
+		"set-max-clients <n>   - Change the client limit",
+		"drain                 - Stop listening and shut down when in-flight requests finish",
+	})
+}
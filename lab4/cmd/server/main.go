@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -14,12 +15,17 @@ import (
 	"NSSaDS/lab4/internal/infrastructure/network"
 	"NSSaDS/lab4/internal/usecase"
 	"NSSaDS/lab4/pkg/config"
+	"NSSaDS/pkg/admin"
 )
 
 func main() {
 	var (
-		host       = flag.String("host", "localhost", "Server host")
-		configFile = flag.String("config", "", "Config file path (optional)")
+		host        = flag.String("host", "localhost", "Server host")
+		configFile  = flag.String("config", "", "Config file path (optional)")
+		maxClients  = flag.Int("max-clients", 0, "Maximum peers tracked at once, others get \"server busy\" (0 = unlimited)")
+		kickBan     = flag.Duration("kick-ban", time.Minute, "How long a kicked peer is ignored")
+		adminSocket = flag.String("admin-socket", "", "Unix socket for lab4-admin (disabled when empty)")
+		adminMode   = flag.String("admin-mode", "0600", "Permissions of the admin socket; they decide who may use it")
 	)
 	flag.Parse()
 
@@ -29,6 +35,8 @@ func main() {
 	}
 
 	cfg.Server.Host = *host
+	cfg.Server.MaxClients = *maxClients
+	cfg.Server.KickBanTime = *kickBan
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -68,6 +76,20 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 
+	if *adminSocket != "" {
+		mode, err := strconv.ParseUint(*adminMode, 8, 32)
+		if err != nil {
+			log.Fatalf("Invalid admin socket mode %q: %v", *adminMode, err)
+		}
+		adminServer := admin.NewServer(*adminSocket, os.FileMode(mode))
+		admin.RegisterControl(adminServer, server)
+		if err := adminServer.Start(); err != nil {
+			log.Fatalf("Failed to start admin socket: %v", err)
+		}
+		defer adminServer.Stop()
+		log.Printf("Admin socket listening on %s (mode %s)", *adminSocket, *adminMode)
+	}
+
 	fmt.Printf("UDP Multiservice Server started on %s\n", cfg.Server.Host)
 	fmt.Println("Services:")
 	for serviceType, serviceConfig := range cfg.Services {
@@ -90,8 +112,12 @@ func main() {
 	fmt.Println("  SERVICE <name> - Show specific service stats")
 	fmt.Println("  HELP - Show help")
 
-	<-sigChan
-	fmt.Println("\nShutting down server...")
+	select {
+	case <-sigChan:
+		fmt.Println("\nShutting down server...")
+	case <-server.Done():
+		fmt.Println("Server drained, shutting down...")
+	}
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
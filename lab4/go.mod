@@ -2,4 +2,13 @@ module NSSaDS/lab4
 
 go 1.26
 
+require NSSaDS v0.0.0
+
 require github.com/google/uuid v1.6.0
+
+require (
+	golang.org/x/net v0.50.0
+	golang.org/x/sys v0.41.0 // indirect
+)
+
+replace NSSaDS => ../lab1
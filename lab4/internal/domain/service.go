@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"NSSaDS/pkg/admin"
 	"context"
 	"errors"
 	"net"
@@ -68,8 +69,14 @@ type UDPServer interface {
 	Stop() error
 	RegisterService(service Service) error
 	GetStats() map[ServiceType]*ServiceStats
+	Done() <-chan struct{}
+	ServerControl
 }
 
+type ClientInfo = admin.ClientInfo
+
+type ServerControl = admin.ServerControl
+
 type ServiceStats struct {
 	RequestsReceived  int64
 	RequestsProcessed int64
@@ -121,4 +128,7 @@ var (
 	ErrServiceDisabled = errors.New("service is disabled")
 	ErrServiceExists   = errors.New("service already exists")
 	ErrPortInUse       = errors.New("port already in use")
+	ErrServerBusy      = errors.New("server busy")
+	ErrKicked          = errors.New("disconnected by administrator")
+	ErrUnknownClient   = errors.New("no such client")
 )
@@ -0,0 +1,174 @@
+package network
+
+import (
+	"NSSaDS/lab4/internal/domain"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"time"
+)
+
+type peer struct {
+	id       string
+	addr     *net.UDPAddr
+	conn     *net.UDPConn
+	service  domain.ServiceType
+	lastSeen time.Time
+	requests int64
+	bytesIn  int64
+	bytesOut int64
+}
+
+func listenerFD(conn *net.UDPConn) int {
+	fd := -1
+	if raw, err := conn.SyscallConn(); err == nil {
+		raw.Control(func(sysfd uintptr) {
+			fd = int(sysfd)
+		})
+	}
+	return fd
+}
+
+func (s *UDPServer) admit(service domain.Service, conn *net.UDPConn, d datagram) error {
+	key := d.addr.String()
+	now := time.Now()
+
+	s.peersMutex.Lock()
+	defer s.peersMutex.Unlock()
+
+	if until, banned := s.banned[key]; banned {
+		if now.Before(until) {
+			return domain.ErrKicked
+		}
+		delete(s.banned, key)
+	}
+
+	p, exists := s.peers[key]
+	if !exists {
+		if s.maxClients > 0 && len(s.peers) >= s.maxClients {
+			s.prunePeers(now)
+		}
+		if s.maxClients > 0 && len(s.peers) >= s.maxClients {
+			return domain.ErrServerBusy
+		}
+
+		s.nextPeerID++
+		p = &peer{
+			id:   fmt.Sprintf("peer_%d", s.nextPeerID),
+			addr: d.addr,
+		}
+		s.peers[key] = p
+	}
+
+	p.conn = conn
+	p.service = service.Name()
+	p.lastSeen = now
+	p.requests++
+	p.bytesIn += int64(d.n)
+	return nil
+}
+
+func (s *UDPServer) trackSent(clientAddr *net.UDPAddr, n int) {
+	s.peersMutex.Lock()
+	defer s.peersMutex.Unlock()
+
+	if p, exists := s.peers[clientAddr.String()]; exists {
+		p.bytesOut += int64(n)
+	}
+}
+
+func (s *UDPServer) prunePeers(now time.Time) {
+	for key, p := range s.peers {
+		if now.Sub(p.lastSeen) > s.config.Server.IdleTimeout {
+			delete(s.peers, key)
+		}
+	}
+}
+
+func (s *UDPServer) ListClients() ([]domain.ClientInfo, error) {
+	s.peersMutex.Lock()
+	defer s.peersMutex.Unlock()
+
+	now := time.Now()
+	s.prunePeers(now)
+
+	clients := make([]domain.ClientInfo, 0, len(s.peers))
+	for _, p := range s.peers {
+		clients = append(clients, domain.ClientInfo{
+			ID:        p.id,
+			Addr:      fmt.Sprintf("%s (%s)", p.addr, p.service),
+			FD:        listenerFD(p.conn),
+			Idle:      now.Sub(p.lastSeen),
+			ChunkSize: s.config.Server.MaxPacketSize,
+			BytesIn:   p.bytesIn,
+			BytesOut:  p.bytesOut,
+		})
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+	return clients, nil
+}
+
+func (s *UDPServer) KickClient(id string) error {
+	s.peersMutex.Lock()
+	var kicked *peer
+	for key, p := range s.peers {
+		if p.id == id {
+			kicked = p
+			delete(s.peers, key)
+			s.banned[key] = time.Now().Add(s.config.Server.KickBanTime)
+			break
+		}
+	}
+	s.peersMutex.Unlock()
+
+	if kicked == nil {
+		return fmt.Errorf("%w: %s", domain.ErrUnknownClient, id)
+	}
+
+	s.sendError(kicked.conn, kicked.addr, "", kicked.service, domain.ErrKicked)
+	log.Printf("Peer %s (%s) kicked by administrator, banned for %v", id, kicked.addr, s.config.Server.KickBanTime)
+	return nil
+}
+
+func (s *UDPServer) SetMaxClients(n int) (int, error) {
+	s.peersMutex.Lock()
+	defer s.peersMutex.Unlock()
+
+	old := s.maxClients
+	s.maxClients = n
+	log.Printf("Max clients changed from %d to %d", old, n)
+	return old, nil
+}
+
+func (s *UDPServer) Drain() (int, error) {
+	s.peersMutex.Lock()
+	if s.draining {
+		s.peersMutex.Unlock()
+		return 0, fmt.Errorf("server is already draining")
+	}
+	s.draining = true
+	s.prunePeers(time.Now())
+	remaining := len(s.peers)
+	s.peersMutex.Unlock()
+
+	log.Printf("Draining: closing listeners and waiting for in-flight requests")
+	s.closeListeners()
+
+	go func() {
+		s.wg.Wait()
+		log.Println("Server drained")
+		close(s.done)
+	}()
+	return remaining, nil
+}
+
+func (s *UDPServer) isDraining() bool {
+	s.peersMutex.Lock()
+	defer s.peersMutex.Unlock()
+	return s.draining
+}
+
+func (s *UDPServer) Done() <-chan struct{} {
+	return s.done
+}
@@ -0,0 +1,67 @@
+package network
+
+import (
+	"net"
+	"sync"
+)
+
+type datagram struct {
+	buf  []byte
+	n    int
+	addr *net.UDPAddr
+}
+
+type datagramReader interface {
+	ReadBatch(batch []datagram) (int, error)
+	BatchSize() int
+}
+
+func newDatagramReader(conn *net.UDPConn, batchSize int) datagramReader {
+	if batchSize <= 1 {
+		return &packetReader{conn: conn}
+	}
+	return newBatchReader(conn, batchSize)
+}
+
+type packetReader struct {
+	conn *net.UDPConn
+}
+
+func (r *packetReader) BatchSize() int {
+	return 1
+}
+
+func (r *packetReader) ReadBatch(batch []datagram) (int, error) {
+	n, addr, err := r.conn.ReadFromUDP(batch[0].buf)
+	if err != nil {
+		return 0, err
+	}
+
+	batch[0].n = n
+	batch[0].addr = addr
+	return 1, nil
+}
+
+type bufferPool struct {
+	pool sync.Pool
+}
+
+func newBufferPool(size int) *bufferPool {
+	return &bufferPool{
+		pool: sync.Pool{
+			New: func() any {
+				buf := make([]byte, size)
+				return &buf
+			},
+		},
+	}
+}
+
+func (p *bufferPool) Get() []byte {
+	return *p.pool.Get().(*[]byte)
+}
+
+func (p *bufferPool) Put(buf []byte) {
+	buf = buf[:cap(buf)]
+	p.pool.Put(&buf)
+}
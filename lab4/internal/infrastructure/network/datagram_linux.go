@@ -0,0 +1,45 @@
+package network
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv4"
+)
+
+type batchReader struct {
+	conn *ipv4.PacketConn
+	msgs []ipv4.Message
+}
+
+func newBatchReader(conn *net.UDPConn, batchSize int) datagramReader {
+	r := &batchReader{
+		conn: ipv4.NewPacketConn(conn),
+		msgs: make([]ipv4.Message, batchSize),
+	}
+	for i := range r.msgs {
+		r.msgs[i].Buffers = make([][]byte, 1)
+	}
+	return r
+}
+
+func (r *batchReader) BatchSize() int {
+	return len(r.msgs)
+}
+
+func (r *batchReader) ReadBatch(batch []datagram) (int, error) {
+	count := min(len(batch), len(r.msgs))
+	for i := 0; i < count; i++ {
+		r.msgs[i].Buffers[0] = batch[i].buf
+	}
+
+	n, err := r.conn.ReadBatch(r.msgs[:count], 0)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < n; i++ {
+		batch[i].n = r.msgs[i].N
+		batch[i].addr, _ = r.msgs[i].Addr.(*net.UDPAddr)
+	}
+	return n, nil
+}
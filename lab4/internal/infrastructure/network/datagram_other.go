@@ -0,0 +1,9 @@
+//go:build !linux
+
+package network
+
+import "net"
+
+func newBatchReader(conn *net.UDPConn, batchSize int) datagramReader {
+	return &packetReader{conn: conn}
+}
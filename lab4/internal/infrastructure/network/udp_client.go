@@ -5,6 +5,7 @@ import (
 	"NSSaDS/lab4/pkg/config"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
@@ -191,7 +192,7 @@ func (c *UDPClient) parseResponse(data []byte) (*domain.Response, error) {
 	}
 
 	if resp.Error != "" {
-		response.Error = fmt.Errorf(resp.Error)
+		response.Error = errors.New(resp.Error)
 	}
 
 	return response, nil
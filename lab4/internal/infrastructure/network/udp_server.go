@@ -20,11 +20,21 @@ type UDPServer struct {
 	registry   domain.ServiceRegistry
 	threadPool domain.ThreadPool
 	listeners  map[int]*net.UDPConn
+	buffers    *bufferPool
 	stats      map[domain.ServiceType]*domain.ServiceStats
 	statsMutex sync.RWMutex
 	ctx        context.Context
 	cancel     context.CancelFunc
 	wg         sync.WaitGroup
+	closeOnce  sync.Once
+	done       chan struct{}
+
+	peers      map[string]*peer
+	banned     map[string]time.Time
+	peersMutex sync.Mutex
+	nextPeerID int
+	maxClients int
+	draining   bool
 }
 
 func NewUDPServer(cfg *config.Config, registry domain.ServiceRegistry, threadPool domain.ThreadPool) domain.UDPServer {
@@ -35,9 +45,14 @@ func NewUDPServer(cfg *config.Config, registry domain.ServiceRegistry, threadPoo
 		registry:   registry,
 		threadPool: threadPool,
 		listeners:  make(map[int]*net.UDPConn),
+		buffers:    newBufferPool(cfg.Server.MaxPacketSize),
 		stats:      make(map[domain.ServiceType]*domain.ServiceStats),
 		ctx:        ctx,
 		cancel:     cancel,
+		done:       make(chan struct{}),
+		peers:      make(map[string]*peer),
+		banned:     make(map[string]time.Time),
+		maxClients: cfg.Server.MaxClients,
 	}
 }
 
@@ -72,12 +87,7 @@ func (s *UDPServer) Start(ctx context.Context) error {
 
 func (s *UDPServer) Stop() error {
 	s.cancel()
-
-	for port, listener := range s.listeners {
-		if err := listener.Close(); err != nil {
-			log.Printf("Error closing listener on port %d: %v", port, err)
-		}
-	}
+	s.closeListeners()
 
 	if err := s.threadPool.Stop(); err != nil {
 		log.Printf("Error stopping thread pool: %v", err)
@@ -88,6 +98,16 @@ func (s *UDPServer) Stop() error {
 	return nil
 }
 
+func (s *UDPServer) closeListeners() {
+	s.closeOnce.Do(func() {
+		for port, listener := range s.listeners {
+			if err := listener.Close(); err != nil {
+				log.Printf("Error closing listener on port %d: %v", port, err)
+			}
+		}
+	})
+}
+
 func (s *UDPServer) RegisterService(service domain.Service) error {
 	return s.registry.RegisterService(service)
 }
@@ -139,41 +159,70 @@ func (s *UDPServer) startServiceListener(service domain.Service, serviceConfig *
 func (s *UDPServer) handleServiceConnections(service domain.Service, conn *net.UDPConn, config *config.ServiceConfig) {
 	defer s.wg.Done()
 
-	buffer := make([]byte, s.config.Server.MaxPacketSize)
+	reader := newDatagramReader(conn, s.config.Server.BatchSize)
+	batch := make([]datagram, reader.BatchSize())
+	defer func() {
+		for i := range batch {
+			if batch[i].buf != nil {
+				s.buffers.Put(batch[i].buf)
+			}
+		}
+	}()
 
 	for {
 		select {
 		case <-s.ctx.Done():
 			return
 		default:
+			for i := range batch {
+				if batch[i].buf == nil {
+					batch[i].buf = s.buffers.Get()
+				}
+			}
+
 			conn.SetReadDeadline(time.Now().Add(s.config.Server.IdleTimeout))
-			n, clientAddr, err := conn.ReadFromUDP(buffer)
+			n, err := reader.ReadBatch(batch)
 			if err != nil {
 				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 					continue
 				}
-				if s.ctx.Err() != nil {
+				if s.ctx.Err() != nil || s.isDraining() {
 					return
 				}
 				log.Printf("Error reading from UDP: %v", err)
 				continue
 			}
 
-			s.wg.Add(1)
-			err = s.threadPool.Submit(func() {
-				defer s.wg.Done()
-				s.handleRequest(service, conn, clientAddr, buffer[:n], config)
-			})
-
-			if err != nil {
-				s.wg.Done()
-				log.Printf("Failed to submit task to thread pool: %v", err)
-				atomic.AddInt64(&s.stats[service.Name()].Errors, 1)
+			for i := 0; i < n; i++ {
+				if err := s.admit(service, conn, batch[i]); err != nil {
+					if err == domain.ErrServerBusy {
+						s.sendError(conn, batch[i].addr, "", service.Name(), err)
+					}
+					continue
+				}
+				s.dispatch(service, conn, batch[i], config)
+				batch[i].buf = nil
 			}
 		}
 	}
 }
 
+func (s *UDPServer) dispatch(service domain.Service, conn *net.UDPConn, d datagram, config *config.ServiceConfig) {
+	s.wg.Add(1)
+	err := s.threadPool.Submit(func() {
+		defer s.wg.Done()
+		defer s.buffers.Put(d.buf)
+		s.handleRequest(service, conn, d.addr, d.buf[:d.n], config)
+	})
+
+	if err != nil {
+		s.wg.Done()
+		s.buffers.Put(d.buf)
+		log.Printf("Failed to submit task to thread pool: %v", err)
+		atomic.AddInt64(&s.stats[service.Name()].Errors, 1)
+	}
+}
+
 func (s *UDPServer) handleRequest(service domain.Service, conn *net.UDPConn, clientAddr *net.UDPAddr, data []byte, config *config.ServiceConfig) {
 	startTime := time.Now()
 
@@ -275,10 +324,12 @@ func (s *UDPServer) sendResponse(conn *net.UDPConn, clientAddr *net.UDPAddr, res
 		return
 	}
 
-	_, err = conn.WriteToUDP(data, clientAddr)
+	n, err := conn.WriteToUDP(data, clientAddr)
 	if err != nil {
 		log.Printf("Error sending response: %v", err)
+		return
 	}
+	s.trackSent(clientAddr, n)
 }
 
 func (s *UDPServer) sendError(conn *net.UDPConn, clientAddr *net.UDPAddr, requestID string, serviceType domain.ServiceType, err error) {
@@ -290,7 +341,9 @@ func (s *UDPServer) sendError(conn *net.UDPConn, clientAddr *net.UDPAddr, reques
 	}
 
 	data, _ := json.Marshal(errorResponse)
-	conn.WriteToUDP(data, clientAddr)
+	if n, err := conn.WriteToUDP(data, clientAddr); err == nil {
+		s.trackSent(clientAddr, n)
+	}
 }
 
 func (s *UDPServer) updateStats(serviceType domain.ServiceType, updateFunc func(*domain.ServiceStats)) {
@@ -31,7 +31,10 @@ type ServerConfig struct {
 	ReadBuffer    int           `json:"read_buffer" yaml:"read_buffer"`
 	WriteBuffer   int           `json:"write_buffer" yaml:"write_buffer"`
 	MaxPacketSize int           `json:"max_packet_size" yaml:"max_packet_size"`
+	BatchSize     int           `json:"batch_size" yaml:"batch_size"`
 	IdleTimeout   time.Duration `json:"idle_timeout" yaml:"idle_timeout"`
+	MaxClients    int           `json:"max_clients" yaml:"max_clients"`
+	KickBanTime   time.Duration `json:"kick_ban_time" yaml:"kick_ban_time"`
 }
 
 func NewConfig() *Config {
@@ -80,7 +83,10 @@ func NewConfig() *Config {
 			ReadBuffer:    4096,
 			WriteBuffer:   4096,
 			MaxPacketSize: 64 * 1024,
+			BatchSize:     32,
 			IdleTimeout:   60 * time.Second,
+			MaxClients:    0,
+			KickBanTime:   time.Minute,
 		},
 	}
 }